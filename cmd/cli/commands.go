@@ -0,0 +1,883 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/berkaycubuk/subtrack/internal/cli"
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/services"
+)
+
+var (
+	cycles   = []string{"monthly", "yearly"}
+	sortKeys = []string{string(database.SortNextPayment), string(database.SortPrice), string(database.SortName)}
+)
+
+func newRootCommand(a *app) *cli.Command {
+	root := &cli.Command{
+		Name: "subtrack",
+		Description: "SubTrack CLI - Subscription Tracker\n\n" +
+			"Commands use the local database unless a profile is selected with 'subtrack profile use'\n" +
+			"or SUBTRACK_PROFILE, in which case subscription, import, export, backup and restore\n" +
+			"commands run against that server.",
+	}
+	root.Subcommands = []*cli.Command{
+		addCommand(a),
+		listCommand(a),
+		updateCommand(a),
+		statusCommand(a),
+		categoryCommand(a),
+		trialCommand(a),
+		deleteCommand(a),
+		tuiCommand(a),
+		checkCommand(a),
+		digestCommand(a),
+		notifyCommand(a),
+		templateCommand(a),
+		importCommand(a),
+		exportCommand(a),
+		backupCommand(a),
+		restoreCommand(a),
+		migrateCommand(a),
+		snapshotCommand(a),
+		healthCommand(a),
+		auditCommand(a),
+		twoFactorCommand(a),
+		hashPasswordCommand(),
+		profileCommand(a),
+		completionCommand(root),
+		helpCommand(root),
+	}
+	return root
+}
+
+func addCommand(a *app) *cli.Command {
+	return &cli.Command{
+		Name:    "add",
+		Args:    "[<name> <price> <currency> <cycle> <payment_date> [category]]",
+		Summary: "Add a subscription",
+		Description: "Add a subscription. Fields can be given as flags or, in order, as arguments.\n" +
+			"Payment dates use DD-MM-YYYY.",
+		Examples: []string{
+			`subtrack add --name Netflix --price 15.99 --currency USD --cycle monthly --payment-date 15-02-2025`,
+			`subtrack add "Netflix" 15.99 USD monthly 15-02-2025 Streaming`,
+		},
+		Setup: func(fs *flag.FlagSet) func([]string) error {
+			name := fs.String("name", "", "subscription name")
+			price := fs.String("price", "", "price per cycle")
+			currency := fs.String("currency", "", "currency code, e.g. USD")
+			cycle := cli.Choice(fs, "cycle", "", cycles, "billing cycle")
+			paymentDate := fs.String("payment-date", "", "next payment date (DD-MM-YYYY)")
+			category := fs.String("category", "", "category, e.g. Streaming")
+			return func(args []string) error {
+				fields := []*string{name, price, currency, cycle, paymentDate, category}
+				if len(args) > len(fields) {
+					return cli.ErrUsage
+				}
+				for i, arg := range args {
+					if *fields[i] == "" {
+						*fields[i] = arg
+					}
+				}
+				for _, f := range fields[:5] {
+					if *f == "" {
+						return cli.ErrUsage
+					}
+				}
+				return a.with("", func(c *cli.CLI) error {
+					return c.Add(*name, *price, *currency, *cycle, *paymentDate, *category)
+				})
+			}
+		},
+	}
+}
+
+func listCommand(a *app) *cli.Command {
+	return &cli.Command{
+		Name:    "list",
+		Summary: "List, search and filter subscriptions",
+		Description: "List subscriptions, sorted by next payment date unless --sort says otherwise.\n" +
+			"Filters that take a list accept comma-separated values.",
+		Examples: []string{
+			"subtrack list",
+			"subtrack list --status active --category streaming --sort price --desc --limit 10",
+			"subtrack list --search net --output json",
+		},
+		Setup: func(fs *flag.FlagSet) func([]string) error {
+			search := fs.String("search", "", "show subscriptions whose name contains this text")
+			currency := fs.String("currency", "", "comma-separated currencies to include")
+			cycle := fs.String("cycle", "", "comma-separated cycles to include: monthly, yearly")
+			status := fs.String("status", "", "comma-separated statuses to include: active, paused, cancelled")
+			category := fs.String("category", "", "comma-separated categories to include")
+			minPrice := fs.String("min-price", "", "lowest price to include")
+			maxPrice := fs.String("max-price", "", "highest price to include")
+			sortKey := cli.Choice(fs, "sort", "", sortKeys, "sort key (default next_payment)")
+			desc := fs.Bool("desc", false, "sort in descending order")
+			limit := fs.Int("limit", 0, "show at most this many subscriptions per page")
+			cursor := fs.String("cursor", "", "continue from the cursor printed by the previous page")
+			output := cli.OutputFlag(fs)
+			return func(args []string) error {
+				if len(args) > 0 {
+					return cli.ErrUsage
+				}
+
+				// Go through the dashboard's query string parsing so both
+				// accept the same filters.
+				values := url.Values{}
+				for key, value := range map[string]string{
+					"q": *search, "currency": *currency, "cycle": *cycle, "status": *status,
+					"category": *category, "min_price": *minPrice, "max_price": *maxPrice,
+					"sort": *sortKey, "cursor": *cursor,
+				} {
+					if value != "" {
+						values.Set(key, value)
+					}
+				}
+				if *desc {
+					values.Set("order", "desc")
+				}
+				if *limit != 0 {
+					values.Set("limit", strconv.Itoa(*limit))
+				}
+				q, err := services.ParseListQuery(values)
+				if err != nil {
+					return err
+				}
+
+				return a.with(*output, func(c *cli.CLI) error { return c.List(q) })
+			}
+		},
+	}
+}
+
+func updateCommand(a *app) *cli.Command {
+	return &cli.Command{
+		Name:        "update",
+		Args:        "<id>",
+		Summary:     "Change a subscription's fields",
+		Description: "Change the given fields of a subscription and leave the others as they are.",
+		Examples: []string{
+			"subtrack update 1 --currency EUR",
+			"subtrack update 1 --price 19.99 --payment-date 15-03-2025",
+			`subtrack update 1 --category ""`,
+		},
+		Setup: func(fs *flag.FlagSet) func([]string) error {
+			var changes cli.SubscriptionChanges
+			fs.StringVar(&changes.Name, "name", "", "new name")
+			fs.StringVar(&changes.Price, "price", "", "new price")
+			fs.StringVar(&changes.Currency, "currency", "", "new currency code")
+			cycle := cli.Choice(fs, "cycle", "", cycles, "new billing cycle")
+			fs.StringVar(&changes.PaymentDate, "payment-date", "", "new next payment date (DD-MM-YYYY)")
+			category := fs.String("category", "", "new category; empty clears it")
+			status := cli.Choice(fs, "status", "", database.Statuses, "new status")
+			return func(args []string) error {
+				if len(args) != 1 {
+					return cli.ErrUsage
+				}
+				changes.Cycle = *cycle
+				changes.Status = *status
+				fs.Visit(func(f *flag.Flag) {
+					if f.Name == "category" {
+						changes.Category = category
+					}
+				})
+				return a.with("", func(c *cli.CLI) error { return c.Update(args[0], changes) })
+			}
+		},
+	}
+}
+
+func statusCommand(a *app) *cli.Command {
+	return &cli.Command{
+		Name:        "status",
+		Args:        "<id> active|paused|cancelled",
+		Summary:     "Pause, cancel or reactivate a subscription",
+		Description: "Set a subscription's status. Only active subscriptions get payment reminders.",
+		Examples:    []string{"subtrack status 1 paused"},
+		Setup: func(fs *flag.FlagSet) func([]string) error {
+			return func(args []string) error {
+				if len(args) != 2 {
+					return cli.ErrUsage
+				}
+				return a.with("", func(c *cli.CLI) error { return c.SetStatus(args[0], args[1]) })
+			}
+		},
+	}
+}
+
+func categoryCommand(a *app) *cli.Command {
+	return &cli.Command{
+		Name:        "category",
+		Args:        "<id> [category]",
+		Summary:     "Set or clear a subscription's category",
+		Description: "Set a subscription's category, or clear it when no category is given.",
+		Examples:    []string{"subtrack category 1 Streaming", "subtrack category 1"},
+		Setup: func(fs *flag.FlagSet) func([]string) error {
+			return func(args []string) error {
+				if len(args) < 1 || len(args) > 2 {
+					return cli.ErrUsage
+				}
+				var category string
+				if len(args) == 2 {
+					category = args[1]
+				}
+				return a.with("", func(c *cli.CLI) error { return c.SetCategory(args[0], category) })
+			}
+		},
+	}
+}
+
+func trialCommand(a *app) *cli.Command {
+	return &cli.Command{
+		Name:        "trial",
+		Args:        "<id> <DD-MM-YYYY>|off",
+		Summary:     "Set or remove the end of a subscription's free trial",
+		Description: "Set the day a subscription's free trial ends, or remove the trial with off. A trial_ending notification is sent in the days before it ends.",
+		Examples:    []string{"subtrack trial 1 15-03-2025", "subtrack trial 1 off"},
+		Setup: func(fs *flag.FlagSet) func([]string) error {
+			return func(args []string) error {
+				if len(args) != 2 {
+					return cli.ErrUsage
+				}
+				return a.with("", func(c *cli.CLI) error { return c.SetTrial(args[0], args[1]) })
+			}
+		},
+	}
+}
+
+func deleteCommand(a *app) *cli.Command {
+	return &cli.Command{
+		Name:     "delete",
+		Args:     "<id>",
+		Summary:  "Delete a subscription",
+		Examples: []string{"subtrack delete 1"},
+		Setup: func(fs *flag.FlagSet) func([]string) error {
+			return func(args []string) error {
+				if len(args) != 1 {
+					return cli.ErrUsage
+				}
+				return a.with("", func(c *cli.CLI) error { return c.Delete(args[0]) })
+			}
+		},
+	}
+}
+
+func tuiCommand(a *app) *cli.Command {
+	return &cli.Command{
+		Name:    "tui",
+		Summary: "Triage subscriptions in a full-screen terminal interface",
+		Description: "Browse subscriptions sorted by next payment with upcoming totals, and add, edit, delete,\n" +
+			"pause or mark them paid from the keyboard. Press ? inside for the keys.",
+		Setup: func(fs *flag.FlagSet) func([]string) error {
+			return func(args []string) error {
+				if len(args) > 0 {
+					return cli.ErrUsage
+				}
+				return a.with("", func(c *cli.CLI) error { return c.TUI() })
+			}
+		},
+	}
+}
+
+func checkCommand(a *app) *cli.Command {
+	return &cli.Command{
+		Name:        "check",
+		Summary:     "Check upcoming payments and send reminders",
+		Description: "Print the payments due in the reminder window and send their Telegram reminders.",
+		Setup: func(fs *flag.FlagSet) func([]string) error {
+			return func(args []string) error {
+				if len(args) > 0 {
+					return cli.ErrUsage
+				}
+				return a.with("", func(c *cli.CLI) error { return c.Check() })
+			}
+		},
+	}
+}
+
+func digestCommand(a *app) *cli.Command {
+	return &cli.Command{
+		Name:        "digest",
+		Args:        "[daily|weekly]",
+		Summary:     "Print a digest of due payments",
+		Description: "Print a digest of the payments due today (daily) or this week (weekly, the default). With --send it is also sent.",
+		Examples:    []string{"subtrack digest weekly", "subtrack digest --send daily"},
+		Setup: func(fs *flag.FlagSet) func([]string) error {
+			send := fs.Bool("send", false, "send the digest as well as printing it")
+			return func(args []string) error {
+				period := "weekly"
+				switch len(args) {
+				case 0:
+				case 1:
+					period = args[0]
+				default:
+					return cli.ErrUsage
+				}
+				return a.with("", func(c *cli.CLI) error { return c.Digest(period, *send) })
+			}
+		},
+	}
+}
+
+func notifyCommand(a *app) *cli.Command {
+	setPreference := func(name, summary string, enabled bool) *cli.Command {
+		return &cli.Command{
+			Name:        name,
+			Args:        "<channel> <event>",
+			Summary:     summary,
+			Description: "Events: upcoming, trial_ending, price_change, budget_exceeded, digest.",
+			Examples:    []string{"subtrack notify " + name + " telegram price_change"},
+			Setup: func(fs *flag.FlagSet) func([]string) error {
+				return func(args []string) error {
+					if len(args) != 2 {
+						return cli.ErrUsage
+					}
+					return a.with("", func(c *cli.CLI) error { return c.NotifySet(args[0], args[1], enabled) })
+				}
+			},
+		}
+	}
+
+	return &cli.Command{
+		Name:        "notify",
+		Summary:     "Show or change notification preferences",
+		Description: "Show or change which notifications are sent, the quiet hours during which they wait, and the monthly budget.",
+		Default:     "list",
+		Subcommands: []*cli.Command{
+			{
+				Name:    "list",
+				Summary: "Show notification preferences and quiet hours",
+				Setup: func(fs *flag.FlagSet) func([]string) error {
+					output := cli.OutputFlag(fs)
+					return func(args []string) error {
+						if len(args) > 0 {
+							return cli.ErrUsage
+						}
+						return a.with(*output, func(c *cli.CLI) error { return c.NotifyPreferences() })
+					}
+				},
+			},
+			setPreference("enable", "Send an event's notifications on a channel", true),
+			setPreference("disable", "Stop an event's notifications on a channel", false),
+			{
+				Name:        "quiet",
+				Args:        "<HH:MM> <HH:MM> | off",
+				Summary:     "Set or turn off quiet hours",
+				Description: "Hold notifications between two times of day, or turn quiet hours off.",
+				Examples:    []string{"subtrack notify quiet 22:00 08:00", "subtrack notify quiet off"},
+				Setup: func(fs *flag.FlagSet) func([]string) error {
+					return func(args []string) error {
+						switch {
+						case len(args) == 1 && args[0] == "off":
+							return a.with("", func(c *cli.CLI) error { return c.NotifyQuiet("off", "") })
+						case len(args) == 2:
+							return a.with("", func(c *cli.CLI) error { return c.NotifyQuiet(args[0], args[1]) })
+						default:
+							return cli.ErrUsage
+						}
+					}
+				},
+			},
+			{
+				Name:        "budget",
+				Args:        "<amount> <currency> | off",
+				Summary:     "Set or turn off the monthly budget",
+				Description: "Send a budget_exceeded notification when a change takes the monthly cost of active subscriptions in the currency over amount.",
+				Examples:    []string{"subtrack notify budget 100 EUR", "subtrack notify budget off"},
+				Setup: func(fs *flag.FlagSet) func([]string) error {
+					return func(args []string) error {
+						switch {
+						case len(args) == 1 && args[0] == "off":
+							return a.with("", func(c *cli.CLI) error { return c.NotifyBudget("off", "") })
+						case len(args) == 2:
+							return a.with("", func(c *cli.CLI) error { return c.NotifyBudget(args[0], args[1]) })
+						default:
+							return cli.ErrUsage
+						}
+					}
+				},
+			},
+		},
+	}
+}
+
+func templateCommand(a *app) *cli.Command {
+	// channelEvent is a template subcommand taking <channel> <event> and
+	// extra arguments.
+	channelEvent := func(name, args, summary string, examples []string, minExtra, maxExtra int, run func(c *cli.CLI, channel, event string, extra []string) error) *cli.Command {
+		return &cli.Command{
+			Name:        name,
+			Args:        "<channel> <event>" + args,
+			Summary:     summary,
+			Description: summary + ".\nEvents: upcoming, trial_ending, price_change, budget_exceeded, digest.",
+			Examples:    examples,
+			Setup: func(fs *flag.FlagSet) func([]string) error {
+				return func(args []string) error {
+					if len(args) < 2+minExtra || len(args) > 2+maxExtra {
+						return cli.ErrUsage
+					}
+					return a.with("", func(c *cli.CLI) error { return run(c, args[0], args[1], args[2:]) })
+				}
+			},
+		}
+	}
+
+	return &cli.Command{
+		Name:        "template",
+		Summary:     "Manage notification message templates",
+		Description: "Show, customise, reset and preview the templates notification messages are rendered from.",
+		Default:     "list",
+		Subcommands: []*cli.Command{
+			{
+				Name:    "list",
+				Summary: "List templates and whether they are customised",
+				Setup: func(fs *flag.FlagSet) func([]string) error {
+					output := cli.OutputFlag(fs)
+					return func(args []string) error {
+						if len(args) > 0 {
+							return cli.ErrUsage
+						}
+						return a.with(*output, func(c *cli.CLI) error { return c.TemplateList() })
+					}
+				},
+			},
+			channelEvent("show", "", "Print a template", nil, 0, 0,
+				func(c *cli.CLI, channel, event string, _ []string) error { return c.TemplateShow(channel, event) }),
+			channelEvent("reset", "", "Go back to the built-in template", nil, 0, 0,
+				func(c *cli.CLI, channel, event string, _ []string) error { return c.TemplateReset(channel, event) }),
+			channelEvent("set", " <file|->", "Replace a template with a file, or stdin for -",
+				[]string{"subtrack template set telegram upcoming upcoming.tmpl"}, 1, 1,
+				func(c *cli.CLI, channel, event string, extra []string) error {
+					return c.TemplateSet(channel, event, extra[0])
+				}),
+			channelEvent("preview", " [id]", "Render a template with a subscription or sample data",
+				[]string{"subtrack template preview telegram upcoming 1"}, 0, 1,
+				func(c *cli.CLI, channel, event string, extra []string) error {
+					var id string
+					if len(extra) > 0 {
+						id = extra[0]
+					}
+					return c.TemplatePreview(channel, event, id)
+				}),
+		},
+	}
+}
+
+func importCommand(a *app) *cli.Command {
+	return &cli.Command{
+		Name:    "import",
+		Args:    "<file.csv>",
+		Summary: "Import subscriptions from CSV",
+		Description: "Add one subscription per CSV row. Rows matching an existing subscription's name are skipped.\n" +
+			"Columns are found by field name unless --map names them.",
+		Examples: []string{`subtrack import --dry-run --map "name=Service,payment_date=Next Billing" subs.csv`},
+		Setup: func(fs *flag.FlagSet) func([]string) error {
+			dryRun := fs.Bool("dry-run", false, "validate and preview without saving")
+			mapping := fs.String("map", "", "column mapping, e.g. name=Service,payment_date=Next Billing")
+			output := cli.OutputFlag(fs)
+			return func(args []string) error {
+				if len(args) != 1 {
+					return cli.ErrUsage
+				}
+				return a.with(*output, func(c *cli.CLI) error { return c.Import(args[0], *mapping, *dryRun) })
+			}
+		},
+	}
+}
+
+func exportCommand(a *app) *cli.Command {
+	return &cli.Command{
+		Name:    "export",
+		Summary: "Export subscriptions as CSV or iCalendar",
+		Examples: []string{
+			"subtrack export --format csv --file subscriptions.csv",
+			"subtrack export --format ics --file payments.ics",
+		},
+		Setup: func(fs *flag.FlagSet) func([]string) error {
+			format := cli.Choice(fs, "format", "csv", []string{"csv", "ics"}, "export format")
+			file := fs.String("file", "", "write to file instead of stdout")
+			return func(args []string) error {
+				if len(args) > 0 {
+					return cli.ErrUsage
+				}
+				return a.with("", func(c *cli.CLI) error { return c.Export(*format, *file) })
+			}
+		},
+	}
+}
+
+func backupCommand(a *app) *cli.Command {
+	return &cli.Command{
+		Name:     "backup",
+		Summary:  "Write a JSON backup of all data",
+		Examples: []string{"subtrack backup --file backup.json"},
+		Setup: func(fs *flag.FlagSet) func([]string) error {
+			file := fs.String("file", "", "write to file instead of stdout")
+			return func(args []string) error {
+				if len(args) > 0 {
+					return cli.ErrUsage
+				}
+				return a.with("", func(c *cli.CLI) error { return c.Backup(*file) })
+			}
+		},
+	}
+}
+
+func restoreCommand(a *app) *cli.Command {
+	return &cli.Command{
+		Name:        "restore",
+		Args:        "<backup.json>",
+		Summary:     "Restore a JSON backup",
+		Description: "Restore a JSON backup. --on-conflict decides what happens to subscriptions that already exist.",
+		Examples:    []string{"subtrack restore --on-conflict rename backup.json"},
+		Setup: func(fs *flag.FlagSet) func([]string) error {
+			policy := cli.Choice(fs, "on-conflict", "skip", []string{"skip", "overwrite", "rename"}, "what to do with existing subscriptions")
+			return func(args []string) error {
+				if len(args) != 1 {
+					return cli.ErrUsage
+				}
+				return a.with("", func(c *cli.CLI) error { return c.Restore(args[0], *policy) })
+			}
+		},
+	}
+}
+
+func migrateCommand(a *app) *cli.Command {
+	return &cli.Command{
+		Name:        "migrate",
+		Summary:     "Show, apply or revert database migrations",
+		Description: "Show, apply or revert database schema migrations. Other commands apply pending migrations automatically.",
+		Default:     "status",
+		Subcommands: []*cli.Command{
+			{
+				Name:    "status",
+				Summary: "List migrations and when they were applied",
+				Setup: func(fs *flag.FlagSet) func([]string) error {
+					output := cli.OutputFlag(fs)
+					return func(args []string) error {
+						if len(args) > 0 {
+							return cli.ErrUsage
+						}
+						return a.migrator(*output, func(c *cli.CLI) error { return c.MigrateStatus() })
+					}
+				},
+			},
+			{
+				Name:    "up",
+				Summary: "Apply pending migrations",
+				Setup: func(fs *flag.FlagSet) func([]string) error {
+					return func(args []string) error {
+						if len(args) > 0 {
+							return cli.ErrUsage
+						}
+						return a.migrator("", func(c *cli.CLI) error { return c.MigrateUp() })
+					}
+				},
+			},
+			{
+				Name:        "down",
+				Summary:     "Revert the latest migrations",
+				Description: "Revert the latest migrations. SQLite databases are snapshotted first.",
+				Examples:    []string{"subtrack migrate down --steps 2"},
+				Setup: func(fs *flag.FlagSet) func([]string) error {
+					steps := fs.Int("steps", 1, "number of migrations to revert")
+					return func(args []string) error {
+						if len(args) > 0 {
+							return cli.ErrUsage
+						}
+						if *steps < 1 {
+							return fmt.Errorf("--steps must be at least 1")
+						}
+						return a.migrator("", func(c *cli.CLI) error { return c.MigrateDown(*steps) })
+					}
+				},
+			},
+		},
+	}
+}
+
+func snapshotCommand(a *app) *cli.Command {
+	return &cli.Command{
+		Name:        "snapshot",
+		Summary:     "List, take and restore database snapshots",
+		Description: "List, take and restore SQLite snapshots in SNAPSHOT_DIR.",
+		Default:     "list",
+		Subcommands: []*cli.Command{
+			{
+				Name:    "list",
+				Summary: "List snapshots",
+				Setup: func(fs *flag.FlagSet) func([]string) error {
+					output := cli.OutputFlag(fs)
+					return func(args []string) error {
+						if len(args) > 0 {
+							return cli.ErrUsage
+						}
+						return a.with(*output, func(c *cli.CLI) error { return c.SnapshotList() })
+					}
+				},
+			},
+			{
+				Name:    "create",
+				Summary: "Take a snapshot now",
+				Setup: func(fs *flag.FlagSet) func([]string) error {
+					return func(args []string) error {
+						if len(args) > 0 {
+							return cli.ErrUsage
+						}
+						return a.with("", func(c *cli.CLI) error { return c.SnapshotCreate() })
+					}
+				},
+			},
+			{
+				Name:    "restore",
+				Args:    "<name>",
+				Summary: "Replace the database with a snapshot",
+				Description: "Replace the database with a snapshot, after snapshotting the current one.\n" +
+					"Stop the service before restoring; run 'subtrack snapshot list' for names.",
+				Examples: []string{"subtrack snapshot restore subtrack-20250215T030000Z.db"},
+				Setup: func(fs *flag.FlagSet) func([]string) error {
+					return func(args []string) error {
+						if len(args) != 1 {
+							return cli.ErrUsage
+						}
+						return a.with("", func(c *cli.CLI) error { return c.SnapshotRestore(args[0]) })
+					}
+				},
+			},
+		},
+	}
+}
+
+func healthCommand(a *app) *cli.Command {
+	return &cli.Command{
+		Name:    "health",
+		Summary: "Check that the Telegram bot, or the profile's server, is reachable",
+		Setup: func(fs *flag.FlagSet) func([]string) error {
+			return func(args []string) error {
+				if len(args) > 0 {
+					return cli.ErrUsage
+				}
+				return a.with("", func(c *cli.CLI) error { return c.Health() })
+			}
+		},
+	}
+}
+
+func auditCommand(a *app) *cli.Command {
+	return &cli.Command{
+		Name:        "audit",
+		Summary:     "Show the audit trail of web logins",
+		Description: "Show the newest audit events, newest first: web logins, failed logins, lockouts and\ntwo-factor authentication changes.",
+		Setup: func(fs *flag.FlagSet) func([]string) error {
+			limit := fs.Int("limit", 50, "show at most this many events")
+			output := cli.OutputFlag(fs)
+			return func(args []string) error {
+				if len(args) > 0 {
+					return cli.ErrUsage
+				}
+				return a.with(*output, func(c *cli.CLI) error { return c.Audit(*limit) })
+			}
+		},
+	}
+}
+
+func twoFactorCommand(a *app) *cli.Command {
+	return &cli.Command{
+		Name:    "2fa",
+		Summary: "Show or reset a web user's two-factor authentication",
+		Description: "Show or reset two-factor authentication for the web UI. Users turn it on and off\n" +
+			"on the Security page; reset it here for a user who has lost their authenticator app\n" +
+			"and their recovery codes. The username defaults to WEB_USERNAME.",
+		Default: "status",
+		Subcommands: []*cli.Command{
+			{
+				Name:    "status",
+				Args:    "[username]",
+				Summary: "Show whether two-factor authentication is on",
+				Setup: func(fs *flag.FlagSet) func([]string) error {
+					output := cli.OutputFlag(fs)
+					return func(args []string) error {
+						if len(args) > 1 {
+							return cli.ErrUsage
+						}
+						username := ""
+						if len(args) == 1 {
+							username = args[0]
+						}
+						return a.with(*output, func(c *cli.CLI) error { return c.TwoFactorStatus(username) })
+					}
+				},
+			},
+			{
+				Name:        "reset",
+				Args:        "[username]",
+				Summary:     "Turn off two-factor authentication for a locked-out user",
+				Description: "Turn off two-factor authentication so the user can log in with their password alone\nand set it up again. The reset is recorded in the audit trail.",
+				Examples:    []string{"subtrack 2fa reset admin"},
+				Setup: func(fs *flag.FlagSet) func([]string) error {
+					return func(args []string) error {
+						if len(args) > 1 {
+							return cli.ErrUsage
+						}
+						username := ""
+						if len(args) == 1 {
+							username = args[0]
+						}
+						return a.with("", func(c *cli.CLI) error { return c.TwoFactorReset(username) })
+					}
+				},
+			},
+		},
+	}
+}
+
+func hashPasswordCommand() *cli.Command {
+	return &cli.Command{
+		Name:    "hash-password",
+		Summary: "Hash a password for WEB_PASSWORD_HASH",
+		Description: "Ask for a password and print its hash, to set as WEB_PASSWORD_HASH or put in the file named by\n" +
+			"WEB_PASSWORD_HASH_FILE instead of keeping WEB_PASSWORD in plain text. When standard input is\n" +
+			"not a terminal, its first line is the password.",
+		Examples: []string{
+			"subtrack hash-password",
+			"subtrack hash-password --bcrypt < password.txt > web_password_hash",
+		},
+		Setup: func(fs *flag.FlagSet) func([]string) error {
+			useBcrypt := fs.Bool("bcrypt", false, "make a bcrypt hash instead of argon2id")
+			return func(args []string) error {
+				if len(args) > 0 {
+					return cli.ErrUsage
+				}
+				return cli.HashPassword(os.Stdin, os.Stdout, *useBcrypt)
+			}
+		},
+	}
+}
+
+func profileCommand(a *app) *cli.Command {
+	return &cli.Command{
+		Name:    "profile",
+		Summary: "Manage servers the CLI can run commands against",
+		Description: "Manage profiles: subtrack servers with the API token to use them. The profiles are kept in\n" +
+			"$SUBTRACK_CONFIG, or subtrack/profiles.yaml in your config directory. SUBTRACK_PROFILE\n" +
+			"overrides the current profile for one command; SUBTRACK_PROFILE=local uses the local database.",
+		Default: "list",
+		Subcommands: []*cli.Command{
+			{
+				Name:    "list",
+				Summary: "List profiles, marking the current one",
+				Setup: func(fs *flag.FlagSet) func([]string) error {
+					output := cli.OutputFlag(fs)
+					return func(args []string) error {
+						if len(args) > 0 {
+							return cli.ErrUsage
+						}
+						return a.with(*output, func(c *cli.CLI) error { return c.ProfileList() })
+					}
+				},
+			},
+			{
+				Name:        "set",
+				Args:        "<name>",
+				Summary:     "Add or replace a profile",
+				Description: "Add or replace a profile. The server needs API_TOKEN set to the same token.",
+				Examples: []string{
+					"subtrack profile set home --server https://subtrack.example.com --token s3cret",
+					"pass show subtrack | subtrack profile set home --server https://subtrack.example.com --token -",
+				},
+				Setup: func(fs *flag.FlagSet) func([]string) error {
+					server := fs.String("server", "", "base URL of the subtrack web server")
+					token := fs.String("token", "", `API token, or "-" to read it from stdin`)
+					return func(args []string) error {
+						if len(args) != 1 || *server == "" || *token == "" {
+							return cli.ErrUsage
+						}
+						return a.with("", func(c *cli.CLI) error { return c.ProfileSet(args[0], *server, *token) })
+					}
+				},
+			},
+			{
+				Name:        "use",
+				Args:        "[name]",
+				Summary:     "Run commands against a profile by default",
+				Description: "Run commands against a profile by default. Without a name, or with 'local', go back to the local database.",
+				Examples:    []string{"subtrack profile use home", "subtrack profile use local"},
+				Setup: func(fs *flag.FlagSet) func([]string) error {
+					return func(args []string) error {
+						if len(args) > 1 {
+							return cli.ErrUsage
+						}
+						name := ""
+						if len(args) == 1 {
+							name = args[0]
+						}
+						return a.with("", func(c *cli.CLI) error { return c.ProfileUse(name) })
+					}
+				},
+			},
+			{
+				Name:    "remove",
+				Args:    "<name>",
+				Summary: "Remove a profile",
+				Setup: func(fs *flag.FlagSet) func([]string) error {
+					return func(args []string) error {
+						if len(args) != 1 {
+							return cli.ErrUsage
+						}
+						return a.with("", func(c *cli.CLI) error { return c.ProfileRemove(args[0]) })
+					}
+				},
+			},
+		},
+	}
+}
+
+func completionCommand(root *cli.Command) *cli.Command {
+	return &cli.Command{
+		Name:    "completion",
+		Args:    "bash|zsh|fish",
+		Summary: "Print a shell completion script",
+		Description: "Print a completion script for commands, flags and flag values.\n" +
+			"The script completes the program under the name it was run as unless --name says otherwise.",
+		Examples: []string{
+			"source <(subtrack completion bash)",
+			"subtrack completion zsh > \"${fpath[1]}/_subtrack\"",
+			"subtrack completion fish > ~/.config/fish/completions/subtrack.fish",
+		},
+		Setup: func(fs *flag.FlagSet) func([]string) error {
+			name := fs.String("name", filepath.Base(os.Args[0]), "program name to complete")
+			return func(args []string) error {
+				if len(args) != 1 {
+					return cli.ErrUsage
+				}
+				return root.WriteCompletion(os.Stdout, args[0], *name)
+			}
+		},
+	}
+}
+
+func helpCommand(root *cli.Command) *cli.Command {
+	return &cli.Command{
+		Name:     "help",
+		Args:     "[command...]",
+		Summary:  "Show help for a command",
+		Examples: []string{"subtrack help snapshot restore"},
+		Setup: func(fs *flag.FlagSet) func([]string) error {
+			return func(args []string) error {
+				cmd := root
+				for _, name := range args {
+					if cmd = cmd.Find(name); cmd == nil {
+						return fmt.Errorf("unknown command: %s", name)
+					}
+				}
+				cmd.PrintHelp(os.Stdout)
+				return nil
+			}
+		},
+	}
+}
@@ -0,0 +1,48 @@
+// Command mock-oidc runs a mock OpenID Connect provider for trying single
+// sign-on locally. It logs everyone in as the configured user without
+// asking for a password; never expose it beyond your machine.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/berkaycubuk/subtrack/internal/sso/mockidp"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:9000", "address to listen on")
+	issuer := flag.String("issuer", "", "issuer URL (default http://<addr>)")
+	clientID := flag.String("client-id", "subtrack", "OAuth2 client ID")
+	clientSecret := flag.String("client-secret", "subtrack-secret", "OAuth2 client secret")
+	username := flag.String("username", "jane", "preferred_username of the user")
+	email := flag.String("email", "jane@example.com", "email of the user")
+	groups := flag.String("groups", "subtrack-admins", "comma-separated groups of the user")
+	flag.Parse()
+
+	var userGroups []string
+	for _, g := range strings.Split(*groups, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			userGroups = append(userGroups, g)
+		}
+	}
+
+	idp, err := mockidp.New(*clientID, *clientSecret, map[string]any{
+		"sub":                *username,
+		"preferred_username": *username,
+		"email":              *email,
+		"groups":             userGroups,
+	})
+	if err != nil {
+		log.Fatalf("Failed to start mock provider: %v", err)
+	}
+	idp.Issuer = *issuer
+	if idp.Issuer == "" {
+		idp.Issuer = "http://" + *addr
+	}
+
+	log.Printf("Mock OpenID provider %s logs everyone in as %q with groups %v", idp.Issuer, *username, userGroups)
+	log.Fatal(http.ListenAndServe(*addr, idp))
+}
@@ -5,13 +5,15 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"strconv"
 	"syscall"
 
 	"github.com/berkaycubuk/subtrack/internal/config"
 	"github.com/berkaycubuk/subtrack/internal/database"
 	"github.com/berkaycubuk/subtrack/internal/scheduler"
 	"github.com/berkaycubuk/subtrack/internal/services"
+	"github.com/berkaycubuk/subtrack/internal/snapshot"
+	"github.com/berkaycubuk/subtrack/internal/sso"
+	"github.com/berkaycubuk/subtrack/internal/utils"
 	"github.com/berkaycubuk/subtrack/internal/web"
 )
 
@@ -21,29 +23,100 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	db, err := database.New(cfg.DBPath)
+	utils.SetLocation(cfg.Location)
+
+	if cfg.WebPasswordHash == "" {
+		log.Printf("WARNING: WEB_PASSWORD is stored in plain text. Anyone who can read the environment or .env can log in.")
+		log.Printf("WARNING: Run 'subtrack hash-password' and set WEB_PASSWORD_HASH or WEB_PASSWORD_HASH_FILE instead.")
+	}
+
+	db, err := database.New(cfg.DatabaseDSN())
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
-	chatID, err := strconv.ParseInt(cfg.TelegramChatID, 10, 64)
+	chatID, err := cfg.ChatID()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	tgFormat, err := services.ParseMessageFormat(cfg.TelegramFormat)
 	if err != nil {
-		log.Fatalf("Invalid TELEGRAM_CHAT_ID: %v", err)
+		log.Fatalf("Invalid TELEGRAM_FORMAT: %v", err)
 	}
 
-	tgSvc, err := services.NewTelegramService(cfg.TelegramBotToken, chatID)
+	tgSvc, err := services.NewTelegramService(cfg.TelegramBotToken, chatID, tgFormat)
 	if err != nil {
 		log.Fatalf("Failed to initialize Telegram bot: %v", err)
 	}
 
 	subSvc := services.NewSubscriptionService(db, tgSvc)
 
-	sched := scheduler.NewScheduler(subSvc)
+	sched, err := scheduler.NewScheduler(subSvc, cfg.CheckSchedules, cfg.Location)
+	if err != nil {
+		log.Fatalf("Invalid CHECK_SCHEDULE: %v", err)
+	}
+
+	if err := sched.ScheduleOutboxDrain(cfg.OutboxSchedule); err != nil {
+		log.Fatalf("Invalid OUTBOX_SCHEDULE: %v", err)
+	}
+
+	if db.Driver() == database.DriverSQLite {
+		snapshots := snapshot.NewManager(db, cfg.SnapshotDir, cfg.SnapshotDaily, cfg.SnapshotWeekly)
+		if err := sched.ScheduleSnapshots(cfg.SnapshotSchedule, snapshots); err != nil {
+			log.Fatalf("Invalid SNAPSHOT_SCHEDULE: %v", err)
+		}
+	} else {
+		log.Printf("Snapshots are disabled: they are only supported for SQLite")
+	}
+
+	if cfg.DigestSchedule != "" {
+		period, err := services.ParseDigestPeriod(cfg.DigestPeriod)
+		if err != nil {
+			log.Fatalf("Invalid DIGEST_PERIOD: %v", err)
+		}
+		if err := sched.ScheduleDigest(cfg.DigestSchedule, period); err != nil {
+			log.Fatalf("Invalid DIGEST_SCHEDULE: %v", err)
+		}
+	}
 	if err := sched.StartCron(); err != nil {
 		log.Fatalf("Failed to start scheduler: %v", err)
 	}
 
-	srv := web.NewServer(subSvc, cfg.WebUsername, cfg.WebPassword)
+	opts := web.Options{
+		Username:       cfg.WebUsername,
+		Password:       cfg.WebPassword,
+		PasswordHash:   cfg.WebPasswordHash,
+		APIToken:       cfg.APIToken,
+		SecureCookies:  cfg.SecureCookies,
+		TrustedProxies: cfg.TrustedProxies,
+		BasePath:       cfg.BasePath,
+		APIRateLimit:   cfg.APIRateLimit,
+	}
+	if cfg.TLS.Enabled() {
+		opts.TLS = &web.TLSOptions{
+			CertFile:   cfg.TLS.CertFile,
+			KeyFile:    cfg.TLS.KeyFile,
+			SelfSigned: cfg.TLS.SelfSigned,
+		}
+	}
+	if cfg.OIDC.Enabled() {
+		opts.SSO = &web.SSOOptions{
+			Config: sso.Config{
+				Issuer:        cfg.OIDC.Issuer,
+				ClientID:      cfg.OIDC.ClientID,
+				ClientSecret:  cfg.OIDC.ClientSecret,
+				Scopes:        cfg.OIDC.Scopes,
+				UsernameClaim: cfg.OIDC.UsernameClaim,
+				RolesClaim:    cfg.OIDC.RolesClaim,
+			},
+			RedirectURL: cfg.OIDC.RedirectURL,
+			AdminRoles:  cfg.OIDC.AdminRoles,
+			ViewerRoles: cfg.OIDC.ViewerRoles,
+		}
+		log.Printf("Single sign-on enabled with %s", cfg.OIDC.Issuer)
+	}
+	srv := web.NewServer(subSvc, services.NewReportService(db), services.NewAuditLog(db), services.NewTwoFactorService(db), opts)
 
 	go func() {
 		if err := srv.Start(":" + cfg.WebPort); err != nil && err != http.ErrServerClosed {
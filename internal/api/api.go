@@ -0,0 +1,115 @@
+// Package api holds the JSON types of the service's HTTP API under /api/v1,
+// shared by the web server and the remote client.
+package api
+
+import (
+	"errors"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/services"
+)
+
+// Prefix is the path every API endpoint is under.
+const Prefix = "/api/v1"
+
+// Error is the body of every failed API response.
+type Error struct {
+	Error string `json:"error"`
+}
+
+// SubscriptionList is one page of a subscription listing.
+type SubscriptionList struct {
+	Subscriptions []database.Subscription `json:"subscriptions"`
+	NextCursor    string                  `json:"next_cursor,omitempty"`
+}
+
+// NewSubscription creates a subscription. Fields are in the same text form as
+// the CLI and web form take them, including DD-MM-YYYY payment dates.
+type NewSubscription struct {
+	Name        string `json:"name"`
+	Price       string `json:"price"`
+	Currency    string `json:"currency"`
+	Cycle       string `json:"cycle"`
+	PaymentDate string `json:"payment_date"`
+	Category    string `json:"category"`
+}
+
+// SubscriptionChanges updates a subscription. Empty fields are left as they
+// are; Category and TrialEndDate are pointers so they can be cleared.
+type SubscriptionChanges struct {
+	Name         string  `json:"name,omitempty"`
+	Price        string  `json:"price,omitempty"`
+	Currency     string  `json:"currency,omitempty"`
+	Cycle        string  `json:"cycle,omitempty"`
+	PaymentDate  string  `json:"payment_date,omitempty"`
+	Category     *string `json:"category,omitempty"`
+	Status       string  `json:"status,omitempty"`
+	TrialEndDate *string `json:"trial_end_date,omitempty"`
+}
+
+type ImportRow struct {
+	Line   int    `json:"line"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ImportResult struct {
+	Rows       []ImportRow `json:"rows"`
+	Added      int         `json:"added"`
+	Duplicates int         `json:"duplicates"`
+	Invalid    int         `json:"invalid"`
+	DryRun     bool        `json:"dry_run"`
+}
+
+func NewImportResult(r *services.ImportResult) ImportResult {
+	result := ImportResult{
+		Rows:       make([]ImportRow, len(r.Rows)),
+		Added:      r.Added,
+		Duplicates: r.Duplicates,
+		Invalid:    r.Invalid,
+		DryRun:     r.DryRun,
+	}
+	for i, row := range r.Rows {
+		result.Rows[i] = ImportRow{Line: row.Line, Name: row.Name, Status: row.Status}
+		if row.Err != nil {
+			result.Rows[i].Error = row.Err.Error()
+		}
+	}
+	return result
+}
+
+// Result converts r back to the service's result.
+func (r ImportResult) Result() *services.ImportResult {
+	result := &services.ImportResult{
+		Rows:       make([]services.ImportRow, len(r.Rows)),
+		Added:      r.Added,
+		Duplicates: r.Duplicates,
+		Invalid:    r.Invalid,
+		DryRun:     r.DryRun,
+	}
+	for i, row := range r.Rows {
+		result.Rows[i] = services.ImportRow{Line: row.Line, Name: row.Name, Status: row.Status}
+		if row.Error != "" {
+			result.Rows[i].Err = errors.New(row.Error)
+		}
+	}
+	return result
+}
+
+type RestoreResult struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Renamed   int `json:"renamed"`
+	Skipped   int `json:"skipped"`
+	Unchanged int `json:"unchanged"`
+}
+
+func NewRestoreResult(r *services.RestoreResult) RestoreResult {
+	return RestoreResult{r.Created, r.Updated, r.Renamed, r.Skipped, r.Unchanged}
+}
+
+// Result converts r back to the service's result.
+func (r RestoreResult) Result() *services.RestoreResult {
+	return &services.RestoreResult{Created: r.Created, Updated: r.Updated, Renamed: r.Renamed, Skipped: r.Skipped, Unchanged: r.Unchanged}
+}
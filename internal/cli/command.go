@@ -0,0 +1,261 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// ErrUsage is returned when a command was called with the wrong arguments.
+// The command's help has already been printed.
+var ErrUsage = errors.New("invalid usage")
+
+// Command is a CLI command. Leaf commands declare their flags in Setup, which
+// returns the function that runs the command with its positional arguments.
+// Commands with Subcommands have no Setup; they run Default when called
+// without a subcommand.
+type Command struct {
+	Name string
+	// Args is the positional argument synopsis, e.g. "<id> [name]".
+	Args        string
+	Summary     string
+	Description string
+	Examples    []string
+
+	Setup       func(fs *flag.FlagSet) func(args []string) error
+	Subcommands []*Command
+	Default     string
+
+	parent *Command
+}
+
+// Execute runs the command named by args under cmd.
+func (cmd *Command) Execute(args []string) error {
+	cmd.link()
+	return cmd.execute(args)
+}
+
+// link sets parent pointers, which help and completion use for full names.
+func (cmd *Command) link() {
+	for _, sub := range cmd.Subcommands {
+		sub.parent = cmd
+		sub.link()
+	}
+}
+
+func (cmd *Command) execute(args []string) error {
+	if cmd.Setup != nil {
+		return cmd.run(args)
+	}
+
+	if len(args) == 0 {
+		if cmd.Default == "" {
+			cmd.PrintHelp(os.Stderr)
+			return ErrUsage
+		}
+		return cmd.Find(cmd.Default).execute(nil)
+	}
+
+	switch args[0] {
+	case "-h", "-help", "--help":
+		cmd.PrintHelp(os.Stdout)
+		return nil
+	}
+	if strings.HasPrefix(args[0], "-") && cmd.Default != "" {
+		return cmd.Find(cmd.Default).execute(args)
+	}
+
+	sub := cmd.Find(args[0])
+	if sub == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", strings.TrimSpace(cmd.FullName()+" "+args[0]))
+		cmd.PrintHelp(os.Stderr)
+		return ErrUsage
+	}
+	return sub.execute(args[1:])
+}
+
+func (cmd *Command) run(args []string) error {
+	fs := cmd.flagSet()
+	run := cmd.Setup(fs)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() { cmd.PrintHelp(os.Stderr) }
+
+	positional, err := parseInterspersed(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return ErrUsage
+	}
+
+	err = run(positional)
+	if errors.Is(err, ErrUsage) {
+		cmd.PrintHelp(os.Stderr)
+	}
+	return err
+}
+
+func (cmd *Command) flagSet() *flag.FlagSet {
+	return flag.NewFlagSet(cmd.FullName(), flag.ContinueOnError)
+}
+
+// parseInterspersed parses flags wherever they appear among the positional
+// arguments, so "update 1 --currency EUR" works. Arguments after "--" are
+// always positional.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		rest := args
+		if i := slices.Index(args, "--"); i >= 0 {
+			rest = args[:i]
+		}
+		if err := fs.Parse(rest); err != nil {
+			return nil, err
+		}
+		parsed := len(rest) - fs.NArg()
+		if fs.NArg() == 0 {
+			if len(rest) < len(args) {
+				positional = append(positional, args[len(rest)+1:]...)
+			}
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = args[parsed+1:]
+	}
+}
+
+// Find returns the subcommand called name, or nil.
+func (cmd *Command) Find(name string) *Command {
+	for _, sub := range cmd.Subcommands {
+		if sub.Name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// FullName is the command's name prefixed with its parents' names.
+func (cmd *Command) FullName() string {
+	if cmd.parent == nil {
+		return cmd.Name
+	}
+	return cmd.parent.FullName() + " " + cmd.Name
+}
+
+// PrintHelp writes the command's usage, flags and examples to w.
+func (cmd *Command) PrintHelp(w io.Writer) {
+	cmd.link()
+	usage := cmd.FullName()
+	switch {
+	case cmd.Subcommands != nil:
+		usage += " <command>"
+	case cmd.Args != "":
+		usage += " " + cmd.Args
+	}
+	if cmd.Setup != nil && cmd.hasFlags() {
+		usage += " [flags]"
+	}
+	fmt.Fprintf(w, "Usage: %s\n", usage)
+
+	if text := cmd.Description; text != "" || cmd.Summary != "" {
+		if text == "" {
+			text = cmd.Summary
+		}
+		fmt.Fprintf(w, "\n%s\n", text)
+	}
+
+	if len(cmd.Subcommands) > 0 {
+		fmt.Fprintln(w, "\nCommands:")
+		tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+		for _, sub := range cmd.Subcommands {
+			name := sub.Name
+			if sub.Name == cmd.Default {
+				name += " (default)"
+			}
+			fmt.Fprintf(tw, "  %s\t%s\n", name, sub.Summary)
+		}
+		tw.Flush()
+		fmt.Fprintf(w, "\nRun '%s <command> --help' for details on a command.\n", cmd.FullName())
+	}
+
+	if cmd.Setup != nil && cmd.hasFlags() {
+		fmt.Fprintln(w, "\nFlags:")
+		tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+		for _, f := range cmd.flags() {
+			name, usage := flag.UnquoteUsage(f)
+			if c, ok := f.Value.(*choiceValue); ok {
+				name = strings.Join(c.choices, "|")
+			}
+			def := ""
+			if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "0" {
+				def = fmt.Sprintf(" (default %s)", f.DefValue)
+			}
+			fmt.Fprintf(tw, "  --%s %s\t%s%s\n", f.Name, name, usage, def)
+		}
+		tw.Flush()
+	}
+
+	if len(cmd.Examples) > 0 {
+		fmt.Fprintln(w, "\nExamples:")
+		for _, example := range cmd.Examples {
+			fmt.Fprintf(w, "  %s\n", example)
+		}
+	}
+}
+
+// flags returns the flags Setup declares, in name order.
+func (cmd *Command) flags() []*flag.Flag {
+	if cmd.Setup == nil {
+		return nil
+	}
+	fs := cmd.flagSet()
+	cmd.Setup(fs)
+	var flags []*flag.Flag
+	fs.VisitAll(func(f *flag.Flag) { flags = append(flags, f) })
+	return flags
+}
+
+func (cmd *Command) hasFlags() bool {
+	return len(cmd.flags()) > 0
+}
+
+// choiceValue is a string flag restricted to a fixed set of values, which
+// help and the completion scripts list.
+type choiceValue struct {
+	value   *string
+	choices []string
+}
+
+// Choice defines a string flag that only accepts one of choices.
+func Choice(fs *flag.FlagSet, name, value string, choices []string, usage string) *string {
+	c := &choiceValue{value: new(string), choices: choices}
+	*c.value = value
+	fs.Var(c, name, usage)
+	return c.value
+}
+
+func (c *choiceValue) String() string {
+	if c.value == nil {
+		return ""
+	}
+	return *c.value
+}
+
+func (c *choiceValue) Set(s string) error {
+	if !slices.Contains(c.choices, s) {
+		return fmt.Errorf("must be one of %s", strings.Join(c.choices, ", "))
+	}
+	*c.value = s
+	return nil
+}
+
+// isBoolFlag reports whether f is given without a value.
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testCommand(got *[]string, format *string) *Command {
+	return &Command{
+		Name: "app",
+		Subcommands: []*Command{
+			{
+				Name:    "update",
+				Summary: "Update it",
+				Setup: func(fs *flag.FlagSet) func([]string) error {
+					name := fs.String("name", "", "new name")
+					output := OutputFlag(fs)
+					return func(args []string) error {
+						*got = append(args, *name)
+						*format = *output
+						return nil
+					}
+				},
+			},
+			{
+				Name:    "snapshot",
+				Summary: "Snapshots",
+				Default: "list",
+				Subcommands: []*Command{
+					{
+						Name: "list",
+						Setup: func(fs *flag.FlagSet) func([]string) error {
+							verbose := fs.Bool("verbose", false, "more detail")
+							return func(args []string) error {
+								*got = []string{"list", map[bool]string{true: "verbose"}[*verbose]}
+								return nil
+							}
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestCommand_Execute(t *testing.T) {
+	var got []string
+	var format string
+	root := testCommand(&got, &format)
+
+	tests := []struct {
+		args   []string
+		want   []string
+		format string
+	}{
+		{[]string{"update", "1", "--name", "Netflix", "2"}, []string{"1", "2", "Netflix"}, OutputTable},
+		{[]string{"update", "--output", "yaml", "1"}, []string{"1", ""}, OutputYAML},
+		{[]string{"update", "--name", "x", "--", "--not-a-flag"}, []string{"--not-a-flag", "x"}, OutputTable},
+		{[]string{"snapshot"}, []string{"list", ""}, ""},
+		{[]string{"snapshot", "--verbose"}, []string{"list", "verbose"}, ""},
+	}
+	for _, tt := range tests {
+		got, format = nil, ""
+		if err := root.Execute(tt.args); err != nil {
+			t.Errorf("Execute(%q) error = %v", tt.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) || format != tt.format {
+			t.Errorf("Execute(%q) ran with %q, output %q, want %q, %q", tt.args, got, format, tt.want, tt.format)
+		}
+	}
+
+	for _, args := range [][]string{{"missing"}, {"update", "--output", "xml"}, {"update", "--nope"}} {
+		if err := root.Execute(args); !errors.Is(err, ErrUsage) {
+			t.Errorf("Execute(%q) error = %v, want ErrUsage", args, err)
+		}
+	}
+}
+
+func TestCommand_WriteCompletion(t *testing.T) {
+	var got []string
+	var format string
+	root := testCommand(&got, &format)
+
+	for _, shell := range CompletionShells {
+		var buf bytes.Buffer
+		if err := root.WriteCompletion(&buf, shell, "app"); err != nil {
+			t.Fatalf("WriteCompletion(%s) error = %v", shell, err)
+		}
+		script := buf.String()
+		for _, want := range []string{"snapshot list", "update", "verbose", "table json yaml csv"} {
+			if !strings.Contains(script, want) && !(shell == "zsh" && want == "table json yaml csv") {
+				t.Errorf("%s script does not mention %q", shell, want)
+			}
+		}
+	}
+	if err := root.WriteCompletion(&bytes.Buffer{}, "tcsh", "app"); err == nil {
+		t.Error("WriteCompletion(tcsh) expected an error")
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	type record struct {
+		Name  string  `json:"name"`
+		Price float64 `json:"price"`
+		Code  string  `json:"code"`
+	}
+	tbl := table{
+		headers: []string{"Name", "Price"},
+		rows:    [][]string{{"Netflix, HD", "15.99"}},
+		value:   []record{{"Netflix, HD", 15.99, "007"}},
+	}
+
+	want := map[string]string{
+		OutputTable: "Name          Price\n----          -----\nNetflix, HD   15.99\n",
+		OutputCSV:   "Name,Price\n\"Netflix, HD\",15.99\n",
+		OutputJSON:  "[\n  {\n    \"name\": \"Netflix, HD\",\n    \"price\": 15.99,\n    \"code\": \"007\"\n  }\n]\n",
+		OutputYAML:  "- name: Netflix, HD\n  price: 15.99\n  code: \"007\"\n",
+	}
+	for _, format := range OutputFormats {
+		var buf bytes.Buffer
+		if err := writeTable(&buf, format, tbl); err != nil {
+			t.Fatalf("writeTable(%s) error = %v", format, err)
+		}
+		if buf.String() != want[format] {
+			t.Errorf("writeTable(%s) = %q, want %q", format, buf.String(), want[format])
+		}
+	}
+}
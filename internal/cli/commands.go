@@ -2,107 +2,415 @@ package cli
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"strconv"
-	"text/tabwriter"
+	"time"
 
+	"github.com/berkaycubuk/subtrack/internal/client"
 	"github.com/berkaycubuk/subtrack/internal/config"
 	"github.com/berkaycubuk/subtrack/internal/database"
 	"github.com/berkaycubuk/subtrack/internal/services"
+	"github.com/berkaycubuk/subtrack/internal/snapshot"
+	"github.com/berkaycubuk/subtrack/internal/tui"
 	"github.com/berkaycubuk/subtrack/internal/utils"
 )
 
+// CLI runs commands against the local database, or against a server when a
+// profile is selected. The database, services, Telegram bot and server client
+// are set up the first time a command needs them, so commands only require
+// the settings they use: listing subscriptions needs neither network access
+// nor Telegram credentials.
 type CLI struct {
-	cfg    *config.Config
+	cfg *config.Config
+	// open opens the database: database.New, which migrates it, or
+	// database.Open for the migrate command.
+	open   func(dsn string) (*database.DB, error)
 	db     *database.DB
 	subSvc *services.SubscriptionService
 	tgSvc  *services.TelegramService
+
+	// profile is the selected profile's name and remote its client, once
+	// resolved; remote is nil for the local database.
+	resolved bool
+	profile  string
+	remote   *client.Client
+
+	// output is the format of commands that print records; see SetOutput.
+	output string
+	stdout io.Writer
 }
 
 func New() (*CLI, error) {
-	cfg, err := config.Load()
+	return newCLI(database.New)
+}
+
+// NewMigrator opens the database without applying migrations, for the
+// migrate command.
+func NewMigrator() (*CLI, error) {
+	return newCLI(database.Open)
+}
+
+func newCLI(open func(dsn string) (*database.DB, error)) (*CLI, error) {
+	cfg, err := config.Read()
 	if err != nil {
 		return nil, err
 	}
 
-	db, err := database.New(cfg.DBPath)
+	utils.SetLocation(cfg.Location)
+
+	return &CLI{cfg: cfg, open: open, output: OutputTable, stdout: os.Stdout}, nil
+}
+
+// Subscriptions is what the subscription and data commands use, served by
+// the local SubscriptionService or by a server through client.Client.
+type Subscriptions interface {
+	AddSubscription(name, price, currency, cycle, paymentDate, category string) error
+	SearchSubscriptions(q database.SubscriptionQuery) (*database.SubscriptionPage, error)
+	UpdateSubscription(id uint, name, price, currency, cycle, paymentDate string) error
+	SetSubscriptionCategory(id uint, category string) error
+	SetSubscriptionStatus(id uint, status string) error
+	SetTrialEndDate(id uint, date string) error
+	DeleteSubscription(id uint) error
+	ImportCSV(r io.Reader, opts services.ImportOptions) (*services.ImportResult, error)
+	ExportCSV(w io.Writer) error
+	ExportICS(w io.Writer) error
+	WriteBackup(w io.Writer) error
+	Restore(r io.Reader, policy services.ConflictPolicy) (*services.RestoreResult, error)
+}
+
+// server returns the client for the profile named by SUBTRACK_PROFILE, or
+// the current profile, and nil when commands run against the local database.
+func (c *CLI) server() (*client.Client, error) {
+	if !c.resolved {
+		path, err := client.ProfilesPath()
+		if err != nil {
+			return nil, err
+		}
+		profiles, err := client.LoadProfiles(path)
+		if err != nil {
+			return nil, err
+		}
+		name, profile, err := profiles.Select(os.Getenv("SUBTRACK_PROFILE"))
+		if err != nil {
+			return nil, err
+		}
+		if profile != nil {
+			remote, err := client.New(profile.Server, profile.Token)
+			if err != nil {
+				return nil, fmt.Errorf("profile %q: %w", name, err)
+			}
+			c.profile, c.remote = name, remote
+		}
+		c.resolved = true
+	}
+	return c.remote, nil
+}
+
+// subscriptions returns the server's API when a profile is selected, and the
+// local service otherwise.
+func (c *CLI) subscriptions() (Subscriptions, error) {
+	remote, err := c.server()
 	if err != nil {
 		return nil, err
 	}
+	if remote != nil {
+		return remote, nil
+	}
+	return c.service()
+}
 
-	chatID, err := strconv.ParseInt(cfg.TelegramChatID, 10, 64)
+// local fails when a profile is selected, for commands that only work on
+// the machine running the service.
+func (c *CLI) local() error {
+	remote, err := c.server()
 	if err != nil {
-		return nil, fmt.Errorf("invalid TELEGRAM_CHAT_ID: %w", err)
+		return err
+	}
+	if remote != nil {
+		return fmt.Errorf("this command needs the local database and is not available with profile %q (%s); run it on the server or with SUBTRACK_PROFILE=%s", c.profile, remote.Server(), client.LocalProfile)
 	}
+	return nil
+}
 
-	tgSvc, err := services.NewTelegramService(cfg.TelegramBotToken, chatID)
-	if err != nil {
+func (c *CLI) database() (*database.DB, error) {
+	if err := c.local(); err != nil {
 		return nil, err
 	}
+	if c.db == nil {
+		db, err := c.open(c.cfg.DatabaseDSN())
+		if err != nil {
+			return nil, err
+		}
+		c.db = db
+	}
+	return c.db, nil
+}
 
-	subSvc := services.NewSubscriptionService(db, tgSvc)
+// service is the local subscription service.
+func (c *CLI) service() (*services.SubscriptionService, error) {
+	if c.subSvc == nil {
+		db, err := c.database()
+		if err != nil {
+			return nil, err
+		}
+		c.subSvc = services.NewSubscriptionService(db, lazyTelegram{c})
+	}
+	return c.subSvc, nil
+}
 
-	return &CLI{
-		cfg:    cfg,
-		db:     db,
-		subSvc: subSvc,
-		tgSvc:  tgSvc,
-	}, nil
+// telegram connects to the Telegram bot, which requires the Telegram
+// settings.
+func (c *CLI) telegram() (*services.TelegramService, error) {
+	if err := c.local(); err != nil {
+		return nil, err
+	}
+	if c.tgSvc == nil {
+		if err := c.cfg.Require(config.RequireTelegram); err != nil {
+			return nil, err
+		}
+		chatID, err := c.cfg.ChatID()
+		if err != nil {
+			return nil, err
+		}
+		format, err := services.ParseMessageFormat(c.cfg.TelegramFormat)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TELEGRAM_FORMAT: %w", err)
+		}
+		tgSvc, err := services.NewTelegramService(c.cfg.TelegramBotToken, chatID, format)
+		if err != nil {
+			return nil, err
+		}
+		c.tgSvc = tgSvc
+	}
+	return c.tgSvc, nil
 }
 
-func (c *CLI) Add(name, price, currency, cycle, paymentDate string) error {
-	if err := c.subSvc.AddSubscription(name, price, currency, cycle, paymentDate); err != nil {
+// lazyTelegram connects to Telegram on the first message it sends. Commands
+// that only queue notifications, or render them for preview, never do.
+type lazyTelegram struct {
+	c *CLI
+}
+
+func (t lazyTelegram) SendMessage(text string) error {
+	tg, err := t.c.telegram()
+	if err != nil {
+		return err
+	}
+	return tg.SendMessage(text)
+}
+
+// MessageFormat is TELEGRAM_FORMAT, or the default if it is invalid; sending
+// reports the invalid setting.
+func (t lazyTelegram) MessageFormat() services.MessageFormat {
+	format, err := services.ParseMessageFormat(t.c.cfg.TelegramFormat)
+	if err != nil {
+		return services.FormatMarkdownV2
+	}
+	return format
+}
+
+func (c *CLI) Add(name, price, currency, cycle, paymentDate, category string) error {
+	subSvc, err := c.subscriptions()
+	if err != nil {
+		return err
+	}
+
+	if err := subSvc.AddSubscription(name, price, currency, cycle, paymentDate, category); err != nil {
 		return err
 	}
 	fmt.Println("✓ Subscription added successfully")
 	return nil
 }
 
-func (c *CLI) List() error {
-	subs, err := c.subSvc.ListSubscriptions()
+func (c *CLI) List(q database.SubscriptionQuery) error {
+	subSvc, err := c.subscriptions()
 	if err != nil {
 		return err
 	}
 
-	if len(subs) == 0 {
+	page, err := subSvc.SearchSubscriptions(q)
+	if err != nil {
+		return err
+	}
+	subs := page.Subscriptions
+
+	if len(subs) == 0 && c.output == OutputTable {
 		fmt.Println("No subscriptions found")
 		return nil
 	}
 
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-	fmt.Fprintf(w, "ID\tName\tPrice\tCurrency\tCycle\tPayment Date\n")
-	fmt.Fprintf(w, "--\t----\t-----\t--------\t-----\t------------\n")
-
+	t := table{
+		headers: []string{"ID", "Name", "Price", "Currency", "Cycle", "Payment Date", "Category", "Status"},
+		value:   subscriptionList{Subscriptions: subscriptionRecords(subs), NextCursor: page.NextCursor},
+	}
 	for _, sub := range subs {
-		paymentDateStr := utils.FormatDate(sub.PaymentDate)
-		fmt.Fprintf(w, "%d\t%s\t%.2f\t%s\t%s\t%s\n",
-			sub.ID, sub.Name, sub.Price, sub.Currency, sub.Cycle, paymentDateStr)
+		t.add(strconv.FormatUint(uint64(sub.ID), 10), sub.Name, strconv.FormatFloat(sub.Price, 'f', 2, 64),
+			sub.Currency, sub.Cycle, utils.FormatDate(sub.PaymentDate), sub.Category, sub.Status)
+	}
+	if err := c.print(t); err != nil {
+		return err
 	}
 
-	w.Flush()
+	if page.NextCursor != "" && !c.structured() {
+		fmt.Fprintf(os.Stderr, "\nMore results: subtrack list ... --cursor %s\n", page.NextCursor)
+	}
 	return nil
 }
 
-func (c *CLI) Update(idStr, name, price, currency, cycle, paymentDate string) error {
+// subscriptionRecord is a subscription as printed by JSON and YAML output.
+type subscriptionRecord struct {
+	ID          uint    `json:"id"`
+	Name        string  `json:"name"`
+	Price       float64 `json:"price"`
+	Currency    string  `json:"currency"`
+	Cycle       string  `json:"cycle"`
+	PaymentDate string  `json:"payment_date"`
+	Category    string  `json:"category"`
+	Status      string  `json:"status"`
+}
+
+type subscriptionList struct {
+	Subscriptions []subscriptionRecord `json:"subscriptions"`
+	NextCursor    string               `json:"next_cursor,omitempty"`
+}
+
+func subscriptionRecords(subs []database.Subscription) []subscriptionRecord {
+	records := make([]subscriptionRecord, len(subs))
+	for i, sub := range subs {
+		records[i] = subscriptionRecord{
+			ID:          sub.ID,
+			Name:        sub.Name,
+			Price:       sub.Price,
+			Currency:    sub.Currency,
+			Cycle:       sub.Cycle,
+			PaymentDate: utils.FormatDate(sub.PaymentDate),
+			Category:    sub.Category,
+			Status:      sub.Status,
+		}
+	}
+	return records
+}
+
+// SubscriptionChanges are the fields Update changes. Empty fields are left
+// as they are; a nil Category keeps the category and an empty one clears it.
+type SubscriptionChanges struct {
+	Name        string
+	Price       string
+	Currency    string
+	Cycle       string
+	PaymentDate string
+	Category    *string
+	Status      string
+}
+
+func (c *CLI) Update(idStr string, changes SubscriptionChanges) error {
+	subSvc, err := c.subscriptions()
+	if err != nil {
+		return err
+	}
+
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
 		return fmt.Errorf("invalid subscription ID: %w", err)
 	}
 
-	if err := c.subSvc.UpdateSubscription(uint(id), name, price, currency, cycle, paymentDate); err != nil {
+	if err := subSvc.UpdateSubscription(uint(id), changes.Name, changes.Price, changes.Currency, changes.Cycle, changes.PaymentDate); err != nil {
 		return err
 	}
+	if changes.Category != nil {
+		if err := subSvc.SetSubscriptionCategory(uint(id), *changes.Category); err != nil {
+			return err
+		}
+	}
+	if changes.Status != "" {
+		if err := subSvc.SetSubscriptionStatus(uint(id), changes.Status); err != nil {
+			return err
+		}
+	}
 	fmt.Println("✓ Subscription updated successfully")
 	return nil
 }
 
+func (c *CLI) SetStatus(idStr, status string) error {
+	subSvc, err := c.subscriptions()
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid subscription ID: %w", err)
+	}
+
+	if err := subSvc.SetSubscriptionStatus(uint(id), status); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Subscription marked %s\n", status)
+	return nil
+}
+
+func (c *CLI) SetCategory(idStr, category string) error {
+	subSvc, err := c.subscriptions()
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid subscription ID: %w", err)
+	}
+
+	if err := subSvc.SetSubscriptionCategory(uint(id), category); err != nil {
+		return err
+	}
+	if category == "" {
+		fmt.Println("✓ Subscription category cleared")
+	} else {
+		fmt.Printf("✓ Subscription category set to %s\n", category)
+	}
+	return nil
+}
+
+// SetTrial sets the day a subscription's trial ends; "off" removes the
+// trial.
+func (c *CLI) SetTrial(idStr, date string) error {
+	subSvc, err := c.subscriptions()
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid subscription ID: %w", err)
+	}
+
+	if date == "off" {
+		date = ""
+	}
+	if err := subSvc.SetTrialEndDate(uint(id), date); err != nil {
+		return err
+	}
+	if date == "" {
+		fmt.Println("✓ Subscription trial removed")
+	} else {
+		fmt.Printf("✓ Subscription trial ends on %s\n", date)
+	}
+	return nil
+}
+
 func (c *CLI) Delete(idStr string) error {
+	subSvc, err := c.subscriptions()
+	if err != nil {
+		return err
+	}
+
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
 		return fmt.Errorf("invalid subscription ID: %w", err)
 	}
 
-	if err := c.subSvc.DeleteSubscription(uint(id)); err != nil {
+	if err := subSvc.DeleteSubscription(uint(id)); err != nil {
 		return err
 	}
 	fmt.Println("✓ Subscription deleted successfully")
@@ -110,19 +418,33 @@ func (c *CLI) Delete(idStr string) error {
 }
 
 func (c *CLI) Check() error {
+	subSvc, err := c.service()
+	if err != nil {
+		return err
+	}
+
 	fmt.Println("Checking upcoming payments...")
 
-	if err := c.subSvc.UpdatePastDuePayments(); err != nil {
+	if err := subSvc.UpdatePastDuePayments(); err != nil {
 		fmt.Printf("Error updating past due payments: %v\n", err)
 	}
 
-	subs, err := c.subSvc.CheckUpcomingPayments()
+	trials, err := subSvc.SendTrialNotifications()
+	if err != nil {
+		fmt.Printf("Error checking ending trials: %v\n", err)
+	}
+	for _, sub := range trials {
+		fmt.Printf("⏳ %s trial ends in %d days\n\n", sub.Name, utils.DaysUntil(*sub.TrialEndDate))
+	}
+
+	subs, err := subSvc.CheckUpcomingPayments()
 	if err != nil {
 		return err
 	}
 
 	if len(subs) == 0 {
 		fmt.Println("No upcoming payments found")
+		c.drainOutbox()
 		return nil
 	}
 
@@ -138,17 +460,727 @@ func (c *CLI) Check() error {
 		fmt.Printf("   📆 Next payment: %s\n\n", paymentDateStr)
 	}
 
-	if err := c.subSvc.SendNotifications(subs); err != nil {
+	if err := subSvc.SendNotifications(subs); err != nil {
 		fmt.Printf("Error sending notifications: %v\n", err)
 	}
 
+	c.drainOutbox()
+	return nil
+}
+
+// Digest prints the period's digest, and queues and delivers it too when
+// send is set.
+func (c *CLI) Digest(periodStr string, send bool) error {
+	subSvc, err := c.service()
+	if err != nil {
+		return err
+	}
+
+	period, err := services.ParseDigestPeriod(periodStr)
+	if err != nil {
+		return err
+	}
+
+	digest, err := subSvc.BuildDigest(period)
+	if err != nil {
+		return err
+	}
+
+	if digest.Count == 0 {
+		fmt.Printf("No payments due for %s digest\n", period)
+		return nil
+	}
+
+	text, err := services.RenderDefault(services.EventDigest, services.FormatText, digest)
+	if err != nil {
+		return err
+	}
+	fmt.Println(text)
+
+	if !send {
+		return nil
+	}
+	if err := subSvc.QueueDigest(digest); err != nil {
+		fmt.Printf("Error sending digest: %v\n", err)
+	}
+
+	c.drainOutbox()
+	return nil
+}
+
+func (c *CLI) drainOutbox() {
+	sent, err := c.subSvc.DrainOutbox()
+	if err != nil {
+		fmt.Printf("Error delivering notifications: %v\n", err)
+		return
+	}
+	if sent > 0 {
+		fmt.Printf("✓ Delivered %d notifications\n", sent)
+	}
+}
+
+func (c *CLI) NotifyPreferences() error {
+	subSvc, err := c.service()
+	if err != nil {
+		return err
+	}
+
+	prefs, quiet, err := subSvc.NotificationPreferences()
+	if err != nil {
+		return err
+	}
+
+	type preference struct {
+		Channel string `json:"channel"`
+		Event   string `json:"event"`
+		Enabled bool   `json:"enabled"`
+	}
+	var records []preference
+	t := table{headers: []string{"Channel", "Event", "Enabled"}}
+	for _, channel := range services.Channels {
+		for _, event := range services.EventTypes {
+			records = append(records, preference{channel, string(event), prefs[channel][event]})
+			t.add(channel, string(event), strconv.FormatBool(prefs[channel][event]))
+		}
+	}
+	budget, err := subSvc.Budget()
+	if err != nil {
+		return err
+	}
+	t.value = struct {
+		Preferences []preference `json:"preferences"`
+		QuietHours  string       `json:"quiet_hours"`
+		Budget      string       `json:"budget"`
+	}{records, quiet.String(), budget.String()}
+	if err := c.print(t); err != nil {
+		return err
+	}
+
+	if c.output == OutputTable {
+		fmt.Printf("\nQuiet hours: %s\n", quiet)
+		fmt.Printf("Budget: %s\n", budget)
+	}
+	return nil
+}
+
+func (c *CLI) NotifySet(channel, event string, enabled bool) error {
+	subSvc, err := c.service()
+	if err != nil {
+		return err
+	}
+
+	if err := subSvc.SetNotificationPreference(channel, event, enabled); err != nil {
+		return err
+	}
+	fmt.Println("✓ Notification preference updated")
+	return nil
+}
+
+func (c *CLI) NotifyQuiet(start, end string) error {
+	subSvc, err := c.service()
+	if err != nil {
+		return err
+	}
+
+	var quiet services.QuietHours
+	if start != "off" {
+		var err error
+		quiet, err = services.ParseQuietHours(start, end)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := subSvc.SetQuietHours(quiet); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Quiet hours set to %s\n", quiet)
+	return nil
+}
+
+// NotifyBudget sets the monthly budget that budget_exceeded notifications
+// watch; an amount of "off" turns it off.
+func (c *CLI) NotifyBudget(amount, currency string) error {
+	subSvc, err := c.service()
+	if err != nil {
+		return err
+	}
+
+	var budget services.Budget
+	if amount != "off" {
+		var err error
+		budget, err = services.ParseBudget(amount, currency)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := subSvc.SetBudget(budget); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Budget set to %s\n", budget)
+	return nil
+}
+
+func (c *CLI) TemplateList() error {
+	subSvc, err := c.service()
+	if err != nil {
+		return err
+	}
+
+	type templateSource struct {
+		Channel  string `json:"channel"`
+		Event    string `json:"event"`
+		Template string `json:"template"`
+	}
+	var records []templateSource
+	t := table{headers: []string{"Channel", "Event", "Template"}}
+	for _, channel := range services.Channels {
+		for _, event := range services.EventTypes {
+			source := "built-in"
+			if _, custom, err := subSvc.MessageTemplate(channel, event); err != nil {
+				source = "none"
+			} else if custom {
+				source = "custom"
+			}
+			records = append(records, templateSource{channel, string(event), source})
+			t.add(channel, string(event), source)
+		}
+	}
+	t.value = records
+	return c.print(t)
+}
+
+func (c *CLI) TemplateShow(channel, event string) error {
+	subSvc, err := c.service()
+	if err != nil {
+		return err
+	}
+
+	eventType, err := services.ParseEventType(event)
+	if err != nil {
+		return err
+	}
+
+	src, _, err := subSvc.MessageTemplate(channel, eventType)
+	if err != nil {
+		return err
+	}
+	fmt.Print(src)
+	return nil
+}
+
+// TemplateSet reads a template from path ("-" for stdin) and stores it.
+func (c *CLI) TemplateSet(channel, event, path string) error {
+	subSvc, err := c.service()
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	if path == "-" {
+		body, err = io.ReadAll(os.Stdin)
+	} else {
+		body, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read template: %w", err)
+	}
+
+	if err := subSvc.SetMessageTemplate(channel, event, string(body)); err != nil {
+		return err
+	}
+	fmt.Println("✓ Template saved")
+	return nil
+}
+
+func (c *CLI) TemplateReset(channel, event string) error {
+	subSvc, err := c.service()
+	if err != nil {
+		return err
+	}
+
+	if err := subSvc.ResetMessageTemplate(channel, event); err != nil {
+		return err
+	}
+	fmt.Println("✓ Template reset to built-in")
+	return nil
+}
+
+func (c *CLI) TemplatePreview(channel, event, idStr string) error {
+	subSvc, err := c.service()
+	if err != nil {
+		return err
+	}
+
+	var id uint64
+	if idStr != "" {
+		var err error
+		id, err = strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid subscription ID: %w", err)
+		}
+	}
+
+	text, err := subSvc.PreviewMessage(channel, event, uint(id))
+	if err != nil {
+		return err
+	}
+	fmt.Println(text)
+	return nil
+}
+
+func (c *CLI) Import(path, mapping string, dryRun bool) error {
+	subSvc, err := c.subscriptions()
+	if err != nil {
+		return err
+	}
+
+	columns, err := services.ParseColumnMapping(mapping)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open CSV file: %w", err)
+	}
+	defer f.Close()
+
+	result, err := subSvc.ImportCSV(f, services.ImportOptions{Mapping: columns, DryRun: dryRun})
+	if err != nil {
+		return err
+	}
+
+	type importRow struct {
+		Line   int    `json:"line"`
+		Name   string `json:"name"`
+		Status string `json:"status"`
+		Error  string `json:"error,omitempty"`
+	}
+	records := make([]importRow, len(result.Rows))
+	t := table{headers: []string{"Line", "Name", "Status", "Error"}}
+	for i, row := range result.Rows {
+		var errStr string
+		if row.Err != nil {
+			errStr = row.Err.Error()
+		}
+		records[i] = importRow{row.Line, row.Name, row.Status, errStr}
+		t.add(strconv.Itoa(row.Line), row.Name, row.Status, errStr)
+	}
+	t.value = struct {
+		Rows       []importRow `json:"rows"`
+		Added      int         `json:"added"`
+		Duplicates int         `json:"duplicates"`
+		Invalid    int         `json:"invalid"`
+		DryRun     bool        `json:"dry_run"`
+	}{records, result.Added, result.Duplicates, result.Invalid, dryRun}
+	if err := c.print(t); err != nil {
+		return err
+	}
+
+	if c.structured() {
+		return nil
+	}
+	if dryRun {
+		fmt.Printf("\nDry run: %d would be added, %d duplicates, %d invalid\n", result.Added, result.Duplicates, result.Invalid)
+		return nil
+	}
+	fmt.Printf("\n✓ Imported %d subscriptions (%d duplicates skipped, %d invalid)\n", result.Added, result.Duplicates, result.Invalid)
+	return nil
+}
+
+// Export writes all subscriptions in format to path, or stdout when path is empty.
+func (c *CLI) Export(format, path string) error {
+	subSvc, err := c.subscriptions()
+	if err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create export file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	switch format {
+	case "csv":
+		return subSvc.ExportCSV(out)
+	case "ics":
+		return subSvc.ExportICS(out)
+	default:
+		return fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// Backup writes a JSON backup to path, or stdout when path is empty.
+func (c *CLI) Backup(path string) error {
+	subSvc, err := c.subscriptions()
+	if err != nil {
+		return err
+	}
+
+	if path == "" {
+		return subSvc.WriteBackup(os.Stdout)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer f.Close()
+
+	if err := subSvc.WriteBackup(f); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Backup written to %s\n", path)
 	return nil
 }
 
+func (c *CLI) Restore(path, policyStr string) error {
+	subSvc, err := c.subscriptions()
+	if err != nil {
+		return err
+	}
+
+	policy, err := services.ParseConflictPolicy(policyStr)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open backup file: %w", err)
+	}
+	defer f.Close()
+
+	result, err := subSvc.Restore(f, policy)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Restore complete: %d created, %d updated, %d renamed, %d skipped, %d unchanged\n",
+		result.Created, result.Updated, result.Renamed, result.Skipped, result.Unchanged)
+	return nil
+}
+
+func (c *CLI) snapshots() (*snapshot.Manager, error) {
+	db, err := c.database()
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.NewManager(db, c.cfg.SnapshotDir, c.cfg.SnapshotDaily, c.cfg.SnapshotWeekly), nil
+}
+
+func (c *CLI) SnapshotList() error {
+	mgr, err := c.snapshots()
+	if err != nil {
+		return err
+	}
+
+	snaps, err := mgr.List()
+	if err != nil {
+		return err
+	}
+
+	if len(snaps) == 0 && c.output == OutputTable {
+		fmt.Printf("No snapshots found in %s\n", c.cfg.SnapshotDir)
+		return nil
+	}
+
+	type snapshotRecord struct {
+		Name      string    `json:"name"`
+		CreatedAt time.Time `json:"created_at"`
+		Size      int64     `json:"size"`
+	}
+	records := make([]snapshotRecord, len(snaps))
+	t := table{headers: []string{"Name", "Created", "Size"}}
+	for i, snap := range snaps {
+		records[i] = snapshotRecord{snap.Name, snap.CreatedAt, snap.Size}
+		t.add(snap.Name, snap.CreatedAt.In(utils.Location()).Format("02-01-2006 15:04:05"), strconv.FormatInt(snap.Size, 10))
+	}
+	t.value = records
+	return c.print(t)
+}
+
+func (c *CLI) SnapshotCreate() error {
+	mgr, err := c.snapshots()
+	if err != nil {
+		return err
+	}
+
+	snap, err := mgr.Create()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✓ Snapshot %s created\n", snap.Name)
+	return nil
+}
+
+// SnapshotRestore replaces the database with a snapshot. A snapshot of the
+// current database is taken first so the restore can be undone.
+func (c *CLI) SnapshotRestore(name string) error {
+	db, err := c.database()
+	if err != nil {
+		return err
+	}
+
+	if db.Driver() != database.DriverSQLite {
+		return fmt.Errorf("snapshots are only supported for SQLite databases")
+	}
+
+	mgr, err := c.snapshots()
+	if err != nil {
+		return err
+	}
+
+	snap, err := mgr.Find(name)
+	if err != nil {
+		return err
+	}
+
+	current, err := mgr.Create()
+	if err != nil {
+		return fmt.Errorf("failed to snapshot current database: %w", err)
+	}
+	fmt.Printf("Current database saved as %s\n", current.Name)
+
+	if err := db.Close(); err != nil {
+		return err
+	}
+	if err := snapshot.Restore(snap.Path, c.cfg.DBPath); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Restored %s from %s\n", c.cfg.DBPath, snap.Name)
+	return nil
+}
+
+func (c *CLI) MigrateStatus() error {
+	db, err := c.database()
+	if err != nil {
+		return err
+	}
+
+	status, err := db.MigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	type migrationRecord struct {
+		Version   int        `json:"version"`
+		Name      string     `json:"name"`
+		AppliedAt *time.Time `json:"applied_at"`
+	}
+	records := make([]migrationRecord, len(status))
+	t := table{headers: []string{"Version", "Name", "Applied"}}
+	for i, s := range status {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.In(utils.Location()).Format("02-01-2006 15:04:05")
+		}
+		records[i] = migrationRecord{s.Version, s.Name, s.AppliedAt}
+		t.add(strconv.Itoa(s.Version), s.Name, applied)
+	}
+	t.value = records
+	if err := c.print(t); err != nil {
+		return err
+	}
+
+	return db.CheckSchemaVersion()
+}
+
+func (c *CLI) MigrateUp() error {
+	db, err := c.database()
+	if err != nil {
+		return err
+	}
+
+	applied, err := db.MigrateUp()
+	for _, m := range applied {
+		fmt.Printf("✓ Applied %d_%s\n", m.Version, m.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Println("Database is up to date")
+	}
+	return nil
+}
+
+// MigrateDown reverts the latest steps migrations. Reverting can drop
+// tables, so a SQLite database is snapshotted first.
+func (c *CLI) MigrateDown(steps int) error {
+	db, err := c.database()
+	if err != nil {
+		return err
+	}
+
+	if db.Driver() == database.DriverSQLite {
+		mgr, err := c.snapshots()
+		if err != nil {
+			return err
+		}
+		snap, err := mgr.Create()
+		if err != nil {
+			return fmt.Errorf("failed to snapshot database before migrating: %w", err)
+		}
+		fmt.Printf("Current database saved as %s\n", snap.Name)
+	}
+
+	reverted, err := db.MigrateDown(steps)
+	for _, m := range reverted {
+		fmt.Printf("✓ Reverted %d_%s\n", m.Version, m.Name)
+	}
+	return err
+}
+
+// Audit shows the newest audit events: logins, failed logins and lockouts
+// on the web UI.
+func (c *CLI) Audit(limit int) error {
+	db, err := c.database()
+	if err != nil {
+		return err
+	}
+
+	events, err := services.NewAuditLog(db).Recent(limit)
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 && c.output == OutputTable {
+		fmt.Println("No audit events recorded")
+		return nil
+	}
+
+	type auditRecord struct {
+		Time     time.Time `json:"time"`
+		Action   string    `json:"action"`
+		Username string    `json:"username"`
+		IP       string    `json:"ip"`
+		Detail   string    `json:"detail"`
+	}
+	records := make([]auditRecord, len(events))
+	t := table{headers: []string{"Time", "Action", "User", "IP", "Detail"}}
+	for i, e := range events {
+		records[i] = auditRecord{e.CreatedAt, e.Action, e.Username, e.IP, e.Detail}
+		t.add(e.CreatedAt.In(utils.Location()).Format("02-01-2006 15:04:05"), e.Action, e.Username, e.IP, e.Detail)
+	}
+	t.value = records
+	return c.print(t)
+}
+
+// TUI runs the full-screen terminal interface on the local database.
+func (c *CLI) TUI() error {
+	subSvc, err := c.service()
+	if err != nil {
+		return err
+	}
+	return tui.Run(tui.New(subSvc), os.Stdin, os.Stdout)
+}
+
 func (c *CLI) Health() error {
-	if err := c.tgSvc.HealthCheck(); err != nil {
+	remote, err := c.server()
+	if err != nil {
+		return err
+	}
+	if remote != nil {
+		if err := remote.Health(); err != nil {
+			return err
+		}
+		fmt.Printf("✓ %s is reachable and accepts the API token\n", remote.Server())
+		return nil
+	}
+
+	tg, err := c.telegram()
+	if err != nil {
+		return err
+	}
+
+	if err := tg.HealthCheck(); err != nil {
 		return fmt.Errorf("Telegram bot health check failed: %w", err)
 	}
 	fmt.Println("✓ Telegram bot is healthy")
 	return nil
 }
+
+// twoFactorUser is the user a 2fa command is for: the one given, or the web
+// UI's user.
+func (c *CLI) twoFactorUser(username string) (string, error) {
+	if username == "" {
+		username = c.cfg.WebUsername
+	}
+	if username == "" {
+		return "", fmt.Errorf("no username given and WEB_USERNAME is not set")
+	}
+	return username, nil
+}
+
+// TwoFactorStatus shows whether username has two-factor authentication.
+func (c *CLI) TwoFactorStatus(username string) error {
+	username, err := c.twoFactorUser(username)
+	if err != nil {
+		return err
+	}
+	db, err := c.database()
+	if err != nil {
+		return err
+	}
+
+	status, err := services.NewTwoFactorService(db).Status(username)
+	if err != nil {
+		return err
+	}
+
+	type twoFactorRecord struct {
+		Username          string     `json:"username"`
+		Status            string     `json:"status"`
+		EnabledAt         *time.Time `json:"enabled_at,omitempty"`
+		RecoveryCodesLeft int        `json:"recovery_codes_left"`
+	}
+	record := twoFactorRecord{Username: username, Status: "off"}
+	since := ""
+	switch {
+	case status.Enabled:
+		record.Status = "on"
+		record.EnabledAt = &status.EnabledAt
+		record.RecoveryCodesLeft = status.RecoveryCodesLeft
+		since = status.EnabledAt.In(utils.Location()).Format("02-01-2006 15:04:05")
+	case status.Pending:
+		record.Status = "pending"
+	}
+	t := table{headers: []string{"User", "Status", "Since", "Recovery Codes"}}
+	t.add(username, record.Status, since, strconv.Itoa(record.RecoveryCodesLeft))
+	t.value = record
+	return c.print(t)
+}
+
+// TwoFactorReset removes username's two-factor authentication, for a user
+// who has lost both their authenticator app and their recovery codes. They
+// can then log in with their password alone and set it up again.
+func (c *CLI) TwoFactorReset(username string) error {
+	username, err := c.twoFactorUser(username)
+	if err != nil {
+		return err
+	}
+	db, err := c.database()
+	if err != nil {
+		return err
+	}
+
+	removed, err := services.NewTwoFactorService(db).Disable(username)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		fmt.Printf("%s does not have two-factor authentication\n", username)
+		return nil
+	}
+	services.NewAuditLog(db).Record(services.AuditTwoFactorReset, username, "", "reset from the command line")
+	fmt.Printf("✓ Two-factor authentication reset for %s; they can now log in with their password alone\n", username)
+	return nil
+}
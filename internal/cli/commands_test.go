@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/config"
+	"github.com/berkaycubuk/subtrack/internal/database"
+)
+
+func TestCLI_Digest(t *testing.T) {
+	db, err := database.New(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without Telegram settings, so queued digests stay in the outbox.
+	c := &CLI{cfg: &config.Config{}, open: database.New, db: db, resolved: true}
+	sub := &database.Subscription{Name: "Netflix", Price: 9.99, Currency: "USD", Cycle: "monthly", PaymentDate: time.Now()}
+	if err := db.CreateSubscription(sub); err != nil {
+		t.Fatal(err)
+	}
+
+	pending := func() int {
+		t.Helper()
+		msgs, err := db.GetPendingOutboxMessages(10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(msgs)
+	}
+
+	if err := c.Digest("weekly", false); err != nil {
+		t.Fatalf("Digest() error = %v", err)
+	}
+	if n := pending(); n != 0 {
+		t.Errorf("previewing queued %d messages, want none", n)
+	}
+
+	if err := c.Digest("weekly", true); err != nil {
+		t.Fatalf("Digest() with send error = %v", err)
+	}
+	if n := pending(); n != 1 {
+		t.Errorf("sending queued %d messages, want 1", n)
+	}
+}
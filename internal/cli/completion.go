@@ -0,0 +1,275 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Shells that completion scripts can be generated for.
+var CompletionShells = []string{"bash", "zsh", "fish"}
+
+// completionEntry is what can follow one command: its subcommands, or its
+// flags and the values of flags that take a fixed set of them.
+type completionEntry struct {
+	path        string
+	subcommands []*Command
+	flags       []*flag.Flag
+}
+
+func (cmd *Command) completionEntries() []completionEntry {
+	var entries []completionEntry
+	var walk func(c *Command, path string)
+	walk = func(c *Command, path string) {
+		entries = append(entries, completionEntry{path: path, subcommands: c.Subcommands, flags: c.flags()})
+		for _, sub := range c.Subcommands {
+			walk(sub, strings.TrimSpace(path+" "+sub.Name))
+		}
+	}
+	walk(cmd, "")
+	return entries
+}
+
+// WriteCompletion writes a completion script for shell that completes cmd's
+// subcommands, flags and flag values for the program called name.
+func (cmd *Command) WriteCompletion(w io.Writer, shell, name string) error {
+	cmd.link()
+	switch shell {
+	case "bash":
+		return cmd.writeBash(w, name)
+	case "zsh":
+		return cmd.writeZsh(w, name)
+	case "fish":
+		return cmd.writeFish(w, name)
+	default:
+		return fmt.Errorf("unsupported shell %q (use %s)", shell, strings.Join(CompletionShells, ", "))
+	}
+}
+
+// shellIdent turns a program name into a shell function name.
+func shellIdent(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func flagChoices(f *flag.Flag) []string {
+	if c, ok := f.Value.(*choiceValue); ok {
+		return c.choices
+	}
+	return nil
+}
+
+func subcommandPaths(entries []completionEntry) []string {
+	var paths []string
+	for _, e := range entries {
+		if e.path != "" {
+			paths = append(paths, e.path)
+		}
+	}
+	return paths
+}
+
+func (cmd *Command) writeBash(w io.Writer, name string) error {
+	fn := "_" + shellIdent(name)
+	entries := cmd.completionEntries()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# bash completion for %s\n", name)
+	fmt.Fprintf(&b, "# Load with: source <(%s completion bash)\n\n", name)
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	b.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	b.WriteString("    local cmdpath=\"\" next words i\n")
+	b.WriteString("    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	b.WriteString("        next=\"${cmdpath:+$cmdpath }${COMP_WORDS[i]}\"\n")
+	b.WriteString("        case \"$next\" in\n")
+	var patterns []string
+	for _, path := range subcommandPaths(entries) {
+		patterns = append(patterns, fmt.Sprintf("%q", path))
+	}
+	fmt.Fprintf(&b, "            %s) cmdpath=\"$next\" ;;\n", strings.Join(patterns, "|"))
+	b.WriteString("        esac\n")
+	b.WriteString("    done\n\n")
+
+	b.WriteString("    case \"$cmdpath:$prev\" in\n")
+	for _, e := range entries {
+		for _, f := range e.flags {
+			if isBoolFlag(f) {
+				continue
+			}
+			if choices := flagChoices(f); choices != nil {
+				fmt.Fprintf(&b, "        %q) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", e.path+":--"+f.Name, strings.Join(choices, " "))
+			} else {
+				fmt.Fprintf(&b, "        %q) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", e.path+":--"+f.Name)
+			}
+		}
+	}
+	b.WriteString("    esac\n\n")
+
+	b.WriteString("    case \"$cmdpath\" in\n")
+	for _, e := range entries {
+		var words []string
+		for _, sub := range e.subcommands {
+			words = append(words, sub.Name)
+		}
+		for _, f := range e.flags {
+			words = append(words, "--"+f.Name)
+		}
+		if e.path == "" {
+			words = append(words, "--help")
+		}
+		fmt.Fprintf(&b, "        %q) words=%q ;;\n", e.path, strings.Join(words, " "))
+	}
+	b.WriteString("    esac\n")
+	b.WriteString("    COMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n")
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "complete -o default -F %s %s\n", fn, name)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// zshQuote quotes s for a single-quoted zsh word.
+func zshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// zshDescribe escapes a "name:description" pair for _describe.
+func zshDescribe(name, desc string) string {
+	return zshQuote(strings.ReplaceAll(name, ":", `\:`) + ":" + desc)
+}
+
+func (cmd *Command) writeZsh(w io.Writer, name string) error {
+	fn := "_" + shellIdent(name)
+	entries := cmd.completionEntries()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "#compdef %s\n", name)
+	fmt.Fprintf(&b, "# zsh completion for %s\n", name)
+	fmt.Fprintf(&b, "# Load with: source <(%s completion zsh), or save as _%s in your $fpath\n\n", name, name)
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	b.WriteString("    # Not \"path\", which zsh ties to $PATH.\n")
+	b.WriteString("    local cmdpath=\"\" next i\n")
+	b.WriteString("    local -a candidates\n")
+	b.WriteString("    for ((i = 2; i < CURRENT; i++)); do\n")
+	b.WriteString("        next=\"${cmdpath:+$cmdpath }${words[i]}\"\n")
+	b.WriteString("        case \"$next\" in\n")
+	var zshPatterns []string
+	for _, path := range subcommandPaths(entries) {
+		zshPatterns = append(zshPatterns, zshQuote(path))
+	}
+	fmt.Fprintf(&b, "            %s) cmdpath=\"$next\" ;;\n", strings.Join(zshPatterns, "|"))
+	b.WriteString("        esac\n")
+	b.WriteString("    done\n\n")
+
+	b.WriteString("    case \"$cmdpath:${words[CURRENT-1]}\" in\n")
+	for _, e := range entries {
+		for _, f := range e.flags {
+			if isBoolFlag(f) {
+				continue
+			}
+			if choices := flagChoices(f); choices != nil {
+				quoted := make([]string, len(choices))
+				for i, c := range choices {
+					quoted[i] = zshQuote(c)
+				}
+				fmt.Fprintf(&b, "        %s) compadd -- %s; return ;;\n", zshQuote(e.path+":--"+f.Name), strings.Join(quoted, " "))
+			} else {
+				fmt.Fprintf(&b, "        %s) _files; return ;;\n", zshQuote(e.path+":--"+f.Name))
+			}
+		}
+	}
+	b.WriteString("    esac\n\n")
+
+	b.WriteString("    case \"$cmdpath\" in\n")
+	for _, e := range entries {
+		var described []string
+		for _, sub := range e.subcommands {
+			described = append(described, zshDescribe(sub.Name, sub.Summary))
+		}
+		for _, f := range e.flags {
+			described = append(described, zshDescribe("--"+f.Name, f.Usage))
+		}
+		if len(described) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "        %s) candidates=(%s) ;;\n", zshQuote(e.path), strings.Join(described, " "))
+	}
+	b.WriteString("    esac\n")
+	b.WriteString("    if (( ${#candidates} )); then\n")
+	b.WriteString("        _describe 'command or flag' candidates\n")
+	b.WriteString("    else\n")
+	b.WriteString("        _files\n")
+	b.WriteString("    fi\n")
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "if [[ \"${funcstack[1]}\" == %s ]]; then\n", fn)
+	fmt.Fprintf(&b, "    %s \"$@\"\n", fn)
+	b.WriteString("else\n")
+	fmt.Fprintf(&b, "    compdef %s %s\n", fn, name)
+	b.WriteString("fi\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// fishQuote quotes s for a single-quoted fish word.
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+func (cmd *Command) writeFish(w io.Writer, name string) error {
+	fn := "__" + shellIdent(name)
+	entries := cmd.completionEntries()
+
+	paths := subcommandPaths(entries)
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = fishQuote(p)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# fish completion for %s\n", name)
+	fmt.Fprintf(&b, "# Load with: %s completion fish | source\n\n", name)
+	fmt.Fprintf(&b, "function %s_path\n", fn)
+	fmt.Fprintf(&b, "    set -l paths %s\n", strings.Join(quoted, " "))
+	b.WriteString("    set -l cmdpath ''\n")
+	b.WriteString("    for token in (commandline -opc)[2..-1]\n")
+	b.WriteString("        set -l next (string trim -- \"$cmdpath $token\")\n")
+	b.WriteString("        if contains -- $next $paths\n")
+	b.WriteString("            set cmdpath $next\n")
+	b.WriteString("        end\n")
+	b.WriteString("    end\n")
+	b.WriteString("    echo $cmdpath\n")
+	b.WriteString("end\n\n")
+	fmt.Fprintf(&b, "function %s_at\n", fn)
+	fmt.Fprintf(&b, "    set -l cmdpath (%s_path)\n", fn)
+	b.WriteString("    test \"$cmdpath\" = \"$argv[1]\"\n")
+	b.WriteString("end\n\n")
+
+	for _, e := range entries {
+		cond := fishQuote(fmt.Sprintf("%s_at %s", fn, fishQuote(e.path)))
+		for _, sub := range e.subcommands {
+			fmt.Fprintf(&b, "complete -c %s -f -n %s -a %s -d %s\n", name, cond, fishQuote(sub.Name), fishQuote(sub.Summary))
+		}
+		for _, f := range e.flags {
+			line := fmt.Sprintf("complete -c %s -n %s -l %s -d %s", name, cond, f.Name, fishQuote(f.Usage))
+			switch {
+			case isBoolFlag(f):
+				line += " -f"
+			case flagChoices(f) != nil:
+				line += " -x -a " + fishQuote(strings.Join(flagChoices(f), " "))
+			default:
+				line += " -r -F"
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats for commands that print records.
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+	OutputCSV   = "csv"
+)
+
+var OutputFormats = []string{OutputTable, OutputJSON, OutputYAML, OutputCSV}
+
+// OutputFlag defines the --output flag.
+func OutputFlag(fs *flag.FlagSet) *string {
+	return Choice(fs, "output", OutputTable, OutputFormats, "output format")
+}
+
+// SetOutput selects the format of commands that print records.
+func (c *CLI) SetOutput(format string) {
+	c.output = format
+}
+
+// structured reports whether records are printed as JSON or YAML, in which
+// case commands print nothing besides them.
+func (c *CLI) structured() bool {
+	return c.output == OutputJSON || c.output == OutputYAML
+}
+
+// table is a command's records. Table and CSV output print the rows; JSON
+// and YAML output print value, which keeps every field.
+type table struct {
+	headers []string
+	rows    [][]string
+	value   any
+}
+
+func (t *table) add(row ...string) {
+	t.rows = append(t.rows, row)
+}
+
+func (c *CLI) print(t table) error {
+	return writeTable(c.stdout, c.output, t)
+}
+
+func writeTable(w io.Writer, format string, t table) error {
+	switch format {
+	case OutputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(t.value)
+
+	case OutputYAML:
+		return writeYAML(w, t.value)
+
+	case OutputCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(t.headers)
+		cw.WriteAll(t.rows)
+		return cw.Error()
+
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+		rules := make([]string, len(t.headers))
+		for i, h := range t.headers {
+			rules[i] = strings.Repeat("-", len(h))
+		}
+		fmt.Fprintln(tw, strings.Join(t.headers, "\t"))
+		fmt.Fprintln(tw, strings.Join(rules, "\t"))
+		for _, row := range t.rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+}
+
+// writeYAML prints v with the same field names and order as the JSON output
+// by going through JSON, which YAML can parse.
+func writeYAML(w io.Writer, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(raw, &node); err != nil {
+		return err
+	}
+	blockStyle(&node)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// blockStyle drops the flow style and quoting parsed from JSON so the
+// encoder picks plain YAML.
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
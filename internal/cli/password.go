@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/berkaycubuk/subtrack/internal/password"
+)
+
+// HashPassword reads a password and writes its hash for WEB_PASSWORD_HASH
+// to out: argon2id, or bcrypt if useBcrypt is set. On a terminal the
+// password is asked for twice without echoing it; otherwise the first line
+// of in is the password, so it can be piped in.
+func HashPassword(in io.Reader, out io.Writer, useBcrypt bool) error {
+	pw, err := readPassword(in)
+	if err != nil {
+		return err
+	}
+	if pw == "" {
+		return errors.New("the password is empty")
+	}
+
+	hash := password.Hash
+	if useBcrypt {
+		hash = password.HashBcrypt
+	}
+	h, err := hash(pw)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, h)
+	return nil
+}
+
+func readPassword(in io.Reader) (string, error) {
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fmt.Fprint(os.Stderr, "Password: ")
+		pw, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprint(os.Stderr, "Repeat password: ")
+		again, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if string(pw) != string(again) {
+			return "", errors.New("the passwords do not match")
+		}
+		return string(pw), nil
+	}
+
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
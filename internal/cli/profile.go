@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/berkaycubuk/subtrack/internal/client"
+)
+
+// editProfiles loads the profile file, lets edit change it and saves it.
+func editProfiles(edit func(p *client.Profiles) error) error {
+	path, err := client.ProfilesPath()
+	if err != nil {
+		return err
+	}
+	profiles, err := client.LoadProfiles(path)
+	if err != nil {
+		return err
+	}
+	if err := edit(profiles); err != nil {
+		return err
+	}
+	return profiles.Save(path)
+}
+
+func (c *CLI) ProfileList() error {
+	path, err := client.ProfilesPath()
+	if err != nil {
+		return err
+	}
+	profiles, err := client.LoadProfiles(path)
+	if err != nil {
+		return err
+	}
+
+	if len(profiles.Profiles) == 0 && c.output == OutputTable {
+		fmt.Printf("No profiles in %s; commands use the local database\n", path)
+		return nil
+	}
+
+	type profileRecord struct {
+		Name    string `json:"name"`
+		Server  string `json:"server"`
+		Current bool   `json:"current"`
+	}
+	var records []profileRecord
+	t := table{headers: []string{"", "Name", "Server"}}
+	for _, name := range profiles.Names() {
+		current := name == profiles.Current
+		records = append(records, profileRecord{name, profiles.Profiles[name].Server, current})
+		t.add(map[bool]string{true: "*"}[current], name, profiles.Profiles[name].Server)
+	}
+	t.value = records
+	return c.print(t)
+}
+
+// ProfileSet adds or replaces a profile. A token of "-" is read from stdin,
+// which keeps it out of the shell history.
+func (c *CLI) ProfileSet(name, server, token string) error {
+	if token == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read token: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	if err := editProfiles(func(p *client.Profiles) error {
+		return p.Set(name, client.Profile{Server: server, Token: token})
+	}); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Profile %s saved\n", name)
+	return nil
+}
+
+// ProfileUse makes commands run against a profile by default, or against the
+// local database when name is empty or "local".
+func (c *CLI) ProfileUse(name string) error {
+	if err := editProfiles(func(p *client.Profiles) error {
+		return p.Use(name)
+	}); err != nil {
+		return err
+	}
+	if name == "" || name == client.LocalProfile {
+		fmt.Println("✓ Commands now use the local database")
+	} else {
+		fmt.Printf("✓ Commands now use profile %s\n", name)
+	}
+	return nil
+}
+
+func (c *CLI) ProfileRemove(name string) error {
+	if err := editProfiles(func(p *client.Profiles) error {
+		return p.Remove(name)
+	}); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Profile %s removed\n", name)
+	return nil
+}
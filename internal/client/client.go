@@ -0,0 +1,226 @@
+// Package client talks to a running subtrack service over its JSON API, so
+// the CLI can manage a deployed instance without access to its database.
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/api"
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/services"
+)
+
+const timeout = 30 * time.Second
+
+// Client calls the API of the service at a base URL with an API token. Its
+// methods mirror the SubscriptionService methods the CLI uses.
+type Client struct {
+	base  *url.URL
+	token string
+	http  *http.Client
+}
+
+func New(server, token string) (*Client, error) {
+	base, err := url.Parse(strings.TrimSuffix(server, "/"))
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("invalid server URL %q (use http://host or https://host)", server)
+	}
+	if token == "" {
+		return nil, fmt.Errorf("an API token is required for %s", server)
+	}
+	return &Client{base: base, token: token, http: &http.Client{Timeout: timeout}}, nil
+}
+
+// Server is the base URL the client talks to.
+func (c *Client) Server() string {
+	return c.base.String()
+}
+
+// do sends a request to path under the API prefix and returns the response
+// if it succeeded. Failed responses are turned into errors carrying the
+// server's message.
+func (c *Client) do(method, path string, query url.Values, contentType string, body io.Reader) (*http.Response, error) {
+	u := *c.base
+	u.Path += api.Prefix + path
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach %s: %w", c.Server(), err)
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	var apiErr api.Error
+	if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+		return nil, fmt.Errorf("%s %s: %s", method, u.Path, resp.Status)
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("%s rejected the API token: %s", c.Server(), apiErr.Error)
+	}
+	return nil, errors.New(apiErr.Error)
+}
+
+// call sends in as JSON, if it is not nil, and decodes the response into out,
+// if that is not nil.
+func (c *Client) call(method, path string, query url.Values, in, out any) error {
+	if in == nil {
+		return c.send(method, path, query, "", nil, out)
+	}
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return c.send(method, path, query, "application/json", bytes.NewReader(data), out)
+}
+
+// send sends body and decodes the response into out, if that is not nil.
+func (c *Client) send(method, path string, query url.Values, contentType string, body io.Reader, out any) error {
+	resp, err := c.do(method, path, query, contentType, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response from %s: %w", c.Server(), err)
+	}
+	return nil
+}
+
+// download copies the response body of a GET to w.
+func (c *Client) download(path string, w io.Writer) error {
+	resp, err := c.do(http.MethodGet, path, nil, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// Health checks that the server is reachable and accepts the token.
+func (c *Client) Health() error {
+	return c.call(http.MethodGet, "/health", nil, nil, nil)
+}
+
+func (c *Client) AddSubscription(name, price, currency, cycle, paymentDate, category string) error {
+	return c.call(http.MethodPost, "/subscriptions", nil, api.NewSubscription{
+		Name: name, Price: price, Currency: currency, Cycle: cycle, PaymentDate: paymentDate, Category: category,
+	}, nil)
+}
+
+func (c *Client) SearchSubscriptions(q database.SubscriptionQuery) (*database.SubscriptionPage, error) {
+	query := services.EncodeListQuery(q)
+	if q.Cursor != "" {
+		query.Set("cursor", q.Cursor)
+	}
+
+	var list api.SubscriptionList
+	if err := c.call(http.MethodGet, "/subscriptions", query, nil, &list); err != nil {
+		return nil, err
+	}
+	return &database.SubscriptionPage{Subscriptions: list.Subscriptions, NextCursor: list.NextCursor}, nil
+}
+
+func (c *Client) GetSubscription(id uint) (*database.Subscription, error) {
+	var sub database.Subscription
+	if err := c.call(http.MethodGet, subscriptionPath(id), nil, nil, &sub); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func (c *Client) UpdateSubscription(id uint, name, price, currency, cycle, paymentDate string) error {
+	return c.update(id, api.SubscriptionChanges{Name: name, Price: price, Currency: currency, Cycle: cycle, PaymentDate: paymentDate})
+}
+
+func (c *Client) SetSubscriptionCategory(id uint, category string) error {
+	return c.update(id, api.SubscriptionChanges{Category: &category})
+}
+
+func (c *Client) SetSubscriptionStatus(id uint, status string) error {
+	return c.update(id, api.SubscriptionChanges{Status: status})
+}
+
+func (c *Client) SetTrialEndDate(id uint, date string) error {
+	return c.update(id, api.SubscriptionChanges{TrialEndDate: &date})
+}
+
+func (c *Client) update(id uint, changes api.SubscriptionChanges) error {
+	return c.call(http.MethodPatch, subscriptionPath(id), nil, changes, nil)
+}
+
+func (c *Client) DeleteSubscription(id uint) error {
+	return c.call(http.MethodDelete, subscriptionPath(id), nil, nil, nil)
+}
+
+func subscriptionPath(id uint) string {
+	return "/subscriptions/" + strconv.FormatUint(uint64(id), 10)
+}
+
+func (c *Client) ImportCSV(r io.Reader, opts services.ImportOptions) (*services.ImportResult, error) {
+	query := url.Values{}
+	var pairs []string
+	for _, field := range slices.Sorted(maps.Keys(opts.Mapping)) {
+		pairs = append(pairs, field+"="+opts.Mapping[field])
+	}
+	if len(pairs) > 0 {
+		query.Set("map", strings.Join(pairs, ","))
+	}
+	if opts.DryRun {
+		query.Set("dry_run", "true")
+	}
+
+	var result api.ImportResult
+	if err := c.send(http.MethodPost, "/import", query, "text/csv", r, &result); err != nil {
+		return nil, err
+	}
+	return result.Result(), nil
+}
+
+func (c *Client) ExportCSV(w io.Writer) error {
+	return c.download("/export.csv", w)
+}
+
+func (c *Client) ExportICS(w io.Writer) error {
+	return c.download("/export.ics", w)
+}
+
+func (c *Client) WriteBackup(w io.Writer) error {
+	return c.download("/backup", w)
+}
+
+func (c *Client) Restore(r io.Reader, policy services.ConflictPolicy) (*services.RestoreResult, error) {
+	var result api.RestoreResult
+	query := url.Values{"on_conflict": {string(policy)}}
+	if err := c.send(http.MethodPost, "/restore", query, "application/json", r, &result); err != nil {
+		return nil, err
+	}
+	return result.Result(), nil
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/services"
+	"github.com/berkaycubuk/subtrack/internal/utils"
+	"github.com/berkaycubuk/subtrack/internal/web"
+)
+
+func setupServer(t *testing.T, apiToken string) (*Client, *services.SubscriptionService) {
+	t.Helper()
+	store := database.NewMemoryStore()
+	subSvc := services.NewSubscriptionService(store, nil)
+	srv := httptest.NewServer(web.NewServer(subSvc, services.NewReportService(store), services.NewAuditLog(store), services.NewTwoFactorService(store), web.Options{Username: "admin", Password: "secret", APIToken: apiToken}).Handler())
+	t.Cleanup(srv.Close)
+
+	c, err := New(srv.URL+"/", "token")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return c, subSvc
+}
+
+func TestClient_Subscriptions(t *testing.T) {
+	c, subSvc := setupServer(t, "token")
+
+	if err := c.Health(); err != nil {
+		t.Fatalf("Health() error = %v", err)
+	}
+
+	for _, name := range []string{"Netflix", "Spotify"} {
+		if err := c.AddSubscription(name, "9.99", "USD", "monthly", "15-12-2030", "Streaming"); err != nil {
+			t.Fatalf("AddSubscription(%s) error = %v", name, err)
+		}
+	}
+	if err := c.AddSubscription("Bad", "9.99", "USD", "weekly", "15-12-2030", ""); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("AddSubscription(weekly) error = %v, want the service's validation error", err)
+	}
+
+	page, err := c.SearchSubscriptions(database.SubscriptionQuery{Sort: database.SortName, Limit: 1})
+	if err != nil || len(page.Subscriptions) != 1 || page.Subscriptions[0].Name != "Netflix" || page.NextCursor == "" {
+		t.Fatalf("SearchSubscriptions() = %+v, %v", page, err)
+	}
+	page, err = c.SearchSubscriptions(database.SubscriptionQuery{Sort: database.SortName, Limit: 1, Cursor: page.NextCursor})
+	if err != nil || len(page.Subscriptions) != 1 || page.Subscriptions[0].Name != "Spotify" || page.NextCursor != "" {
+		t.Fatalf("SearchSubscriptions(second page) = %+v, %v", page, err)
+	}
+	id := page.Subscriptions[0].ID
+
+	if err := c.UpdateSubscription(id, "", "12.50", "", "", ""); err != nil {
+		t.Fatalf("UpdateSubscription() error = %v", err)
+	}
+	if err := c.SetSubscriptionCategory(id, ""); err != nil {
+		t.Fatalf("SetSubscriptionCategory() error = %v", err)
+	}
+	if err := c.SetSubscriptionStatus(id, "paused"); err != nil {
+		t.Fatalf("SetSubscriptionStatus() error = %v", err)
+	}
+	if err := c.SetTrialEndDate(id, "01-12-2030"); err != nil {
+		t.Fatalf("SetTrialEndDate() error = %v", err)
+	}
+	sub, err := subSvc.GetSubscription(id)
+	if err != nil || sub.Price != 12.5 || sub.Category != "" || sub.Status != database.StatusPaused ||
+		sub.TrialEndDate == nil || utils.FormatDate(*sub.TrialEndDate) != "01-12-2030" {
+		t.Errorf("after updates = %+v, %v", sub, err)
+	}
+	if err := c.SetTrialEndDate(id, ""); err != nil {
+		t.Fatalf("SetTrialEndDate(\"\") error = %v", err)
+	}
+	if sub, _ := subSvc.GetSubscription(id); sub.TrialEndDate != nil {
+		t.Errorf("trial end date = %v after removing it", sub.TrialEndDate)
+	}
+
+	if err := c.SetSubscriptionStatus(9999, "paused"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("SetSubscriptionStatus(missing) error = %v", err)
+	}
+
+	var csv bytes.Buffer
+	if err := c.ExportCSV(&csv); err != nil || !strings.Contains(csv.String(), "Spotify,12.50") {
+		t.Errorf("ExportCSV() = %q, %v", csv.String(), err)
+	}
+
+	result, err := c.ImportCSV(strings.NewReader("Title,price,currency,cycle,payment_date\nHulu,7.99,USD,monthly,01-01-2031\nNetflix,1,USD,monthly,01-01-2031\n"),
+		services.ImportOptions{Mapping: map[string]string{"name": "Title"}, DryRun: true})
+	if err != nil || result.Added != 1 || result.Duplicates != 1 || !result.DryRun {
+		t.Errorf("ImportCSV(dry run) = %+v, %v", result, err)
+	}
+
+	var backup bytes.Buffer
+	if err := c.WriteBackup(&backup); err != nil {
+		t.Fatalf("WriteBackup() error = %v", err)
+	}
+	if err := c.DeleteSubscription(id); err != nil {
+		t.Fatalf("DeleteSubscription() error = %v", err)
+	}
+	restored, err := c.Restore(&backup, services.ConflictSkip)
+	if err != nil || restored.Created != 1 || restored.Unchanged != 1 {
+		t.Errorf("Restore() = %+v, %v", restored, err)
+	}
+}
+
+func TestClient_Auth(t *testing.T) {
+	c, _ := setupServer(t, "other")
+	if err := c.Health(); err == nil || !strings.Contains(err.Error(), "rejected the API token") {
+		t.Errorf("Health(wrong token) error = %v", err)
+	}
+
+	c, _ = setupServer(t, "")
+	if err := c.Health(); err == nil || !strings.Contains(err.Error(), "API_TOKEN") {
+		t.Errorf("Health(API disabled) error = %v", err)
+	}
+
+	for _, server := range []string{"", "subtrack.example.com", "ftp://subtrack.example.com"} {
+		if _, err := New(server, "token"); err == nil {
+			t.Errorf("New(%q) expected an error", server)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LocalProfile selects the local database even when a current profile is
+// set. It cannot be used as a profile name.
+const LocalProfile = "local"
+
+// Profile is a server the CLI can run commands against.
+type Profile struct {
+	Server string `yaml:"server"`
+	Token  string `yaml:"token"`
+}
+
+// Profiles is the profile file: the named servers and the one commands use
+// by default. Without a current profile commands use the local database.
+type Profiles struct {
+	Current  string             `yaml:"current,omitempty"`
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// ProfilesPath is the profile file: $SUBTRACK_CONFIG, or profiles.yaml in the
+// user's config directory.
+func ProfilesPath() (string, error) {
+	if path := os.Getenv("SUBTRACK_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the profile file: %w", err)
+	}
+	return filepath.Join(dir, "subtrack", "profiles.yaml"), nil
+}
+
+// LoadProfiles reads the profile file at path. A missing file has no
+// profiles.
+func LoadProfiles(path string) (*Profiles, error) {
+	p := &Profiles{}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("invalid profile file %s: %w", path, err)
+	}
+	if p.Profiles == nil {
+		p.Profiles = make(map[string]Profile)
+	}
+	return p, nil
+}
+
+// Save writes the profiles to path. The file holds API tokens, so only its
+// owner may read it.
+func (p *Profiles) Save(path string) error {
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".profiles-*.yaml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Names are the profile names in order.
+func (p *Profiles) Names() []string {
+	return slices.Sorted(maps.Keys(p.Profiles))
+}
+
+// Set adds or replaces the profile called name.
+func (p *Profiles) Set(name string, profile Profile) error {
+	if err := validName(name); err != nil {
+		return err
+	}
+	if _, err := New(profile.Server, profile.Token); err != nil {
+		return err
+	}
+	p.Profiles[name] = profile
+	return nil
+}
+
+// Remove deletes a profile, and stops using it if it is current.
+func (p *Profiles) Remove(name string) error {
+	if _, ok := p.Profiles[name]; !ok {
+		return fmt.Errorf("no profile called %q", name)
+	}
+	delete(p.Profiles, name)
+	if p.Current == name {
+		p.Current = ""
+	}
+	return nil
+}
+
+// Use makes name the current profile. An empty name or LocalProfile goes back
+// to the local database.
+func (p *Profiles) Use(name string) error {
+	if name == LocalProfile {
+		name = ""
+	}
+	if _, ok := p.Profiles[name]; name != "" && !ok {
+		return fmt.Errorf("no profile called %q", name)
+	}
+	p.Current = name
+	return nil
+}
+
+// Select returns the profile commands run against: name, or the current
+// profile if name is empty. It returns a nil profile for the local database.
+func (p *Profiles) Select(name string) (string, *Profile, error) {
+	if name == "" {
+		name = p.Current
+	}
+	if name == "" || name == LocalProfile {
+		return "", nil, nil
+	}
+	profile, ok := p.Profiles[name]
+	if !ok {
+		return "", nil, fmt.Errorf("no profile called %q", name)
+	}
+	return name, &profile, nil
+}
+
+func validName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("profile name is empty")
+	case name == LocalProfile:
+		return fmt.Errorf("%q means the local database and cannot be a profile name", LocalProfile)
+	case strings.ContainsAny(name, " \t\n"):
+		return fmt.Errorf("profile name %q contains whitespace", name)
+	}
+	return nil
+}
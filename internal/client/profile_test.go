@@ -0,0 +1,63 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subtrack", "profiles.yaml")
+
+	p, err := LoadProfiles(path)
+	if err != nil {
+		t.Fatalf("LoadProfiles(missing) error = %v", err)
+	}
+	if name, profile, err := p.Select(""); name != "" || profile != nil || err != nil {
+		t.Errorf("Select() without profiles = %q, %v, %v, want local", name, profile, err)
+	}
+
+	if err := p.Set("home", Profile{Server: "https://subtrack.example.com", Token: "s3cret"}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	for _, name := range []string{"", LocalProfile, "my home"} {
+		if err := p.Set(name, Profile{Server: "https://subtrack.example.com", Token: "s3cret"}); err == nil {
+			t.Errorf("Set(%q) expected an error", name)
+		}
+	}
+	if err := p.Set("work", Profile{Server: "https://work.example.com"}); err == nil {
+		t.Error("Set() without a token expected an error")
+	}
+	if err := p.Use("missing"); err == nil {
+		t.Error("Use(missing) expected an error")
+	}
+	if err := p.Use("home"); err != nil {
+		t.Fatalf("Use() error = %v", err)
+	}
+	if err := p.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("profile file mode = %v, %v, want 0600", info.Mode().Perm(), err)
+	}
+
+	p, err = LoadProfiles(path)
+	if err != nil {
+		t.Fatalf("LoadProfiles() error = %v", err)
+	}
+	name, profile, err := p.Select("")
+	if err != nil || name != "home" || profile.Token != "s3cret" {
+		t.Errorf("Select() = %q, %+v, %v, want home", name, profile, err)
+	}
+	if _, profile, err := p.Select(LocalProfile); profile != nil || err != nil {
+		t.Errorf("Select(local) = %+v, %v, want the local database", profile, err)
+	}
+	if _, _, err := p.Select("missing"); err == nil {
+		t.Error("Select(missing) expected an error")
+	}
+
+	if err := p.Remove("home"); err != nil || p.Current != "" {
+		t.Errorf("Remove() error = %v, current = %q", err, p.Current)
+	}
+}
@@ -2,35 +2,119 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
 	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+
+	"github.com/berkaycubuk/subtrack/internal/password"
 )
 
 type Config struct {
 	TelegramBotToken string
 	TelegramChatID   string
+	TelegramFormat   string
 	DBPath           string
-	WebUsername       string
+	DatabaseURL      string
+	WebUsername      string
 	WebPassword      string
+	WebPasswordHash  string
 	WebPort          string
+	APIToken         string
+	SecureCookies    bool
+	TrustedProxies   []netip.Prefix
+	BasePath         string
+	TLS              TLSConfig
+	APIRateLimit     int
+	OIDC             OIDCConfig
+	// CheckSchedules is empty when CHECK_SCHEDULE is unset, leaving the
+	// scheduler to its defaults.
+	CheckSchedules   []string
+	Location         *time.Location
+	DigestSchedule   string
+	DigestPeriod     string
+	OutboxSchedule   string
+	SnapshotDir      string
+	SnapshotSchedule string
+	SnapshotDaily    int
+	SnapshotWeekly   int
+}
+
+// TLSConfig is HTTPS served by the web server itself; it is off when no
+// certificate is configured.
+type TLSConfig struct {
+	CertFile   string
+	KeyFile    string
+	SelfSigned bool
+}
+
+// Enabled reports whether the web server serves HTTPS.
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.SelfSigned
+}
+
+// OIDCConfig is single sign-on with an OpenID Connect provider; it is off
+// when Issuer is empty.
+type OIDCConfig struct {
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        []string
+	UsernameClaim string
+	RolesClaim    string
+	AdminRoles    []string
+	ViewerRoles   []string
 }
 
+// Enabled reports whether single sign-on is configured.
+func (o OIDCConfig) Enabled() bool {
+	return o.Issuer != ""
+}
+
+// Requirement is a group of settings that some uses of the configuration
+// cannot do without.
+type Requirement int
+
+const (
+	// RequireTelegram needs TELEGRAM_BOT_TOKEN and a numeric TELEGRAM_CHAT_ID.
+	RequireTelegram Requirement = 1 << iota
+	// RequireWeb needs WEB_USERNAME and WEB_PASSWORD or WEB_PASSWORD_HASH,
+	// and a client ID and roles when OIDC_ISSUER turns single sign-on on.
+	RequireWeb
+)
+
+// Load reads the configuration and requires everything the service needs.
 func Load() (*Config, error) {
+	cfg, err := Read()
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Require(RequireTelegram | RequireWeb); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Read reads the configuration from the environment and .env, applying
+// defaults. It rejects malformed values but not missing ones; callers check
+// what they need with Require.
+func Read() (*Config, error) {
 	if err := godotenv.Load(); err != nil {
 		if !os.IsNotExist(err) {
 			return nil, fmt.Errorf("error loading .env file: %w", err)
 		}
 	}
 
-	botToken := os.Getenv("TELEGRAM_BOT_TOKEN")
-	if botToken == "" {
-		return nil, fmt.Errorf("TELEGRAM_BOT_TOKEN is required")
-	}
-
-	chatID := os.Getenv("TELEGRAM_CHAT_ID")
-	if chatID == "" {
-		return nil, fmt.Errorf("TELEGRAM_CHAT_ID is required")
+	telegramFormat := os.Getenv("TELEGRAM_FORMAT")
+	if telegramFormat == "" {
+		telegramFormat = "markdownv2"
 	}
 
 	dbPath := os.Getenv("DB_PATH")
@@ -38,27 +122,305 @@ func Load() (*Config, error) {
 		dbPath = "subtrack.db"
 	}
 
-	webUsername := os.Getenv("WEB_USERNAME")
-	if webUsername == "" {
-		return nil, fmt.Errorf("WEB_USERNAME is required")
+	webPort := os.Getenv("WEB_PORT")
+	if webPort == "" {
+		webPort = "8080"
+	}
+
+	checkSchedules := parseSchedules(os.Getenv("CHECK_SCHEDULE"))
+
+	timezone := os.Getenv("TIMEZONE")
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TIMEZONE %q: %w", timezone, err)
+	}
+
+	digestPeriod := os.Getenv("DIGEST_PERIOD")
+	if digestPeriod == "" {
+		digestPeriod = "weekly"
 	}
 
+	outboxSchedule := strings.TrimSpace(os.Getenv("OUTBOX_SCHEDULE"))
+	if outboxSchedule == "" {
+		outboxSchedule = "0 */5 * * * *"
+	}
+
+	snapshotDir := os.Getenv("SNAPSHOT_DIR")
+	if snapshotDir == "" {
+		snapshotDir = filepath.Join(filepath.Dir(dbPath), "snapshots")
+	}
+
+	snapshotSchedule := strings.TrimSpace(os.Getenv("SNAPSHOT_SCHEDULE"))
+	if snapshotSchedule == "" {
+		snapshotSchedule = "0 0 3 * * *"
+	}
+
+	snapshotDaily, err := envInt("SNAPSHOT_KEEP_DAILY", 7)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotWeekly, err := envInt("SNAPSHOT_KEEP_WEEKLY", 4)
+	if err != nil {
+		return nil, err
+	}
+
+	secureCookies, err := envBool("WEB_SECURE_COOKIES", false)
+	if err != nil {
+		return nil, err
+	}
+
+	trustedProxies, err := parsePrefixes(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+
+	basePath, err := parseBasePath(os.Getenv("WEB_BASE_PATH"))
+	if err != nil {
+		return nil, err
+	}
+
+	tlsSelfSigned, err := envBool("TLS_SELF_SIGNED", false)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := TLSConfig{
+		CertFile:   strings.TrimSpace(os.Getenv("TLS_CERT_FILE")),
+		KeyFile:    strings.TrimSpace(os.Getenv("TLS_KEY_FILE")),
+		SelfSigned: tlsSelfSigned,
+	}
+	if (tlsConfig.CertFile == "") != (tlsConfig.KeyFile == "") {
+		return nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if tlsConfig.CertFile != "" && tlsConfig.SelfSigned {
+		return nil, fmt.Errorf("set TLS_CERT_FILE or TLS_SELF_SIGNED, not both")
+	}
+
+	apiRateLimit, err := envInt("API_RATE_LIMIT", 120)
+	if err != nil {
+		return nil, err
+	}
+
+	webPasswordHash, err := readPasswordHash()
+	if err != nil {
+		return nil, err
+	}
 	webPassword := os.Getenv("WEB_PASSWORD")
-	if webPassword == "" {
-		return nil, fmt.Errorf("WEB_PASSWORD is required")
+	if webPassword != "" && webPasswordHash != "" {
+		return nil, fmt.Errorf("set WEB_PASSWORD or WEB_PASSWORD_HASH, not both")
 	}
 
-	webPort := os.Getenv("WEB_PORT")
-	if webPort == "" {
-		webPort = "8080"
+	oidc := OIDCConfig{
+		Issuer:        strings.TrimSpace(os.Getenv("OIDC_ISSUER")),
+		ClientID:      os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret:  os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:   strings.TrimSpace(os.Getenv("OIDC_REDIRECT_URL")),
+		Scopes:        strings.Fields(strings.ReplaceAll(os.Getenv("OIDC_SCOPES"), ",", " ")),
+		UsernameClaim: strings.TrimSpace(os.Getenv("OIDC_USERNAME_CLAIM")),
+		RolesClaim:    strings.TrimSpace(os.Getenv("OIDC_ROLES_CLAIM")),
+		AdminRoles:    parseList(os.Getenv("OIDC_ADMIN_ROLES")),
+		ViewerRoles:   parseList(os.Getenv("OIDC_VIEWER_ROLES")),
+	}
+	if len(oidc.Scopes) == 0 {
+		oidc.Scopes = []string{"profile", "email"}
 	}
 
 	return &Config{
-		TelegramBotToken: botToken,
-		TelegramChatID:   chatID,
+		TelegramBotToken: os.Getenv("TELEGRAM_BOT_TOKEN"),
+		TelegramChatID:   os.Getenv("TELEGRAM_CHAT_ID"),
+		TelegramFormat:   telegramFormat,
 		DBPath:           dbPath,
-		WebUsername:       webUsername,
+		DatabaseURL:      os.Getenv("DATABASE_URL"),
+		WebUsername:      os.Getenv("WEB_USERNAME"),
 		WebPassword:      webPassword,
+		WebPasswordHash:  webPasswordHash,
 		WebPort:          webPort,
+		APIToken:         os.Getenv("API_TOKEN"),
+		SecureCookies:    secureCookies,
+		TrustedProxies:   trustedProxies,
+		BasePath:         basePath,
+		TLS:              tlsConfig,
+		APIRateLimit:     apiRateLimit,
+		OIDC:             oidc,
+		CheckSchedules:   checkSchedules,
+		Location:         location,
+		DigestSchedule:   strings.TrimSpace(os.Getenv("DIGEST_SCHEDULE")),
+		DigestPeriod:     digestPeriod,
+		OutboxSchedule:   outboxSchedule,
+		SnapshotDir:      snapshotDir,
+		SnapshotSchedule: snapshotSchedule,
+		SnapshotDaily:    snapshotDaily,
+		SnapshotWeekly:   snapshotWeekly,
 	}, nil
 }
+
+// Require returns an error naming the first missing or invalid setting in
+// the required groups.
+func (c *Config) Require(r Requirement) error {
+	if r&RequireTelegram != 0 {
+		if c.TelegramBotToken == "" {
+			return fmt.Errorf("TELEGRAM_BOT_TOKEN is required")
+		}
+		if c.TelegramChatID == "" {
+			return fmt.Errorf("TELEGRAM_CHAT_ID is required")
+		}
+		if _, err := c.ChatID(); err != nil {
+			return err
+		}
+	}
+	if r&RequireWeb != 0 {
+		if c.WebUsername == "" {
+			return fmt.Errorf("WEB_USERNAME is required")
+		}
+		if c.WebPassword == "" && c.WebPasswordHash == "" {
+			return fmt.Errorf("WEB_PASSWORD_HASH or WEB_PASSWORD is required")
+		}
+		if c.OIDC.Enabled() {
+			if c.OIDC.ClientID == "" {
+				return fmt.Errorf("OIDC_CLIENT_ID is required with OIDC_ISSUER")
+			}
+			if len(c.OIDC.AdminRoles) == 0 && len(c.OIDC.ViewerRoles) == 0 {
+				return fmt.Errorf("OIDC_ADMIN_ROLES or OIDC_VIEWER_ROLES is required with OIDC_ISSUER")
+			}
+		}
+	}
+	return nil
+}
+
+// ChatID is TELEGRAM_CHAT_ID as a number.
+func (c *Config) ChatID() (int64, error) {
+	id, err := strconv.ParseInt(c.TelegramChatID, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid TELEGRAM_CHAT_ID: %w", err)
+	}
+	return id, nil
+}
+
+// DatabaseDSN is DATABASE_URL when set and the SQLite file DB_PATH otherwise.
+func (c *Config) DatabaseDSN() string {
+	if c.DatabaseURL != "" {
+		return c.DatabaseURL
+	}
+	return c.DBPath
+}
+
+// readPasswordHash returns WEB_PASSWORD_HASH, or the contents of the file
+// named by WEB_PASSWORD_HASH_FILE as used for Docker secrets.
+func readPasswordHash() (string, error) {
+	hash := strings.TrimSpace(os.Getenv("WEB_PASSWORD_HASH"))
+	name := "WEB_PASSWORD_HASH"
+	if path := os.Getenv("WEB_PASSWORD_HASH_FILE"); path != "" {
+		if hash != "" {
+			return "", fmt.Errorf("set WEB_PASSWORD_HASH or WEB_PASSWORD_HASH_FILE, not both")
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read WEB_PASSWORD_HASH_FILE: %w", err)
+		}
+		hash = strings.TrimSpace(string(data))
+		name = "WEB_PASSWORD_HASH_FILE"
+	}
+	if hash == "" {
+		return "", nil
+	}
+	if err := password.Validate(hash); err != nil {
+		// .env expands $variables outside single quotes, which mangles
+		// hashes before they get here.
+		return "", fmt.Errorf("invalid %s (in .env, put the hash in single quotes): %w", name, err)
+	}
+	return hash, nil
+}
+
+func envInt(name string, def int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", name)
+	}
+	return n, nil
+}
+
+func envBool(name string, def bool) (bool, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false", name)
+	}
+	return b, nil
+}
+
+// parseSchedules splits a semicolon-separated list of cron expressions.
+// Semicolons are used because cron fields themselves contain spaces and commas.
+func parseSchedules(value string) []string {
+	var schedules []string
+	for _, spec := range strings.Split(value, ";") {
+		spec = strings.TrimSpace(spec)
+		if spec != "" {
+			schedules = append(schedules, spec)
+		}
+	}
+	return schedules
+}
+
+// parseList splits a comma-separated list, dropping empty items.
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseBasePath normalises WEB_BASE_PATH to a path with a leading slash and
+// no trailing one, e.g. /subtrack; the root is the empty string.
+func parseBasePath(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+	if strings.ContainsAny(value, "?#") || slices.Contains(strings.Split(value, "/"), "..") {
+		return "", fmt.Errorf("WEB_BASE_PATH must be a path such as /subtrack")
+	}
+	p := path.Clean("/" + value)
+	if p == "/" {
+		return "", nil
+	}
+	return p, nil
+}
+
+// parsePrefixes parses a comma-separated list of IP addresses and CIDR
+// ranges; an address stands for itself alone.
+func parsePrefixes(value string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if strings.Contains(item, "/") {
+			prefix, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
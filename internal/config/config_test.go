@@ -0,0 +1,254 @@
+package config
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/berkaycubuk/subtrack/internal/password"
+)
+
+// settings are the environment variables Read looks at.
+var settings = []string{
+	"API_RATE_LIMIT", "API_TOKEN", "CHECK_SCHEDULE", "DATABASE_URL", "DB_PATH", "DIGEST_PERIOD", "DIGEST_SCHEDULE",
+	"OIDC_ADMIN_ROLES", "OIDC_CLIENT_ID", "OIDC_CLIENT_SECRET", "OIDC_ISSUER", "OIDC_REDIRECT_URL", "OIDC_ROLES_CLAIM",
+	"OIDC_SCOPES", "OIDC_USERNAME_CLAIM", "OIDC_VIEWER_ROLES", "OUTBOX_SCHEDULE", "SNAPSHOT_DIR", "SNAPSHOT_KEEP_DAILY",
+	"SNAPSHOT_KEEP_WEEKLY", "SNAPSHOT_SCHEDULE", "TELEGRAM_BOT_TOKEN", "TELEGRAM_CHAT_ID", "TELEGRAM_FORMAT", "TIMEZONE",
+	"TLS_CERT_FILE", "TLS_KEY_FILE", "TLS_SELF_SIGNED", "TRUSTED_PROXIES", "WEB_BASE_PATH", "WEB_PASSWORD",
+	"WEB_PASSWORD_HASH", "WEB_PASSWORD_HASH_FILE", "WEB_PORT", "WEB_SECURE_COOKIES", "WEB_USERNAME",
+}
+
+// setEnv clears every setting, then sets env, for the rest of the test.
+func setEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, name := range settings {
+		t.Setenv(name, env[name])
+	}
+}
+
+func TestConfig_Require(t *testing.T) {
+	full := func() *Config {
+		return &Config{TelegramBotToken: "token", TelegramChatID: "-100123", WebUsername: "admin", WebPassword: "secret"}
+	}
+	sso := func(c *Config) {
+		c.OIDC = OIDCConfig{Issuer: "https://id.example.com", ClientID: "subtrack", AdminRoles: []string{"admin"}}
+	}
+
+	tests := []struct {
+		name    string
+		require Requirement
+		change  func(*Config)
+		wantErr string
+	}{
+		// Subscription commands need neither Telegram nor the web UI.
+		{name: "list without settings", change: func(c *Config) { *c = Config{} }},
+
+		// check and test talk to Telegram.
+		{name: "check", require: RequireTelegram},
+		{name: "check without web settings", require: RequireTelegram, change: func(c *Config) { c.WebUsername, c.WebPassword = "", "" }},
+		{name: "check without bot token", require: RequireTelegram, change: func(c *Config) { c.TelegramBotToken = "" }, wantErr: "TELEGRAM_BOT_TOKEN is required"},
+		{name: "check without chat ID", require: RequireTelegram, change: func(c *Config) { c.TelegramChatID = "" }, wantErr: "TELEGRAM_CHAT_ID is required"},
+		{name: "check with channel name", require: RequireTelegram, change: func(c *Config) { c.TelegramChatID = "@subtrack" }, wantErr: "invalid TELEGRAM_CHAT_ID"},
+
+		// The service needs both.
+		{name: "service", require: RequireTelegram | RequireWeb},
+		{name: "service with password hash", require: RequireTelegram | RequireWeb, change: func(c *Config) { c.WebPassword, c.WebPasswordHash = "", "$argon2id$..." }},
+		{name: "service without Telegram", require: RequireTelegram | RequireWeb, change: func(c *Config) { c.TelegramBotToken = "" }, wantErr: "TELEGRAM_BOT_TOKEN is required"},
+		{name: "service without username", require: RequireTelegram | RequireWeb, change: func(c *Config) { c.WebUsername = "" }, wantErr: "WEB_USERNAME is required"},
+		{name: "service without password", require: RequireTelegram | RequireWeb, change: func(c *Config) { c.WebPassword = "" }, wantErr: "WEB_PASSWORD_HASH or WEB_PASSWORD is required"},
+		{name: "service with SSO", require: RequireTelegram | RequireWeb, change: sso},
+		{name: "service with SSO without client ID", require: RequireTelegram | RequireWeb, change: func(c *Config) { sso(c); c.OIDC.ClientID = "" }, wantErr: "OIDC_CLIENT_ID is required"},
+		{name: "service with SSO without roles", require: RequireTelegram | RequireWeb, change: func(c *Config) { sso(c); c.OIDC.AdminRoles = nil }, wantErr: "OIDC_ADMIN_ROLES or OIDC_VIEWER_ROLES is required"},
+		{name: "service with SSO viewers only", require: RequireTelegram | RequireWeb, change: func(c *Config) { sso(c); c.OIDC.AdminRoles, c.OIDC.ViewerRoles = nil, []string{"staff"} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := full()
+			if tt.change != nil {
+				tt.change(cfg)
+			}
+			err := cfg.Require(tt.require)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Require() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Require() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRead_Defaults(t *testing.T) {
+	setEnv(t, map[string]string{"DB_PATH": "/data/subtrack.db"})
+
+	cfg, err := Read()
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if cfg.WebPort != "8080" || cfg.TelegramFormat != "markdownv2" || cfg.DigestPeriod != "weekly" || cfg.APIRateLimit != 120 {
+		t.Errorf("Read() = %+v, want defaults", cfg)
+	}
+	if cfg.SnapshotDir != "/data/snapshots" {
+		t.Errorf("SnapshotDir = %q, want it next to DB_PATH", cfg.SnapshotDir)
+	}
+	if cfg.CheckSchedules != nil || cfg.BasePath != "" || cfg.TLS.Enabled() || cfg.OIDC.Enabled() {
+		t.Errorf("Read() = %+v, want optional settings off", cfg)
+	}
+	if !slices.Equal(cfg.OIDC.Scopes, []string{"profile", "email"}) {
+		t.Errorf("OIDC scopes = %v", cfg.OIDC.Scopes)
+	}
+	if cfg.DatabaseDSN() != "/data/subtrack.db" {
+		t.Errorf("DatabaseDSN() = %q, want DB_PATH", cfg.DatabaseDSN())
+	}
+
+	// Missing settings are left to Require.
+	if err := cfg.Require(0); err != nil {
+		t.Errorf("Require(0) error = %v", err)
+	}
+	if err := cfg.Require(RequireWeb); err == nil {
+		t.Error("Require(RequireWeb) expected error")
+	}
+}
+
+func TestRead_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "timezone", env: map[string]string{"TIMEZONE": "Mars/Olympus_Mons"}, wantErr: "invalid TIMEZONE"},
+		{name: "negative count", env: map[string]string{"SNAPSHOT_KEEP_DAILY": "-1"}, wantErr: "SNAPSHOT_KEEP_DAILY must be a non-negative integer"},
+		{name: "boolean", env: map[string]string{"WEB_SECURE_COOKIES": "maybe"}, wantErr: "WEB_SECURE_COOKIES must be true or false"},
+		{name: "trusted proxies", env: map[string]string{"TRUSTED_PROXIES": "10.0.0.0/33"}, wantErr: "invalid TRUSTED_PROXIES"},
+		{name: "base path", env: map[string]string{"WEB_BASE_PATH": "/apps/../subtrack"}, wantErr: "WEB_BASE_PATH"},
+		{name: "certificate without key", env: map[string]string{"TLS_CERT_FILE": "cert.pem"}, wantErr: "must be set together"},
+		{name: "certificate and self-signed", env: map[string]string{"TLS_CERT_FILE": "cert.pem", "TLS_KEY_FILE": "key.pem", "TLS_SELF_SIGNED": "true"}, wantErr: "not both"},
+		{name: "password and hash", env: map[string]string{"WEB_PASSWORD": "secret", "WEB_PASSWORD_HASH": "$2a$12$R9h/cIPz0gi.URNNX3kh2OPST9/PgBkqquzi.Ss7KIUgO2t0jWMUW"}, wantErr: "set WEB_PASSWORD or WEB_PASSWORD_HASH, not both"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, tt.env)
+			if _, err := Read(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Read() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseBasePath(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "", want: ""},
+		{value: "/", want: ""},
+		{value: "/subtrack", want: "/subtrack"},
+		{value: "subtrack", want: "/subtrack"},
+		{value: " /subtrack/ ", want: "/subtrack"},
+		{value: "/apps//subtrack/", want: "/apps/subtrack"},
+		{value: "/apps/./subtrack", want: "/apps/subtrack"},
+		{value: "/apps/../subtrack", wantErr: true},
+		{value: "..", wantErr: true},
+		{value: "/subtrack/..", wantErr: true},
+		{value: "/subtrack?x=1", wantErr: true},
+		{value: "/subtrack#top", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseBasePath(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseBasePath(%q) = %q, want error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseBasePath(%q) = %q, %v; want %q", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestParsePrefixes(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{value: "", want: nil},
+		{value: "10.0.0.0/8", want: []string{"10.0.0.0/8"}},
+		{value: "192.168.1.7/24", want: []string{"192.168.1.0/24"}},
+		{value: "127.0.0.1, ::1", want: []string{"127.0.0.1/32", "::1/128"}},
+		{value: " 172.16.0.0/12 ,, fd00::/8 ", want: []string{"172.16.0.0/12", "fd00::/8"}},
+		{value: "10.0.0.0/33", wantErr: true},
+		{value: "10.0.0.0/8, 300.0.0.1", wantErr: true},
+		{value: "proxy.local", wantErr: true},
+		{value: "10.0.0.0/", wantErr: true},
+	}
+	for _, tt := range tests {
+		prefixes, err := parsePrefixes(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parsePrefixes(%q) = %v, want error", tt.value, prefixes)
+			}
+			continue
+		}
+		var want []netip.Prefix
+		for _, p := range tt.want {
+			want = append(want, netip.MustParsePrefix(p))
+		}
+		if err != nil || !slices.Equal(prefixes, want) {
+			t.Errorf("parsePrefixes(%q) = %v, %v; want %v", tt.value, prefixes, err, want)
+		}
+	}
+}
+
+func TestReadPasswordHash(t *testing.T) {
+	hash, err := password.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "hash")
+	if err := os.WriteFile(file, []byte(hash+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(dir, "bad")
+	if err := os.WriteFile(bad, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr string
+	}{
+		{name: "unset", want: ""},
+		{name: "env", env: map[string]string{"WEB_PASSWORD_HASH": " " + hash + " "}, want: hash},
+		{name: "file", env: map[string]string{"WEB_PASSWORD_HASH_FILE": file}, want: hash},
+		{name: "env and file", env: map[string]string{"WEB_PASSWORD_HASH": hash, "WEB_PASSWORD_HASH_FILE": file}, wantErr: "not both"},
+		{name: "missing file", env: map[string]string{"WEB_PASSWORD_HASH_FILE": filepath.Join(dir, "missing")}, wantErr: "failed to read WEB_PASSWORD_HASH_FILE"},
+		{name: "invalid env", env: map[string]string{"WEB_PASSWORD_HASH": "secret"}, wantErr: "invalid WEB_PASSWORD_HASH "},
+		{name: "invalid file", env: map[string]string{"WEB_PASSWORD_HASH_FILE": bad}, wantErr: "invalid WEB_PASSWORD_HASH_FILE"},
+		// .env expands $ in double quotes, which leaves an argon2id hash
+		// without its parameters.
+		{name: "mangled", env: map[string]string{"WEB_PASSWORD_HASH": "=19,t=2,p=1"}, wantErr: "single quotes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, tt.env)
+			got, err := readPasswordHash()
+			switch {
+			case tt.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("readPasswordHash() error = %v, want %q", err, tt.wantErr)
+				}
+			case err != nil || got != tt.want:
+				t.Errorf("readPasswordHash() = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
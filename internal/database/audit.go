@@ -0,0 +1,24 @@
+package database
+
+import "time"
+
+// AuditEvent records a security-relevant action, such as a failed login.
+type AuditEvent struct {
+	ID        uint      `gorm:"primaryKey"`
+	Action    string    `gorm:"not null;index"`
+	Username  string    `gorm:"not null;default:''"`
+	IP        string    `gorm:"not null;default:''"`
+	Detail    string    `gorm:"not null;default:''"`
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (db *DB) CreateAuditEvent(event *AuditEvent) error {
+	return db.Create(event).Error
+}
+
+// GetAuditEvents returns up to limit events, newest first.
+func (db *DB) GetAuditEvents(limit int) ([]AuditEvent, error) {
+	var events []AuditEvent
+	err := db.Order("created_at DESC, id DESC").Limit(limit).Find(&events).Error
+	return events, err
+}
@@ -2,8 +2,11 @@ package database
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/berkaycubuk/subtrack/internal/utils"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -15,29 +18,137 @@ type Subscription struct {
 	Currency    string    `gorm:"not null" json:"currency"`
 	Cycle       string    `gorm:"not null" json:"cycle"`
 	PaymentDate time.Time `gorm:"not null" json:"payment_date"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	PaymentDay  int       `gorm:"not null;default:0" json:"payment_day"`
+	Category    string    `gorm:"not null;default:''" json:"category"`
+	Status      string    `gorm:"not null;default:active;index" json:"status"`
+	// TrialEndDate is the day a free trial ends, or nil for no trial.
+	TrialEndDate *time.Time `json:"trial_end_date,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// Subscription statuses. Only active subscriptions get payment alerts.
+const (
+	StatusActive    = "active"
+	StatusPaused    = "paused"
+	StatusCancelled = "cancelled"
+)
+
+var Statuses = []string{StatusActive, StatusPaused, StatusCancelled}
+
+// BeforeSave normalizes the subscription before it is written.
+func (s *Subscription) BeforeSave(tx *gorm.DB) error {
+	s.normalize()
+	return nil
+}
+
+// AnchorDay returns the day of the month the subscription is due on. Months
+// that lack that day move the stored payment date to their last day, so the
+// payment date alone cannot tell a payment on the 31st from one on the 28th.
+// Rows saved before PaymentDay existed fall back to the payment date's day.
+func (s *Subscription) AnchorDay() int {
+	if s.PaymentDay != 0 {
+		return s.PaymentDay
+	}
+	return utils.DateOf(s.PaymentDate).Day
+}
+
+// normalize stores payment dates in UTC, since SQLite compares the stored
+// timestamps as text and mixed offsets would break the range queries below,
+// and fills in the payment day and the default status.
+func (s *Subscription) normalize() {
+	s.PaymentDay = s.AnchorDay()
+	s.PaymentDate = s.PaymentDate.UTC()
+	if s.TrialEndDate != nil {
+		end := s.TrialEndDate.UTC()
+		s.TrialEndDate = &end
+	}
+	if s.Status == "" {
+		s.Status = StatusActive
+	}
 }
 
 type DB struct {
 	*gorm.DB
 }
 
-func New(dbPath string) (*DB, error) {
-	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+// Supported database drivers.
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// ParseDSN picks the driver for dsn. postgres:// and postgresql:// URLs
+// select Postgres; anything else is a SQLite file path, optionally prefixed
+// with sqlite://.
+func ParseDSN(dsn string) (driver, conn string) {
+	switch {
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+		return DriverPostgres, dsn
+	default:
+		return DriverSQLite, strings.TrimPrefix(dsn, "sqlite://")
+	}
+}
+
+// New opens the database and applies any pending migrations. It refuses to
+// use a database whose schema is newer than this build.
+func New(dsn string) (*DB, error) {
+	db, err := Open(dsn)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, err
 	}
 
-	if err := db.AutoMigrate(&Subscription{}); err != nil {
+	if _, err := db.MigrateUp(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	return db, nil
+}
+
+// Open connects to the database without touching its schema.
+func Open(dsn string) (*DB, error) {
+	var dialector gorm.Dialector
+	switch driver, conn := ParseDSN(dsn); driver {
+	case DriverPostgres:
+		dialector = postgres.Open(conn)
+	default:
+		dialector = sqlite.Open(conn)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
 	return &DB{db}, nil
 }
 
+// Driver returns DriverSQLite or DriverPostgres.
+func (db *DB) Driver() string {
+	return db.Dialector.Name()
+}
+
 func (db *DB) CreateSubscription(sub *Subscription) error {
-	return db.Create(sub).Error
+	explicitID := sub.ID != 0
+	if err := db.Create(sub).Error; err != nil {
+		return err
+	}
+	if explicitID {
+		return db.syncIDSequence("subscriptions")
+	}
+	return nil
+}
+
+// syncIDSequence moves a Postgres ID sequence past rows inserted with
+// explicit IDs, which do not advance it. SQLite needs no help.
+func (db *DB) syncIDSequence(table string) error {
+	if db.Driver() != DriverPostgres {
+		return nil
+	}
+	return db.Exec(fmt.Sprintf(
+		"SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), COALESCE((SELECT MAX(id) FROM %[1]s), 0) + 1, false)", table,
+	)).Error
 }
 
 func (db *DB) GetSubscriptionByID(id uint) (*Subscription, error) {
@@ -49,9 +160,26 @@ func (db *DB) GetSubscriptionByID(id uint) (*Subscription, error) {
 	return &sub, nil
 }
 
+// GetSubscriptionByName returns the subscription with exactly this name, or
+// nil when there is none.
+func (db *DB) GetSubscriptionByName(name string) (*Subscription, error) {
+	var subs []Subscription
+	err := db.Where("name = ?", name).Order("id").Limit(1).Find(&subs).Error
+	if err != nil || len(subs) == 0 {
+		return nil, err
+	}
+	return &subs[0], nil
+}
+
+func (db *DB) SubscriptionExists(id uint) (bool, error) {
+	var count int64
+	err := db.Model(&Subscription{}).Where("id = ?", id).Count(&count).Error
+	return count > 0, err
+}
+
 func (db *DB) GetAllSubscriptions() ([]Subscription, error) {
 	var subs []Subscription
-	err := db.Find(&subs).Error
+	err := db.Order("id").Find(&subs).Error
 	return subs, err
 }
 
@@ -63,16 +191,42 @@ func (db *DB) DeleteSubscription(id uint) error {
 	return db.Delete(&Subscription{}, id).Error
 }
 
+// GetUpcomingPayments returns active subscriptions whose payment falls on
+// today or one of the following days-1 calendar days in the configured
+// location.
 func (db *DB) GetUpcomingPayments(days int) ([]Subscription, error) {
 	var subs []Subscription
-	now := time.Now()
-	cutoff := now.AddDate(0, 0, days)
-	err := db.Where("payment_date >= ? AND payment_date <= ?", now, cutoff).Find(&subs).Error
+	today := utils.Today()
+	start := today.Time().UTC()
+	end := today.AddDays(days).Time().UTC()
+	err := db.Where("payment_date >= ? AND payment_date < ? AND status = ?", start, end, StatusActive).Order("id").Find(&subs).Error
 	return subs, err
 }
 
+// GetEndingTrials returns active subscriptions whose trial ends today or on
+// one of the following days-1 calendar days in the configured location.
+func (db *DB) GetEndingTrials(days int) ([]Subscription, error) {
+	var subs []Subscription
+	today := utils.Today()
+	start := today.Time().UTC()
+	end := today.AddDays(days).Time().UTC()
+	err := db.Where("trial_end_date >= ? AND trial_end_date < ? AND status = ?", start, end, StatusActive).Order("id").Find(&subs).Error
+	return subs, err
+}
+
+// GetPastDuePayments returns subscriptions whose payment fell on a day before
+// today in the configured location.
 func (db *DB) GetPastDuePayments() ([]Subscription, error) {
 	var subs []Subscription
-	err := db.Where("payment_date < ?", time.Now()).Find(&subs).Error
+	start := utils.Today().Time().UTC()
+	err := db.Where("payment_date < ?", start).Order("id").Find(&subs).Error
 	return subs, err
 }
+
+// WithTransaction runs fn with a DB bound to a single transaction, which is
+// committed when fn returns nil and rolled back otherwise.
+func (db *DB) WithTransaction(fn func(tx Store) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		return fn(&DB{tx})
+	})
+}
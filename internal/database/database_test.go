@@ -1,20 +1,70 @@
 package database
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/utils"
 )
 
+// testDatabaseURLEnv names a Postgres URL to run the database tests against
+// instead of SQLite. The tests drop and recreate its public schema.
+const testDatabaseURLEnv = "SUBTRACK_TEST_DATABASE_URL"
+
+// testDSN returns the DSN of an empty database: a fresh SQLite file, or the
+// Postgres database from testDatabaseURLEnv with its schema wiped.
+func testDSN(t *testing.T) string {
+	t.Helper()
+
+	dsn := os.Getenv(testDatabaseURLEnv)
+	if dsn == "" {
+		return filepath.Join(t.TempDir(), "subtrack.db")
+	}
+
+	db, err := Open(dsn)
+	if err != nil {
+		t.Fatalf("failed to connect to %s: %v", testDatabaseURLEnv, err)
+	}
+	defer db.Close()
+	if err := db.Exec("DROP SCHEMA public CASCADE; CREATE SCHEMA public").Error; err != nil {
+		t.Fatalf("failed to reset test database: %v", err)
+	}
+	return dsn
+}
+
 func setupTestDB(t *testing.T) *DB {
-	db, err := New(":memory:")
+	db, err := New(testDSN(t))
 	if err != nil {
 		t.Fatalf("failed to create test database: %v", err)
 	}
+	t.Cleanup(func() { db.Close() })
 	return db
 }
 
+func TestParseDSN(t *testing.T) {
+	tests := []struct {
+		dsn, driver, conn string
+	}{
+		{"subtrack.db", DriverSQLite, "subtrack.db"},
+		{"/data/subtrack.db", DriverSQLite, "/data/subtrack.db"},
+		{"sqlite:///data/subtrack.db", DriverSQLite, "/data/subtrack.db"},
+		{":memory:", DriverSQLite, ":memory:"},
+		{"postgres://user:pw@localhost:5432/subtrack", DriverPostgres, "postgres://user:pw@localhost:5432/subtrack"},
+		{"postgresql://localhost/subtrack?sslmode=disable", DriverPostgres, "postgresql://localhost/subtrack?sslmode=disable"},
+	}
+
+	for _, tt := range tests {
+		driver, conn := ParseDSN(tt.dsn)
+		if driver != tt.driver || conn != tt.conn {
+			t.Errorf("ParseDSN(%q) = %s, %q; want %s, %q", tt.dsn, driver, conn, tt.driver, tt.conn)
+		}
+	}
+}
+
 func TestNew(t *testing.T) {
-	db, err := New(":memory:")
+	db, err := New(testDSN(t))
 	if err != nil {
 		t.Fatalf("New() error = %v", err)
 	}
@@ -281,3 +331,53 @@ func TestGetPastDuePayments(t *testing.T) {
 		t.Errorf("GetPastDuePayments() returned %d subscriptions, want %d", len(got), expected)
 	}
 }
+
+func TestPaymentBoundaries_Today(t *testing.T) {
+	db := setupTestDB(t)
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatalf("failed to load location: %v", err)
+	}
+	utils.SetLocation(tokyo)
+	defer utils.SetLocation(time.UTC)
+
+	today := utils.Today()
+
+	subs := []*Subscription{
+		{Name: "Today", Price: 10, Currency: "USD", Cycle: "monthly", PaymentDate: today.Time()},
+		{Name: "Yesterday", Price: 10, Currency: "USD", Cycle: "monthly", PaymentDate: today.AddDays(-1).Time()},
+		{Name: "Last upcoming day", Price: 10, Currency: "USD", Cycle: "monthly", PaymentDate: today.AddDays(4).Time()},
+		{Name: "Outside window", Price: 10, Currency: "USD", Cycle: "monthly", PaymentDate: today.AddDays(5).Time()},
+	}
+
+	for _, sub := range subs {
+		if err := db.CreateSubscription(sub); err != nil {
+			t.Fatalf("failed to create test subscription: %v", err)
+		}
+	}
+
+	upcoming, err := db.GetUpcomingPayments(5)
+	if err != nil {
+		t.Fatalf("GetUpcomingPayments() error = %v", err)
+	}
+	if names := subscriptionNames(upcoming); len(names) != 2 || names[0] != "Today" || names[1] != "Last upcoming day" {
+		t.Errorf("GetUpcomingPayments() = %v, want [Today Last upcoming day]", names)
+	}
+
+	pastDue, err := db.GetPastDuePayments()
+	if err != nil {
+		t.Fatalf("GetPastDuePayments() error = %v", err)
+	}
+	if names := subscriptionNames(pastDue); len(names) != 1 || names[0] != "Yesterday" {
+		t.Errorf("GetPastDuePayments() = %v, want [Yesterday]", names)
+	}
+}
+
+func subscriptionNames(subs []Subscription) []string {
+	names := make([]string, len(subs))
+	for i, sub := range subs {
+		names[i] = sub.Name
+	}
+	return names
+}
@@ -0,0 +1,289 @@
+package database
+
+import (
+	"sync"
+	"time"
+)
+
+// FaultyStore wraps a Store and makes chosen methods fail, so tests can
+// exercise error paths that a working database never takes. Methods are
+// named as in the Store interface, e.g. "UpdateSubscription".
+type FaultyStore struct {
+	Store
+	*faults
+}
+
+type faults struct {
+	mu     sync.Mutex
+	rules  map[string]faultRule
+	counts map[string]int
+}
+
+type faultRule struct {
+	err   error
+	match func(call int, args []any) bool
+}
+
+func NewFaultyStore(store Store) *FaultyStore {
+	return &FaultyStore{Store: store, faults: &faults{
+		rules:  make(map[string]faultRule),
+		counts: make(map[string]int),
+	}}
+}
+
+// FailOn makes every call to method return err.
+func (f *FaultyStore) FailOn(method string, err error) {
+	f.FailWhen(method, err, func(int, []any) bool { return true })
+}
+
+// FailOnCall makes only the nth call (counting from 1) to method return err.
+func (f *FaultyStore) FailOnCall(method string, n int, err error) {
+	f.FailWhen(method, err, func(call int, _ []any) bool { return call == n })
+}
+
+// FailWhen makes calls to method return err when match reports true for the
+// call number and the call's arguments.
+func (f *FaultyStore) FailWhen(method string, err error, match func(call int, args []any) bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules[method] = faultRule{err: err, match: match}
+}
+
+// Heal removes the fault on method.
+func (f *FaultyStore) Heal(method string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.rules, method)
+}
+
+// Calls returns how many times method has been called, failed or not.
+func (f *FaultyStore) Calls(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.counts[method]
+}
+
+func (f *faults) fault(method string, args ...any) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.counts[method]++
+	rule, ok := f.rules[method]
+	if ok && rule.match(f.counts[method], args) {
+		return rule.err
+	}
+	return nil
+}
+
+func (f *FaultyStore) CreateSubscription(sub *Subscription) error {
+	if err := f.fault("CreateSubscription", sub); err != nil {
+		return err
+	}
+	return f.Store.CreateSubscription(sub)
+}
+
+func (f *FaultyStore) GetSubscriptionByID(id uint) (*Subscription, error) {
+	if err := f.fault("GetSubscriptionByID", id); err != nil {
+		return nil, err
+	}
+	return f.Store.GetSubscriptionByID(id)
+}
+
+func (f *FaultyStore) GetSubscriptionByName(name string) (*Subscription, error) {
+	if err := f.fault("GetSubscriptionByName", name); err != nil {
+		return nil, err
+	}
+	return f.Store.GetSubscriptionByName(name)
+}
+
+func (f *FaultyStore) SubscriptionExists(id uint) (bool, error) {
+	if err := f.fault("SubscriptionExists", id); err != nil {
+		return false, err
+	}
+	return f.Store.SubscriptionExists(id)
+}
+
+func (f *FaultyStore) GetAllSubscriptions() ([]Subscription, error) {
+	if err := f.fault("GetAllSubscriptions"); err != nil {
+		return nil, err
+	}
+	return f.Store.GetAllSubscriptions()
+}
+
+func (f *FaultyStore) UpdateSubscription(sub *Subscription) error {
+	if err := f.fault("UpdateSubscription", sub); err != nil {
+		return err
+	}
+	return f.Store.UpdateSubscription(sub)
+}
+
+func (f *FaultyStore) DeleteSubscription(id uint) error {
+	if err := f.fault("DeleteSubscription", id); err != nil {
+		return err
+	}
+	return f.Store.DeleteSubscription(id)
+}
+
+func (f *FaultyStore) GetUpcomingPayments(days int) ([]Subscription, error) {
+	if err := f.fault("GetUpcomingPayments", days); err != nil {
+		return nil, err
+	}
+	return f.Store.GetUpcomingPayments(days)
+}
+
+func (f *FaultyStore) GetEndingTrials(days int) ([]Subscription, error) {
+	if err := f.fault("GetEndingTrials", days); err != nil {
+		return nil, err
+	}
+	return f.Store.GetEndingTrials(days)
+}
+
+func (f *FaultyStore) GetPastDuePayments() ([]Subscription, error) {
+	if err := f.fault("GetPastDuePayments"); err != nil {
+		return nil, err
+	}
+	return f.Store.GetPastDuePayments()
+}
+
+func (f *FaultyStore) GetNotificationPreferences() ([]NotificationPreference, error) {
+	if err := f.fault("GetNotificationPreferences"); err != nil {
+		return nil, err
+	}
+	return f.Store.GetNotificationPreferences()
+}
+
+func (f *FaultyStore) SetNotificationPreference(channel, eventType string, enabled bool) error {
+	if err := f.fault("SetNotificationPreference", channel, eventType, enabled); err != nil {
+		return err
+	}
+	return f.Store.SetNotificationPreference(channel, eventType, enabled)
+}
+
+func (f *FaultyStore) GetNotificationSettings() (*NotificationSettings, error) {
+	if err := f.fault("GetNotificationSettings"); err != nil {
+		return nil, err
+	}
+	return f.Store.GetNotificationSettings()
+}
+
+func (f *FaultyStore) SaveNotificationSettings(settings *NotificationSettings) error {
+	if err := f.fault("SaveNotificationSettings", settings); err != nil {
+		return err
+	}
+	return f.Store.SaveNotificationSettings(settings)
+}
+
+func (f *FaultyStore) CreateOutboxMessage(msg *OutboxMessage) error {
+	if err := f.fault("CreateOutboxMessage", msg); err != nil {
+		return err
+	}
+	return f.Store.CreateOutboxMessage(msg)
+}
+
+func (f *FaultyStore) GetPendingOutboxMessages(limit int) ([]OutboxMessage, error) {
+	if err := f.fault("GetPendingOutboxMessages", limit); err != nil {
+		return nil, err
+	}
+	return f.Store.GetPendingOutboxMessages(limit)
+}
+
+func (f *FaultyStore) MarkOutboxSent(id uint, sentAt time.Time) error {
+	if err := f.fault("MarkOutboxSent", id, sentAt); err != nil {
+		return err
+	}
+	return f.Store.MarkOutboxSent(id, sentAt)
+}
+
+func (f *FaultyStore) MarkOutboxAttemptFailed(id uint, sendErr error, maxAttempts int) error {
+	if err := f.fault("MarkOutboxAttemptFailed", id, sendErr, maxAttempts); err != nil {
+		return err
+	}
+	return f.Store.MarkOutboxAttemptFailed(id, sendErr, maxAttempts)
+}
+
+func (f *FaultyStore) GetMessageTemplates() ([]MessageTemplate, error) {
+	if err := f.fault("GetMessageTemplates"); err != nil {
+		return nil, err
+	}
+	return f.Store.GetMessageTemplates()
+}
+
+func (f *FaultyStore) GetMessageTemplate(channel, eventType string) (*MessageTemplate, error) {
+	if err := f.fault("GetMessageTemplate", channel, eventType); err != nil {
+		return nil, err
+	}
+	return f.Store.GetMessageTemplate(channel, eventType)
+}
+
+func (f *FaultyStore) SaveMessageTemplate(tmpl *MessageTemplate) error {
+	if err := f.fault("SaveMessageTemplate", tmpl); err != nil {
+		return err
+	}
+	return f.Store.SaveMessageTemplate(tmpl)
+}
+
+func (f *FaultyStore) DeleteMessageTemplate(channel, eventType string) error {
+	if err := f.fault("DeleteMessageTemplate", channel, eventType); err != nil {
+		return err
+	}
+	return f.Store.DeleteMessageTemplate(channel, eventType)
+}
+
+func (f *FaultyStore) GetFeedToken(username string) (*FeedToken, error) {
+	if err := f.fault("GetFeedToken", username); err != nil {
+		return nil, err
+	}
+	return f.Store.GetFeedToken(username)
+}
+
+func (f *FaultyStore) SaveFeedToken(token *FeedToken) error {
+	if err := f.fault("SaveFeedToken", token); err != nil {
+		return err
+	}
+	return f.Store.SaveFeedToken(token)
+}
+
+func (f *FaultyStore) CreateAuditEvent(event *AuditEvent) error {
+	if err := f.fault("CreateAuditEvent", event); err != nil {
+		return err
+	}
+	return f.Store.CreateAuditEvent(event)
+}
+
+func (f *FaultyStore) GetAuditEvents(limit int) ([]AuditEvent, error) {
+	if err := f.fault("GetAuditEvents", limit); err != nil {
+		return nil, err
+	}
+	return f.Store.GetAuditEvents(limit)
+}
+
+func (f *FaultyStore) GetTwoFactor(username string) (*TwoFactor, error) {
+	if err := f.fault("GetTwoFactor", username); err != nil {
+		return nil, err
+	}
+	return f.Store.GetTwoFactor(username)
+}
+
+func (f *FaultyStore) SaveTwoFactor(tf *TwoFactor) error {
+	if err := f.fault("SaveTwoFactor", tf); err != nil {
+		return err
+	}
+	return f.Store.SaveTwoFactor(tf)
+}
+
+func (f *FaultyStore) DeleteTwoFactor(username string) error {
+	if err := f.fault("DeleteTwoFactor", username); err != nil {
+		return err
+	}
+	return f.Store.DeleteTwoFactor(username)
+}
+
+// WithTransaction passes fn a transaction that shares this store's faults.
+func (f *FaultyStore) WithTransaction(fn func(tx Store) error) error {
+	if err := f.fault("WithTransaction"); err != nil {
+		return err
+	}
+	return f.Store.WithTransaction(func(tx Store) error {
+		return fn(&FaultyStore{Store: tx, faults: f.faults})
+	})
+}
@@ -0,0 +1,34 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// FeedToken is the secret that grants read-only access to a user's calendar
+// feed without a login session.
+type FeedToken struct {
+	ID        uint   `gorm:"primaryKey"`
+	Username  string `gorm:"not null;uniqueIndex"`
+	Token     string `gorm:"not null;uniqueIndex"`
+	CreatedAt time.Time
+}
+
+// GetFeedToken returns the feed token for username, or nil when none has
+// been issued yet.
+func (db *DB) GetFeedToken(username string) (*FeedToken, error) {
+	var tokens []FeedToken
+	err := db.Where("username = ?", username).Limit(1).Find(&tokens).Error
+	if err != nil || len(tokens) == 0 {
+		return nil, err
+	}
+	return &tokens[0], nil
+}
+
+func (db *DB) SaveFeedToken(token *FeedToken) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "username"}},
+		DoUpdates: clause.AssignmentColumns([]string{"token", "created_at"}),
+	}).Create(token).Error
+}
@@ -0,0 +1,467 @@
+package database
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/utils"
+)
+
+// MemoryStore is a Store that keeps everything in memory. It behaves like
+// *DB for the services' purposes and lets their tests run without SQLite.
+// Transactions roll back on error but are not isolated from other callers.
+type MemoryStore struct {
+	mu sync.Mutex
+	memoryState
+}
+
+type memoryState struct {
+	subscriptions map[uint]Subscription
+	preferences   map[[2]string]NotificationPreference
+	settings      *NotificationSettings
+	outbox        map[uint]OutboxMessage
+	templates     map[[2]string]MessageTemplate
+	feedTokens    map[string]FeedToken
+	auditEvents   []AuditEvent
+	twoFactors    map[string]TwoFactor
+	nextID        uint
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{memoryState: memoryState{
+		subscriptions: make(map[uint]Subscription),
+		preferences:   make(map[[2]string]NotificationPreference),
+		outbox:        make(map[uint]OutboxMessage),
+		templates:     make(map[[2]string]MessageTemplate),
+		feedTokens:    make(map[string]FeedToken),
+		twoFactors:    make(map[string]TwoFactor),
+	}}
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func (m *MemoryStore) newID() uint {
+	m.nextID++
+	return m.nextID
+}
+
+func (m *MemoryStore) CreateSubscription(sub *Subscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if sub.ID == 0 {
+		sub.ID = m.newID()
+	} else if sub.ID > m.nextID {
+		m.nextID = sub.ID
+	}
+	now := time.Now()
+	if sub.CreatedAt.IsZero() {
+		sub.CreatedAt = now
+	}
+	if sub.UpdatedAt.IsZero() {
+		sub.UpdatedAt = now
+	}
+	sub.normalize()
+	m.subscriptions[sub.ID] = *sub
+	return nil
+}
+
+func (m *MemoryStore) GetSubscriptionByID(id uint) (*Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub, ok := m.subscriptions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &sub, nil
+}
+
+func (m *MemoryStore) GetSubscriptionByName(name string) (*Subscription, error) {
+	subs, _ := m.findSubscriptions(func(s Subscription) bool { return s.Name == name })
+	if len(subs) == 0 {
+		return nil, nil
+	}
+	return &subs[0], nil
+}
+
+func (m *MemoryStore) SubscriptionExists(id uint) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.subscriptions[id]
+	return ok, nil
+}
+
+func (m *MemoryStore) GetAllSubscriptions() ([]Subscription, error) {
+	return m.findSubscriptions(func(Subscription) bool { return true })
+}
+
+func (m *MemoryStore) UpdateSubscription(sub *Subscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub.UpdatedAt = time.Now()
+	sub.normalize()
+	m.subscriptions[sub.ID] = *sub
+	return nil
+}
+
+func (m *MemoryStore) DeleteSubscription(id uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.subscriptions, id)
+	return nil
+}
+
+func (m *MemoryStore) GetUpcomingPayments(days int) ([]Subscription, error) {
+	today := utils.Today()
+	end := today.AddDays(days)
+	return m.findSubscriptions(func(s Subscription) bool {
+		day := utils.DateOf(s.PaymentDate)
+		return s.Status == StatusActive && !day.Before(today) && day.Before(end)
+	})
+}
+
+func (m *MemoryStore) GetEndingTrials(days int) ([]Subscription, error) {
+	today := utils.Today()
+	end := today.AddDays(days)
+	return m.findSubscriptions(func(s Subscription) bool {
+		if s.TrialEndDate == nil || s.Status != StatusActive {
+			return false
+		}
+		day := utils.DateOf(*s.TrialEndDate)
+		return !day.Before(today) && day.Before(end)
+	})
+}
+
+func (m *MemoryStore) GetPastDuePayments() ([]Subscription, error) {
+	today := utils.Today()
+	return m.findSubscriptions(func(s Subscription) bool {
+		return utils.DateOf(s.PaymentDate).Before(today)
+	})
+}
+
+// findSubscriptions returns the matching subscriptions ordered by ID, like
+// the queries in *DB.
+func (m *MemoryStore) findSubscriptions(match func(Subscription) bool) ([]Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var subs []Subscription
+	for _, sub := range m.subscriptions {
+		if match(sub) {
+			subs = append(subs, sub)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+	return subs, nil
+}
+
+func (m *MemoryStore) GetNotificationPreferences() ([]NotificationPreference, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prefs := make([]NotificationPreference, 0, len(m.preferences))
+	for _, pref := range m.preferences {
+		prefs = append(prefs, pref)
+	}
+	sort.Slice(prefs, func(i, j int) bool {
+		if prefs[i].Channel != prefs[j].Channel {
+			return prefs[i].Channel < prefs[j].Channel
+		}
+		return prefs[i].EventType < prefs[j].EventType
+	})
+	return prefs, nil
+}
+
+func (m *MemoryStore) SetNotificationPreference(channel, eventType string, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := [2]string{channel, eventType}
+	pref, ok := m.preferences[key]
+	if !ok {
+		pref = NotificationPreference{ID: m.newID(), Channel: channel, EventType: eventType}
+	}
+	pref.Enabled = enabled
+	pref.UpdatedAt = time.Now()
+	m.preferences[key] = pref
+	return nil
+}
+
+func (m *MemoryStore) GetNotificationSettings() (*NotificationSettings, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.settings == nil {
+		return &NotificationSettings{ID: settingsID}, nil
+	}
+	settings := *m.settings
+	return &settings, nil
+}
+
+func (m *MemoryStore) SaveNotificationSettings(settings *NotificationSettings) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	settings.ID = settingsID
+	settings.UpdatedAt = time.Now()
+	saved := *settings
+	m.settings = &saved
+	return nil
+}
+
+func (m *MemoryStore) CreateOutboxMessage(msg *OutboxMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if msg.Status == "" {
+		msg.Status = OutboxPending
+	}
+	msg.ID = m.newID()
+	msg.CreatedAt = time.Now()
+	m.outbox[msg.ID] = *msg
+	return nil
+}
+
+func (m *MemoryStore) GetPendingOutboxMessages(limit int) ([]OutboxMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var msgs []OutboxMessage
+	for _, msg := range m.outbox {
+		if msg.Status == OutboxPending {
+			msgs = append(msgs, msg)
+		}
+	}
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].ID < msgs[j].ID })
+	if limit >= 0 && len(msgs) > limit {
+		msgs = msgs[:limit]
+	}
+	return msgs, nil
+}
+
+func (m *MemoryStore) MarkOutboxSent(id uint, sentAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	msg, ok := m.outbox[id]
+	if !ok {
+		return nil
+	}
+	msg.Status = OutboxSent
+	msg.SentAt = &sentAt
+	msg.Attempts++
+	msg.LastError = ""
+	m.outbox[id] = msg
+	return nil
+}
+
+func (m *MemoryStore) MarkOutboxAttemptFailed(id uint, sendErr error, maxAttempts int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	msg, ok := m.outbox[id]
+	if !ok {
+		return ErrNotFound
+	}
+	msg.Attempts++
+	msg.LastError = sendErr.Error()
+	if msg.Attempts >= maxAttempts {
+		msg.Status = OutboxFailed
+	}
+	m.outbox[id] = msg
+	return nil
+}
+
+// OutboxMessages returns every outbox message regardless of status, ordered
+// by ID, so tests can inspect what was queued.
+func (m *MemoryStore) OutboxMessages() []OutboxMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	msgs := make([]OutboxMessage, 0, len(m.outbox))
+	for _, msg := range m.outbox {
+		msgs = append(msgs, msg)
+	}
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].ID < msgs[j].ID })
+	return msgs
+}
+
+func (m *MemoryStore) GetMessageTemplates() ([]MessageTemplate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tmpls := make([]MessageTemplate, 0, len(m.templates))
+	for _, tmpl := range m.templates {
+		tmpls = append(tmpls, tmpl)
+	}
+	sort.Slice(tmpls, func(i, j int) bool {
+		if tmpls[i].Channel != tmpls[j].Channel {
+			return tmpls[i].Channel < tmpls[j].Channel
+		}
+		return tmpls[i].EventType < tmpls[j].EventType
+	})
+	return tmpls, nil
+}
+
+func (m *MemoryStore) GetMessageTemplate(channel, eventType string) (*MessageTemplate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tmpl, ok := m.templates[[2]string{channel, eventType}]
+	if !ok {
+		return nil, nil
+	}
+	return &tmpl, nil
+}
+
+func (m *MemoryStore) SaveMessageTemplate(tmpl *MessageTemplate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := [2]string{tmpl.Channel, tmpl.EventType}
+	if existing, ok := m.templates[key]; ok {
+		tmpl.ID = existing.ID
+	} else {
+		tmpl.ID = m.newID()
+	}
+	tmpl.UpdatedAt = time.Now()
+	m.templates[key] = *tmpl
+	return nil
+}
+
+func (m *MemoryStore) DeleteMessageTemplate(channel, eventType string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.templates, [2]string{channel, eventType})
+	return nil
+}
+
+func (m *MemoryStore) GetFeedToken(username string) (*FeedToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.feedTokens[username]
+	if !ok {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+func (m *MemoryStore) SaveFeedToken(token *FeedToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing, ok := m.feedTokens[token.Username]; ok {
+		token.ID = existing.ID
+	} else {
+		token.ID = m.newID()
+	}
+	token.CreatedAt = time.Now()
+	m.feedTokens[token.Username] = *token
+	return nil
+}
+
+func (m *MemoryStore) CreateAuditEvent(event *AuditEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	event.ID = m.newID()
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	m.auditEvents = append(m.auditEvents, *event)
+	return nil
+}
+
+func (m *MemoryStore) GetAuditEvents(limit int) ([]AuditEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	events := slices.Clone(m.auditEvents)
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].CreatedAt.Equal(events[j].CreatedAt) {
+			return events[i].CreatedAt.After(events[j].CreatedAt)
+		}
+		return events[i].ID > events[j].ID
+	})
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+func (m *MemoryStore) GetTwoFactor(username string) (*TwoFactor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tf, ok := m.twoFactors[username]
+	if !ok {
+		return nil, nil
+	}
+	return &tf, nil
+}
+
+func (m *MemoryStore) SaveTwoFactor(tf *TwoFactor) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if existing, ok := m.twoFactors[tf.Username]; ok {
+		tf.ID = existing.ID
+		tf.CreatedAt = existing.CreatedAt
+	} else {
+		tf.ID = m.newID()
+		tf.CreatedAt = now
+	}
+	tf.UpdatedAt = now
+	m.twoFactors[tf.Username] = *tf
+	return nil
+}
+
+func (m *MemoryStore) DeleteTwoFactor(username string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.twoFactors, username)
+	return nil
+}
+
+// WithTransaction runs fn against the store itself and restores the previous
+// contents if fn fails.
+func (m *MemoryStore) WithTransaction(fn func(tx Store) error) error {
+	m.mu.Lock()
+	saved := m.memoryState.clone()
+	m.mu.Unlock()
+
+	if err := fn(m); err != nil {
+		m.mu.Lock()
+		m.memoryState = saved
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (s memoryState) clone() memoryState {
+	c := s
+	c.subscriptions = maps.Clone(s.subscriptions)
+	c.preferences = maps.Clone(s.preferences)
+	c.outbox = maps.Clone(s.outbox)
+	c.templates = maps.Clone(s.templates)
+	c.feedTokens = maps.Clone(s.feedTokens)
+	c.auditEvents = slices.Clone(s.auditEvents)
+	c.twoFactors = maps.Clone(s.twoFactors)
+	if s.settings != nil {
+		settings := *s.settings
+		c.settings = &settings
+	}
+	return c
+}
@@ -0,0 +1,259 @@
+package database
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+// ErrSchemaTooNew is returned when the database has migrations applied that
+// this build does not know about, i.e. it was last used by a newer version.
+var ErrSchemaTooNew = errors.New("database schema is newer than this version of subtrack supports")
+
+// Migration is one versioned schema change. Files in migrations/<driver>/
+// are named NNNN_name.up.sql and NNNN_name.down.sql; every driver has the
+// same set of migrations written in its own SQL dialect.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus pairs a migration with the time it was applied, which is
+// nil for pending migrations.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// schemaVersion records each applied migration.
+type schemaVersion struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaVersion) TableName() string {
+	return "schema_version"
+}
+
+var migrations = mustLoadMigrations()
+
+func mustLoadMigrations() map[string][]Migration {
+	byDriver := make(map[string][]Migration)
+	for _, driver := range []string{DriverSQLite, DriverPostgres} {
+		m, err := loadMigrations(migrationFiles, path.Join("migrations", driver))
+		if err != nil {
+			panic(err)
+		}
+		byDriver[driver] = m
+	}
+
+	reference := byDriver[DriverSQLite]
+	for driver, m := range byDriver {
+		if len(m) != len(reference) {
+			panic(fmt.Sprintf("%s has %d migrations, sqlite has %d", driver, len(m), len(reference)))
+		}
+		for i := range m {
+			if m[i].Name != reference[i].Name {
+				panic(fmt.Sprintf("%s migration %d is %s, sqlite has %s", driver, m[i].Version, m[i].Name, reference[i].Name))
+			}
+		}
+	}
+	return byDriver
+}
+
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		file := entry.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(file, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name: %s", file)
+		}
+		num, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(num)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration file name: %s", file)
+		}
+
+		body, err := fs.ReadFile(fsys, path.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	result := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	for i, m := range result {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration versions must be consecutive from 1, found %d at position %d", m.Version, i+1)
+		}
+	}
+	return result, nil
+}
+
+// LatestSchemaVersion is the version the newest migration brings the
+// database to.
+func LatestSchemaVersion() int {
+	return len(migrations[DriverSQLite])
+}
+
+func (db *DB) migrations() []Migration {
+	return migrations[db.Driver()]
+}
+
+func (db *DB) appliedVersions() ([]schemaVersion, error) {
+	err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		version integer PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamp NOT NULL
+	)`).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	var applied []schemaVersion
+	if err := db.Order("version").Find(&applied).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return applied, nil
+}
+
+// SchemaVersion returns the highest applied migration version, or 0 for an
+// empty database.
+func (db *DB) SchemaVersion() (int, error) {
+	applied, err := db.appliedVersions()
+	if err != nil || len(applied) == 0 {
+		return 0, err
+	}
+	return applied[len(applied)-1].Version, nil
+}
+
+// CheckSchemaVersion returns ErrSchemaTooNew when the database has been
+// migrated past what this build knows.
+func (db *DB) CheckSchemaVersion() error {
+	version, err := db.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if version > LatestSchemaVersion() {
+		return fmt.Errorf("%w (database is at version %d, latest known is %d)", ErrSchemaTooNew, version, LatestSchemaVersion())
+	}
+	return nil
+}
+
+// MigrationStatus lists every known migration and when it was applied.
+func (db *DB) MigrationStatus() ([]MigrationStatus, error) {
+	applied, err := db.appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	appliedAt := make(map[int]time.Time, len(applied))
+	for _, a := range applied {
+		appliedAt[a.Version] = a.AppliedAt
+	}
+
+	status := make([]MigrationStatus, LatestSchemaVersion())
+	for i, m := range db.migrations() {
+		status[i] = MigrationStatus{Migration: m}
+		if at, ok := appliedAt[m.Version]; ok {
+			status[i].AppliedAt = &at
+		}
+	}
+	return status, nil
+}
+
+// MigrateUp applies every pending migration in order, each in its own
+// transaction, and returns the ones it applied. The first migrations use
+// CREATE ... IF NOT EXISTS, so databases created before versioned
+// migrations existed are adopted as they are.
+func (db *DB) MigrateUp() ([]Migration, error) {
+	if err := db.CheckSchemaVersion(); err != nil {
+		return nil, err
+	}
+	version, err := db.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range db.migrations()[version:] {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(m.Up).Error; err != nil {
+				return err
+			}
+			return tx.Create(&schemaVersion{Version: m.Version, Name: m.Name, AppliedAt: time.Now().UTC()}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// MigrateDown reverts the latest steps applied migrations, newest first, and
+// returns the ones it reverted.
+func (db *DB) MigrateDown(steps int) ([]Migration, error) {
+	if err := db.CheckSchemaVersion(); err != nil {
+		return nil, err
+	}
+	version, err := db.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for ; steps > 0 && version > 0; steps, version = steps-1, version-1 {
+		m := db.migrations()[version-1]
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(m.Down).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&schemaVersion{}, m.Version).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("reverting migration %d_%s failed: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
@@ -0,0 +1,184 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// schemaObjects returns the tables and indexes in the database, excluding
+// schema_version and the database's own bookkeeping.
+func schemaObjects(t *testing.T, db *DB) []string {
+	t.Helper()
+	query := `SELECT type || ' ' || name FROM sqlite_master
+		WHERE name NOT LIKE 'sqlite_%' AND name != 'schema_version' ORDER BY 1`
+	if db.Driver() == DriverPostgres {
+		query = `SELECT 'table ' || tablename FROM pg_tables
+			WHERE schemaname = 'public' AND tablename != 'schema_version'
+		UNION ALL
+		SELECT 'index ' || indexname FROM pg_indexes
+			WHERE schemaname = 'public' AND tablename != 'schema_version' AND indexname NOT LIKE '%_pkey'
+		ORDER BY 1`
+	}
+
+	var names []string
+	if err := db.Raw(query).Scan(&names).Error; err != nil {
+		t.Fatalf("failed to list schema: %v", err)
+	}
+	return names
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	db := setupTestDB(t)
+
+	version, err := db.SchemaVersion()
+	if err != nil {
+		t.Fatalf("SchemaVersion() error = %v", err)
+	}
+	if version != LatestSchemaVersion() {
+		t.Errorf("SchemaVersion() = %d, want %d", version, LatestSchemaVersion())
+	}
+
+	status, err := db.MigrationStatus()
+	if err != nil {
+		t.Fatalf("MigrationStatus() error = %v", err)
+	}
+	for _, s := range status {
+		if s.AppliedAt == nil {
+			t.Errorf("migration %d_%s not applied", s.Version, s.Name)
+		}
+	}
+
+	// Reverting each migration must leave exactly the schema of the version
+	// before it, and re-applying must restore the full schema.
+	full := schemaObjects(t, db)
+	var schemas [][]string
+	for v := LatestSchemaVersion(); v > 0; v-- {
+		if _, err := db.MigrateDown(1); err != nil {
+			t.Fatalf("MigrateDown() from %d error = %v", v, err)
+		}
+		schemas = append(schemas, schemaObjects(t, db))
+	}
+	if got := schemas[len(schemas)-1]; len(got) != 0 {
+		t.Errorf("schema after reverting everything = %v", got)
+	}
+
+	applied, err := db.MigrateUp()
+	if err != nil {
+		t.Fatalf("MigrateUp() error = %v", err)
+	}
+	if len(applied) != LatestSchemaVersion() {
+		t.Errorf("MigrateUp() applied %d migrations, want %d", len(applied), LatestSchemaVersion())
+	}
+	if got := schemaObjects(t, db); !reflect.DeepEqual(got, full) {
+		t.Errorf("schema after re-applying = %v, want %v", got, full)
+	}
+
+	if applied, err := db.MigrateUp(); err != nil || len(applied) != 0 {
+		t.Errorf("second MigrateUp() = %d migrations, %v; want none", len(applied), err)
+	}
+}
+
+func TestNew_AdoptsAutoMigratedDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subtrack.db")
+
+	// Databases created before versioned migrations only have the
+	// subscriptions table as GORM's AutoMigrate made it at the time.
+	type legacySubscription struct {
+		ID          uint      `gorm:"primaryKey"`
+		Name        string    `gorm:"not null"`
+		Price       float64   `gorm:"not null"`
+		Currency    string    `gorm:"not null"`
+		Cycle       string    `gorm:"not null"`
+		PaymentDate time.Time `gorm:"not null"`
+		CreatedAt   time.Time
+		UpdatedAt   time.Time
+	}
+	legacy, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := legacy.Table("subscriptions").AutoMigrate(&legacySubscription{}); err != nil {
+		t.Fatal(err)
+	}
+	sub := &legacySubscription{Name: "Netflix", Price: 15.99, Currency: "USD", Cycle: "monthly", PaymentDate: time.Now()}
+	if err := legacy.Table("subscriptions").Create(sub).Error; err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := legacy.DB()
+	sqlDB.Close()
+
+	db, err := New(path)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer db.Close()
+
+	subs, err := db.GetAllSubscriptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 1 || subs[0].Name != "Netflix" || subs[0].Status != StatusActive {
+		t.Errorf("subscriptions after adoption = %+v", subs)
+	}
+	if version, _ := db.SchemaVersion(); version != LatestSchemaVersion() {
+		t.Errorf("SchemaVersion() = %d, want %d", version, LatestSchemaVersion())
+	}
+}
+
+func TestNew_RefusesNewerSchema(t *testing.T) {
+	dsn := testDSN(t)
+
+	db, err := New(dsn)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	future := schemaVersion{Version: LatestSchemaVersion() + 1, Name: "from_the_future", AppliedAt: time.Now()}
+	if err := db.Create(&future).Error; err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if _, err := New(dsn); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("New() error = %v, want ErrSchemaTooNew", err)
+	}
+
+	db, err = Open(dsn)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+	if _, err := db.MigrateDown(1); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("MigrateDown() error = %v, want ErrSchemaTooNew", err)
+	}
+}
+
+func TestLoadMigrations_Invalid(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"missing down": {
+			"m/0001_a.up.sql": {Data: []byte("SELECT 1;")},
+		},
+		"gap": {
+			"m/0001_a.up.sql":   {Data: []byte("SELECT 1;")},
+			"m/0001_a.down.sql": {Data: []byte("SELECT 1;")},
+			"m/0003_c.up.sql":   {Data: []byte("SELECT 1;")},
+			"m/0003_c.down.sql": {Data: []byte("SELECT 1;")},
+		},
+		"bad name": {
+			"m/first.up.sql": {Data: []byte("SELECT 1;")},
+		},
+	}
+
+	for name, fsys := range tests {
+		if _, err := loadMigrations(fsys, "m"); err == nil {
+			t.Errorf("%s: loadMigrations() succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS subscriptions (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL,
+    price double precision NOT NULL,
+    currency text NOT NULL,
+    cycle text NOT NULL,
+    payment_date timestamptz NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz
+);
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    id bigserial PRIMARY KEY,
+    channel text NOT NULL,
+    event_type text NOT NULL,
+    enabled boolean NOT NULL,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_pref_channel_event ON notification_preferences (channel, event_type);
+
+CREATE TABLE IF NOT EXISTS notification_settings (
+    id bigserial PRIMARY KEY,
+    quiet_start bigint NOT NULL DEFAULT 0,
+    quiet_end bigint NOT NULL DEFAULT 0,
+    updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS outbox_messages (
+    id bigserial PRIMARY KEY,
+    channel text NOT NULL,
+    event_type text NOT NULL,
+    body text NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    attempts bigint NOT NULL DEFAULT 0,
+    last_error text,
+    created_at timestamptz,
+    sent_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_messages_status ON outbox_messages (status);
+CREATE INDEX IF NOT EXISTS idx_outbox_messages_channel ON outbox_messages (channel);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS feed_tokens (
+    id bigserial PRIMARY KEY,
+    username text NOT NULL,
+    token text NOT NULL,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_feed_tokens_username ON feed_tokens (username);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_feed_tokens_token ON feed_tokens (token);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_subscriptions_status;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS status;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS category;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS two_factors (
+    id bigserial PRIMARY KEY,
+    username text NOT NULL,
+    secret text NOT NULL,
+    recovery_codes text NOT NULL DEFAULT '',
+    last_step bigint NOT NULL DEFAULT 0,
+    confirmed_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_two_factors_username ON two_factors (username);
@@ -0,0 +1 @@
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS payment_day integer NOT NULL DEFAULT 0;
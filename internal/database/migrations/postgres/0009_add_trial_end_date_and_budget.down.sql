@@ -0,0 +1,3 @@
+ALTER TABLE notification_settings DROP COLUMN IF EXISTS budget_currency;
+ALTER TABLE notification_settings DROP COLUMN IF EXISTS budget_amount;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS trial_end_date;
@@ -0,0 +1,3 @@
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS trial_end_date timestamptz;
+ALTER TABLE notification_settings ADD COLUMN IF NOT EXISTS budget_amount double precision NOT NULL DEFAULT 0;
+ALTER TABLE notification_settings ADD COLUMN IF NOT EXISTS budget_currency text NOT NULL DEFAULT '';
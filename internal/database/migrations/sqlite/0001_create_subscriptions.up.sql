@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS `subscriptions` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `name` text NOT NULL,
+    `price` real NOT NULL,
+    `currency` text NOT NULL,
+    `cycle` text NOT NULL,
+    `payment_date` datetime NOT NULL,
+    `created_at` datetime,
+    `updated_at` datetime
+);
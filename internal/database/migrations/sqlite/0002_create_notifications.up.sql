@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS `notification_preferences` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `channel` text NOT NULL,
+    `event_type` text NOT NULL,
+    `enabled` numeric NOT NULL,
+    `updated_at` datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_pref_channel_event` ON `notification_preferences` (`channel`, `event_type`);
+
+CREATE TABLE IF NOT EXISTS `notification_settings` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `quiet_start` integer NOT NULL DEFAULT 0,
+    `quiet_end` integer NOT NULL DEFAULT 0,
+    `updated_at` datetime
+);
+
+CREATE TABLE IF NOT EXISTS `outbox_messages` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `channel` text NOT NULL,
+    `event_type` text NOT NULL,
+    `body` text NOT NULL,
+    `status` text NOT NULL DEFAULT 'pending',
+    `attempts` integer NOT NULL DEFAULT 0,
+    `last_error` text,
+    `created_at` datetime,
+    `sent_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_outbox_messages_status` ON `outbox_messages` (`status`);
+CREATE INDEX IF NOT EXISTS `idx_outbox_messages_channel` ON `outbox_messages` (`channel`);
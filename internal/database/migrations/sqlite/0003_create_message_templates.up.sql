@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS `message_templates` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `channel` text NOT NULL,
+    `event_type` text NOT NULL,
+    `body` text NOT NULL,
+    `updated_at` datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_template_channel_event` ON `message_templates` (`channel`, `event_type`);
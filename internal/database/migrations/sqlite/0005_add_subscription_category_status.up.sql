@@ -0,0 +1,3 @@
+ALTER TABLE `subscriptions` ADD COLUMN `category` text NOT NULL DEFAULT '';
+ALTER TABLE `subscriptions` ADD COLUMN `status` text NOT NULL DEFAULT 'active';
+CREATE INDEX IF NOT EXISTS `idx_subscriptions_status` ON `subscriptions` (`status`);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS `audit_events` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `action` text NOT NULL,
+    `username` text NOT NULL DEFAULT '',
+    `ip` text NOT NULL DEFAULT '',
+    `detail` text NOT NULL DEFAULT '',
+    `created_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_audit_events_action` ON `audit_events` (`action`);
+CREATE INDEX IF NOT EXISTS `idx_audit_events_created_at` ON `audit_events` (`created_at`);
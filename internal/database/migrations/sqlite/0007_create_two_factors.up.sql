@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS `two_factors` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `username` text NOT NULL,
+    `secret` text NOT NULL,
+    `recovery_codes` text NOT NULL DEFAULT '',
+    `last_step` integer NOT NULL DEFAULT 0,
+    `confirmed_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_two_factors_username` ON `two_factors` (`username`);
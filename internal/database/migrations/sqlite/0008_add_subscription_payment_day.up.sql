@@ -0,0 +1 @@
+ALTER TABLE `subscriptions` ADD COLUMN `payment_day` integer NOT NULL DEFAULT 0;
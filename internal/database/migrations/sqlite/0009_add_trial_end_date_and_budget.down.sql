@@ -0,0 +1,3 @@
+ALTER TABLE `notification_settings` DROP COLUMN `budget_currency`;
+ALTER TABLE `notification_settings` DROP COLUMN `budget_amount`;
+ALTER TABLE `subscriptions` DROP COLUMN `trial_end_date`;
@@ -0,0 +1,3 @@
+ALTER TABLE `subscriptions` ADD COLUMN `trial_end_date` datetime;
+ALTER TABLE `notification_settings` ADD COLUMN `budget_amount` real NOT NULL DEFAULT 0;
+ALTER TABLE `notification_settings` ADD COLUMN `budget_currency` text NOT NULL DEFAULT '';
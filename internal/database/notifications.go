@@ -0,0 +1,121 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxFailed  = "failed"
+)
+
+// NotificationPreference records whether a channel receives an event type.
+// Channel and event combinations without a row are enabled.
+type NotificationPreference struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Channel   string    `gorm:"not null;uniqueIndex:idx_pref_channel_event" json:"channel"`
+	EventType string    `gorm:"not null;uniqueIndex:idx_pref_channel_event" json:"event_type"`
+	Enabled   bool      `gorm:"not null" json:"enabled"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NotificationSettings is a single-row table of user-wide settings. Quiet
+// hours are minutes after midnight in the configured location; equal start
+// and end disable them. The budget is a monthly amount in BudgetCurrency; a
+// zero amount disables it.
+type NotificationSettings struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	QuietStart     int       `gorm:"not null;default:0" json:"quiet_start"`
+	QuietEnd       int       `gorm:"not null;default:0" json:"quiet_end"`
+	BudgetAmount   float64   `gorm:"not null;default:0" json:"budget_amount"`
+	BudgetCurrency string    `gorm:"not null;default:''" json:"budget_currency"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// OutboxMessage is a rendered notification waiting to be delivered.
+type OutboxMessage struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	Channel   string     `gorm:"not null;index" json:"channel"`
+	EventType string     `gorm:"not null" json:"event_type"`
+	Body      string     `gorm:"not null" json:"body"`
+	Status    string     `gorm:"not null;index;default:pending" json:"status"`
+	Attempts  int        `gorm:"not null;default:0" json:"attempts"`
+	LastError string     `json:"last_error"`
+	CreatedAt time.Time  `json:"created_at"`
+	SentAt    *time.Time `json:"sent_at"`
+}
+
+const settingsID = 1
+
+func (db *DB) GetNotificationPreferences() ([]NotificationPreference, error) {
+	var prefs []NotificationPreference
+	err := db.Order("channel, event_type").Find(&prefs).Error
+	return prefs, err
+}
+
+func (db *DB) SetNotificationPreference(channel, eventType string, enabled bool) error {
+	pref := &NotificationPreference{Channel: channel, EventType: eventType, Enabled: enabled}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "channel"}, {Name: "event_type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "updated_at"}),
+	}).Create(pref).Error
+}
+
+func (db *DB) GetNotificationSettings() (*NotificationSettings, error) {
+	var settings []NotificationSettings
+	if err := db.Where("id = ?", settingsID).Limit(1).Find(&settings).Error; err != nil {
+		return nil, err
+	}
+	if len(settings) == 0 {
+		return &NotificationSettings{ID: settingsID}, nil
+	}
+	return &settings[0], nil
+}
+
+func (db *DB) SaveNotificationSettings(settings *NotificationSettings) error {
+	settings.ID = settingsID
+	return db.Save(settings).Error
+}
+
+func (db *DB) CreateOutboxMessage(msg *OutboxMessage) error {
+	if msg.Status == "" {
+		msg.Status = OutboxPending
+	}
+	return db.Create(msg).Error
+}
+
+// GetPendingOutboxMessages returns undelivered messages, oldest first.
+func (db *DB) GetPendingOutboxMessages(limit int) ([]OutboxMessage, error) {
+	var msgs []OutboxMessage
+	err := db.Where("status = ?", OutboxPending).Order("id").Limit(limit).Find(&msgs).Error
+	return msgs, err
+}
+
+func (db *DB) MarkOutboxSent(id uint, sentAt time.Time) error {
+	return db.Model(&OutboxMessage{}).Where("id = ?", id).Updates(map[string]any{
+		"status":     OutboxSent,
+		"sent_at":    sentAt,
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": "",
+	}).Error
+}
+
+// MarkOutboxAttemptFailed records a failed delivery. The message stays
+// pending until it has been tried maxAttempts times.
+func (db *DB) MarkOutboxAttemptFailed(id uint, sendErr error, maxAttempts int) error {
+	var msg OutboxMessage
+	if err := db.First(&msg, id).Error; err != nil {
+		return err
+	}
+
+	msg.Attempts++
+	msg.LastError = sendErr.Error()
+	if msg.Attempts >= maxAttempts {
+		msg.Status = OutboxFailed
+	}
+	return db.Save(&msg).Error
+}
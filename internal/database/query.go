@@ -0,0 +1,313 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SortKey orders subscription listings. Ties are broken by ID.
+type SortKey string
+
+const (
+	SortNextPayment SortKey = "next_payment"
+	SortPrice       SortKey = "price"
+	SortName        SortKey = "name"
+)
+
+var SortKeys = []SortKey{SortNextPayment, SortPrice, SortName}
+
+// ErrInvalidCursor is returned for cursors that were not produced by the
+// same query's previous page.
+var ErrInvalidCursor = errors.New("invalid or expired page cursor")
+
+// SubscriptionQuery selects, orders and pages subscriptions. Empty fields do
+// not filter. Filters within one field match any of the values.
+type SubscriptionQuery struct {
+	// Search matches names containing it, ignoring case. SQLite only folds
+	// the case of ASCII letters.
+	Search string
+	// Currencies and Categories match ignoring case.
+	Currencies []string
+	Categories []string
+	Cycles     []string
+	Statuses   []string
+	MinPrice   *float64
+	MaxPrice   *float64
+
+	// Sort defaults to SortNextPayment.
+	Sort SortKey
+	Desc bool
+
+	// Limit is the page size; zero returns everything. Cursor is the
+	// NextCursor of the previous page.
+	Limit  int
+	Cursor string
+}
+
+type SubscriptionPage struct {
+	Subscriptions []Subscription
+	// NextCursor fetches the following page, or is empty on the last page.
+	NextCursor string
+}
+
+// pageCursor is the position after the last row of a page: its sort value
+// and ID. Sort and Desc tie the cursor to the ordering it was made for.
+// Name is kept as stored, so the query lowercases it with the same LOWER it
+// sorts by; Go's Unicode lowercasing would not match SQLite's ASCII-only
+// one.
+type pageCursor struct {
+	Sort  SortKey   `json:"s"`
+	Desc  bool      `json:"d,omitempty"`
+	ID    uint      `json:"i"`
+	Date  time.Time `json:"t,omitempty"`
+	Price float64   `json:"p,omitempty"`
+	Name  string    `json:"n,omitempty"`
+}
+
+func (q SubscriptionQuery) sortKey() (SortKey, error) {
+	switch q.Sort {
+	case "":
+		return SortNextPayment, nil
+	case SortNextPayment, SortPrice, SortName:
+		return q.Sort, nil
+	default:
+		return "", fmt.Errorf("unknown sort key %q", q.Sort)
+	}
+}
+
+func (q SubscriptionQuery) decodeCursor(key SortKey) (*pageCursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c pageCursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.Sort != key || c.Desc != q.Desc {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+func newCursor(key SortKey, desc bool, last Subscription) string {
+	c := pageCursor{Sort: key, Desc: desc, ID: last.ID}
+	switch key {
+	case SortNextPayment:
+		c.Date = last.PaymentDate.UTC()
+	case SortPrice:
+		c.Price = last.Price
+	case SortName:
+		c.Name = last.Name
+	}
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// FindSubscriptions returns one page of the subscriptions matching q.
+func (db *DB) FindSubscriptions(q SubscriptionQuery) (*SubscriptionPage, error) {
+	key, err := q.sortKey()
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := q.decodeCursor(key)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := db.Model(&Subscription{})
+	if q.Search != "" {
+		tx = tx.Where(`LOWER(name) LIKE LOWER(?) ESCAPE '\'`, "%"+escapeLike(q.Search)+"%")
+	}
+	if len(q.Currencies) > 0 {
+		tx = tx.Where("UPPER(currency) IN ?", mapStrings(q.Currencies, strings.ToUpper))
+	}
+	if len(q.Categories) > 0 {
+		tx = tx.Where("LOWER(category) IN ?", mapStrings(q.Categories, strings.ToLower))
+	}
+	if len(q.Cycles) > 0 {
+		tx = tx.Where("cycle IN ?", q.Cycles)
+	}
+	if len(q.Statuses) > 0 {
+		tx = tx.Where("status IN ?", q.Statuses)
+	}
+	if q.MinPrice != nil {
+		tx = tx.Where("price >= ?", *q.MinPrice)
+	}
+	if q.MaxPrice != nil {
+		tx = tx.Where("price <= ?", *q.MaxPrice)
+	}
+
+	var column string
+	var value any
+	placeholder := "?"
+	switch key {
+	case SortNextPayment:
+		column = "payment_date"
+		if cursor != nil {
+			value = cursor.Date
+		}
+	case SortPrice:
+		column = "price"
+		if cursor != nil {
+			value = cursor.Price
+		}
+	case SortName:
+		column, placeholder = "LOWER(name)", "LOWER(?)"
+		if cursor != nil {
+			value = cursor.Name
+		}
+	}
+
+	op, dir := ">", "ASC"
+	if q.Desc {
+		op, dir = "<", "DESC"
+	}
+	if cursor != nil {
+		tx = tx.Where(fmt.Sprintf("((%[1]s %[2]s %[3]s) OR (%[1]s = %[3]s AND id %[2]s ?))", column, op, placeholder), value, value, cursor.ID)
+	}
+	tx = tx.Order(column + " " + dir).Order("id " + dir)
+	if q.Limit > 0 {
+		tx = tx.Limit(q.Limit + 1)
+	}
+
+	var subs []Subscription
+	if err := tx.Find(&subs).Error; err != nil {
+		return nil, err
+	}
+	return newPage(subs, key, q), nil
+}
+
+// newPage trims the extra row fetched to detect a following page.
+func newPage(subs []Subscription, key SortKey, q SubscriptionQuery) *SubscriptionPage {
+	page := &SubscriptionPage{Subscriptions: subs}
+	if q.Limit > 0 && len(subs) > q.Limit {
+		page.Subscriptions = subs[:q.Limit]
+		page.NextCursor = newCursor(key, q.Desc, subs[q.Limit-1])
+	}
+	return page
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+func mapStrings(values []string, fn func(string) string) []string {
+	mapped := make([]string, len(values))
+	for i, v := range values {
+		mapped[i] = fn(v)
+	}
+	return mapped
+}
+
+// lowerASCII lowercases s like SQLite's LOWER, which leaves non-ASCII
+// letters alone.
+func lowerASCII(s string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindSubscriptions filters and orders in memory with the same semantics as
+// the SQL version.
+func (m *MemoryStore) FindSubscriptions(q SubscriptionQuery) (*SubscriptionPage, error) {
+	key, err := q.sortKey()
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := q.decodeCursor(key)
+	if err != nil {
+		return nil, err
+	}
+
+	search := lowerASCII(q.Search)
+	subs, _ := m.findSubscriptions(func(s Subscription) bool {
+		switch {
+		case search != "" && !strings.Contains(lowerASCII(s.Name), search),
+			len(q.Currencies) > 0 && !containsFold(q.Currencies, s.Currency),
+			len(q.Categories) > 0 && !containsFold(q.Categories, s.Category),
+			len(q.Cycles) > 0 && !containsFold(q.Cycles, s.Cycle),
+			len(q.Statuses) > 0 && !containsFold(q.Statuses, s.Status),
+			q.MinPrice != nil && s.Price < *q.MinPrice,
+			q.MaxPrice != nil && s.Price > *q.MaxPrice:
+			return false
+		}
+		return true
+	})
+
+	// compare orders a before b (-1) or after it (1) by the sort key, then ID.
+	compare := func(a Subscription, bID uint, bDate time.Time, bPrice float64, bName string) int {
+		c := 0
+		switch key {
+		case SortNextPayment:
+			c = a.PaymentDate.Compare(bDate)
+		case SortPrice:
+			c = cmpFloat(a.Price, bPrice)
+		case SortName:
+			c = strings.Compare(lowerASCII(a.Name), lowerASCII(bName))
+		}
+		if c == 0 {
+			c = cmpFloat(float64(a.ID), float64(bID))
+		}
+		if q.Desc {
+			c = -c
+		}
+		return c
+	}
+
+	sort.SliceStable(subs, func(i, j int) bool {
+		b := subs[j]
+		return compare(subs[i], b.ID, b.PaymentDate, b.Price, b.Name) < 0
+	})
+
+	if cursor != nil {
+		start := len(subs)
+		for i, s := range subs {
+			if compare(s, cursor.ID, cursor.Date, cursor.Price, cursor.Name) > 0 {
+				start = i
+				break
+			}
+		}
+		subs = subs[start:]
+	}
+	if q.Limit > 0 && len(subs) > q.Limit+1 {
+		subs = subs[:q.Limit+1]
+	}
+	return newPage(subs, key, q), nil
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func (f *FaultyStore) FindSubscriptions(q SubscriptionQuery) (*SubscriptionPage, error) {
+	if err := f.fault("FindSubscriptions", q); err != nil {
+		return nil, err
+	}
+	return f.Store.FindSubscriptions(q)
+}
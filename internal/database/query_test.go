@@ -0,0 +1,228 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func seedQuerySubscriptions(t *testing.T, store Store) {
+	t.Helper()
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	subs := []Subscription{
+		{Name: "Netflix", Price: 15.99, Currency: "USD", Cycle: "monthly", PaymentDate: day.AddDate(0, 0, 9), Category: "Streaming"},
+		{Name: "spotify", Price: 9.99, Currency: "usd", Cycle: "monthly", PaymentDate: day.AddDate(0, 0, 2), Category: "streaming"},
+		{Name: "Gym", Price: 30, Currency: "EUR", Cycle: "monthly", PaymentDate: day.AddDate(0, 0, 2), Category: "Health", Status: StatusPaused},
+		{Name: "Domain", Price: 12, Currency: "EUR", Cycle: "yearly", PaymentDate: day.AddDate(0, 5, 0)},
+		{Name: "100%_Cloud", Price: 9.99, Currency: "USD", Cycle: "yearly", PaymentDate: day.AddDate(0, 1, 0), Status: StatusCancelled},
+	}
+	for i := range subs {
+		if err := store.CreateSubscription(&subs[i]); err != nil {
+			t.Fatalf("CreateSubscription() error = %v", err)
+		}
+	}
+}
+
+func TestStore_FindSubscriptions(t *testing.T) {
+	price := func(p float64) *float64 { return &p }
+
+	tests := []struct {
+		name  string
+		query SubscriptionQuery
+		want  []string
+	}{
+		{"default sorts by next payment then ID", SubscriptionQuery{}, []string{"spotify", "Gym", "Netflix", "100%_Cloud", "Domain"}},
+		{"search ignores case", SubscriptionQuery{Search: "FLIX"}, []string{"Netflix"}},
+		{"search escapes wildcards", SubscriptionQuery{Search: "%_c"}, []string{"100%_Cloud"}},
+		{"currency ignores case", SubscriptionQuery{Currencies: []string{"usd"}}, []string{"spotify", "Netflix", "100%_Cloud"}},
+		{"category ignores case", SubscriptionQuery{Categories: []string{"STREAMING"}}, []string{"spotify", "Netflix"}},
+		{"several statuses", SubscriptionQuery{Statuses: []string{StatusPaused, StatusCancelled}}, []string{"Gym", "100%_Cloud"}},
+		{"cycle", SubscriptionQuery{Cycles: []string{"yearly"}}, []string{"100%_Cloud", "Domain"}},
+		{"price range is inclusive", SubscriptionQuery{MinPrice: price(9.99), MaxPrice: price(12)}, []string{"spotify", "100%_Cloud", "Domain"}},
+		{"combined filters", SubscriptionQuery{Currencies: []string{"EUR"}, Statuses: []string{StatusActive}}, []string{"Domain"}},
+		{"sort by price", SubscriptionQuery{Sort: SortPrice}, []string{"spotify", "100%_Cloud", "Domain", "Netflix", "Gym"}},
+		{"sort by name descending", SubscriptionQuery{Sort: SortName, Desc: true}, []string{"spotify", "Netflix", "Gym", "Domain", "100%_Cloud"}},
+	}
+
+	stores(t, func(t *testing.T, store Store) {
+		seedQuerySubscriptions(t, store)
+		for _, tt := range tests {
+			page, err := store.FindSubscriptions(tt.query)
+			if err != nil {
+				t.Fatalf("%s: FindSubscriptions() error = %v", tt.name, err)
+			}
+			if got := subscriptionNames(page.Subscriptions); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: FindSubscriptions() = %v, want %v", tt.name, got, tt.want)
+			}
+			if page.NextCursor != "" {
+				t.Errorf("%s: unpaged query returned a cursor", tt.name)
+			}
+		}
+	})
+}
+
+// TestStore_FindSubscriptionsSearch_NonASCII searches names with letters
+// outside ASCII, which SQLite's LOWER leaves alone.
+func TestStore_FindSubscriptionsSearch_NonASCII(t *testing.T) {
+	tests := []struct {
+		search string
+		want   []string
+	}{
+		{"Émile", []string{"Émile"}},
+		{"ÉMILE", []string{"Émile"}},
+		{"mile", []string{"Émile"}},
+		{"zoë", []string{"Zoë"}},
+		{"Über", []string{"Über Eats"}},
+	}
+
+	stores(t, func(t *testing.T, store Store) {
+		for _, name := range []string{"Émile", "Zoë", "Über Eats", "Emily"} {
+			sub := &Subscription{Name: name, Price: 1, Currency: "USD", Cycle: "monthly", PaymentDate: time.Now()}
+			if err := store.CreateSubscription(sub); err != nil {
+				t.Fatalf("CreateSubscription() error = %v", err)
+			}
+		}
+		for _, tt := range tests {
+			page, err := store.FindSubscriptions(SubscriptionQuery{Search: tt.search})
+			if err != nil {
+				t.Fatalf("FindSubscriptions() error = %v", err)
+			}
+			if got := subscriptionNames(page.Subscriptions); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("search %q = %v, want %v", tt.search, got, tt.want)
+			}
+		}
+	})
+}
+
+func TestStore_FindSubscriptionsPagination(t *testing.T) {
+	orders := []SubscriptionQuery{
+		{},
+		{Desc: true},
+		{Sort: SortPrice},
+		{Sort: SortPrice, Desc: true},
+		{Sort: SortName},
+	}
+
+	stores(t, func(t *testing.T, store Store) {
+		seedQuerySubscriptions(t, store)
+		for _, q := range orders {
+			all, err := store.FindSubscriptions(q)
+			if err != nil {
+				t.Fatalf("FindSubscriptions(%+v) error = %v", q, err)
+			}
+
+			var paged []Subscription
+			q.Limit = 2
+			for pages := 0; ; pages++ {
+				if pages > 5 {
+					t.Fatalf("pagination of %+v does not end", q)
+				}
+				page, err := store.FindSubscriptions(q)
+				if err != nil {
+					t.Fatalf("FindSubscriptions(%+v) error = %v", q, err)
+				}
+				if len(page.Subscriptions) > q.Limit {
+					t.Fatalf("page has %d subscriptions, limit is %d", len(page.Subscriptions), q.Limit)
+				}
+				paged = append(paged, page.Subscriptions...)
+				if page.NextCursor == "" {
+					break
+				}
+				q.Cursor = page.NextCursor
+			}
+
+			if got, want := subscriptionNames(paged), subscriptionNames(all.Subscriptions); !reflect.DeepEqual(got, want) {
+				t.Errorf("pages of sort=%s desc=%v = %v, want %v", q.Sort, q.Desc, got, want)
+			}
+		}
+
+		first, _ := store.FindSubscriptions(SubscriptionQuery{Limit: 2})
+		if _, err := store.FindSubscriptions(SubscriptionQuery{Limit: 2, Sort: SortPrice, Cursor: first.NextCursor}); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("cursor reused with another sort: error = %v, want ErrInvalidCursor", err)
+		}
+		if _, err := store.FindSubscriptions(SubscriptionQuery{Cursor: "not a cursor"}); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("garbage cursor: error = %v, want ErrInvalidCursor", err)
+		}
+		if _, err := store.FindSubscriptions(SubscriptionQuery{Sort: "color"}); err == nil {
+			t.Errorf("unknown sort key: expected an error")
+		}
+	})
+}
+
+// TestStore_FindSubscriptionsPagination_NonASCII pages by name through
+// names that SQLite's ASCII-only LOWER leaves alone but Go would lowercase.
+func TestStore_FindSubscriptionsPagination_NonASCII(t *testing.T) {
+	names := []string{"Éclair", "İnternet", "internet", "eclair", "Zoom", "Émile", "apple", "ÉCLAIR"}
+
+	var orders [][]string
+	sameOrder := true
+	stores(t, func(t *testing.T, store Store) {
+		// Postgres lowercases and orders names by its locale, so only
+		// SQLite's order is expected to match the memory store's.
+		if db, ok := store.(*DB); ok && db.Driver() != DriverSQLite {
+			sameOrder = false
+		}
+		for _, name := range names {
+			sub := &Subscription{Name: name, Price: 1, Currency: "USD", Cycle: "monthly", PaymentDate: time.Now()}
+			if err := store.CreateSubscription(sub); err != nil {
+				t.Fatalf("CreateSubscription() error = %v", err)
+			}
+		}
+
+		for _, desc := range []bool{false, true} {
+			all, err := store.FindSubscriptions(SubscriptionQuery{Sort: SortName, Desc: desc})
+			if err != nil {
+				t.Fatalf("FindSubscriptions() error = %v", err)
+			}
+			want := subscriptionNames(all.Subscriptions)
+			orders = append(orders, want)
+
+			for limit := 1; limit <= 3; limit++ {
+				q := SubscriptionQuery{Sort: SortName, Desc: desc, Limit: limit}
+				var paged []Subscription
+				for pages := 0; ; pages++ {
+					if pages > len(names) {
+						t.Fatalf("pagination by name desc=%v limit=%d does not end", desc, limit)
+					}
+					page, err := store.FindSubscriptions(q)
+					if err != nil {
+						t.Fatalf("FindSubscriptions() error = %v", err)
+					}
+					paged = append(paged, page.Subscriptions...)
+					if page.NextCursor == "" {
+						break
+					}
+					q.Cursor = page.NextCursor
+				}
+				if got := subscriptionNames(paged); !reflect.DeepEqual(got, want) {
+					t.Errorf("pages by name desc=%v limit=%d = %v, want %v", desc, limit, got, want)
+				}
+			}
+		}
+	})
+
+	// The in-memory store orders names as SQLite does.
+	if sameOrder && len(orders) == 4 && !reflect.DeepEqual(orders[:2], orders[2:]) {
+		t.Errorf("database orders names %v, memory store %v", orders[:2], orders[2:])
+	}
+}
+
+func TestGetUpcomingPaymentsSkipsInactive(t *testing.T) {
+	stores(t, func(t *testing.T, store Store) {
+		soon := time.Now().AddDate(0, 0, 1)
+		for _, sub := range []*Subscription{
+			{Name: "Active", Price: 1, Currency: "USD", Cycle: "monthly", PaymentDate: soon},
+			{Name: "Paused", Price: 1, Currency: "USD", Cycle: "monthly", PaymentDate: soon, Status: StatusPaused},
+		} {
+			if err := store.CreateSubscription(sub); err != nil {
+				t.Fatalf("CreateSubscription() error = %v", err)
+			}
+		}
+
+		upcoming, err := store.GetUpcomingPayments(5)
+		if names := subscriptionNames(upcoming); err != nil || !reflect.DeepEqual(names, []string{"Active"}) {
+			t.Errorf("GetUpcomingPayments() = %v, %v, want [Active]", names, err)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned by lookups of a single record by ID that does not
+// exist. It is GORM's error so that *DB and MemoryStore agree.
+var ErrNotFound = gorm.ErrRecordNotFound
+
+// SubscriptionRepository stores subscriptions.
+type SubscriptionRepository interface {
+	CreateSubscription(sub *Subscription) error
+	GetSubscriptionByID(id uint) (*Subscription, error)
+	GetSubscriptionByName(name string) (*Subscription, error)
+	SubscriptionExists(id uint) (bool, error)
+	GetAllSubscriptions() ([]Subscription, error)
+	UpdateSubscription(sub *Subscription) error
+	DeleteSubscription(id uint) error
+	GetUpcomingPayments(days int) ([]Subscription, error)
+	GetPastDuePayments() ([]Subscription, error)
+	GetEndingTrials(days int) ([]Subscription, error)
+	FindSubscriptions(q SubscriptionQuery) (*SubscriptionPage, error)
+}
+
+// NotificationRepository stores notification preferences, settings and the
+// outbox.
+type NotificationRepository interface {
+	GetNotificationPreferences() ([]NotificationPreference, error)
+	SetNotificationPreference(channel, eventType string, enabled bool) error
+	GetNotificationSettings() (*NotificationSettings, error)
+	SaveNotificationSettings(settings *NotificationSettings) error
+	CreateOutboxMessage(msg *OutboxMessage) error
+	GetPendingOutboxMessages(limit int) ([]OutboxMessage, error)
+	MarkOutboxSent(id uint, sentAt time.Time) error
+	MarkOutboxAttemptFailed(id uint, sendErr error, maxAttempts int) error
+}
+
+// TemplateRepository stores message template overrides.
+type TemplateRepository interface {
+	GetMessageTemplates() ([]MessageTemplate, error)
+	GetMessageTemplate(channel, eventType string) (*MessageTemplate, error)
+	SaveMessageTemplate(tmpl *MessageTemplate) error
+	DeleteMessageTemplate(channel, eventType string) error
+}
+
+// FeedTokenRepository stores calendar feed tokens.
+type FeedTokenRepository interface {
+	GetFeedToken(username string) (*FeedToken, error)
+	SaveFeedToken(token *FeedToken) error
+}
+
+// AuditRepository stores the audit trail.
+type AuditRepository interface {
+	CreateAuditEvent(event *AuditEvent) error
+	GetAuditEvents(limit int) ([]AuditEvent, error)
+}
+
+// TwoFactorRepository stores two-factor enrolments.
+type TwoFactorRepository interface {
+	GetTwoFactor(username string) (*TwoFactor, error)
+	SaveTwoFactor(tf *TwoFactor) error
+	DeleteTwoFactor(username string) error
+}
+
+// Store is everything the services persist. *DB implements it with GORM;
+// MemoryStore and FaultyStore are for tests.
+type Store interface {
+	SubscriptionRepository
+	NotificationRepository
+	TemplateRepository
+	FeedTokenRepository
+	AuditRepository
+	TwoFactorRepository
+
+	// WithTransaction runs fn with a Store whose changes are committed when
+	// fn returns nil and rolled back otherwise.
+	WithTransaction(fn func(tx Store) error) error
+}
+
+var _ Store = (*DB)(nil)
@@ -0,0 +1,252 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/utils"
+)
+
+// stores runs test against every Store implementation.
+func stores(t *testing.T, test func(t *testing.T, store Store)) {
+	t.Run("DB", func(t *testing.T) { test(t, setupTestDB(t)) })
+	t.Run("Memory", func(t *testing.T) { test(t, NewMemoryStore()) })
+}
+
+func TestStore_Subscriptions(t *testing.T) {
+	stores(t, func(t *testing.T, store Store) {
+		now := time.Now()
+		netflix := &Subscription{Name: "Netflix", Price: 15.99, Currency: "USD", Cycle: "monthly", PaymentDate: now.AddDate(0, 0, 2)}
+		gym := &Subscription{Name: "Gym", Price: 30, Currency: "EUR", Cycle: "monthly", PaymentDate: now.AddDate(0, 0, -3)}
+		domain := &Subscription{Name: "Domain", Price: 12, Currency: "EUR", Cycle: "yearly", PaymentDate: now.AddDate(0, 2, 0)}
+		for _, sub := range []*Subscription{netflix, gym, domain} {
+			if err := store.CreateSubscription(sub); err != nil {
+				t.Fatalf("CreateSubscription() error = %v", err)
+			}
+			if sub.ID == 0 {
+				t.Fatalf("CreateSubscription() did not assign an ID")
+			}
+		}
+
+		got, err := store.GetSubscriptionByID(netflix.ID)
+		if err != nil || got.Name != "Netflix" {
+			t.Errorf("GetSubscriptionByID() = %+v, %v", got, err)
+		}
+		if _, err := store.GetSubscriptionByID(9999); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetSubscriptionByID(missing) error = %v, want ErrNotFound", err)
+		}
+
+		if got, err := store.GetSubscriptionByName("Gym"); err != nil || got == nil || got.ID != gym.ID {
+			t.Errorf("GetSubscriptionByName() = %+v, %v", got, err)
+		}
+		if got, err := store.GetSubscriptionByName("gym"); err != nil || got != nil {
+			t.Errorf("GetSubscriptionByName() is not exact: %+v, %v", got, err)
+		}
+		if ok, err := store.SubscriptionExists(domain.ID); err != nil || !ok {
+			t.Errorf("SubscriptionExists() = %v, %v", ok, err)
+		}
+
+		upcoming, _ := store.GetUpcomingPayments(5)
+		if names := subscriptionNames(upcoming); len(names) != 1 || names[0] != "Netflix" {
+			t.Errorf("GetUpcomingPayments() = %v", names)
+		}
+		pastDue, _ := store.GetPastDuePayments()
+		if names := subscriptionNames(pastDue); len(names) != 1 || names[0] != "Gym" {
+			t.Errorf("GetPastDuePayments() = %v", names)
+		}
+
+		netflix.Price = 19.99
+		if err := store.UpdateSubscription(netflix); err != nil {
+			t.Fatalf("UpdateSubscription() error = %v", err)
+		}
+		if got, _ := store.GetSubscriptionByID(netflix.ID); got.Price != 19.99 {
+			t.Errorf("price after update = %.2f", got.Price)
+		}
+
+		if err := store.DeleteSubscription(gym.ID); err != nil {
+			t.Fatalf("DeleteSubscription() error = %v", err)
+		}
+		all, _ := store.GetAllSubscriptions()
+		if names := subscriptionNames(all); len(names) != 2 || names[0] != "Netflix" || names[1] != "Domain" {
+			t.Errorf("GetAllSubscriptions() = %v, want [Netflix Domain]", names)
+		}
+	})
+}
+
+func TestStore_GetEndingTrials(t *testing.T) {
+	stores(t, func(t *testing.T, store Store) {
+		today := utils.Today()
+		trial := func(days int) *time.Time {
+			end := today.AddDays(days).Time()
+			return &end
+		}
+		for _, sub := range []*Subscription{
+			{Name: "Today", TrialEndDate: trial(0)},
+			{Name: "Last day", TrialEndDate: trial(4)},
+			{Name: "Outside window", TrialEndDate: trial(5)},
+			{Name: "Ended", TrialEndDate: trial(-1)},
+			{Name: "Paused", TrialEndDate: trial(1), Status: StatusPaused},
+			{Name: "No trial"},
+		} {
+			sub.Price, sub.Currency, sub.Cycle, sub.PaymentDate = 1, "USD", "monthly", today.AddDays(1).Time()
+			if err := store.CreateSubscription(sub); err != nil {
+				t.Fatalf("CreateSubscription() error = %v", err)
+			}
+		}
+
+		subs, err := store.GetEndingTrials(5)
+		if err != nil {
+			t.Fatalf("GetEndingTrials() error = %v", err)
+		}
+		if got := subscriptionNames(subs); !reflect.DeepEqual(got, []string{"Today", "Last day"}) {
+			t.Errorf("GetEndingTrials() = %v, want [Today Last day]", got)
+		}
+	})
+}
+
+func TestStore_TransactionRollback(t *testing.T) {
+	stores(t, func(t *testing.T, store Store) {
+		failure := errors.New("boom")
+		err := store.WithTransaction(func(tx Store) error {
+			if err := tx.CreateSubscription(&Subscription{Name: "Netflix", Price: 1, Currency: "USD", Cycle: "monthly", PaymentDate: time.Now()}); err != nil {
+				return err
+			}
+			if err := tx.SetNotificationPreference("telegram", "digest", false); err != nil {
+				return err
+			}
+			return failure
+		})
+		if !errors.Is(err, failure) {
+			t.Fatalf("WithTransaction() error = %v", err)
+		}
+
+		if subs, _ := store.GetAllSubscriptions(); len(subs) != 0 {
+			t.Errorf("subscriptions after rollback = %v", subscriptionNames(subs))
+		}
+		if prefs, _ := store.GetNotificationPreferences(); len(prefs) != 0 {
+			t.Errorf("preferences after rollback = %+v", prefs)
+		}
+	})
+}
+
+func TestStore_Outbox(t *testing.T) {
+	stores(t, func(t *testing.T, store Store) {
+		for _, body := range []string{"one", "two"} {
+			if err := store.CreateOutboxMessage(&OutboxMessage{Channel: "telegram", EventType: "upcoming", Body: body}); err != nil {
+				t.Fatalf("CreateOutboxMessage() error = %v", err)
+			}
+		}
+
+		pending, _ := store.GetPendingOutboxMessages(10)
+		if len(pending) != 2 || pending[0].Body != "one" {
+			t.Fatalf("GetPendingOutboxMessages() = %+v", pending)
+		}
+
+		if err := store.MarkOutboxSent(pending[0].ID, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.MarkOutboxAttemptFailed(pending[1].ID, errors.New("timeout"), 1); err != nil {
+			t.Fatal(err)
+		}
+		if pending, _ := store.GetPendingOutboxMessages(10); len(pending) != 0 {
+			t.Errorf("pending after delivery = %+v", pending)
+		}
+	})
+}
+
+func TestStore_AuditEvents(t *testing.T) {
+	stores(t, func(t *testing.T, store Store) {
+		start := time.Now().Add(-time.Hour)
+		for i, action := range []string{"login.failed", "login.failed", "login.locked"} {
+			event := &AuditEvent{Action: action, Username: "admin", IP: "192.0.2.1", CreatedAt: start.Add(time.Duration(i) * time.Minute)}
+			if err := store.CreateAuditEvent(event); err != nil {
+				t.Fatalf("CreateAuditEvent() error = %v", err)
+			}
+		}
+
+		events, err := store.GetAuditEvents(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 2 || events[0].Action != "login.locked" || events[1].Action != "login.failed" || events[0].IP != "192.0.2.1" {
+			t.Errorf("GetAuditEvents(2) = %+v, want the newest two", events)
+		}
+	})
+}
+
+func TestStore_TwoFactor(t *testing.T) {
+	stores(t, func(t *testing.T, store Store) {
+		if tf, err := store.GetTwoFactor("admin"); err != nil || tf != nil {
+			t.Fatalf("GetTwoFactor() before enrolment = %+v, %v", tf, err)
+		}
+
+		if err := store.SaveTwoFactor(&TwoFactor{Username: "admin", Secret: "PENDING"}); err != nil {
+			t.Fatalf("SaveTwoFactor() error = %v", err)
+		}
+		confirmed := time.Now().Truncate(time.Second)
+		if err := store.SaveTwoFactor(&TwoFactor{Username: "admin", Secret: "SECRET", RecoveryCodes: "a b", LastStep: 42, ConfirmedAt: &confirmed}); err != nil {
+			t.Fatalf("SaveTwoFactor() update error = %v", err)
+		}
+
+		tf, err := store.GetTwoFactor("admin")
+		if err != nil || tf == nil {
+			t.Fatalf("GetTwoFactor() = %+v, %v", tf, err)
+		}
+		if tf.Secret != "SECRET" || tf.RecoveryCodes != "a b" || tf.LastStep != 42 || tf.ConfirmedAt == nil || !tf.ConfirmedAt.Equal(confirmed) {
+			t.Errorf("GetTwoFactor() = %+v, want the updated enrolment", tf)
+		}
+
+		if err := store.DeleteTwoFactor("admin"); err != nil {
+			t.Fatalf("DeleteTwoFactor() error = %v", err)
+		}
+		if tf, _ := store.GetTwoFactor("admin"); tf != nil {
+			t.Errorf("GetTwoFactor() after delete = %+v", tf)
+		}
+	})
+}
+
+func TestFaultyStore(t *testing.T) {
+	store := NewFaultyStore(NewMemoryStore())
+	failure := errors.New("disk full")
+
+	store.FailOnCall("CreateSubscription", 2, failure)
+	for i, want := range []error{nil, failure, nil} {
+		err := store.CreateSubscription(&Subscription{Name: "Netflix", PaymentDate: time.Now()})
+		if !errors.Is(err, want) {
+			t.Errorf("call %d error = %v, want %v", i+1, err, want)
+		}
+	}
+	if n := store.Calls("CreateSubscription"); n != 3 {
+		t.Errorf("Calls() = %d, want 3", n)
+	}
+	if subs, _ := store.GetAllSubscriptions(); len(subs) != 2 {
+		t.Errorf("failed call was not skipped: %d subscriptions", len(subs))
+	}
+
+	store.FailWhen("UpdateSubscription", failure, func(_ int, args []any) bool {
+		return args[0].(*Subscription).ID == 1
+	})
+	first, _ := store.GetSubscriptionByID(1)
+	if err := store.UpdateSubscription(first); !errors.Is(err, failure) {
+		t.Errorf("UpdateSubscription(1) error = %v", err)
+	}
+	second, _ := store.GetSubscriptionByID(2)
+	if err := store.UpdateSubscription(second); err != nil {
+		t.Errorf("UpdateSubscription(2) error = %v", err)
+	}
+
+	store.FailOn("DeleteSubscription", failure)
+	err := store.WithTransaction(func(tx Store) error {
+		return tx.DeleteSubscription(1)
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("fault did not apply inside transaction: %v", err)
+	}
+
+	store.Heal("DeleteSubscription")
+	if err := store.DeleteSubscription(1); err != nil {
+		t.Errorf("DeleteSubscription() after Heal error = %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// VacuumInto writes a consistent copy of the live database to path using
+// SQLite's VACUUM INTO, which is safe while other connections are writing.
+// Postgres databases are backed up with pg_dump instead.
+func (db *DB) VacuumInto(path string) error {
+	if db.Driver() != DriverSQLite {
+		return fmt.Errorf("snapshots are only supported for SQLite databases")
+	}
+	if err := db.Exec("VACUUM INTO ?", path).Error; err != nil {
+		return fmt.Errorf("failed to snapshot database: %w", err)
+	}
+	return nil
+}
+
+// VerifyIntegrity opens the SQLite file at path and runs PRAGMA
+// integrity_check on it.
+func VerifyIntegrity(path string) error {
+	conn, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	sqlDB, err := conn.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	var results []string
+	if err := conn.Raw("PRAGMA integrity_check").Scan(&results).Error; err != nil {
+		return fmt.Errorf("integrity check of %s failed: %w", path, err)
+	}
+	if len(results) != 1 || results[0] != "ok" {
+		return fmt.Errorf("integrity check of %s failed: %s", path, strings.Join(results, "; "))
+	}
+	return nil
+}
+
+func (db *DB) Close() error {
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
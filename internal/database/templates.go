@@ -0,0 +1,45 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// MessageTemplate overrides the built-in notification text for one channel
+// and event type. Body is a Go text/template in the channel's format.
+type MessageTemplate struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Channel   string    `gorm:"not null;uniqueIndex:idx_template_channel_event" json:"channel"`
+	EventType string    `gorm:"not null;uniqueIndex:idx_template_channel_event" json:"event_type"`
+	Body      string    `gorm:"not null" json:"body"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (db *DB) GetMessageTemplates() ([]MessageTemplate, error) {
+	var tmpls []MessageTemplate
+	err := db.Order("channel, event_type").Find(&tmpls).Error
+	return tmpls, err
+}
+
+// GetMessageTemplate returns the override for channel and eventType, or nil
+// when the built-in template is in use.
+func (db *DB) GetMessageTemplate(channel, eventType string) (*MessageTemplate, error) {
+	var tmpls []MessageTemplate
+	err := db.Where("channel = ? AND event_type = ?", channel, eventType).Limit(1).Find(&tmpls).Error
+	if err != nil || len(tmpls) == 0 {
+		return nil, err
+	}
+	return &tmpls[0], nil
+}
+
+func (db *DB) SaveMessageTemplate(tmpl *MessageTemplate) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "channel"}, {Name: "event_type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"body", "updated_at"}),
+	}).Create(tmpl).Error
+}
+
+func (db *DB) DeleteMessageTemplate(channel, eventType string) error {
+	return db.Where("channel = ? AND event_type = ?", channel, eventType).Delete(&MessageTemplate{}).Error
+}
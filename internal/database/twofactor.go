@@ -0,0 +1,46 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// TwoFactor is a user's TOTP enrolment. It is pending until ConfirmedAt is
+// set by the user entering a code from their app.
+type TwoFactor struct {
+	ID       uint   `gorm:"primaryKey"`
+	Username string `gorm:"not null;uniqueIndex"`
+	Secret   string `gorm:"not null"`
+	// RecoveryCodes holds SHA-256 hashes of the unused recovery codes,
+	// separated by spaces.
+	RecoveryCodes string `gorm:"not null;default:''"`
+	// LastStep is the TOTP time step of the last accepted code, so a code
+	// cannot be used twice.
+	LastStep    int64 `gorm:"not null;default:0"`
+	ConfirmedAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// GetTwoFactor returns the enrolment for username, or nil when there is
+// none.
+func (db *DB) GetTwoFactor(username string) (*TwoFactor, error) {
+	var enrolments []TwoFactor
+	err := db.Where("username = ?", username).Limit(1).Find(&enrolments).Error
+	if err != nil || len(enrolments) == 0 {
+		return nil, err
+	}
+	return &enrolments[0], nil
+}
+
+func (db *DB) SaveTwoFactor(tf *TwoFactor) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "username"}},
+		DoUpdates: clause.AssignmentColumns([]string{"secret", "recovery_codes", "last_step", "confirmed_at", "updated_at"}),
+	}).Create(tf).Error
+}
+
+func (db *DB) DeleteTwoFactor(username string) error {
+	return db.Where("username = ?", username).Delete(&TwoFactor{}).Error
+}
@@ -0,0 +1,153 @@
+// Package password hashes and checks the web UI password. Hashes are argon2id
+// in the PHC string format used by the argon2 reference tools, or bcrypt as
+// written by htpasswd and most other tools.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Argon2id parameters for new hashes, the minimum OWASP recommends: 19 MiB
+// of memory, two passes and one thread. Checking a hash uses the parameters
+// stored in it.
+const (
+	argonMemory  = 19 * 1024
+	argonTime    = 2
+	argonThreads = 1
+	argonSaltLen = 16
+	argonKeyLen  = 32
+)
+
+// BcryptCost is the cost of new bcrypt hashes.
+const BcryptCost = 12
+
+// ErrUnknownHash is returned for a hash that is neither argon2id nor bcrypt.
+var ErrUnknownHash = errors.New("not an argon2id or bcrypt hash")
+
+var b64 = base64.RawStdEncoding
+
+// Hash returns an argon2id hash of password.
+func Hash(password string) (string, error) {
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argonMemory, argonTime, argonThreads, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+// HashBcrypt returns a bcrypt hash of password. bcrypt only looks at the
+// first 72 bytes, so longer passwords are refused.
+func HashBcrypt(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), BcryptCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// IsHash reports whether s looks like a hash Check understands, as opposed
+// to a password in plain text.
+func IsHash(s string) bool {
+	return isArgon2id(s) || isBcrypt(s)
+}
+
+// Validate returns an error describing what is wrong with hash, or nil if
+// Check can use it.
+func Validate(hash string) error {
+	switch {
+	case isArgon2id(hash):
+		_, err := parseArgon2id(hash)
+		return err
+	case isBcrypt(hash):
+		_, err := bcrypt.Cost([]byte(hash))
+		return err
+	}
+	return ErrUnknownHash
+}
+
+// Check reports whether password matches hash.
+func Check(hash, password string) (bool, error) {
+	switch {
+	case isArgon2id(hash):
+		h, err := parseArgon2id(hash)
+		if err != nil {
+			return false, err
+		}
+		key := argon2.IDKey([]byte(password), h.salt, h.time, h.memory, h.threads, uint32(len(h.key)))
+		return subtle.ConstantTimeCompare(key, h.key) == 1, nil
+	case isBcrypt(hash):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	return false, ErrUnknownHash
+}
+
+func isArgon2id(s string) bool {
+	return strings.HasPrefix(s, "$argon2id$")
+}
+
+func isBcrypt(s string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type argon2idHash struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+// parseArgon2id parses $argon2id$v=19$m=<KiB>,t=<passes>,p=<threads>$<salt>$<key>.
+func parseArgon2id(s string) (*argon2idHash, error) {
+	parts := strings.Split(s, "$")
+	if len(parts) != 6 {
+		return nil, fmt.Errorf("malformed argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return nil, fmt.Errorf("malformed argon2id version: %w", err)
+	}
+	if version != argon2.Version {
+		return nil, fmt.Errorf("unsupported argon2id version %d", version)
+	}
+
+	var h argon2idHash
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.memory, &h.time, &h.threads); err != nil {
+		return nil, fmt.Errorf("malformed argon2id parameters: %w", err)
+	}
+	if h.memory == 0 || h.time == 0 || h.threads == 0 {
+		return nil, fmt.Errorf("malformed argon2id parameters")
+	}
+
+	var err error
+	if h.salt, err = b64.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("malformed argon2id salt: %w", err)
+	}
+	if h.key, err = b64.DecodeString(parts[5]); err != nil {
+		return nil, fmt.Errorf("malformed argon2id key: %w", err)
+	}
+	if len(h.key) == 0 {
+		return nil, fmt.Errorf("malformed argon2id key")
+	}
+	return &h, nil
+}
@@ -0,0 +1,67 @@
+package password
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHashAndCheck(t *testing.T) {
+	argon, err := Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	if !strings.HasPrefix(argon, "$argon2id$v=19$m=19456,t=2,p=1$") {
+		t.Errorf("Hash() = %s", argon)
+	}
+	if again, _ := Hash("correct horse"); again == argon {
+		t.Error("Hash() reused a salt")
+	}
+
+	bcryptHash, err := HashBcrypt("correct horse")
+	if err != nil {
+		t.Fatalf("HashBcrypt() error = %v", err)
+	}
+	// htpasswd and PHP write $2y$, which is the same algorithm.
+	htpasswd := "$2y$" + strings.TrimPrefix(bcryptHash, "$2a$")
+
+	for _, hash := range []string{argon, bcryptHash, htpasswd} {
+		if !IsHash(hash) {
+			t.Errorf("IsHash(%s) = false", hash)
+		}
+		if err := Validate(hash); err != nil {
+			t.Errorf("Validate(%s) error = %v", hash, err)
+		}
+		if ok, err := Check(hash, "correct horse"); !ok || err != nil {
+			t.Errorf("Check(%s, right) = %v, %v", hash, ok, err)
+		}
+		if ok, err := Check(hash, "correct horse "); ok || err != nil {
+			t.Errorf("Check(%s, wrong) = %v, %v", hash, ok, err)
+		}
+	}
+
+	if _, err := HashBcrypt(strings.Repeat("x", 73)); err == nil {
+		t.Error("HashBcrypt() accepted a password bcrypt would truncate")
+	}
+}
+
+func TestValidate_Malformed(t *testing.T) {
+	for _, hash := range []string{
+		"changeme",
+		"",
+		"$argon2id$v=19$m=19456,t=2,p=1$c2FsdA",
+		"$argon2id$v=16$m=19456,t=2,p=1$c29tZXNhbHQ$a2V5",
+		"$argon2id$v=19$m=0,t=2,p=1$c29tZXNhbHQ$a2V5",
+		"$argon2id$v=19$m=19456,t=2,p=1$not base64!$a2V5",
+		"$2b$12$tooshort",
+	} {
+		if err := Validate(hash); err == nil {
+			t.Errorf("Validate(%q) succeeded", hash)
+		}
+		if ok, _ := Check(hash, "changeme"); ok {
+			t.Errorf("Check(%q) succeeded", hash)
+		}
+	}
+	if IsHash("changeme") {
+		t.Error("IsHash(plain text) = true")
+	}
+}
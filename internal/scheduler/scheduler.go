@@ -1,38 +1,123 @@
 package scheduler
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/berkaycubuk/subtrack/internal/services"
+	"github.com/berkaycubuk/subtrack/internal/snapshot"
 	"github.com/robfig/cron/v3"
 )
 
+// DefaultSchedules runs the check at 9:00 AM and 9:00 PM.
+var DefaultSchedules = []string{"0 0 9,21 * * *"}
+
+var cronParser = cron.NewParser(
+	cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
 type Scheduler struct {
-	cron     *cron.Cron
-	subSvc   *services.SubscriptionService
-	stopChan chan struct{}
+	cron      *cron.Cron
+	subSvc    *services.SubscriptionService
+	schedules []string
+	location  *time.Location
+	digest    bool
+	stopChan  chan struct{}
 }
 
-func NewScheduler(subSvc *services.SubscriptionService) *Scheduler {
+// NewScheduler creates a scheduler that runs the subscription check on each
+// of the given cron expressions (with a leading seconds field) in loc.
+// Empty schedules fall back to DefaultSchedules and a nil loc to UTC.
+func NewScheduler(subSvc *services.SubscriptionService, schedules []string, loc *time.Location) (*Scheduler, error) {
+	if len(schedules) == 0 {
+		schedules = DefaultSchedules
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	for _, spec := range schedules {
+		if err := ValidateSchedule(spec); err != nil {
+			return nil, err
+		}
+	}
+
 	return &Scheduler{
-		cron:     cron.New(cron.WithSeconds()),
-		subSvc:   subSvc,
-		stopChan: make(chan struct{}),
+		cron:      cron.New(cron.WithParser(cronParser), cron.WithLocation(loc)),
+		subSvc:    subSvc,
+		schedules: schedules,
+		location:  loc,
+		stopChan:  make(chan struct{}),
+	}, nil
+}
+
+// ValidateSchedule reports whether spec is a valid six-field cron expression
+// or descriptor such as "@daily".
+func ValidateSchedule(spec string) error {
+	if _, err := cronParser.Parse(spec); err != nil {
+		return fmt.Errorf("invalid cron expression %q (expected \"sec min hour dom month dow\"): %w", spec, err)
 	}
+	return nil
 }
 
-func (s *Scheduler) Start() error {
-	log.Println("Starting SubTrack scheduler...")
+// ScheduleDigest sends a digest for period on spec. Once a digest is
+// scheduled, the regular check only advances past-due payment dates and
+// sends trial alerts, and no longer sends one alert per payment.
+func (s *Scheduler) ScheduleDigest(spec string, period services.DigestPeriod) error {
+	if err := ValidateSchedule(spec); err != nil {
+		return err
+	}
 
-	if _, err := s.cron.AddFunc("0 0 9,21 * * *", s.runCheck); err != nil {
+	if _, err := s.cron.AddFunc(spec, func() { s.runDigest(period) }); err != nil {
+		return fmt.Errorf("invalid cron expression %q: %w", spec, err)
+	}
+
+	s.digest = true
+	log.Printf("Scheduled %s digest (schedule: %s, timezone: %s)", period, spec, s.location)
+	return nil
+}
+
+// ScheduleOutboxDrain delivers queued notifications on spec, so messages held
+// back during quiet hours or after a failed attempt go out later.
+func (s *Scheduler) ScheduleOutboxDrain(spec string) error {
+	if err := ValidateSchedule(spec); err != nil {
 		return err
 	}
 
-	s.cron.Start()
-	log.Println("Scheduler started (runs at 9:00 AM and 9:00 PM)")
+	if _, err := s.cron.AddFunc(spec, s.drainOutbox); err != nil {
+		return fmt.Errorf("invalid cron expression %q: %w", spec, err)
+	}
+
+	return nil
+}
+
+// ScheduleSnapshots takes and rotates database snapshots on spec.
+func (s *Scheduler) ScheduleSnapshots(spec string, snapshots *snapshot.Manager) error {
+	if err := ValidateSchedule(spec); err != nil {
+		return err
+	}
+
+	_, err := s.cron.AddFunc(spec, func() {
+		if err := snapshots.Run(); err != nil {
+			log.Printf("Error taking snapshot: %v", err)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("invalid cron expression %q: %w", spec, err)
+	}
+
+	return nil
+}
+
+func (s *Scheduler) Start() error {
+	if err := s.StartCron(); err != nil {
+		return err
+	}
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -51,12 +136,14 @@ func (s *Scheduler) Start() error {
 func (s *Scheduler) StartCron() error {
 	log.Println("Starting SubTrack scheduler...")
 
-	if _, err := s.cron.AddFunc("0 0 9,21 * * *", s.runCheck); err != nil {
-		return err
+	for _, spec := range s.schedules {
+		if _, err := s.cron.AddFunc(spec, s.runCheck); err != nil {
+			return fmt.Errorf("invalid cron expression %q: %w", spec, err)
+		}
 	}
 
 	s.cron.Start()
-	log.Println("Scheduler started (runs at 9:00 AM and 9:00 PM)")
+	log.Printf("Scheduler started (schedules: %s, timezone: %s)", strings.Join(s.schedules, "; "), s.location)
 	return nil
 }
 
@@ -73,6 +160,19 @@ func (s *Scheduler) runCheck() {
 		log.Printf("Error updating past due payments: %v", err)
 	}
 
+	// The digest lists payments but not trials, so trial alerts go out in
+	// both modes.
+	if trials, err := s.subSvc.SendTrialNotifications(); err != nil {
+		log.Printf("Error checking ending trials: %v", err)
+	} else if len(trials) > 0 {
+		log.Printf("Found %d subscriptions with ending trials", len(trials))
+	}
+
+	if s.digest {
+		s.drainOutbox()
+		return
+	}
+
 	subs, err := s.subSvc.CheckUpcomingPayments()
 	if err != nil {
 		log.Printf("Error checking upcoming payments: %v", err)
@@ -89,6 +189,32 @@ func (s *Scheduler) runCheck() {
 	if err := s.subSvc.SendNotifications(subs); err != nil {
 		log.Printf("Error sending notifications: %v", err)
 	}
+
+	s.drainOutbox()
+}
+
+func (s *Scheduler) runDigest(period services.DigestPeriod) {
+	log.Printf("Running %s digest...", period)
+
+	if err := s.subSvc.UpdatePastDuePayments(); err != nil {
+		log.Printf("Error updating past due payments: %v", err)
+	}
+
+	if err := s.subSvc.SendDigest(period); err != nil {
+		log.Printf("Error sending digest: %v", err)
+	}
+
+	s.drainOutbox()
+}
+
+func (s *Scheduler) drainOutbox() {
+	sent, err := s.subSvc.DrainOutbox()
+	if err != nil {
+		log.Printf("Error delivering notifications: %v", err)
+	}
+	if sent > 0 {
+		log.Printf("Delivered %d notifications", sent)
+	}
 }
 
 func (s *Scheduler) StopGracefully() {
@@ -1,6 +1,8 @@
 package scheduler
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -16,7 +18,10 @@ func setupScheduler(t *testing.T) (*Scheduler, *database.DB, *services.MockTeleg
 
 	mockTg := &services.MockTelegramService{}
 	subSvc := services.NewSubscriptionService(db, mockTg)
-	sched := NewScheduler(subSvc)
+	sched, err := NewScheduler(subSvc, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create scheduler: %v", err)
+	}
 
 	return sched, db, mockTg
 }
@@ -29,7 +34,10 @@ func TestNewScheduler(t *testing.T) {
 
 	mockTg := &services.MockTelegramService{}
 	subSvc := services.NewSubscriptionService(db, mockTg)
-	sched := NewScheduler(subSvc)
+	sched, err := NewScheduler(subSvc, nil, nil)
+	if err != nil {
+		t.Fatalf("NewScheduler() error = %v", err)
+	}
 
 	if sched == nil {
 		t.Fatal("NewScheduler() returned nil")
@@ -38,6 +46,10 @@ func TestNewScheduler(t *testing.T) {
 	if sched.subSvc != subSvc {
 		t.Error("NewScheduler() did not set subSvc")
 	}
+	// An unset CHECK_SCHEDULE reaches here empty.
+	if !reflect.DeepEqual(sched.schedules, DefaultSchedules) {
+		t.Errorf("NewScheduler() schedules = %v, want %v", sched.schedules, DefaultSchedules)
+	}
 }
 
 func TestScheduler_runCheck(t *testing.T) {
@@ -83,7 +95,7 @@ func TestScheduler_runCheck(t *testing.T) {
 	}
 
 	notificationsSent := 0
-	mockTg.SendNotificationFunc = func(name string, price float64, currency string, days int, cycle string, paymentDate string) error {
+	mockTg.SendMessageFunc = func(text string) error {
 		notificationsSent++
 		return nil
 	}
@@ -114,7 +126,10 @@ func TestScheduler_StopGracefully(t *testing.T) {
 
 	mockTg := &services.MockTelegramService{}
 	subSvc := services.NewSubscriptionService(db, mockTg)
-	sched := NewScheduler(subSvc)
+	sched, err := NewScheduler(subSvc, nil, nil)
+	if err != nil {
+		t.Fatalf("NewScheduler() error = %v", err)
+	}
 
 	done := make(chan bool)
 	go func() {
@@ -129,3 +144,105 @@ func TestScheduler_StopGracefully(t *testing.T) {
 		t.Error("StopGracefully() did not complete within timeout")
 	}
 }
+
+func TestNewScheduler_Schedules(t *testing.T) {
+	db, err := database.New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+	subSvc := services.NewSubscriptionService(db, &services.MockTelegramService{})
+
+	istanbul, err := time.LoadLocation("Europe/Istanbul")
+	if err != nil {
+		t.Fatalf("failed to load location: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		schedules []string
+		wantErr   bool
+	}{
+		{
+			name:      "default schedule",
+			schedules: nil,
+		},
+		{
+			name:      "multiple schedules",
+			schedules: []string{"0 30 8 * * 1-5", "@daily"},
+		},
+		{
+			name:      "five field expression",
+			schedules: []string{"0 9 * * *"},
+			wantErr:   true,
+		},
+		{
+			name:      "garbage",
+			schedules: []string{"every morning"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sched, err := NewScheduler(subSvc, tt.schedules, istanbul)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewScheduler() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if sched.location != istanbul {
+				t.Errorf("NewScheduler() location = %v, want %v", sched.location, istanbul)
+			}
+			if err := sched.StartCron(); err != nil {
+				t.Fatalf("StartCron() error = %v", err)
+			}
+			sched.Stop()
+		})
+	}
+}
+
+func TestScheduler_runDigest(t *testing.T) {
+	sched, db, mockTg := setupScheduler(t)
+
+	if err := sched.ScheduleDigest("0 0 8 * * 1", services.DigestWeekly); err != nil {
+		t.Fatalf("ScheduleDigest() error = %v", err)
+	}
+	if err := sched.ScheduleDigest("weekly at 8", services.DigestWeekly); err == nil {
+		t.Error("ScheduleDigest() expected error for invalid expression")
+	}
+
+	now := time.Now()
+	for _, days := range []int{1, 3} {
+		sub := &database.Subscription{
+			Name:        "Sub",
+			Price:       10.00,
+			Currency:    "USD",
+			Cycle:       "monthly",
+			PaymentDate: now.Add(time.Duration(days) * 24 * time.Hour),
+		}
+		if err := db.CreateSubscription(sub); err != nil {
+			t.Fatalf("failed to create test subscription: %v", err)
+		}
+	}
+
+	alerts, digests := 0, 0
+	mockTg.SendMessageFunc = func(text string) error {
+		if strings.Contains(text, "Subscription Alert") {
+			alerts++
+		} else {
+			digests++
+		}
+		return nil
+	}
+
+	sched.runCheck()
+	sched.runDigest(services.DigestWeekly)
+
+	if alerts != 0 {
+		t.Errorf("runCheck() sent %d alerts with digest enabled, want 0", alerts)
+	}
+	if digests != 1 {
+		t.Errorf("runDigest() sent %d messages, want 1", digests)
+	}
+}
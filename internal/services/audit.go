@@ -0,0 +1,61 @@
+package services
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+)
+
+// Audited actions.
+const (
+	AuditLoginSucceeded = "login.succeeded"
+	AuditLoginFailed    = "login.failed"
+	// AuditLoginLocked is recorded when failed logins lock a client IP or a
+	// username out. Attempts refused during the lockout are not recorded.
+	AuditLoginLocked = "login.locked"
+	AuditLogout      = "logout"
+
+	AuditTwoFactorEnabled  = "2fa.enabled"
+	AuditTwoFactorDisabled = "2fa.disabled"
+	// AuditTwoFactorReset is recorded when an administrator removes a
+	// user's two-factor authentication from the command line.
+	AuditTwoFactorReset       = "2fa.reset"
+	AuditRecoveryCodesRenewed = "2fa.recovery_codes_renewed"
+)
+
+// AuditLog records security-relevant events in the database and the
+// service log.
+type AuditLog struct {
+	db database.Store
+}
+
+func NewAuditLog(db database.Store) *AuditLog {
+	return &AuditLog{db: db}
+}
+
+// Record stores an event. Auditing must not stop the action it describes,
+// so a failure to store the event is logged rather than returned.
+func (a *AuditLog) Record(action, username, ip, detail string) {
+	if detail != "" {
+		log.Printf("Audit: %s user=%q ip=%s: %s", action, username, ip, detail)
+	} else {
+		log.Printf("Audit: %s user=%q ip=%s", action, username, ip)
+	}
+	event := &database.AuditEvent{Action: action, Username: username, IP: ip, Detail: detail}
+	if err := a.db.CreateAuditEvent(event); err != nil {
+		log.Printf("Error recording audit event %s: %v", action, err)
+	}
+}
+
+// Recent returns up to limit events, newest first.
+func (a *AuditLog) Recent(limit int) ([]database.AuditEvent, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive")
+	}
+	events, err := a.db.GetAuditEvents(limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load audit events: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+)
+
+func TestAuditLog(t *testing.T) {
+	store := database.NewFaultyStore(database.NewMemoryStore())
+	audit := NewAuditLog(store)
+
+	audit.Record(AuditLoginFailed, "admin", "192.0.2.1", "bad password")
+	audit.Record(AuditLoginSucceeded, "admin", "192.0.2.1", "")
+
+	events, err := audit.Recent(10)
+	if err != nil {
+		t.Fatalf("Recent() error = %v", err)
+	}
+	if len(events) != 2 || events[0].Action != AuditLoginSucceeded || events[1].Detail != "bad password" {
+		t.Errorf("Recent() = %+v", events)
+	}
+
+	// A failing store does not stop the audited action.
+	store.FailOn("CreateAuditEvent", errors.New("disk full"))
+	audit.Record(AuditLogout, "admin", "192.0.2.1", "")
+	if events, _ := audit.Recent(10); len(events) != 2 {
+		t.Errorf("got %d events after a failed Record, want 2", len(events))
+	}
+
+	if _, err := audit.Recent(0); err == nil {
+		t.Error("Recent(0) succeeded")
+	}
+	store.FailOn("GetAuditEvents", errors.New("disk full"))
+	if _, err := audit.Recent(10); err == nil {
+		t.Error("Recent() with a failing store succeeded")
+	}
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+)
+
+const (
+	backupFormat  = "subtrack-backup"
+	BackupVersion = 1
+)
+
+// Backup is a portable dump of everything a user configures. The outbox is
+// not included since it only holds transient delivery state.
+type Backup struct {
+	Format                  string                            `json:"format"`
+	Version                 int                               `json:"version"`
+	CreatedAt               time.Time                         `json:"created_at"`
+	Subscriptions           []database.Subscription           `json:"subscriptions"`
+	NotificationPreferences []database.NotificationPreference `json:"notification_preferences"`
+	NotificationSettings    *database.NotificationSettings    `json:"notification_settings"`
+	MessageTemplates        []database.MessageTemplate        `json:"message_templates"`
+}
+
+// ConflictPolicy decides what Restore does with a subscription whose name
+// already exists with different values. Settings, preferences and templates
+// are keyed, so rename behaves like skip for them.
+type ConflictPolicy string
+
+const (
+	ConflictSkip      ConflictPolicy = "skip"
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	ConflictRename    ConflictPolicy = "rename"
+)
+
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(s); p {
+	case ConflictSkip, ConflictOverwrite, ConflictRename:
+		return p, nil
+	default:
+		return "", fmt.Errorf("conflict policy must be 'skip', 'overwrite' or 'rename'")
+	}
+}
+
+type RestoreResult struct {
+	Created   int
+	Updated   int
+	Renamed   int
+	Skipped   int
+	Unchanged int
+}
+
+func (s *SubscriptionService) Backup() (*Backup, error) {
+	subs, err := s.db.GetAllSubscriptions()
+	if err != nil {
+		return nil, err
+	}
+	prefs, err := s.db.GetNotificationPreferences()
+	if err != nil {
+		return nil, err
+	}
+	settings, err := s.db.GetNotificationSettings()
+	if err != nil {
+		return nil, err
+	}
+	tmpls, err := s.db.GetMessageTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Backup{
+		Format:                  backupFormat,
+		Version:                 BackupVersion,
+		CreatedAt:               s.now().UTC(),
+		Subscriptions:           subs,
+		NotificationPreferences: prefs,
+		NotificationSettings:    settings,
+		MessageTemplates:        tmpls,
+	}, nil
+}
+
+func (s *SubscriptionService) WriteBackup(w io.Writer) error {
+	backup, err := s.Backup()
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(backup)
+}
+
+// Restore loads a backup in a single transaction. Subscriptions are matched
+// by name; a match with identical values is left alone, as is a renamed copy
+// from an earlier restore, so restoring the same backup twice changes nothing
+// under any policy.
+func (s *SubscriptionService) Restore(r io.Reader, policy ConflictPolicy) (*RestoreResult, error) {
+	var backup Backup
+	if err := json.NewDecoder(r).Decode(&backup); err != nil {
+		return nil, fmt.Errorf("invalid backup file: %w", err)
+	}
+	if backup.Format != backupFormat {
+		return nil, fmt.Errorf("not a SubTrack backup (format %q)", backup.Format)
+	}
+	if backup.Version < 1 || backup.Version > BackupVersion {
+		return nil, fmt.Errorf("unsupported backup version %d (this build reads up to %d)", backup.Version, BackupVersion)
+	}
+
+	result := &RestoreResult{}
+	err := s.db.WithTransaction(func(tx database.Store) error {
+		for _, sub := range backup.Subscriptions {
+			if err := restoreSubscription(tx, sub, policy, result); err != nil {
+				return fmt.Errorf("failed to restore %s: %w", sub.Name, err)
+			}
+		}
+		return restoreSettings(tx, &backup, policy)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func restoreSubscription(tx database.Store, sub database.Subscription, policy ConflictPolicy, result *RestoreResult) error {
+	existing, err := tx.GetSubscriptionByName(sub.Name)
+	if err != nil {
+		return err
+	}
+
+	if existing == nil {
+		if err := createPreservingID(tx, &sub); err != nil {
+			return err
+		}
+		result.Created++
+		return nil
+	}
+
+	if sameSubscription(*existing, sub) {
+		result.Unchanged++
+		return nil
+	}
+
+	switch policy {
+	case ConflictOverwrite:
+		sub.ID = existing.ID
+		sub.CreatedAt = existing.CreatedAt
+		if err := tx.UpdateSubscription(&sub); err != nil {
+			return err
+		}
+		result.Updated++
+
+	case ConflictRename:
+		name, restored, err := restoredName(tx, sub)
+		if err != nil {
+			return err
+		}
+		if restored {
+			// An earlier restore of this backup already renamed it.
+			result.Unchanged++
+			return nil
+		}
+		sub.Name = name
+		sub.ID = 0
+		if err := tx.CreateSubscription(&sub); err != nil {
+			return err
+		}
+		result.Renamed++
+
+	default:
+		result.Skipped++
+	}
+	return nil
+}
+
+// createPreservingID keeps the backed-up ID when it is free so that a
+// restore into an empty database reproduces the original IDs.
+func createPreservingID(tx database.Store, sub *database.Subscription) error {
+	if sub.ID != 0 {
+		taken, err := tx.SubscriptionExists(sub.ID)
+		if err != nil {
+			return err
+		}
+		if taken {
+			sub.ID = 0
+		}
+	}
+	return tx.CreateSubscription(sub)
+}
+
+// restoredName returns the first free "(restored)" name for sub, or the
+// name of an earlier renamed copy with the same values, reporting which.
+func restoredName(tx database.Store, sub database.Subscription) (string, bool, error) {
+	for i := 1; ; i++ {
+		candidate := sub.Name + " (restored)"
+		if i > 1 {
+			candidate = fmt.Sprintf("%s (restored %d)", sub.Name, i)
+		}
+		existing, err := tx.GetSubscriptionByName(candidate)
+		if err != nil {
+			return "", false, err
+		}
+		if existing == nil {
+			return candidate, false, nil
+		}
+		renamed := sub
+		renamed.Name = candidate
+		if sameSubscription(*existing, renamed) {
+			return candidate, true, nil
+		}
+	}
+}
+
+func sameSubscription(a, b database.Subscription) bool {
+	return a.Name == b.Name &&
+		a.Price == b.Price &&
+		strings.EqualFold(a.Currency, b.Currency) &&
+		a.Cycle == b.Cycle &&
+		a.PaymentDate.Equal(b.PaymentDate) &&
+		a.AnchorDay() == b.AnchorDay() &&
+		a.Category == b.Category &&
+		a.Status == b.Status &&
+		sameDate(a.TrialEndDate, b.TrialEndDate)
+}
+
+// sameDate reports whether two optional dates are both unset or equal.
+func sameDate(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func restoreSettings(tx database.Store, backup *Backup, policy ConflictPolicy) error {
+	overwrite := policy == ConflictOverwrite
+
+	prefs, err := tx.GetNotificationPreferences()
+	if err != nil {
+		return err
+	}
+	havePref := make(map[string]bool, len(prefs))
+	for _, p := range prefs {
+		havePref[p.Channel+"/"+p.EventType] = true
+	}
+	for _, p := range backup.NotificationPreferences {
+		if havePref[p.Channel+"/"+p.EventType] && !overwrite {
+			continue
+		}
+		if err := tx.SetNotificationPreference(p.Channel, p.EventType, p.Enabled); err != nil {
+			return err
+		}
+	}
+
+	if backup.NotificationSettings != nil {
+		current, err := tx.GetNotificationSettings()
+		if err != nil {
+			return err
+		}
+		restored := *backup.NotificationSettings
+		changed := false
+		if overwrite || current.QuietStart == current.QuietEnd {
+			current.QuietStart, current.QuietEnd = restored.QuietStart, restored.QuietEnd
+			changed = true
+		}
+		if overwrite || current.BudgetAmount == 0 {
+			current.BudgetAmount, current.BudgetCurrency = restored.BudgetAmount, restored.BudgetCurrency
+			changed = true
+		}
+		if changed {
+			if err := tx.SaveNotificationSettings(current); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, t := range backup.MessageTemplates {
+		existing, err := tx.GetMessageTemplate(t.Channel, t.EventType)
+		if err != nil {
+			return err
+		}
+		if existing != nil && !overwrite {
+			continue
+		}
+		if err := tx.SaveMessageTemplate(&database.MessageTemplate{Channel: t.Channel, EventType: t.EventType, Body: t.Body}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
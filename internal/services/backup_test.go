@@ -0,0 +1,235 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/utils"
+)
+
+func TestSubscriptionService_BackupRestore(t *testing.T) {
+	src, _, _ := setupSubscriptionService(t)
+
+	if err := src.AddSubscription("Netflix", "15.99", "USD", "monthly", "15-02-2025", ""); err != nil {
+		t.Fatalf("AddSubscription() error = %v", err)
+	}
+	if err := src.AddSubscription("Spotify", "9.99", "USD", "monthly", "01-03-2025", ""); err != nil {
+		t.Fatalf("AddSubscription() error = %v", err)
+	}
+	if err := src.SetNotificationPreference(ChannelTelegram, "price_change", false); err != nil {
+		t.Fatalf("SetNotificationPreference() error = %v", err)
+	}
+	quiet, _ := ParseQuietHours("22:00", "07:00")
+	if err := src.SetQuietHours(quiet); err != nil {
+		t.Fatalf("SetQuietHours() error = %v", err)
+	}
+	if err := src.SetMessageTemplate(ChannelTelegram, "upcoming", "{{.Name}}"); err != nil {
+		t.Fatalf("SetMessageTemplate() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := src.WriteBackup(&buf); err != nil {
+		t.Fatalf("WriteBackup() error = %v", err)
+	}
+	backup := buf.String()
+
+	dst, db, _ := setupSubscriptionService(t)
+	result, err := dst.Restore(strings.NewReader(backup), ConflictSkip)
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if result.Created != 2 {
+		t.Errorf("Restore() created %d, want 2", result.Created)
+	}
+
+	subs, _ := db.GetAllSubscriptions()
+	if len(subs) != 2 || subs[0].ID != 1 || subs[0].Name != "Netflix" {
+		t.Errorf("restored subscriptions = %+v", subs)
+	}
+	prefs, gotQuiet, _ := dst.NotificationPreferences()
+	if prefs[ChannelTelegram][EventPriceChange] || gotQuiet != quiet {
+		t.Errorf("restored preferences = %v, quiet hours %s", prefs, gotQuiet)
+	}
+	if src, custom, _ := dst.MessageTemplate(ChannelTelegram, EventUpcoming); !custom || src != "{{.Name}}" {
+		t.Errorf("restored template = %q (custom %v)", src, custom)
+	}
+
+	for _, policy := range []ConflictPolicy{ConflictSkip, ConflictOverwrite, ConflictRename} {
+		again, err := dst.Restore(strings.NewReader(backup), policy)
+		if err != nil {
+			t.Fatalf("Restore(%s) again error = %v", policy, err)
+		}
+		if again.Unchanged != 2 || again.Created+again.Updated+again.Renamed+again.Skipped != 0 {
+			t.Errorf("Restore(%s) again = %+v, want everything unchanged", policy, again)
+		}
+	}
+}
+
+func TestSubscriptionService_Restore_ConflictPolicies(t *testing.T) {
+	src, _, _ := setupSubscriptionService(t)
+	if err := src.AddSubscription("Netflix", "19.99", "USD", "monthly", "15-02-2025", ""); err != nil {
+		t.Fatalf("AddSubscription() error = %v", err)
+	}
+	var buf bytes.Buffer
+	if err := src.WriteBackup(&buf); err != nil {
+		t.Fatalf("WriteBackup() error = %v", err)
+	}
+	backup := buf.String()
+
+	tests := []struct {
+		policy    ConflictPolicy
+		wantNames []string
+		wantPrice float64
+		// wantAgain is the result of restoring the same backup again.
+		wantAgain RestoreResult
+	}{
+		{policy: ConflictSkip, wantNames: []string{"Netflix"}, wantPrice: 15.99, wantAgain: RestoreResult{Skipped: 1}},
+		{policy: ConflictOverwrite, wantNames: []string{"Netflix"}, wantPrice: 19.99, wantAgain: RestoreResult{Unchanged: 1}},
+		{policy: ConflictRename, wantNames: []string{"Netflix", "Netflix (restored)"}, wantPrice: 15.99, wantAgain: RestoreResult{Unchanged: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			dst, db, _ := setupSubscriptionService(t)
+			if err := dst.AddSubscription("Netflix", "15.99", "USD", "monthly", "15-02-2025", ""); err != nil {
+				t.Fatalf("AddSubscription() error = %v", err)
+			}
+
+			if _, err := dst.Restore(strings.NewReader(backup), tt.policy); err != nil {
+				t.Fatalf("Restore() error = %v", err)
+			}
+			for i := 0; i < 2; i++ {
+				again, err := dst.Restore(strings.NewReader(backup), tt.policy)
+				if err != nil {
+					t.Fatalf("Restore() again error = %v", err)
+				}
+				if *again != tt.wantAgain {
+					t.Errorf("Restore() again = %+v, want %+v", *again, tt.wantAgain)
+				}
+			}
+
+			subs, _ := db.GetAllSubscriptions()
+			if len(subs) != len(tt.wantNames) {
+				t.Fatalf("got %d subscriptions, want %d", len(subs), len(tt.wantNames))
+			}
+			for i, name := range tt.wantNames {
+				if subs[i].Name != name {
+					t.Errorf("subs[%d].Name = %q, want %q", i, subs[i].Name, name)
+				}
+			}
+			if subs[0].Price != tt.wantPrice {
+				t.Errorf("Netflix price = %v, want %v", subs[0].Price, tt.wantPrice)
+			}
+		})
+	}
+}
+
+func TestSubscriptionService_Restore_OverwritesCategoryAndStatus(t *testing.T) {
+	src, srcDB, _ := setupSubscriptionService(t)
+	if err := src.AddSubscription("Netflix", "15.99", "USD", "monthly", "15-02-2025", "Streaming"); err != nil {
+		t.Fatalf("AddSubscription() error = %v", err)
+	}
+	sub, _ := srcDB.GetSubscriptionByName("Netflix")
+	if err := src.SetSubscriptionStatus(sub.ID, database.StatusPaused); err != nil {
+		t.Fatalf("SetSubscriptionStatus() error = %v", err)
+	}
+	var buf bytes.Buffer
+	if err := src.WriteBackup(&buf); err != nil {
+		t.Fatalf("WriteBackup() error = %v", err)
+	}
+
+	dst, db, _ := setupSubscriptionService(t)
+	if err := dst.AddSubscription("Netflix", "15.99", "USD", "monthly", "15-02-2025", ""); err != nil {
+		t.Fatalf("AddSubscription() error = %v", err)
+	}
+	result, err := dst.Restore(&buf, ConflictOverwrite)
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if result.Updated != 1 {
+		t.Errorf("Restore() = %+v, want 1 updated", *result)
+	}
+	got, _ := db.GetSubscriptionByName("Netflix")
+	if got.Category != "Streaming" || got.Status != database.StatusPaused {
+		t.Errorf("restored category %q, status %q; want Streaming, paused", got.Category, got.Status)
+	}
+}
+
+func TestSubscriptionService_Restore_OverwritesTrialEndDate(t *testing.T) {
+	tests := []struct {
+		name        string
+		backupTrial string
+		dstTrial    string
+	}{
+		{name: "trial added", backupTrial: "01-03-2025"},
+		{name: "trial removed", dstTrial: "01-03-2025"},
+		{name: "trial moved", backupTrial: "01-03-2025", dstTrial: "08-03-2025"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, srcDB, _ := setupSubscriptionService(t)
+			if err := src.AddSubscription("Netflix", "15.99", "USD", "monthly", "15-02-2025", ""); err != nil {
+				t.Fatalf("AddSubscription() error = %v", err)
+			}
+			sub, _ := srcDB.GetSubscriptionByName("Netflix")
+			if err := src.SetTrialEndDate(sub.ID, tt.backupTrial); err != nil {
+				t.Fatalf("SetTrialEndDate() error = %v", err)
+			}
+			var buf bytes.Buffer
+			if err := src.WriteBackup(&buf); err != nil {
+				t.Fatalf("WriteBackup() error = %v", err)
+			}
+
+			dst, db, _ := setupSubscriptionService(t)
+			if err := dst.AddSubscription("Netflix", "15.99", "USD", "monthly", "15-02-2025", ""); err != nil {
+				t.Fatalf("AddSubscription() error = %v", err)
+			}
+			sub, _ = db.GetSubscriptionByName("Netflix")
+			if err := dst.SetTrialEndDate(sub.ID, tt.dstTrial); err != nil {
+				t.Fatalf("SetTrialEndDate() error = %v", err)
+			}
+			result, err := dst.Restore(&buf, ConflictOverwrite)
+			if err != nil {
+				t.Fatalf("Restore() error = %v", err)
+			}
+			if result.Updated != 1 {
+				t.Errorf("Restore() = %+v, want 1 updated", *result)
+			}
+
+			got, _ := db.GetSubscriptionByName("Netflix")
+			switch {
+			case tt.backupTrial == "" && got.TrialEndDate != nil:
+				t.Errorf("restored trial end date %v, want none", *got.TrialEndDate)
+			case tt.backupTrial != "" && (got.TrialEndDate == nil || utils.FormatDate(*got.TrialEndDate) != tt.backupTrial):
+				t.Errorf("restored trial end date %v, want %s", got.TrialEndDate, tt.backupTrial)
+			}
+		})
+	}
+}
+
+func TestSubscriptionService_Restore_Invalid(t *testing.T) {
+	subSvc, _, _ := setupSubscriptionService(t)
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "not json", input: "name,price"},
+		{name: "wrong format", input: `{"format": "other", "version": 1}`},
+		{name: "newer version", input: `{"format": "subtrack-backup", "version": 99}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := subSvc.Restore(strings.NewReader(tt.input), ConflictSkip); err == nil {
+				t.Error("Restore() expected error")
+			}
+		})
+	}
+
+	if _, err := ParseConflictPolicy("merge"); err == nil {
+		t.Error("ParseConflictPolicy() expected error")
+	}
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+)
+
+// Budget is a limit on monthly spending in one currency. The zero Budget
+// is off.
+type Budget struct {
+	Amount   float64
+	Currency string
+}
+
+// ParseBudget parses a monthly budget given as an amount and a currency.
+func ParseBudget(amount, currency string) (Budget, error) {
+	a, err := strconv.ParseFloat(amount, 64)
+	if err != nil || a <= 0 {
+		return Budget{}, fmt.Errorf("invalid budget amount %q (use a positive number)", amount)
+	}
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return Budget{}, fmt.Errorf("budget currency is required")
+	}
+	return Budget{Amount: a, Currency: currency}, nil
+}
+
+func (b Budget) Enabled() bool {
+	return b.Amount > 0
+}
+
+func (b Budget) String() string {
+	if !b.Enabled() {
+		return "off"
+	}
+	return fmt.Sprintf("%.2f %s per month", b.Amount, b.Currency)
+}
+
+// BudgetData is the template data for budget_exceeded events: the
+// subscription whose change took monthly spending over the budget, and the
+// spending and budget in its currency.
+type BudgetData struct {
+	database.Subscription
+	Spent  float64
+	Budget float64
+}
+
+func (s *SubscriptionService) Budget() (Budget, error) {
+	settings, err := s.db.GetNotificationSettings()
+	if err != nil {
+		return Budget{}, err
+	}
+	return Budget{Amount: settings.BudgetAmount, Currency: settings.BudgetCurrency}, nil
+}
+
+// SetBudget stores the monthly budget. Passing the zero Budget turns it off.
+func (s *SubscriptionService) SetBudget(b Budget) error {
+	settings, err := s.db.GetNotificationSettings()
+	if err != nil {
+		return err
+	}
+	settings.BudgetAmount = b.Amount
+	settings.BudgetCurrency = b.Currency
+	return s.db.SaveNotificationSettings(settings)
+}
+
+// monthlySpend is what the active subscriptions in currency cost per month.
+func (s *SubscriptionService) monthlySpend(currency string) (float64, error) {
+	page, err := s.db.FindSubscriptions(database.SubscriptionQuery{
+		Currencies: []string{currency},
+		Statuses:   []string{database.StatusActive},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0.0
+	for i := range page.Subscriptions {
+		total += monthlyCost(&page.Subscriptions[i])
+	}
+	return total, nil
+}
+
+// watchBudget notes monthly spending before a change to a subscription. The
+// returned func, called with the subscription once the change is saved,
+// queues a budget_exceeded notification when the change took spending over
+// the budget.
+func (s *SubscriptionService) watchBudget() func(sub *database.Subscription) {
+	budget, err := s.Budget()
+	if err != nil {
+		log.Printf("Failed to load budget: %v", err)
+		return func(*database.Subscription) {}
+	}
+	if !budget.Enabled() {
+		return func(*database.Subscription) {}
+	}
+	before, err := s.monthlySpend(budget.Currency)
+	if err != nil {
+		log.Printf("Failed to total monthly spending: %v", err)
+		return func(*database.Subscription) {}
+	}
+
+	return func(sub *database.Subscription) {
+		if before > budget.Amount || !strings.EqualFold(sub.Currency, budget.Currency) {
+			return
+		}
+		after, err := s.monthlySpend(budget.Currency)
+		if err != nil {
+			log.Printf("Failed to total monthly spending: %v", err)
+			return
+		}
+		if after <= budget.Amount {
+			return
+		}
+		if _, err := s.enqueue(EventBudgetExceeded, BudgetData{Subscription: *sub, Spent: after, Budget: budget.Amount}); err != nil {
+			log.Printf("Failed to queue budget notification for %s: %v", sub.Name, err)
+		}
+	}
+}
+
+// budgetData is sub's budget_exceeded data with the current spending and
+// budget, for previews.
+func (s *SubscriptionService) budgetData(sub *database.Subscription) (BudgetData, error) {
+	budget, err := s.Budget()
+	if err != nil {
+		return BudgetData{}, err
+	}
+	spent, err := s.monthlySpend(sub.Currency)
+	if err != nil {
+		return BudgetData{}, err
+	}
+	return BudgetData{Subscription: *sub, Spent: spent, Budget: budget.Amount}, nil
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/utils"
+)
+
+// Calendar is a month grid of the payments active subscriptions make,
+// projected from their next payment date. Months before a subscription's
+// next payment show none of its past payments.
+type Calendar struct {
+	// Month is the first day of the month shown.
+	Month utils.Date
+	// Weeks run Monday to Sunday and cover the whole month, padded with
+	// days of the months either side.
+	Weeks [][]CalendarDay
+	// Totals sum the month's payments per currency.
+	Totals []CurrencyTotal
+	Count  int
+}
+
+type CalendarDay struct {
+	Date utils.Date
+	// InMonth is false for the padding days, which never list payments.
+	InMonth bool
+	Today   bool
+	// Payments are the subscriptions charging on the day, by name.
+	Payments []database.Subscription
+	Totals   []CurrencyTotal
+}
+
+// Calendar builds the grid for the month containing month.
+func (s *ReportService) Calendar(month utils.Date) (*Calendar, error) {
+	subs, err := s.db.GetAllSubscriptions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load subscriptions: %w", err)
+	}
+	return NewCalendar(month, utils.DateOf(s.now()), subs)
+}
+
+// NewCalendar lays out the month containing month, marking today.
+func NewCalendar(month, today utils.Date, subs []database.Subscription) (*Calendar, error) {
+	first := utils.Date{Year: month.Year, Month: month.Month, Day: 1}
+	next := first.AddDate(0, 1, 0)
+	c := &Calendar{Month: first}
+
+	byDate := make(map[utils.Date][]database.Subscription)
+	var all []database.Subscription
+	for i := range subs {
+		sub := &subs[i]
+		if sub.Status != database.StatusActive {
+			continue
+		}
+		err := forEachPayment(sub, next, func(date utils.Date) {
+			if !date.Before(first) {
+				byDate[date] = append(byDate[date], *sub)
+				all = append(all, *sub)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Weekday counts from Sunday; step back to the Monday on or before the
+	// first.
+	start := first.AddDays(-((int(first.In(time.UTC).Weekday()) + 6) % 7))
+	for day := start; day.Before(next); {
+		week := make([]CalendarDay, 7)
+		for i := range week {
+			week[i] = CalendarDay{Date: day, InMonth: day.Month == first.Month, Today: day == today}
+			if payments := byDate[day]; week[i].InMonth && len(payments) > 0 {
+				sort.Slice(payments, func(i, j int) bool { return payments[i].Name < payments[j].Name })
+				week[i].Payments = payments
+				week[i].Totals = totalsByCurrency(payments)
+			}
+			day = day.AddDays(1)
+		}
+		c.Weeks = append(c.Weeks, week)
+	}
+
+	c.Totals = totalsByCurrency(all)
+	c.Count = len(all)
+	return c, nil
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/utils"
+)
+
+func TestNewCalendar(t *testing.T) {
+	date := func(year int, month time.Month, day int) utils.Date {
+		return utils.Date{Year: year, Month: month, Day: day}
+	}
+	subs := []database.Subscription{
+		{Name: "Spotify", Price: 10, Currency: "USD", Cycle: "monthly", PaymentDate: date(2025, time.February, 15).Time(), Status: database.StatusActive},
+		{Name: "Netflix", Price: 15, Currency: "USD", Cycle: "monthly", PaymentDate: date(2025, time.January, 15).Time(), Status: database.StatusActive},
+		{Name: "Gym", Price: 30, Currency: "EUR", Cycle: "monthly", PaymentDate: date(2025, time.February, 28).Time(), Status: database.StatusActive},
+		{Name: "Domain", Price: 12, Currency: "USD", Cycle: "yearly", PaymentDate: date(2024, time.March, 3).Time(), Status: database.StatusActive},
+		{Name: "Later", Price: 5, Currency: "USD", Cycle: "monthly", PaymentDate: date(2025, time.March, 1).Time(), Status: database.StatusActive},
+		{Name: "Paused", Price: 99, Currency: "USD", Cycle: "monthly", PaymentDate: date(2025, time.February, 10).Time(), Status: database.StatusPaused},
+	}
+
+	c, err := NewCalendar(date(2025, time.February, 20), date(2025, time.February, 3), subs)
+	if err != nil {
+		t.Fatalf("NewCalendar() error = %v", err)
+	}
+
+	if c.Month != date(2025, time.February, 1) {
+		t.Errorf("Month = %s, want 01-02-2025", c.Month)
+	}
+	// 1 February 2025 is a Saturday, so the grid starts on Monday 27 January
+	// and ends on Sunday 2 March.
+	if len(c.Weeks) != 5 || c.Weeks[0][0].Date != date(2025, time.January, 27) || c.Weeks[4][6].Date != date(2025, time.March, 2) {
+		t.Fatalf("grid is %d weeks from %s", len(c.Weeks), c.Weeks[0][0].Date)
+	}
+
+	days := make(map[utils.Date]CalendarDay)
+	for _, week := range c.Weeks {
+		if len(week) != 7 {
+			t.Fatalf("week has %d days", len(week))
+		}
+		for _, day := range week {
+			days[day.Date] = day
+		}
+	}
+
+	if day := days[date(2025, time.February, 3)]; !day.Today || !day.InMonth {
+		t.Errorf("3 February = %+v, want today in month", day)
+	}
+	if day := days[date(2025, time.January, 31)]; day.InMonth {
+		t.Error("31 January is in month")
+	}
+
+	clustered := days[date(2025, time.February, 15)]
+	var names []string
+	for _, sub := range clustered.Payments {
+		names = append(names, sub.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"Netflix", "Spotify"}) {
+		t.Errorf("15 February payments = %v, want Netflix and Spotify", names)
+	}
+	if want := []CurrencyTotal{{"USD", 25}}; !reflect.DeepEqual(clustered.Totals, want) {
+		t.Errorf("15 February totals = %v, want %v", clustered.Totals, want)
+	}
+
+	if day := days[date(2025, time.February, 10)]; len(day.Payments) != 0 {
+		t.Errorf("paused subscription listed: %+v", day.Payments)
+	}
+	if day := days[date(2025, time.March, 1)]; len(day.Payments) != 0 {
+		t.Errorf("padding day lists payments: %+v", day.Payments)
+	}
+
+	if want := []CurrencyTotal{{"EUR", 30}, {"USD", 25}}; c.Count != 3 || !reflect.DeepEqual(c.Totals, want) {
+		t.Errorf("Count = %d, Totals = %v, want 3 and %v", c.Count, c.Totals, want)
+	}
+
+	// A year ahead the yearly subscription comes round again.
+	c, err = NewCalendar(date(2026, time.March, 1), date(2025, time.February, 3), subs)
+	if err != nil {
+		t.Fatalf("NewCalendar() error = %v", err)
+	}
+	if want := []CurrencyTotal{{"EUR", 30}, {"USD", 42}}; !reflect.DeepEqual(c.Totals, want) {
+		t.Errorf("March 2026 totals = %v, want %v", c.Totals, want)
+	}
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/utils"
+)
+
+// CSVFields are the subscription fields read from and written to CSV, in
+// export column order.
+var CSVFields = []string{"name", "price", "currency", "cycle", "payment_date", "category"}
+
+// optionalCSVFields may be missing from an imported CSV.
+var optionalCSVFields = map[string]bool{"category": true}
+
+const (
+	ImportAdded     = "added"
+	ImportDuplicate = "duplicate"
+	ImportInvalid   = "invalid"
+)
+
+type ImportOptions struct {
+	// Mapping maps a field in CSVFields to the CSV header holding it.
+	// Unmapped fields are looked up by their own name, ignoring case.
+	Mapping map[string]string
+	// DryRun validates every row without writing to the database.
+	DryRun bool
+}
+
+type ImportRow struct {
+	Line   int
+	Name   string
+	Status string
+	Err    error
+}
+
+type ImportResult struct {
+	Rows       []ImportRow
+	Added      int
+	Duplicates int
+	Invalid    int
+	DryRun     bool
+}
+
+// ParseColumnMapping parses "field=Column" pairs separated by commas.
+func ParseColumnMapping(s string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	if strings.TrimSpace(s) == "" {
+		return mapping, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !ok || !isCSVField(field) || strings.TrimSpace(column) == "" {
+			return nil, fmt.Errorf("invalid column mapping %q (use field=Column with fields %s)", pair, strings.Join(CSVFields, ", "))
+		}
+		mapping[field] = strings.TrimSpace(column)
+	}
+	return mapping, nil
+}
+
+func isCSVField(field string) bool {
+	for _, f := range CSVFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// ImportCSV adds one subscription per CSV row. Rows are validated like
+// AddSubscription; rows whose name matches an existing subscription or an
+// earlier row, ignoring case, are skipped as duplicates. Row problems are
+// reported in the result; only unreadable input returns an error.
+func (s *SubscriptionService) ImportCSV(r io.Reader, opts ImportOptions) (*ImportResult, error) {
+	// Spreadsheet programs often start UTF-8 CSV files with a byte order
+	// mark, which would otherwise become part of the first column name.
+	br := bufio.NewReader(r)
+	if bom, _ := br.Peek(3); string(bom) == "\uFEFF" {
+		br.Discard(3)
+	}
+	reader := csv.NewReader(br)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("CSV file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns, err := resolveColumns(header, opts.Mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.db.GetAllSubscriptions()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(existing))
+	for _, sub := range existing {
+		seen[strings.ToLower(strings.TrimSpace(sub.Name))] = true
+	}
+
+	checkBudget := func(*database.Subscription) {}
+	if !opts.DryRun {
+		checkBudget = s.watchBudget()
+	}
+	// The budget is checked once the import is done, against the last row
+	// added in each currency.
+	lastAdded := make(map[string]*database.Subscription)
+
+	result := &ImportResult{DryRun: opts.DryRun}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("failed to read CSV: %w", err)
+			}
+			result.add(ImportRow{Line: parseErr.Line, Status: ImportInvalid, Err: parseErr.Err})
+			continue
+		}
+		// FieldPos is only valid after a successful Read.
+		line, _ := reader.FieldPos(0)
+
+		value := func(field string) string {
+			if i, ok := columns[field]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row := ImportRow{Line: line, Name: value("name")}
+		sub, err := newSubscription(row.Name, value("price"), value("currency"), value("cycle"), value("payment_date"))
+		switch {
+		case err != nil:
+			row.Status, row.Err = ImportInvalid, err
+		case seen[strings.ToLower(row.Name)]:
+			row.Status = ImportDuplicate
+		default:
+			row.Status = ImportAdded
+			seen[strings.ToLower(row.Name)] = true
+			sub.Category = value("category")
+			if !opts.DryRun {
+				if err := s.db.CreateSubscription(sub); err != nil {
+					row.Status, row.Err = ImportInvalid, err
+				} else {
+					lastAdded[strings.ToUpper(sub.Currency)] = sub
+				}
+			}
+		}
+		result.add(row)
+	}
+
+	for _, sub := range lastAdded {
+		checkBudget(sub)
+	}
+	return result, nil
+}
+
+func (r *ImportResult) add(row ImportRow) {
+	r.Rows = append(r.Rows, row)
+	switch row.Status {
+	case ImportAdded:
+		r.Added++
+	case ImportDuplicate:
+		r.Duplicates++
+	default:
+		r.Invalid++
+	}
+}
+
+// resolveColumns returns the column index of every field the CSV has.
+func resolveColumns(header []string, mapping map[string]string) (map[string]int, error) {
+	index := make(map[string]int, len(header))
+	for i, h := range header {
+		index[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+
+	columns := make(map[string]int, len(CSVFields))
+	for _, field := range CSVFields {
+		column, mapped := mapping[field]
+		if !mapped {
+			column = field
+		}
+		i, ok := index[strings.ToLower(column)]
+		switch {
+		case ok:
+			columns[field] = i
+		case optionalCSVFields[field] && !mapped:
+			// Rows from a CSV without the column are imported without it.
+		default:
+			return nil, fmt.Errorf("CSV has no column %q for %s", column, field)
+		}
+	}
+	return columns, nil
+}
+
+// ExportCSV writes all subscriptions with a header row that ImportCSV
+// accepts without a mapping.
+func (s *SubscriptionService) ExportCSV(w io.Writer) error {
+	subs, err := s.db.GetAllSubscriptions()
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(CSVFields); err != nil {
+		return err
+	}
+	for _, sub := range subs {
+		record := []string{
+			sub.Name,
+			strconv.FormatFloat(sub.Price, 'f', 2, 64),
+			sub.Currency,
+			sub.Cycle,
+			utils.FormatDate(sub.PaymentDate),
+			sub.Category,
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
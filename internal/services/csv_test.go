@@ -0,0 +1,167 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+)
+
+func TestSubscriptionService_ImportCSV(t *testing.T) {
+	subSvc, db, _ := setupSubscriptionService(t)
+
+	existing := &database.Subscription{Name: "Netflix", Price: 15.99, Currency: "USD", Cycle: "monthly", PaymentDate: time.Now()}
+	if err := db.CreateSubscription(existing); err != nil {
+		t.Fatalf("failed to create test subscription: %v", err)
+	}
+
+	input := `Service,Amount,Currency,Cycle,Next Billing
+Spotify,9.99,USD,monthly,15-02-2025
+netflix,19.99,USD,monthly,15-02-2025
+Broken,abc,USD,monthly,15-02-2025
+Gym,30,EUR,weekly,01-03-2025
+"Cloud, Storage",2.99,EUR,yearly,01-03-2025
+spotify,9.99,USD,monthly,15-02-2025
+`
+	mapping, err := ParseColumnMapping("name=Service, price=amount,payment_date=Next Billing")
+	if err != nil {
+		t.Fatalf("ParseColumnMapping() error = %v", err)
+	}
+
+	preview, err := subSvc.ImportCSV(strings.NewReader(input), ImportOptions{Mapping: mapping, DryRun: true})
+	if err != nil {
+		t.Fatalf("ImportCSV() dry run error = %v", err)
+	}
+	if preview.Added != 2 || preview.Duplicates != 2 || preview.Invalid != 2 {
+		t.Errorf("dry run = %d added, %d duplicates, %d invalid, want 2/2/2", preview.Added, preview.Duplicates, preview.Invalid)
+	}
+	if subs, _ := db.GetAllSubscriptions(); len(subs) != 1 {
+		t.Fatalf("dry run wrote %d subscriptions", len(subs)-1)
+	}
+
+	wantStatus := []string{ImportAdded, ImportDuplicate, ImportInvalid, ImportInvalid, ImportAdded, ImportDuplicate}
+	for i, row := range preview.Rows {
+		if row.Status != wantStatus[i] {
+			t.Errorf("row %d (%s) status = %s, want %s", i, row.Name, row.Status, wantStatus[i])
+		}
+		if row.Line != i+2 {
+			t.Errorf("row %d line = %d, want %d", i, row.Line, i+2)
+		}
+	}
+	if err := preview.Rows[3].Err; err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("row 3 error = %v, want cycle validation error", err)
+	}
+
+	result, err := subSvc.ImportCSV(strings.NewReader(input), ImportOptions{Mapping: mapping})
+	if err != nil {
+		t.Fatalf("ImportCSV() error = %v", err)
+	}
+	if result.Added != 2 {
+		t.Errorf("ImportCSV() added %d, want 2", result.Added)
+	}
+	if subs, _ := db.GetAllSubscriptions(); len(subs) != 3 {
+		t.Errorf("database has %d subscriptions, want 3", len(subs))
+	}
+}
+
+func TestSubscriptionService_ImportCSV_MalformedRow(t *testing.T) {
+	subSvc, _, _ := setupSubscriptionService(t)
+
+	input := `name,price,currency,cycle,payment_date
+B"x,1,USD,monthly,01-01-2030
+Gym,30,EUR,monthly,01-03-2030
+`
+	result, err := subSvc.ImportCSV(strings.NewReader(input), ImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("ImportCSV() error = %v", err)
+	}
+	if result.Added != 1 || result.Invalid != 1 {
+		t.Fatalf("ImportCSV() = %d added, %d invalid, want 1/1", result.Added, result.Invalid)
+	}
+	bad := result.Rows[0]
+	if bad.Status != ImportInvalid || bad.Line != 2 || bad.Err == nil {
+		t.Errorf("malformed row = %+v, want invalid on line 2", bad)
+	}
+	if good := result.Rows[1]; good.Status != ImportAdded || good.Line != 3 {
+		t.Errorf("next row = %+v, want added on line 3", good)
+	}
+}
+
+func TestSubscriptionService_ImportCSV_ByteOrderMark(t *testing.T) {
+	subSvc, _, _ := setupSubscriptionService(t)
+
+	for _, header := range []string{`name,price,currency,cycle,payment_date`, `"name","price","currency","cycle","payment_date"`} {
+		input := "\uFEFF" + header + "\nGym,30,EUR,monthly,01-03-2030\n"
+		result, err := subSvc.ImportCSV(strings.NewReader(input), ImportOptions{DryRun: true})
+		if err != nil {
+			t.Fatalf("ImportCSV(%s) error = %v", header, err)
+		}
+		if result.Added != 1 || result.Rows[0].Name != "Gym" {
+			t.Errorf("ImportCSV(%s) = %+v, want Gym added", header, result.Rows)
+		}
+	}
+}
+
+func TestSubscriptionService_ImportCSV_Errors(t *testing.T) {
+	subSvc, _, _ := setupSubscriptionService(t)
+
+	tests := []struct {
+		name    string
+		input   string
+		mapping string
+	}{
+		{name: "empty file", input: ""},
+		{name: "missing column", input: "name,price,currency,cycle\nA,1,USD,monthly\n"},
+		{name: "mapped column missing", input: "name,price,currency,cycle,payment_date\n", mapping: "name=Service"},
+		{name: "mapped optional column missing", input: "name,price,currency,cycle,payment_date\n", mapping: "category=Group"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapping, err := ParseColumnMapping(tt.mapping)
+			if err != nil {
+				t.Fatalf("ParseColumnMapping() error = %v", err)
+			}
+			if _, err := subSvc.ImportCSV(strings.NewReader(tt.input), ImportOptions{Mapping: mapping}); err == nil {
+				t.Error("ImportCSV() expected error")
+			}
+		})
+	}
+
+	if _, err := ParseColumnMapping("colour=Color"); err == nil {
+		t.Error("ParseColumnMapping() expected error for unknown field")
+	}
+}
+
+func TestSubscriptionService_ExportCSV_RoundTrip(t *testing.T) {
+	subSvc, _, _ := setupSubscriptionService(t)
+
+	if err := subSvc.AddSubscription("Cloud, Storage", "2.99", "EUR", "yearly", "01-03-2025", "Backup"); err != nil {
+		t.Fatalf("AddSubscription() error = %v", err)
+	}
+	if err := subSvc.AddSubscription("Netflix", "15.99", "USD", "monthly", "15-02-2025", ""); err != nil {
+		t.Fatalf("AddSubscription() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := subSvc.ExportCSV(&buf); err != nil {
+		t.Fatalf("ExportCSV() error = %v", err)
+	}
+
+	want := "name,price,currency,cycle,payment_date,category\n\"Cloud, Storage\",2.99,EUR,yearly,01-03-2025,Backup\nNetflix,15.99,USD,monthly,15-02-2025,\n"
+	if buf.String() != want {
+		t.Errorf("ExportCSV() = %q, want %q", buf.String(), want)
+	}
+
+	other, db, _ := setupSubscriptionService(t)
+	result, err := other.ImportCSV(&buf, ImportOptions{})
+	if err != nil || result.Added != 2 {
+		t.Fatalf("re-import = %+v, %v, want 2 added", result, err)
+	}
+	subs, _ := db.GetAllSubscriptions()
+	if len(subs) != 2 || subs[0].Category != "Backup" || subs[1].Category != "" {
+		t.Errorf("re-imported subscriptions = %+v, want categories Backup and none", subs)
+	}
+}
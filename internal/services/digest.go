@@ -0,0 +1,141 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/utils"
+)
+
+type DigestPeriod string
+
+const (
+	DigestDaily  DigestPeriod = "daily"
+	DigestWeekly DigestPeriod = "weekly"
+)
+
+// ParseDigestPeriod validates a period name from config or the command line.
+func ParseDigestPeriod(s string) (DigestPeriod, error) {
+	switch p := DigestPeriod(s); p {
+	case DigestDaily, DigestWeekly:
+		return p, nil
+	default:
+		return "", fmt.Errorf("digest period must be 'daily' or 'weekly'")
+	}
+}
+
+// Days returns the number of calendar days, starting today, the period covers.
+func (p DigestPeriod) Days() int {
+	if p == DigestWeekly {
+		return 7
+	}
+	return 1
+}
+
+type CurrencyTotal struct {
+	Currency string
+	Amount   float64
+}
+
+type DigestDay struct {
+	Date          utils.Date
+	Subscriptions []database.Subscription
+	Totals        []CurrencyTotal
+}
+
+// Digest summarizes all payments due within a period, grouped by day, with
+// per-currency totals for each day and for the whole period.
+type Digest struct {
+	Period DigestPeriod
+	From   utils.Date
+	To     utils.Date
+	Days   []DigestDay
+	Totals []CurrencyTotal
+	Count  int
+}
+
+// NewDigest groups subs by payment date. Subscriptions outside the period
+// starting at from are ignored.
+func NewDigest(period DigestPeriod, from utils.Date, subs []database.Subscription) *Digest {
+	d := &Digest{
+		Period: period,
+		From:   from,
+		To:     from.AddDays(period.Days() - 1),
+	}
+
+	byDate := make(map[utils.Date][]database.Subscription)
+	var all []database.Subscription
+	for _, sub := range subs {
+		date := utils.DateOf(sub.PaymentDate)
+		if date.Before(d.From) || date.After(d.To) {
+			continue
+		}
+		byDate[date] = append(byDate[date], sub)
+		all = append(all, sub)
+	}
+
+	for date, daySubs := range byDate {
+		sort.Slice(daySubs, func(i, j int) bool { return daySubs[i].Name < daySubs[j].Name })
+		d.Days = append(d.Days, DigestDay{
+			Date:          date,
+			Subscriptions: daySubs,
+			Totals:        totalsByCurrency(daySubs),
+		})
+	}
+	sort.Slice(d.Days, func(i, j int) bool { return d.Days[i].Date.Before(d.Days[j].Date) })
+
+	d.Totals = totalsByCurrency(all)
+	d.Count = len(all)
+	return d
+}
+
+func totalsByCurrency(subs []database.Subscription) []CurrencyTotal {
+	amounts := make(map[string]float64)
+	for _, sub := range subs {
+		amounts[sub.Currency] += sub.Price
+	}
+
+	totals := make([]CurrencyTotal, 0, len(amounts))
+	for currency, amount := range amounts {
+		totals = append(totals, CurrencyTotal{Currency: currency, Amount: amount})
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].Currency < totals[j].Currency })
+	return totals
+}
+
+// BuildDigest collects the payments due in the period starting today.
+func (s *SubscriptionService) BuildDigest(period DigestPeriod) (*Digest, error) {
+	subs, err := s.UpcomingPayments(period.Days())
+	if err != nil {
+		return nil, err
+	}
+
+	return NewDigest(period, utils.Today(), subs), nil
+}
+
+// SendDigest queues a single message summarizing the period's payments.
+// Nothing is queued when no payments are due.
+func (s *SubscriptionService) SendDigest(period DigestPeriod) error {
+	digest, err := s.BuildDigest(period)
+	if err != nil {
+		return err
+	}
+	return s.QueueDigest(digest)
+}
+
+// QueueDigest queues a digest built by BuildDigest, unless it is empty.
+func (s *SubscriptionService) QueueDigest(digest *Digest) error {
+	if digest.Count == 0 {
+		log.Printf("No payments due for %s digest", digest.Period)
+		return nil
+	}
+
+	if _, err := s.enqueue(EventDigest, digest); err != nil {
+		return fmt.Errorf("failed to queue %s digest: %w", digest.Period, err)
+	}
+
+	log.Printf("Queued %s digest with %d payments", digest.Period, digest.Count)
+	return nil
+}
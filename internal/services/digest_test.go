@@ -0,0 +1,132 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/utils"
+)
+
+func TestNewDigest(t *testing.T) {
+	from := utils.Date{Year: 2025, Month: time.June, Day: 9}
+
+	subs := []database.Subscription{
+		{Name: "Spotify", Price: 9.99, Currency: "USD", Cycle: "monthly", PaymentDate: from.Time()},
+		{Name: "Netflix", Price: 15.99, Currency: "USD", Cycle: "monthly", PaymentDate: from.Time()},
+		{Name: "Hetzner", Price: 4.51, Currency: "EUR", Cycle: "monthly", PaymentDate: from.AddDays(3).Time()},
+		{Name: "iCloud", Price: 2.99, Currency: "EUR", Cycle: "monthly", PaymentDate: from.AddDays(6).Time()},
+		{Name: "Next week", Price: 100, Currency: "USD", Cycle: "yearly", PaymentDate: from.AddDays(7).Time()},
+	}
+
+	digest := NewDigest(DigestWeekly, from, subs)
+
+	if digest.Count != 4 {
+		t.Errorf("Count = %d, want 4", digest.Count)
+	}
+	if len(digest.Days) != 3 {
+		t.Fatalf("len(Days) = %d, want 3", len(digest.Days))
+	}
+	if digest.Days[0].Date != from || digest.Days[0].Subscriptions[0].Name != "Netflix" {
+		t.Errorf("Days[0] = %v %s, want %v Netflix first", digest.Days[0].Date, digest.Days[0].Subscriptions[0].Name, from)
+	}
+	if got := digest.Days[0].Totals; len(got) != 1 || got[0].Currency != "USD" || !closeTo(got[0].Amount, 25.98) {
+		t.Errorf("Days[0].Totals = %v, want [{USD 25.98}]", got)
+	}
+
+	want := []CurrencyTotal{{Currency: "EUR", Amount: 7.5}, {Currency: "USD", Amount: 25.98}}
+	if len(digest.Totals) != len(want) {
+		t.Fatalf("Totals = %v, want %v", digest.Totals, want)
+	}
+	for i := range want {
+		if digest.Totals[i].Currency != want[i].Currency || !closeTo(digest.Totals[i].Amount, want[i].Amount) {
+			t.Errorf("Totals[%d] = %v, want %v", i, digest.Totals[i], want[i])
+		}
+	}
+}
+
+func TestRenderDefault_Digest(t *testing.T) {
+	from := utils.Date{Year: 2025, Month: time.June, Day: 9}
+	subs := []database.Subscription{
+		{Name: "My_Service <Pro>", Price: 9.99, Currency: "USD", Cycle: "monthly", PaymentDate: from.Time()},
+	}
+	digest := NewDigest(DigestDaily, from, subs)
+
+	tests := []struct {
+		format MessageFormat
+		want   []string
+	}{
+		{format: FormatMarkdownV2, want: []string{`Today you pay 1 subscription totalling 9\.99 USD`, `My\_Service <Pro\>: 9\.99 USD \(monthly\)`, `Monday, 09\-06\-2025`}},
+		{format: FormatText, want: []string{"Today you pay 1 subscription totalling 9.99 USD", "  - My_Service <Pro>: 9.99 USD (monthly)"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			got, err := RenderDefault(EventDigest, tt.format, digest)
+			if err != nil {
+				t.Fatalf("RenderDefault() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("RenderDefault() = %q, want it to contain %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSubscriptionService_SendDigest(t *testing.T) {
+	subSvc, db, mockTg := setupSubscriptionService(t)
+
+	today := utils.Today()
+	for i, name := range []string{"Netflix", "Spotify", "Later"} {
+		sub := &database.Subscription{
+			Name:        name,
+			Price:       10,
+			Currency:    "USD",
+			Cycle:       "monthly",
+			PaymentDate: today.AddDays(i * 5).Time(),
+		}
+		if err := db.CreateSubscription(sub); err != nil {
+			t.Fatalf("failed to create test subscription: %v", err)
+		}
+	}
+
+	var messages []string
+	mockTg.SendMessageFunc = func(text string) error {
+		messages = append(messages, text)
+		return nil
+	}
+
+	if err := subSvc.SendDigest(DigestWeekly); err != nil {
+		t.Fatalf("SendDigest() error = %v", err)
+	}
+	if _, err := subSvc.DrainOutbox(); err != nil {
+		t.Fatalf("DrainOutbox() error = %v", err)
+	}
+
+	if len(messages) != 1 {
+		t.Fatalf("SendDigest() sent %d messages, want 1", len(messages))
+	}
+	if !strings.Contains(messages[0], `2 subscriptions totalling 20\.00 USD`) {
+		t.Errorf("SendDigest() message = %q", messages[0])
+	}
+}
+
+func TestParseDigestPeriod(t *testing.T) {
+	if p, err := ParseDigestPeriod("daily"); err != nil || p.Days() != 1 {
+		t.Errorf("ParseDigestPeriod(daily) = %v, %v", p, err)
+	}
+	if p, err := ParseDigestPeriod("weekly"); err != nil || p.Days() != 7 {
+		t.Errorf("ParseDigestPeriod(weekly) = %v, %v", p, err)
+	}
+	if _, err := ParseDigestPeriod("hourly"); err == nil {
+		t.Error("ParseDigestPeriod(hourly) expected error")
+	}
+}
+
+func closeTo(a, b float64) bool {
+	d := a - b
+	return d < 1e-9 && d > -1e-9
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/utils"
+)
+
+const icsProdID = "-//subtrack//subtrack//EN"
+
+// ExportICS writes every active subscription as a recurring all-day event in
+// iCalendar format (RFC 5545). Each event has alarms at the start of the
+// upcoming payment alert window and on the payment day.
+func (s *SubscriptionService) ExportICS(w io.Writer) error {
+	page, err := s.db.FindSubscriptions(database.SubscriptionQuery{Statuses: []string{database.StatusActive}})
+	if err != nil {
+		return err
+	}
+	subs := page.Subscriptions
+
+	stamp := s.now().UTC().Format("20060102T150405Z")
+
+	iw := &icsWriter{w: bufio.NewWriter(w)}
+	iw.line("BEGIN", "VCALENDAR")
+	iw.line("VERSION", "2.0")
+	iw.line("PRODID", icsProdID)
+	iw.line("CALSCALE", "GREGORIAN")
+	iw.line("METHOD", "PUBLISH")
+	iw.line("X-WR-CALNAME", "Subscription payments")
+
+	for _, sub := range subs {
+		start := utils.DateOf(sub.PaymentDate)
+		amount := fmt.Sprintf("%.2f %s", sub.Price, sub.Currency)
+
+		iw.line("BEGIN", "VEVENT")
+		iw.line("UID", fmt.Sprintf("subscription-%d@subtrack", sub.ID))
+		iw.line("DTSTAMP", stamp)
+		iw.line("DTSTART;VALUE=DATE", icsDate(start))
+		iw.line("DTEND;VALUE=DATE", icsDate(start.AddDays(1)))
+		iw.line("RRULE", recurrenceRule(start, sub.AnchorDay(), sub.Cycle))
+		iw.line("SUMMARY", icsText(sub.Name+" payment ("+amount+")"))
+		iw.line("DESCRIPTION", icsText(fmt.Sprintf("%s %s payment of %s.", sub.Name, sub.Cycle, amount)))
+		iw.line("TRANSP", "TRANSPARENT")
+		for _, days := range []int{reminderDays - 1, 0} {
+			iw.line("BEGIN", "VALARM")
+			iw.line("ACTION", "DISPLAY")
+			iw.line("DESCRIPTION", icsText(reminderText(sub.Name, days)))
+			iw.line("TRIGGER", icsTrigger(days))
+			iw.line("END", "VALARM")
+		}
+		iw.line("END", "VEVENT")
+	}
+
+	iw.line("END", "VCALENDAR")
+	return iw.flush()
+}
+
+// recurrenceRule repeats the payment on day every month, or every year in
+// start's month. Payment days that some months lack (the 29th to 31st, or
+// 29 February) fall back to the last day of those months rather than
+// skipping them.
+func recurrenceRule(start utils.Date, day int, cycle string) string {
+	rule := "FREQ=MONTHLY"
+	if cycle == "yearly" {
+		rule = fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d", start.Month)
+	}
+
+	// A yearly payment only needs the fallback when its own month is shorter
+	// than the day, as April is for a payment day of 31. February counts as
+	// 28 days, since most years it has no 29th.
+	const shortestMonth = 28
+	shortest := shortestMonth
+	if cycle == "yearly" {
+		shortest = utils.Date{Year: 2025, Month: start.Month, Day: 1}.AddDate(0, 1, -1).Day
+	}
+	if day <= shortest {
+		return fmt.Sprintf("%s;BYMONTHDAY=%d", rule, day)
+	}
+
+	days := make([]string, 0, day-shortestMonth+1)
+	for d := shortestMonth; d <= day; d++ {
+		days = append(days, fmt.Sprint(d))
+	}
+	return fmt.Sprintf("%s;BYMONTHDAY=%s;BYSETPOS=-1", rule, strings.Join(days, ","))
+}
+
+func reminderText(name string, days int) string {
+	switch days {
+	case 0:
+		return name + " payment is due today"
+	case 1:
+		return name + " payment is due tomorrow"
+	default:
+		return fmt.Sprintf("%s payment is due in %d days", name, days)
+	}
+}
+
+func icsDate(d utils.Date) string {
+	return fmt.Sprintf("%04d%02d%02d", d.Year, int(d.Month), d.Day)
+}
+
+func icsTrigger(days int) string {
+	if days == 0 {
+		return "PT0S"
+	}
+	return fmt.Sprintf("-P%dD", days)
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// icsText escapes a TEXT property value.
+func icsText(s string) string {
+	return icsEscaper.Replace(s)
+}
+
+// icsWriter writes content lines with CRLF endings, folding them at 75
+// octets without splitting UTF-8 sequences.
+type icsWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (iw *icsWriter) line(name, value string) {
+	if iw.err != nil {
+		return
+	}
+
+	line := name + ":" + value
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		if _, iw.err = iw.w.WriteString(line[:cut] + "\r\n "); iw.err != nil {
+			return
+		}
+		line = line[cut:]
+		limit = 74 // continuation lines start with a space
+	}
+	_, iw.err = iw.w.WriteString(line + "\r\n")
+}
+
+func (iw *icsWriter) flush() error {
+	if iw.err != nil {
+		return iw.err
+	}
+	return iw.w.Flush()
+}
+
+// FeedToken returns username's calendar feed token, issuing one on first use.
+func (s *SubscriptionService) FeedToken(username string) (string, error) {
+	token, err := s.db.GetFeedToken(username)
+	if err != nil {
+		return "", err
+	}
+	if token != nil {
+		return token.Token, nil
+	}
+	return s.RotateFeedToken(username)
+}
+
+// RotateFeedToken issues a new feed token for username. Calendar apps using
+// the old feed URL lose access.
+func (s *SubscriptionService) RotateFeedToken(username string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate feed token: %w", err)
+	}
+
+	token := &database.FeedToken{Username: username, Token: base64.RawURLEncoding.EncodeToString(buf)}
+	if err := s.db.SaveFeedToken(token); err != nil {
+		return "", fmt.Errorf("failed to save feed token: %w", err)
+	}
+	return token.Token, nil
+}
+
+// ValidFeedToken reports whether token is username's current feed token.
+func (s *SubscriptionService) ValidFeedToken(username, token string) (bool, error) {
+	current, err := s.db.GetFeedToken(username)
+	if err != nil || current == nil || token == "" {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(current.Token)) == 1, nil
+}
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/utils"
+)
+
+func TestSubscriptionService_ExportICS(t *testing.T) {
+	subSvc, db, _ := setupSubscriptionService(t)
+	subSvc.now = func() time.Time { return time.Date(2025, 2, 10, 8, 30, 0, 0, time.UTC) }
+
+	subs := []*database.Subscription{
+		{Name: "Netflix", Price: 15.99, Currency: "USD", Cycle: "monthly", PaymentDate: time.Date(2025, 2, 15, 0, 0, 0, 0, time.UTC)},
+		{Name: "Domain, Renewal; example.com", Price: 12, Currency: "EUR", Cycle: "yearly", PaymentDate: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, sub := range subs {
+		if err := db.CreateSubscription(sub); err != nil {
+			t.Fatalf("failed to create test subscription: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := subSvc.ExportICS(&buf); err != nil {
+		t.Fatalf("ExportICS() error = %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"UID:subscription-1@subtrack\r\n",
+		"DTSTAMP:20250210T083000Z\r\n",
+		"DTSTART;VALUE=DATE:20250215\r\n",
+		"DTEND;VALUE=DATE:20250216\r\n",
+		"RRULE:FREQ=MONTHLY;BYMONTHDAY=15\r\n",
+		"SUMMARY:Netflix payment (15.99 USD)\r\n",
+		"TRIGGER:-P4D\r\n",
+		"TRIGGER:PT0S\r\n",
+		"RRULE:FREQ=YEARLY;BYMONTH=7;BYMONTHDAY=1\r\n",
+		`SUMMARY:Domain\, Renewal\; example.com payment (12.00 EUR)`,
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("feed missing %q", want)
+		}
+	}
+	if n := strings.Count(out, "BEGIN:VEVENT"); n != 2 {
+		t.Errorf("feed has %d events, want 2", n)
+	}
+	if n := strings.Count(out, "BEGIN:VALARM"); n != 4 {
+		t.Errorf("feed has %d alarms, want 4", n)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line longer than 75 octets: %q", line)
+		}
+		if strings.ContainsAny(line, "\r\n") {
+			t.Errorf("bare line break in %q", line)
+		}
+	}
+}
+
+func TestRecurrenceRule(t *testing.T) {
+	tests := []struct {
+		date  utils.Date
+		day   int
+		cycle string
+		want  string
+	}{
+		{utils.Date{Year: 2025, Month: time.January, Day: 28}, 28, "monthly", "FREQ=MONTHLY;BYMONTHDAY=28"},
+		{utils.Date{Year: 2025, Month: time.January, Day: 31}, 31, "monthly", "FREQ=MONTHLY;BYMONTHDAY=28,29,30,31;BYSETPOS=-1"},
+		{utils.Date{Year: 2025, Month: time.March, Day: 30}, 30, "monthly", "FREQ=MONTHLY;BYMONTHDAY=28,29,30;BYSETPOS=-1"},
+		{utils.Date{Year: 2025, Month: time.March, Day: 31}, 31, "yearly", "FREQ=YEARLY;BYMONTH=3;BYMONTHDAY=31"},
+		{utils.Date{Year: 2024, Month: time.February, Day: 29}, 29, "yearly", "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=28,29;BYSETPOS=-1"},
+		// Moved to the end of a short month, the payment keeps its day.
+		{utils.Date{Year: 2025, Month: time.February, Day: 28}, 31, "monthly", "FREQ=MONTHLY;BYMONTHDAY=28,29,30,31;BYSETPOS=-1"},
+		{utils.Date{Year: 2025, Month: time.February, Day: 28}, 29, "yearly", "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=28,29;BYSETPOS=-1"},
+		{utils.Date{Year: 2027, Month: time.April, Day: 30}, 31, "yearly", "FREQ=YEARLY;BYMONTH=4;BYMONTHDAY=28,29,30,31;BYSETPOS=-1"},
+	}
+
+	for _, tt := range tests {
+		if got := recurrenceRule(tt.date, tt.day, tt.cycle); got != tt.want {
+			t.Errorf("recurrenceRule(%s, %d, %s) = %s, want %s", tt.date, tt.day, tt.cycle, got, tt.want)
+		}
+	}
+}
+
+// TestSubscriptionService_ExportICS_MatchesSchedule checks that calendar apps
+// repeat each payment on the dates SubTrack moves it to.
+func TestSubscriptionService_ExportICS_MatchesSchedule(t *testing.T) {
+	subSvc, db, _ := setupSubscriptionService(t)
+
+	subs := []*database.Subscription{
+		{Name: "Gym", Price: 30, Currency: "EUR", Cycle: "monthly", PaymentDate: time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)},
+		{Name: "Rent", Price: 900, Currency: "EUR", Cycle: "monthly", PaymentDate: time.Date(2025, 1, 30, 0, 0, 0, 0, time.UTC)},
+		{Name: "Netflix", Price: 15.99, Currency: "USD", Cycle: "monthly", PaymentDate: time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{Name: "Domain", Price: 12, Currency: "EUR", Cycle: "yearly", PaymentDate: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{Name: "Insurance", Price: 300, Currency: "EUR", Cycle: "yearly", PaymentDate: time.Date(2025, 8, 31, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, sub := range subs {
+		if err := db.CreateSubscription(sub); err != nil {
+			t.Fatalf("failed to create test subscription: %v", err)
+		}
+	}
+
+	until := utils.Date{Year: 2030, Month: time.January, Day: 1}
+	for _, sub := range subs {
+		var want []utils.Date
+		if err := forEachPayment(sub, until, func(d utils.Date) { want = append(want, d) }); err != nil {
+			t.Fatal(err)
+		}
+
+		// Paying moves the stored date along the same schedule, and the feed
+		// and projections made afterwards keep repeating on it.
+		for paid := 0; paid <= 4; paid++ {
+			if paid > 0 {
+				if _, err := subSvc.MarkPaid(sub.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+			stored, err := db.GetSubscriptionByID(sub.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			if err := subSvc.ExportICS(&buf); err != nil {
+				t.Fatalf("ExportICS() error = %v", err)
+			}
+			if got := expandEvent(t, feedEvent(t, buf.String(), sub.ID), until); !slices.Equal(got, want[paid:]) {
+				t.Errorf("%s: paid %d times, feed repeats on %v, SubTrack on %v", sub.Name, paid, got, want[paid:])
+				break
+			}
+
+			var projected []utils.Date
+			if err := forEachPayment(stored, until, func(d utils.Date) { projected = append(projected, d) }); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(projected, want[paid:]) {
+				t.Errorf("%s: paid %d times, projected on %v, want %v", sub.Name, paid, projected, want[paid:])
+				break
+			}
+		}
+	}
+}
+
+// TestSubscriptionService_ExportICS_CycleChange checks that the feed follows a
+// payment made yearly after its day was moved to the end of a shorter month.
+func TestSubscriptionService_ExportICS_CycleChange(t *testing.T) {
+	subSvc, db, _ := setupSubscriptionService(t)
+
+	sub := &database.Subscription{Name: "Gym", Price: 30, Currency: "EUR", Cycle: "monthly", PaymentDate: time.Date(2027, 1, 31, 0, 0, 0, 0, time.UTC)}
+	if err := db.CreateSubscription(sub); err != nil {
+		t.Fatalf("failed to create test subscription: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := subSvc.MarkPaid(sub.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := subSvc.UpdateSubscription(sub.ID, "", "", "", "yearly", ""); err != nil {
+		t.Fatalf("UpdateSubscription() error = %v", err)
+	}
+	stored, err := db.GetSubscriptionByID(sub.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := utils.DateOf(stored.PaymentDate); got != (utils.Date{Year: 2027, Month: time.April, Day: 30}) {
+		t.Fatalf("payment date = %s, want 2027-04-30", got)
+	}
+
+	until := utils.Date{Year: 2031, Month: time.January, Day: 1}
+	var want []utils.Date
+	if err := forEachPayment(stored, until, func(d utils.Date) { want = append(want, d) }); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := subSvc.ExportICS(&buf); err != nil {
+		t.Fatalf("ExportICS() error = %v", err)
+	}
+	if got := expandEvent(t, feedEvent(t, buf.String(), sub.ID), until); len(want) != 4 || !slices.Equal(got, want) {
+		t.Errorf("feed repeats on %v, SubTrack on %v", got, want)
+	}
+}
+
+// feedEvent returns the VEVENT for subscription id in an exported feed.
+func feedEvent(t *testing.T, feed string, id uint) string {
+	t.Helper()
+	uid := fmt.Sprintf("UID:subscription-%d@subtrack\r\n", id)
+	for _, event := range strings.Split(feed, "BEGIN:VEVENT\r\n")[1:] {
+		if strings.Contains(event, uid) {
+			return event
+		}
+	}
+	t.Fatalf("subscription %d missing from the feed", id)
+	return ""
+}
+
+// expandEvent returns the dates before until that an event's DTSTART and
+// RRULE repeat on, for the rules recurrenceRule writes.
+func expandEvent(t *testing.T, event string, until utils.Date) []utils.Date {
+	t.Helper()
+	props := make(map[string]string)
+	for _, line := range strings.Split(event, "\r\n") {
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			props[name] = value
+		}
+	}
+	start, err := time.Parse("20060102", props["DTSTART;VALUE=DATE"])
+	if err != nil {
+		t.Fatalf("DTSTART: %v", err)
+	}
+	rule := make(map[string]string)
+	for _, part := range strings.Split(props["RRULE"], ";") {
+		name, value, _ := strings.Cut(part, "=")
+		rule[name] = value
+	}
+
+	step := 1
+	if rule["FREQ"] == "YEARLY" {
+		step = 12
+		if rule["BYMONTH"] != fmt.Sprint(int(start.Month())) {
+			t.Fatalf("RRULE %s repeats in another month", props["RRULE"])
+		}
+	}
+	var dates []utils.Date
+	for month := (utils.Date{Year: start.Year(), Month: start.Month(), Day: 1}); month.Before(until); month = month.AddDate(0, step, 0) {
+		length := month.AddDate(0, 1, -1).Day
+		var days []int
+		for _, day := range strings.Split(rule["BYMONTHDAY"], ",") {
+			var d int
+			fmt.Sscan(day, &d)
+			if d <= length {
+				days = append(days, d)
+			}
+		}
+		if rule["BYSETPOS"] == "-1" && len(days) > 0 {
+			days = days[len(days)-1:]
+		}
+		for _, d := range days {
+			date := utils.Date{Year: month.Year, Month: month.Month, Day: d}
+			if !date.Before(utils.DateOf(start)) && date.Before(until) {
+				dates = append(dates, date)
+			}
+		}
+	}
+	return dates
+}
+
+func TestICSWriter_Folding(t *testing.T) {
+	var buf bytes.Buffer
+	iw := &icsWriter{w: bufio.NewWriter(&buf)}
+	iw.line("DESCRIPTION", strings.Repeat("ü", 100))
+	if err := iw.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	var unfolded strings.Builder
+	for i, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line %d is %d octets", i, len(line))
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line %d splits a UTF-8 sequence", i)
+		}
+		if i > 0 {
+			line = strings.TrimPrefix(line, " ")
+		}
+		unfolded.WriteString(line)
+	}
+	if want := "DESCRIPTION:" + strings.Repeat("ü", 100); unfolded.String() != want {
+		t.Errorf("unfolded = %q, want %q", unfolded.String(), want)
+	}
+}
+
+func TestSubscriptionService_FeedToken(t *testing.T) {
+	subSvc, _, _ := setupSubscriptionService(t)
+
+	if ok, err := subSvc.ValidFeedToken("admin", ""); err != nil || ok {
+		t.Fatalf("ValidFeedToken() before issuing = %v, %v", ok, err)
+	}
+
+	token, err := subSvc.FeedToken("admin")
+	if err != nil {
+		t.Fatalf("FeedToken() error = %v", err)
+	}
+	if len(token) < 40 {
+		t.Errorf("token %q is too short", token)
+	}
+	if again, _ := subSvc.FeedToken("admin"); again != token {
+		t.Errorf("FeedToken() changed between calls")
+	}
+	if ok, _ := subSvc.ValidFeedToken("admin", token); !ok {
+		t.Errorf("ValidFeedToken() rejected current token")
+	}
+	if ok, _ := subSvc.ValidFeedToken("other", token); ok {
+		t.Errorf("ValidFeedToken() accepted another user's token")
+	}
+
+	rotated, err := subSvc.RotateFeedToken("admin")
+	if err != nil {
+		t.Fatalf("RotateFeedToken() error = %v", err)
+	}
+	if rotated == token {
+		t.Errorf("RotateFeedToken() returned the old token")
+	}
+	if ok, _ := subSvc.ValidFeedToken("admin", token); ok {
+		t.Errorf("ValidFeedToken() accepted rotated-out token")
+	}
+	if ok, _ := subSvc.ValidFeedToken("admin", rotated); !ok {
+		t.Errorf("ValidFeedToken() rejected new token")
+	}
+}
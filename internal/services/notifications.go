@@ -0,0 +1,235 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/utils"
+)
+
+type EventType string
+
+const (
+	EventUpcoming       EventType = "upcoming"
+	EventTrialEnding    EventType = "trial_ending"
+	EventPriceChange    EventType = "price_change"
+	EventBudgetExceeded EventType = "budget_exceeded"
+	EventDigest         EventType = "digest"
+)
+
+var EventTypes = []EventType{EventUpcoming, EventTrialEnding, EventPriceChange, EventBudgetExceeded, EventDigest}
+
+const ChannelTelegram = "telegram"
+
+// Channels lists the delivery channels notifications can be routed to.
+var Channels = []string{ChannelTelegram}
+
+const (
+	outboxBatchSize   = 100
+	outboxMaxAttempts = 5
+)
+
+func ParseEventType(s string) (EventType, error) {
+	for _, e := range EventTypes {
+		if string(e) == s {
+			return e, nil
+		}
+	}
+	return "", fmt.Errorf("unknown event type: %s (use upcoming, trial_ending, price_change, budget_exceeded or digest)", s)
+}
+
+func validateChannel(channel string) error {
+	for _, c := range Channels {
+		if c == channel {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown channel: %s", channel)
+}
+
+// QuietHours is a daily window, in minutes after midnight in the configured
+// location, during which notifications stay in the outbox. The window may
+// wrap past midnight; Start == End means no quiet hours.
+type QuietHours struct {
+	Start int
+	End   int
+}
+
+// ParseQuietHours parses a window given as two HH:MM times.
+func ParseQuietHours(start, end string) (QuietHours, error) {
+	s, err := parseClock(start)
+	if err != nil {
+		return QuietHours{}, err
+	}
+	e, err := parseClock(end)
+	if err != nil {
+		return QuietHours{}, err
+	}
+	return QuietHours{Start: s, End: e}, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (use HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (q QuietHours) Enabled() bool {
+	return q.Start != q.End
+}
+
+// Contains reports whether t falls inside the quiet window.
+func (q QuietHours) Contains(t time.Time) bool {
+	if !q.Enabled() {
+		return false
+	}
+	local := t.In(utils.Location())
+	minute := local.Hour()*60 + local.Minute()
+	if q.Start < q.End {
+		return minute >= q.Start && minute < q.End
+	}
+	return minute >= q.Start || minute < q.End
+}
+
+func (q QuietHours) String() string {
+	if !q.Enabled() {
+		return "off"
+	}
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", q.Start/60, q.Start%60, q.End/60, q.End%60)
+}
+
+// NotificationPreferences reports, per channel, which event types are
+// delivered, together with the current quiet hours.
+func (s *SubscriptionService) NotificationPreferences() (map[string]map[EventType]bool, QuietHours, error) {
+	rows, err := s.db.GetNotificationPreferences()
+	if err != nil {
+		return nil, QuietHours{}, err
+	}
+
+	prefs := make(map[string]map[EventType]bool, len(Channels))
+	for _, channel := range Channels {
+		prefs[channel] = make(map[EventType]bool, len(EventTypes))
+		for _, event := range EventTypes {
+			prefs[channel][event] = true
+		}
+	}
+	for _, row := range rows {
+		if events, ok := prefs[row.Channel]; ok {
+			events[EventType(row.EventType)] = row.Enabled
+		}
+	}
+
+	quiet, err := s.QuietHours()
+	if err != nil {
+		return nil, QuietHours{}, err
+	}
+
+	return prefs, quiet, nil
+}
+
+func (s *SubscriptionService) SetNotificationPreference(channel, eventStr string, enabled bool) error {
+	if err := validateChannel(channel); err != nil {
+		return err
+	}
+	event, err := ParseEventType(eventStr)
+	if err != nil {
+		return err
+	}
+	return s.db.SetNotificationPreference(channel, string(event), enabled)
+}
+
+func (s *SubscriptionService) QuietHours() (QuietHours, error) {
+	settings, err := s.db.GetNotificationSettings()
+	if err != nil {
+		return QuietHours{}, err
+	}
+	return QuietHours{Start: settings.QuietStart, End: settings.QuietEnd}, nil
+}
+
+// SetQuietHours stores the quiet window. Passing the zero QuietHours turns
+// quiet hours off.
+func (s *SubscriptionService) SetQuietHours(q QuietHours) error {
+	settings, err := s.db.GetNotificationSettings()
+	if err != nil {
+		return err
+	}
+	settings.QuietStart = q.Start
+	settings.QuietEnd = q.End
+	return s.db.SaveNotificationSettings(settings)
+}
+
+// enqueue renders the event's template with data for every channel that has
+// event enabled, stores the messages in the outbox and returns how many were
+// queued.
+func (s *SubscriptionService) enqueue(event EventType, data any) (int, error) {
+	prefs, _, err := s.NotificationPreferences()
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for _, channel := range Channels {
+		if !prefs[channel][event] {
+			continue
+		}
+		body, err := s.renderMessage(channel, event, data)
+		if err != nil {
+			return queued, err
+		}
+		msg := &database.OutboxMessage{Channel: channel, EventType: string(event), Body: body}
+		if err := s.db.CreateOutboxMessage(msg); err != nil {
+			return queued, fmt.Errorf("failed to queue %s notification: %w", event, err)
+		}
+		queued++
+	}
+	return queued, nil
+}
+
+// DrainOutbox delivers pending messages unless it is currently quiet hours.
+// Failed deliveries are retried on later drains up to a fixed number of
+// attempts. It returns the number of messages delivered.
+func (s *SubscriptionService) DrainOutbox() (int, error) {
+	quiet, err := s.QuietHours()
+	if err != nil {
+		return 0, err
+	}
+	if quiet.Contains(s.now()) {
+		log.Printf("Quiet hours (%s), holding notifications", quiet)
+		return 0, nil
+	}
+
+	msgs, err := s.db.GetPendingOutboxMessages(outboxBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, msg := range msgs {
+		if err := s.deliver(msg); err != nil {
+			log.Printf("Failed to deliver %s notification %d via %s: %v", msg.EventType, msg.ID, msg.Channel, err)
+			if err := s.db.MarkOutboxAttemptFailed(msg.ID, err, outboxMaxAttempts); err != nil {
+				return sent, err
+			}
+			continue
+		}
+		if err := s.db.MarkOutboxSent(msg.ID, s.now()); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+func (s *SubscriptionService) deliver(msg database.OutboxMessage) error {
+	switch msg.Channel {
+	case ChannelTelegram:
+		return s.tg.SendMessage(msg.Body)
+	default:
+		return fmt.Errorf("unknown channel: %s", msg.Channel)
+	}
+}
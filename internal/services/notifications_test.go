@@ -0,0 +1,368 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/utils"
+)
+
+func TestQuietHours_Contains(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2025, time.June, 10, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name  string
+		start string
+		end   string
+		t     time.Time
+		want  bool
+	}{
+		{name: "same day window inside", start: "12:00", end: "14:00", t: at(13, 0), want: true},
+		{name: "same day window end is exclusive", start: "12:00", end: "14:00", t: at(14, 0), want: false},
+		{name: "overnight window late", start: "22:00", end: "07:30", t: at(23, 15), want: true},
+		{name: "overnight window early", start: "22:00", end: "07:30", t: at(7, 29), want: true},
+		{name: "overnight window daytime", start: "22:00", end: "07:30", t: at(12, 0), want: false},
+		{name: "disabled", start: "00:00", end: "00:00", t: at(0, 0), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := ParseQuietHours(tt.start, tt.end)
+			if err != nil {
+				t.Fatalf("ParseQuietHours() error = %v", err)
+			}
+			if got := q.Contains(tt.t); got != tt.want {
+				t.Errorf("Contains() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseQuietHours("25:00", "07:00"); err == nil {
+		t.Error("ParseQuietHours() expected error for invalid time")
+	}
+}
+
+func TestSubscriptionService_NotificationPreferences(t *testing.T) {
+	subSvc, _, _ := setupSubscriptionService(t)
+
+	if err := subSvc.SetNotificationPreference(ChannelTelegram, "price_change", false); err != nil {
+		t.Fatalf("SetNotificationPreference() error = %v", err)
+	}
+	if err := subSvc.SetNotificationPreference("carrier-pigeon", "upcoming", false); err == nil {
+		t.Error("SetNotificationPreference() expected error for unknown channel")
+	}
+	if err := subSvc.SetNotificationPreference(ChannelTelegram, "birthday", false); err == nil {
+		t.Error("SetNotificationPreference() expected error for unknown event")
+	}
+
+	prefs, quiet, err := subSvc.NotificationPreferences()
+	if err != nil {
+		t.Fatalf("NotificationPreferences() error = %v", err)
+	}
+	if prefs[ChannelTelegram][EventPriceChange] {
+		t.Error("price_change should be disabled for telegram")
+	}
+	if !prefs[ChannelTelegram][EventUpcoming] {
+		t.Error("upcoming should be enabled by default")
+	}
+	if quiet.Enabled() {
+		t.Errorf("quiet hours = %s, want off", quiet)
+	}
+}
+
+func TestSubscriptionService_PriceChangeRespectsPreferences(t *testing.T) {
+	subSvc, db, mockTg := setupSubscriptionService(t)
+
+	sub := &database.Subscription{Name: "Netflix", Price: 15.99, Currency: "USD", Cycle: "monthly", PaymentDate: time.Now().AddDate(0, 0, 20)}
+	if err := db.CreateSubscription(sub); err != nil {
+		t.Fatalf("failed to create test subscription: %v", err)
+	}
+
+	var messages []string
+	mockTg.SendMessageFunc = func(text string) error {
+		messages = append(messages, text)
+		return nil
+	}
+
+	if err := subSvc.UpdateSubscription(sub.ID, "", "19.99", "", "", ""); err != nil {
+		t.Fatalf("UpdateSubscription() error = %v", err)
+	}
+	if _, err := subSvc.DrainOutbox(); err != nil {
+		t.Fatalf("DrainOutbox() error = %v", err)
+	}
+	if len(messages) != 1 || !strings.Contains(messages[0], `15\.99 USD → 19\.99 USD`) {
+		t.Fatalf("messages = %q, want one price change", messages)
+	}
+
+	if err := subSvc.SetNotificationPreference(ChannelTelegram, "price_change", false); err != nil {
+		t.Fatalf("SetNotificationPreference() error = %v", err)
+	}
+	if err := subSvc.UpdateSubscription(sub.ID, "", "21.99", "", "", ""); err != nil {
+		t.Fatalf("UpdateSubscription() error = %v", err)
+	}
+	if _, err := subSvc.DrainOutbox(); err != nil {
+		t.Fatalf("DrainOutbox() error = %v", err)
+	}
+	if len(messages) != 1 {
+		t.Errorf("messages = %d after disabling price_change, want 1", len(messages))
+	}
+}
+
+func TestSubscriptionService_SendTrialNotifications(t *testing.T) {
+	subSvc, db, mockTg := setupSubscriptionService(t)
+
+	var messages []string
+	mockTg.SendMessageFunc = func(text string) error {
+		messages = append(messages, text)
+		return nil
+	}
+
+	today := utils.Today()
+	for _, sub := range []*database.Subscription{
+		{Name: "Ending", Price: 9.99, Currency: "USD", Cycle: "monthly", PaymentDate: today.AddDays(20).Time()},
+		{Name: "Later", Price: 9.99, Currency: "USD", Cycle: "monthly", PaymentDate: today.AddDays(20).Time()},
+		{Name: "Paused", Price: 9.99, Currency: "USD", Cycle: "monthly", PaymentDate: today.AddDays(20).Time(), Status: database.StatusPaused},
+		{Name: "No trial", Price: 9.99, Currency: "USD", Cycle: "monthly", PaymentDate: today.AddDays(1).Time()},
+	} {
+		if err := db.CreateSubscription(sub); err != nil {
+			t.Fatalf("failed to create test subscription: %v", err)
+		}
+	}
+	for id, end := range map[uint]string{1: utils.FormatDate(today.AddDays(2).Time()), 2: utils.FormatDate(today.AddDays(10).Time()), 3: utils.FormatDate(today.Time())} {
+		if err := subSvc.SetTrialEndDate(id, end); err != nil {
+			t.Fatalf("SetTrialEndDate() error = %v", err)
+		}
+	}
+	if err := subSvc.SetTrialEndDate(1, "31-02-2025"); err == nil {
+		t.Error("SetTrialEndDate() expected error for invalid date")
+	}
+
+	subs, err := subSvc.SendTrialNotifications()
+	if err != nil {
+		t.Fatalf("SendTrialNotifications() error = %v", err)
+	}
+	if len(subs) != 1 || subs[0].Name != "Ending" {
+		t.Errorf("SendTrialNotifications() = %v, want Ending", subs)
+	}
+	if _, err := subSvc.DrainOutbox(); err != nil {
+		t.Fatalf("DrainOutbox() error = %v", err)
+	}
+	if len(messages) != 1 || !strings.Contains(messages[0], "Trial ending: Ending") || !strings.Contains(messages[0], "2 days") {
+		t.Errorf("messages = %q, want one trial alert for Ending in 2 days", messages)
+	}
+
+	if err := subSvc.SetTrialEndDate(1, ""); err != nil {
+		t.Fatalf("SetTrialEndDate() error = %v", err)
+	}
+	if subs, _ := subSvc.SendTrialNotifications(); len(subs) != 0 {
+		t.Errorf("SendTrialNotifications() after removing the trial = %v", subs)
+	}
+}
+
+func TestSubscriptionService_BudgetExceeded(t *testing.T) {
+	subSvc, db, mockTg := setupSubscriptionService(t)
+
+	var messages []string
+	mockTg.SendMessageFunc = func(text string) error {
+		messages = append(messages, text)
+		return nil
+	}
+	drain := func() {
+		t.Helper()
+		if _, err := subSvc.DrainOutbox(); err != nil {
+			t.Fatalf("DrainOutbox() error = %v", err)
+		}
+	}
+
+	budget, err := ParseBudget("50", "eur")
+	if err != nil {
+		t.Fatalf("ParseBudget() error = %v", err)
+	}
+	if err := subSvc.SetBudget(budget); err != nil {
+		t.Fatalf("SetBudget() error = %v", err)
+	}
+	if got, _ := subSvc.Budget(); got != (Budget{Amount: 50, Currency: "EUR"}) {
+		t.Errorf("Budget() = %+v", got)
+	}
+
+	if err := subSvc.AddSubscription("Gym", "30", "EUR", "monthly", "01-03-2031", ""); err != nil {
+		t.Fatalf("AddSubscription() error = %v", err)
+	}
+	if err := subSvc.AddSubscription("Netflix", "99", "USD", "monthly", "01-03-2031", ""); err != nil {
+		t.Fatalf("AddSubscription() error = %v", err)
+	}
+	drain()
+	if len(messages) != 0 {
+		t.Fatalf("messages = %q while under budget", messages)
+	}
+
+	// 30 EUR a month plus a twelfth of 300 EUR a year is 55 EUR.
+	if err := subSvc.AddSubscription("Insurance", "300", "EUR", "yearly", "01-03-2031", ""); err != nil {
+		t.Fatalf("AddSubscription() error = %v", err)
+	}
+	drain()
+	if len(messages) != 1 || !strings.Contains(messages[0], "Budget exceeded: Insurance") || !strings.Contains(messages[0], `55\.00 EUR of 50\.00 EUR`) {
+		t.Fatalf("messages = %q, want one budget alert for Insurance", messages)
+	}
+
+	// Already over budget: further changes do not alert again.
+	if err := subSvc.UpdateSubscription(1, "", "35", "", "", ""); err != nil {
+		t.Fatalf("UpdateSubscription() error = %v", err)
+	}
+	drain()
+	if n := countContaining(messages, "Budget exceeded"); n != 1 {
+		t.Errorf("%d budget alerts after a change over budget, want 1", n)
+	}
+
+	// Back under budget, then over it again by reactivating.
+	if err := subSvc.SetSubscriptionStatus(1, database.StatusPaused); err != nil {
+		t.Fatalf("SetSubscriptionStatus() error = %v", err)
+	}
+	if err := subSvc.SetSubscriptionStatus(1, database.StatusActive); err != nil {
+		t.Fatalf("SetSubscriptionStatus() error = %v", err)
+	}
+	drain()
+	if n := countContaining(messages, "Budget exceeded: Gym"); n != 1 {
+		t.Errorf("%d budget alerts for reactivating Gym, want 1", n)
+	}
+
+	if err := subSvc.SetBudget(Budget{}); err != nil {
+		t.Fatalf("SetBudget() error = %v", err)
+	}
+	if settings, _ := db.GetNotificationSettings(); settings.BudgetAmount != 0 {
+		t.Errorf("budget amount = %v after turning it off", settings.BudgetAmount)
+	}
+	for _, tt := range [][2]string{{"0", "EUR"}, {"abc", "EUR"}, {"10", " "}} {
+		if _, err := ParseBudget(tt[0], tt[1]); err == nil {
+			t.Errorf("ParseBudget(%q, %q) expected error", tt[0], tt[1])
+		}
+	}
+}
+
+func TestSubscriptionService_BudgetExceeded_Import(t *testing.T) {
+	subSvc, _, mockTg := setupSubscriptionService(t)
+
+	var messages []string
+	mockTg.SendMessageFunc = func(text string) error {
+		messages = append(messages, text)
+		return nil
+	}
+	if err := subSvc.SetBudget(Budget{Amount: 50, Currency: "EUR"}); err != nil {
+		t.Fatalf("SetBudget() error = %v", err)
+	}
+
+	input := `name,price,currency,cycle,payment_date
+Gym,30,EUR,monthly,01-03-2031
+Netflix,99,USD,monthly,01-03-2031
+`
+	if _, err := subSvc.ImportCSV(strings.NewReader(input), ImportOptions{}); err != nil {
+		t.Fatalf("ImportCSV() error = %v", err)
+	}
+
+	// 30 EUR a month plus 15 and 10 EUR from the second import is 55 EUR.
+	input = `name,price,currency,cycle,payment_date
+Music,15,eur,monthly,01-03-2031
+Cloud,10,EUR,monthly,01-03-2031
+`
+	if _, err := subSvc.ImportCSV(strings.NewReader(input), ImportOptions{DryRun: true}); err != nil {
+		t.Fatalf("ImportCSV() dry run error = %v", err)
+	}
+	if _, err := subSvc.DrainOutbox(); err != nil {
+		t.Fatalf("DrainOutbox() error = %v", err)
+	}
+	if len(messages) != 0 {
+		t.Fatalf("messages = %q while under budget", messages)
+	}
+
+	if _, err := subSvc.ImportCSV(strings.NewReader(input), ImportOptions{}); err != nil {
+		t.Fatalf("ImportCSV() error = %v", err)
+	}
+	if _, err := subSvc.DrainOutbox(); err != nil {
+		t.Fatalf("DrainOutbox() error = %v", err)
+	}
+	if len(messages) != 1 || !strings.Contains(messages[0], "Budget exceeded: Cloud") || !strings.Contains(messages[0], `55\.00 EUR of 50\.00 EUR`) {
+		t.Fatalf("messages = %q, want one budget alert for the import", messages)
+	}
+}
+
+func countContaining(messages []string, s string) int {
+	n := 0
+	for _, m := range messages {
+		if strings.Contains(m, s) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSubscriptionService_DrainOutbox_QuietHours(t *testing.T) {
+	subSvc, _, mockTg := setupSubscriptionService(t)
+
+	sent := 0
+	mockTg.SendMessageFunc = func(text string) error {
+		sent++
+		return nil
+	}
+
+	quiet, _ := ParseQuietHours("22:00", "07:00")
+	if err := subSvc.SetQuietHours(quiet); err != nil {
+		t.Fatalf("SetQuietHours() error = %v", err)
+	}
+
+	if _, err := subSvc.enqueue(EventUpcoming, sampleData(EventUpcoming)); err != nil {
+		t.Fatalf("enqueue() error = %v", err)
+	}
+
+	subSvc.now = func() time.Time { return time.Date(2025, time.June, 10, 23, 0, 0, 0, time.UTC) }
+	if n, err := subSvc.DrainOutbox(); err != nil || n != 0 || sent != 0 {
+		t.Fatalf("DrainOutbox() during quiet hours = %d, %v (sent %d), want nothing delivered", n, err, sent)
+	}
+
+	subSvc.now = func() time.Time { return time.Date(2025, time.June, 11, 7, 0, 0, 0, time.UTC) }
+	if n, err := subSvc.DrainOutbox(); err != nil || n != 1 || sent != 1 {
+		t.Fatalf("DrainOutbox() after quiet hours = %d, %v (sent %d), want 1 delivered", n, err, sent)
+	}
+
+	if n, _ := subSvc.DrainOutbox(); n != 0 {
+		t.Errorf("DrainOutbox() delivered %d messages twice", n)
+	}
+}
+
+func TestSubscriptionService_DrainOutbox_Retries(t *testing.T) {
+	subSvc, db, mockTg := setupSubscriptionService(t)
+
+	mockTg.SendMessageFunc = func(text string) error {
+		return errors.New("telegram unavailable")
+	}
+
+	if _, err := subSvc.enqueue(EventUpcoming, sampleData(EventUpcoming)); err != nil {
+		t.Fatalf("enqueue() error = %v", err)
+	}
+
+	for i := 0; i < outboxMaxAttempts; i++ {
+		if _, err := subSvc.DrainOutbox(); err != nil {
+			t.Fatalf("DrainOutbox() error = %v", err)
+		}
+	}
+
+	pending, err := db.GetPendingOutboxMessages(10)
+	if err != nil {
+		t.Fatalf("GetPendingOutboxMessages() error = %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("message still pending after %d failed attempts", outboxMaxAttempts)
+	}
+
+	var failed database.OutboxMessage
+	if err := db.First(&failed).Error; err != nil {
+		t.Fatalf("failed to load outbox message: %v", err)
+	}
+	if failed.Status != database.OutboxFailed || failed.Attempts != outboxMaxAttempts || failed.LastError != "telegram unavailable" {
+		t.Errorf("outbox message = %+v, want failed after %d attempts", failed, outboxMaxAttempts)
+	}
+}
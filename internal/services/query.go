@@ -0,0 +1,148 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+)
+
+// MaxPageSize caps the page size a listing request may ask for.
+const MaxPageSize = 500
+
+// SearchSubscriptions returns one page of the subscriptions matching q.
+func (s *SubscriptionService) SearchSubscriptions(q database.SubscriptionQuery) (*database.SubscriptionPage, error) {
+	if err := ValidateQuery(q); err != nil {
+		return nil, err
+	}
+	return s.db.FindSubscriptions(q)
+}
+
+// ValidateQuery checks the values of a listing query that the database would
+// otherwise silently match nothing for.
+func ValidateQuery(q database.SubscriptionQuery) error {
+	for _, cycle := range q.Cycles {
+		if cycle != "monthly" && cycle != "yearly" {
+			return fmt.Errorf("cycle must be 'monthly' or 'yearly'")
+		}
+	}
+	for _, status := range q.Statuses {
+		if !slices.Contains(database.Statuses, status) {
+			return fmt.Errorf("status must be one of %s", strings.Join(database.Statuses, ", "))
+		}
+	}
+	if q.Sort != "" && !slices.Contains(database.SortKeys, q.Sort) {
+		return fmt.Errorf("unknown sort key %q (use next_payment, price or name)", q.Sort)
+	}
+	if q.MinPrice != nil && q.MaxPrice != nil && *q.MinPrice > *q.MaxPrice {
+		return fmt.Errorf("minimum price is greater than maximum price")
+	}
+	if q.Limit < 0 || q.Limit > MaxPageSize {
+		return fmt.Errorf("limit must be between 0 and %d", MaxPageSize)
+	}
+	return nil
+}
+
+// ParseListQuery reads a listing query from URL query parameters: q, currency,
+// cycle, status, category, min_price, max_price, sort, order (asc or desc),
+// limit and cursor. List parameters may repeat or hold comma-separated values.
+func ParseListQuery(values url.Values) (database.SubscriptionQuery, error) {
+	q := database.SubscriptionQuery{
+		Search:     strings.TrimSpace(values.Get("q")),
+		Currencies: listParam(values, "currency"),
+		Cycles:     listParam(values, "cycle"),
+		Statuses:   listParam(values, "status"),
+		Categories: listParam(values, "category"),
+		Sort:       database.SortKey(strings.TrimSpace(values.Get("sort"))),
+		Cursor:     values.Get("cursor"),
+	}
+
+	var err error
+	if q.MinPrice, err = priceParam(values, "min_price"); err != nil {
+		return q, err
+	}
+	if q.MaxPrice, err = priceParam(values, "max_price"); err != nil {
+		return q, err
+	}
+
+	switch strings.ToLower(values.Get("order")) {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return q, fmt.Errorf("order must be 'asc' or 'desc'")
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil {
+			return q, fmt.Errorf("invalid limit: %w", err)
+		}
+	}
+
+	return q, ValidateQuery(q)
+}
+
+// EncodeListQuery is the inverse of ParseListQuery. It leaves out the cursor
+// so callers can add the one for the page they link to.
+func EncodeListQuery(q database.SubscriptionQuery) url.Values {
+	values := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	set("q", q.Search)
+	set("currency", strings.Join(q.Currencies, ","))
+	set("cycle", strings.Join(q.Cycles, ","))
+	set("status", strings.Join(q.Statuses, ","))
+	set("category", strings.Join(q.Categories, ","))
+	if q.MinPrice != nil {
+		set("min_price", strconv.FormatFloat(*q.MinPrice, 'f', -1, 64))
+	}
+	if q.MaxPrice != nil {
+		set("max_price", strconv.FormatFloat(*q.MaxPrice, 'f', -1, 64))
+	}
+	set("sort", string(q.Sort))
+	if q.Desc {
+		set("order", "desc")
+	}
+	if q.Limit > 0 {
+		set("limit", strconv.Itoa(q.Limit))
+	}
+	return values
+}
+
+// SplitList splits a comma-separated flag or parameter value, dropping empty
+// items.
+func SplitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func listParam(values url.Values, key string) []string {
+	var items []string
+	for _, v := range values[key] {
+		items = append(items, SplitList(v)...)
+	}
+	return items
+}
+
+func priceParam(values url.Values, key string) (*float64, error) {
+	v := strings.TrimSpace(values.Get(key))
+	if v == "" {
+		return nil, nil
+	}
+	price, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", strings.ReplaceAll(key, "_", " "), err)
+	}
+	return &price, nil
+}
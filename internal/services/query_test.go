@@ -0,0 +1,92 @@
+package services
+
+import (
+	"bytes"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+)
+
+func TestParseListQuery(t *testing.T) {
+	values, _ := url.ParseQuery("q=net&currency=USD,eur&currency=TRY&status=active&category=Streaming&min_price=5&max_price=20.5&sort=price&order=desc&limit=10&cursor=abc")
+	q, err := ParseListQuery(values)
+	if err != nil {
+		t.Fatalf("ParseListQuery() error = %v", err)
+	}
+
+	if q.Search != "net" || q.Sort != database.SortPrice || !q.Desc || q.Limit != 10 || q.Cursor != "abc" {
+		t.Errorf("ParseListQuery() = %+v", q)
+	}
+	if !reflect.DeepEqual(q.Currencies, []string{"USD", "eur", "TRY"}) {
+		t.Errorf("Currencies = %v", q.Currencies)
+	}
+	if q.MinPrice == nil || *q.MinPrice != 5 || q.MaxPrice == nil || *q.MaxPrice != 20.5 {
+		t.Errorf("price range = %v, %v", q.MinPrice, q.MaxPrice)
+	}
+
+	back, err := ParseListQuery(EncodeListQuery(q))
+	q.Cursor = ""
+	if err != nil || !reflect.DeepEqual(back, q) {
+		t.Errorf("EncodeListQuery() round trip = %+v, %v, want %+v", back, err, q)
+	}
+
+	invalid := []string{
+		"cycle=weekly",
+		"status=gone",
+		"sort=color",
+		"order=up",
+		"min_price=cheap",
+		"min_price=10&max_price=5",
+		"limit=-1",
+		"limit=100000",
+	}
+	for _, raw := range invalid {
+		values, _ := url.ParseQuery(raw)
+		if _, err := ParseListQuery(values); err == nil {
+			t.Errorf("ParseListQuery(%q) expected an error", raw)
+		}
+	}
+}
+
+func TestSubscriptionService_StatusAndCategory(t *testing.T) {
+	store := database.NewMemoryStore()
+	subSvc := NewSubscriptionService(store, &MockTelegramService{})
+
+	if err := subSvc.AddSubscription("Netflix", "15.99", "USD", "monthly", "15-02-2025", " Streaming "); err != nil {
+		t.Fatalf("AddSubscription() error = %v", err)
+	}
+	sub, _ := store.GetSubscriptionByName("Netflix")
+	if sub.Category != "Streaming" || sub.Status != database.StatusActive {
+		t.Fatalf("new subscription category = %q, status = %q", sub.Category, sub.Status)
+	}
+
+	if err := subSvc.SetSubscriptionStatus(sub.ID, "Paused"); err != nil {
+		t.Fatalf("SetSubscriptionStatus() error = %v", err)
+	}
+	if err := subSvc.SetSubscriptionStatus(sub.ID, "gone"); err == nil {
+		t.Error("SetSubscriptionStatus() accepted an unknown status")
+	}
+	if err := subSvc.SetSubscriptionCategory(sub.ID, ""); err != nil {
+		t.Fatalf("SetSubscriptionCategory() error = %v", err)
+	}
+	sub, _ = store.GetSubscriptionByID(sub.ID)
+	if sub.Category != "" || sub.Status != database.StatusPaused {
+		t.Errorf("after updates category = %q, status = %q", sub.Category, sub.Status)
+	}
+
+	page, err := subSvc.SearchSubscriptions(database.SubscriptionQuery{Statuses: []string{database.StatusActive}})
+	if err != nil || len(page.Subscriptions) != 0 {
+		t.Errorf("SearchSubscriptions(active) = %+v, %v, want none", page, err)
+	}
+
+	var buf bytes.Buffer
+	if err := subSvc.ExportICS(&buf); err != nil {
+		t.Fatalf("ExportICS() error = %v", err)
+	}
+	if strings.Contains(buf.String(), "BEGIN:VEVENT") {
+		t.Error("ExportICS() included a paused subscription")
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/utils"
+)
+
+// reportMonths is how many calendar months, starting with the current one,
+// the spend projection covers.
+const reportMonths = 12
+
+// ReportService computes the dashboard's summary figures. Amounts are never
+// converted between currencies, so every figure is given per currency.
+type ReportService struct {
+	db  database.Store
+	now func() time.Time
+}
+
+func NewReportService(db database.Store) *ReportService {
+	return &ReportService{db: db, now: time.Now}
+}
+
+// Report summarizes all subscriptions. Totals, the projection and the
+// category breakdown count active subscriptions only.
+type Report struct {
+	// From is the first day of the current month, where Months start.
+	From utils.Date
+	// Currencies has an entry per currency with an active subscription,
+	// sorted by currency.
+	Currencies []CurrencyReport
+	// NextPayment is the active subscription due soonest, today or later,
+	// or nil if there is none.
+	NextPayment *database.Subscription
+	// Statuses counts subscriptions by status, in database.Statuses order.
+	Statuses []StatusCount
+	Count    int
+}
+
+type CurrencyReport struct {
+	Currency string
+	// Monthly and Yearly are the recurring cost, with yearly subscriptions
+	// spread evenly over the months.
+	Monthly float64
+	Yearly  float64
+	// Months are the payments due in each of the next reportMonths
+	// calendar months, starting with the current one.
+	Months []MonthSpend
+	// Categories split Monthly by category, largest first.
+	Categories []CategorySpend
+}
+
+type MonthSpend struct {
+	Month  utils.Date
+	Amount float64
+}
+
+type CategorySpend struct {
+	// Category is empty for uncategorized subscriptions.
+	Category string
+	Monthly  float64
+}
+
+type StatusCount struct {
+	Status string
+	Count  int
+}
+
+// Report builds the summary from every stored subscription.
+func (s *ReportService) Report() (*Report, error) {
+	subs, err := s.db.GetAllSubscriptions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load subscriptions: %w", err)
+	}
+	return NewReport(utils.DateOf(s.now()), subs)
+}
+
+// NewReport summarizes subs as of today.
+func NewReport(today utils.Date, subs []database.Subscription) (*Report, error) {
+	from := utils.Date{Year: today.Year, Month: today.Month, Day: 1}
+	to := from.AddDate(0, reportMonths, 0)
+	r := &Report{From: from, Count: len(subs)}
+
+	statuses := make(map[string]int)
+	currencies := make(map[string]*CurrencyReport)
+	categories := make(map[string]map[string]float64)
+	for i := range subs {
+		sub := &subs[i]
+		statuses[sub.Status]++
+		if sub.Status != database.StatusActive {
+			continue
+		}
+
+		cr := currencies[sub.Currency]
+		if cr == nil {
+			cr = &CurrencyReport{Currency: sub.Currency, Months: make([]MonthSpend, reportMonths)}
+			for m := range cr.Months {
+				cr.Months[m].Month = from.AddDate(0, m, 0)
+			}
+			currencies[sub.Currency] = cr
+			categories[sub.Currency] = make(map[string]float64)
+		}
+
+		monthly := monthlyCost(sub)
+		cr.Monthly += monthly
+		cr.Yearly += monthly * 12
+		categories[sub.Currency][sub.Category] += monthly
+
+		err := forEachPayment(sub, to, func(date utils.Date) {
+			if !date.Before(from) {
+				m := (date.Year-from.Year)*12 + int(date.Month-from.Month)
+				cr.Months[m].Amount += sub.Price
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if due := utils.DateOf(sub.PaymentDate); !due.Before(today) {
+			if r.NextPayment == nil || due.Before(utils.DateOf(r.NextPayment.PaymentDate)) {
+				r.NextPayment = sub
+			}
+		}
+	}
+
+	for _, status := range database.Statuses {
+		r.Statuses = append(r.Statuses, StatusCount{Status: status, Count: statuses[status]})
+	}
+
+	for currency, cr := range currencies {
+		for category, monthly := range categories[currency] {
+			cr.Categories = append(cr.Categories, CategorySpend{Category: category, Monthly: monthly})
+		}
+		sort.Slice(cr.Categories, func(i, j int) bool {
+			a, b := cr.Categories[i], cr.Categories[j]
+			if a.Monthly != b.Monthly {
+				return a.Monthly > b.Monthly
+			}
+			return a.Category < b.Category
+		})
+		r.Currencies = append(r.Currencies, *cr)
+	}
+	sort.Slice(r.Currencies, func(i, j int) bool { return r.Currencies[i].Currency < r.Currencies[j].Currency })
+	return r, nil
+}
+
+// forEachPayment calls fn with each of sub's payment dates before to,
+// starting at its stored payment date. Dates advance the way the scheduler
+// moves them on, and the way the calendar feed repeats them, so projections
+// match the dates that will be stored.
+func forEachPayment(sub *database.Subscription, to utils.Date, fn func(utils.Date)) error {
+	date := utils.DateOf(sub.PaymentDate)
+	for n := 1; date.Before(to); n++ {
+		fn(date)
+		next, err := utils.AdvancePaymentDate(sub.PaymentDate, sub.AnchorDay(), sub.Cycle, n)
+		if err != nil {
+			return fmt.Errorf("subscription %d: %w", sub.ID, err)
+		}
+		date = utils.DateOf(next)
+	}
+	return nil
+}
+
+// monthlyCost is what sub costs per month on average.
+func monthlyCost(sub *database.Subscription) float64 {
+	if sub.Cycle == "yearly" {
+		return sub.Price / 12
+	}
+	return sub.Price
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/utils"
+)
+
+func TestNewReport(t *testing.T) {
+	today := utils.Date{Year: 2025, Month: time.March, Day: 20}
+	date := func(month time.Month, day int) time.Time {
+		return utils.Date{Year: 2025, Month: month, Day: day}.Time()
+	}
+	subs := []database.Subscription{
+		{ID: 1, Name: "Netflix", Price: 15, Currency: "USD", Cycle: "monthly", PaymentDate: date(time.March, 25), Category: "Streaming", Status: database.StatusActive},
+		{ID: 2, Name: "Spotify", Price: 10, Currency: "USD", Cycle: "monthly", PaymentDate: date(time.April, 5), Category: "Streaming", Status: database.StatusActive},
+		{ID: 3, Name: "Domain", Price: 24, Currency: "USD", Cycle: "yearly", PaymentDate: date(time.June, 1), Status: database.StatusActive},
+		{ID: 4, Name: "Gym", Price: 30, Currency: "EUR", Cycle: "monthly", PaymentDate: date(time.March, 22), Category: "Health", Status: database.StatusActive},
+		{ID: 5, Name: "Paused", Price: 99, Currency: "USD", Cycle: "monthly", PaymentDate: date(time.March, 21), Status: database.StatusPaused},
+		{ID: 6, Name: "Old", Price: 5, Currency: "GBP", Cycle: "monthly", PaymentDate: date(time.January, 1), Status: database.StatusCancelled},
+	}
+
+	r, err := NewReport(today, subs)
+	if err != nil {
+		t.Fatalf("NewReport() error = %v", err)
+	}
+
+	if r.From != (utils.Date{Year: 2025, Month: time.March, Day: 1}) || r.Count != 6 {
+		t.Errorf("From = %s, Count = %d", r.From, r.Count)
+	}
+	if r.NextPayment == nil || r.NextPayment.Name != "Gym" {
+		t.Errorf("NextPayment = %+v, want Gym", r.NextPayment)
+	}
+	wantStatuses := []StatusCount{{"active", 4}, {"paused", 1}, {"cancelled", 1}}
+	if !reflect.DeepEqual(r.Statuses, wantStatuses) {
+		t.Errorf("Statuses = %v, want %v", r.Statuses, wantStatuses)
+	}
+
+	if len(r.Currencies) != 2 || r.Currencies[0].Currency != "EUR" || r.Currencies[1].Currency != "USD" {
+		t.Fatalf("Currencies = %+v, want EUR and USD", r.Currencies)
+	}
+	usd := r.Currencies[1]
+	if !approx(usd.Monthly, 27) || !approx(usd.Yearly, 324) {
+		t.Errorf("USD Monthly = %v, Yearly = %v, want 27 and 324", usd.Monthly, usd.Yearly)
+	}
+
+	// Spotify's first payment is in April; Domain is paid in June only.
+	wantMonths := []float64{15, 25, 25, 49, 25, 25, 25, 25, 25, 25, 25, 25}
+	if len(usd.Months) != len(wantMonths) {
+		t.Fatalf("got %d months, want %d", len(usd.Months), len(wantMonths))
+	}
+	for i, m := range usd.Months {
+		if wantMonth := r.From.AddDate(0, i, 0); m.Month != wantMonth || !approx(m.Amount, wantMonths[i]) {
+			t.Errorf("Months[%d] = %s %v, want %s %v", i, m.Month, m.Amount, wantMonth, wantMonths[i])
+		}
+	}
+
+	if len(usd.Categories) != 2 || usd.Categories[0].Category != "Streaming" || !approx(usd.Categories[0].Monthly, 25) ||
+		usd.Categories[1].Category != "" || !approx(usd.Categories[1].Monthly, 2) {
+		t.Errorf("USD Categories = %+v", usd.Categories)
+	}
+}
+
+func TestNewReport_Empty(t *testing.T) {
+	r, err := NewReport(utils.Today(), nil)
+	if err != nil {
+		t.Fatalf("NewReport() error = %v", err)
+	}
+	if r.NextPayment != nil || len(r.Currencies) != 0 || len(r.Statuses) != len(database.Statuses) {
+		t.Errorf("empty report = %+v", r)
+	}
+}
+
+func TestReportService_Report(t *testing.T) {
+	store := database.NewMemoryStore()
+	today := utils.Today()
+	sub := database.Subscription{Name: "Netflix", Price: 15, Currency: "USD", Cycle: "monthly", PaymentDate: today.AddDays(3).Time()}
+	if err := store.CreateSubscription(&sub); err != nil {
+		t.Fatalf("failed to create test subscription: %v", err)
+	}
+
+	r, err := NewReportService(store).Report()
+	if err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+	if r.NextPayment == nil || r.NextPayment.Name != "Netflix" || len(r.Currencies) != 1 || r.Currencies[0].Monthly != 15 {
+		t.Errorf("Report() = %+v", r)
+	}
+
+	faulty := database.NewFaultyStore(store)
+	faulty.FailOn("GetAllSubscriptions", errors.New("disk on fire"))
+	if _, err := NewReportService(faulty).Report(); err == nil {
+		t.Error("Report() with a failing store succeeded")
+	}
+}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
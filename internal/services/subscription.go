@@ -3,52 +3,82 @@ package services
 import (
 	"fmt"
 	"log"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/berkaycubuk/subtrack/internal/database"
 	"github.com/berkaycubuk/subtrack/internal/utils"
 )
 
+// reminderDays is the length of the upcoming payment alert window, including
+// the payment day itself.
+const reminderDays = 5
+
 type TelegramNotifier interface {
-	SendNotification(name string, price float64, currency string, days int, cycle string, paymentDate string) error
+	SendMessage(text string) error
 }
 
 type SubscriptionService struct {
-	db *database.DB
-	tg TelegramNotifier
+	db  database.Store
+	tg  TelegramNotifier
+	now func() time.Time
 }
 
-func NewSubscriptionService(db *database.DB, tg TelegramNotifier) *SubscriptionService {
+// NewSubscriptionService returns a service backed by db, which is normally a
+// *database.DB.
+func NewSubscriptionService(db database.Store, tg TelegramNotifier) *SubscriptionService {
 	return &SubscriptionService{
-		db: db,
-		tg: tg,
+		db:  db,
+		tg:  tg,
+		now: time.Now,
+	}
+}
+
+func (s *SubscriptionService) AddSubscription(name, priceStr, currency, cycle, paymentDateStr, category string) error {
+	sub, err := newSubscription(name, priceStr, currency, cycle, paymentDateStr)
+	if err != nil {
+		return err
 	}
+	sub.Category = strings.TrimSpace(category)
+
+	checkBudget := s.watchBudget()
+	if err := s.db.CreateSubscription(sub); err != nil {
+		return err
+	}
+	checkBudget(sub)
+	return nil
 }
 
-func (s *SubscriptionService) AddSubscription(name, priceStr, currency, cycle, paymentDateStr string) error {
+// newSubscription validates raw field values the way they arrive from the
+// CLI, web forms and imports.
+func newSubscription(name, priceStr, currency, cycle, paymentDateStr string) (*database.Subscription, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+
 	price, err := strconv.ParseFloat(priceStr, 64)
 	if err != nil {
-		return fmt.Errorf("invalid price format: %w", err)
+		return nil, fmt.Errorf("invalid price format: %w", err)
 	}
 
 	paymentDate, err := utils.ParseDate(paymentDateStr)
 	if err != nil {
-		return fmt.Errorf("invalid payment date format (use DD-MM-YYYY): %w", err)
+		return nil, fmt.Errorf("invalid payment date format (use DD-MM-YYYY): %w", err)
 	}
 
 	if cycle != "monthly" && cycle != "yearly" {
-		return fmt.Errorf("cycle must be 'monthly' or 'yearly'")
+		return nil, fmt.Errorf("cycle must be 'monthly' or 'yearly'")
 	}
 
-	sub := &database.Subscription{
+	return &database.Subscription{
 		Name:        name,
 		Price:       price,
 		Currency:    currency,
 		Cycle:       cycle,
 		PaymentDate: paymentDate,
-	}
-
-	return s.db.CreateSubscription(sub)
+	}, nil
 }
 
 func (s *SubscriptionService) UpdateSubscription(id uint, name, priceStr, currency, cycle, paymentDateStr string) error {
@@ -57,6 +87,8 @@ func (s *SubscriptionService) UpdateSubscription(id uint, name, priceStr, curren
 		return fmt.Errorf("subscription not found: %w", err)
 	}
 
+	oldPrice := sub.Price
+
 	if name != "" {
 		sub.Name = name
 	}
@@ -86,8 +118,75 @@ func (s *SubscriptionService) UpdateSubscription(id uint, name, priceStr, curren
 			return fmt.Errorf("invalid payment date format (use DD-MM-YYYY): %w", err)
 		}
 		sub.PaymentDate = paymentDate
+		sub.PaymentDay = utils.DateOf(paymentDate).Day
+	}
+
+	checkBudget := s.watchBudget()
+	if err := s.db.UpdateSubscription(sub); err != nil {
+		return err
+	}
+	checkBudget(sub)
+
+	if sub.Price != oldPrice {
+		if _, err := s.enqueue(EventPriceChange, MessageData{Subscription: *sub, Days: utils.DaysUntil(sub.PaymentDate), OldPrice: oldPrice}); err != nil {
+			log.Printf("Failed to queue price change notification for %s: %v", sub.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// SetSubscriptionStatus marks a subscription active, paused or cancelled.
+// Only active subscriptions are reminded about and appear in the calendar
+// feed.
+func (s *SubscriptionService) SetSubscriptionStatus(id uint, status string) error {
+	status = strings.ToLower(strings.TrimSpace(status))
+	if !slices.Contains(database.Statuses, status) {
+		return fmt.Errorf("status must be one of %s", strings.Join(database.Statuses, ", "))
+	}
+
+	sub, err := s.db.GetSubscriptionByID(id)
+	if err != nil {
+		return fmt.Errorf("subscription not found: %w", err)
+	}
+	sub.Status = status
+
+	checkBudget := s.watchBudget()
+	if err := s.db.UpdateSubscription(sub); err != nil {
+		return err
+	}
+	checkBudget(sub)
+	return nil
+}
+
+// SetSubscriptionCategory sets a subscription's category; an empty category
+// clears it.
+func (s *SubscriptionService) SetSubscriptionCategory(id uint, category string) error {
+	sub, err := s.db.GetSubscriptionByID(id)
+	if err != nil {
+		return fmt.Errorf("subscription not found: %w", err)
+	}
+	sub.Category = strings.TrimSpace(category)
+	return s.db.UpdateSubscription(sub)
+}
+
+// SetTrialEndDate records the day a subscription's free trial ends, which
+// trial_ending notifications are sent ahead of. An empty date removes the
+// trial.
+func (s *SubscriptionService) SetTrialEndDate(id uint, dateStr string) error {
+	sub, err := s.db.GetSubscriptionByID(id)
+	if err != nil {
+		return fmt.Errorf("subscription not found: %w", err)
 	}
 
+	sub.TrialEndDate = nil
+	if dateStr = strings.TrimSpace(dateStr); dateStr != "" {
+		end, err := utils.ParseDate(dateStr)
+		if err != nil {
+			return fmt.Errorf("invalid trial end date format (use DD-MM-YYYY): %w", err)
+		}
+		sub.TrialEndDate = &end
+	}
 	return s.db.UpdateSubscription(sub)
 }
 
@@ -95,6 +194,26 @@ func (s *SubscriptionService) GetSubscription(id uint) (*database.Subscription,
 	return s.db.GetSubscriptionByID(id)
 }
 
+// MarkPaid records that a subscription's current payment was made by moving
+// its payment date on by one cycle.
+func (s *SubscriptionService) MarkPaid(id uint) (*database.Subscription, error) {
+	sub, err := s.db.GetSubscriptionByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("subscription not found: %w", err)
+	}
+
+	next, err := utils.UpdatePaymentDate(sub.PaymentDate, sub.AnchorDay(), sub.Cycle)
+	if err != nil {
+		return nil, err
+	}
+	sub.PaymentDate = next
+
+	if err := s.db.UpdateSubscription(sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
 func (s *SubscriptionService) DeleteSubscription(id uint) error {
 	return s.db.DeleteSubscription(id)
 }
@@ -104,7 +223,12 @@ func (s *SubscriptionService) ListSubscriptions() ([]database.Subscription, erro
 }
 
 func (s *SubscriptionService) CheckUpcomingPayments() ([]database.Subscription, error) {
-	subs, err := s.db.GetUpcomingPayments(5)
+	return s.UpcomingPayments(reminderDays)
+}
+
+// UpcomingPayments returns subscriptions due today or within the next days-1 days.
+func (s *SubscriptionService) UpcomingPayments(days int) ([]database.Subscription, error) {
+	subs, err := s.db.GetUpcomingPayments(days)
 	if err != nil {
 		return nil, err
 	}
@@ -112,22 +236,44 @@ func (s *SubscriptionService) CheckUpcomingPayments() ([]database.Subscription,
 	return subs, nil
 }
 
+// SendNotifications queues an upcoming payment alert for each subscription
+// due within reminderDays days. Messages are delivered by DrainOutbox.
 func (s *SubscriptionService) SendNotifications(subs []database.Subscription) error {
 	for _, sub := range subs {
 		days := utils.DaysUntil(sub.PaymentDate)
-		if days >= 0 && days < 5 {
-			paymentDateStr := utils.FormatDate(sub.PaymentDate)
-			err := s.tg.SendNotification(sub.Name, sub.Price, sub.Currency, days, sub.Cycle, paymentDateStr)
+		if days >= 0 && days < reminderDays {
+			n, err := s.enqueue(EventUpcoming, MessageData{Subscription: sub, Days: days, OldPrice: sub.Price})
 			if err != nil {
-				log.Printf("Failed to send notification for %s: %v", sub.Name, err)
-			} else {
-				log.Printf("Sent notification for %s (payment in %d days)", sub.Name, days)
+				log.Printf("Failed to queue notification for %s: %v", sub.Name, err)
+			} else if n > 0 {
+				log.Printf("Queued notification for %s (payment in %d days)", sub.Name, days)
 			}
 		}
 	}
 	return nil
 }
 
+// SendTrialNotifications queues a trial_ending alert for each active
+// subscription whose trial ends within reminderDays days and returns those
+// subscriptions. Messages are delivered by DrainOutbox.
+func (s *SubscriptionService) SendTrialNotifications() ([]database.Subscription, error) {
+	subs, err := s.db.GetEndingTrials(reminderDays)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sub := range subs {
+		days := utils.DaysUntil(*sub.TrialEndDate)
+		n, err := s.enqueue(EventTrialEnding, MessageData{Subscription: sub, Days: days, OldPrice: sub.Price})
+		if err != nil {
+			log.Printf("Failed to queue trial notification for %s: %v", sub.Name, err)
+		} else if n > 0 {
+			log.Printf("Queued trial notification for %s (trial ends in %d days)", sub.Name, days)
+		}
+	}
+	return subs, nil
+}
+
 func (s *SubscriptionService) UpdatePastDuePayments() error {
 	subs, err := s.db.GetPastDuePayments()
 	if err != nil {
@@ -135,7 +281,7 @@ func (s *SubscriptionService) UpdatePastDuePayments() error {
 	}
 
 	for _, sub := range subs {
-		newPaymentDate, err := utils.CalculateNextPaymentDate(sub.PaymentDate, sub.Cycle)
+		newPaymentDate, err := utils.CalculateNextPaymentDate(sub.PaymentDate, sub.AnchorDay(), sub.Cycle)
 		if err != nil {
 			log.Printf("Failed to calculate next payment date for %s: %v", sub.Name, err)
 			continue
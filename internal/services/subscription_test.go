@@ -1,10 +1,13 @@
 package services
 
 import (
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/utils"
 )
 
 func setupSubscriptionService(t *testing.T) (*SubscriptionService, *database.DB, *MockTelegramService) {
@@ -66,7 +69,7 @@ func TestSubscriptionService_AddSubscription(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := subSvc.AddSubscription(tt.name, tt.price, tt.currency, tt.cycle, tt.paymentDate)
+			err := subSvc.AddSubscription(tt.name, tt.price, tt.currency, tt.cycle, tt.paymentDate, "")
 			if (err != nil) != tt.wantErr {
 				t.Errorf("AddSubscription() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -171,6 +174,70 @@ func TestSubscriptionService_DeleteSubscription(t *testing.T) {
 	}
 }
 
+func TestSubscriptionService_MarkPaid(t *testing.T) {
+	subSvc, db, _ := setupSubscriptionService(t)
+
+	for _, tt := range []struct {
+		cycle string
+		due   string
+		want  string
+	}{
+		{"monthly", "15-01-2031", "15-02-2031"},
+		{"yearly", "15-02-2031", "15-02-2032"},
+	} {
+		due, _ := utils.ParseDate(tt.due)
+		sub := &database.Subscription{Name: "Netflix " + tt.cycle, Price: 15.99, Currency: "USD", Cycle: tt.cycle, PaymentDate: due}
+		if err := db.CreateSubscription(sub); err != nil {
+			t.Fatalf("failed to create test subscription: %v", err)
+		}
+
+		paid, err := subSvc.MarkPaid(sub.ID)
+		if err != nil {
+			t.Fatalf("MarkPaid(%s) error = %v", tt.cycle, err)
+		}
+		stored, _ := db.GetSubscriptionByID(sub.ID)
+		if got := utils.FormatDate(paid.PaymentDate); got != tt.want || utils.FormatDate(stored.PaymentDate) != tt.want {
+			t.Errorf("MarkPaid(%s) payment date = %s, stored %s, want %s", tt.cycle, got, utils.FormatDate(stored.PaymentDate), tt.want)
+		}
+	}
+
+	if _, err := subSvc.MarkPaid(9999); err == nil {
+		t.Error("MarkPaid(missing) expected an error")
+	}
+}
+
+func TestSubscriptionService_MarkPaid_EndOfMonth(t *testing.T) {
+	subSvc, db, _ := setupSubscriptionService(t)
+
+	if err := subSvc.AddSubscription("Gym", "30", "EUR", "monthly", "31-01-2025", ""); err != nil {
+		t.Fatalf("AddSubscription() error = %v", err)
+	}
+	sub, _ := db.GetSubscriptionByName("Gym")
+	for _, want := range []string{"28-02-2025", "31-03-2025", "30-04-2025", "31-05-2025"} {
+		paid, err := subSvc.MarkPaid(sub.ID)
+		if err != nil {
+			t.Fatalf("MarkPaid() error = %v", err)
+		}
+		if got := utils.FormatDate(paid.PaymentDate); got != want {
+			t.Errorf("MarkPaid() payment date = %s, want %s", got, want)
+		}
+	}
+
+	// A new payment date moves the payment to that day of the month.
+	if err := subSvc.UpdateSubscription(sub.ID, "", "", "", "", "30-06-2025"); err != nil {
+		t.Fatalf("UpdateSubscription() error = %v", err)
+	}
+	for _, want := range []string{"30-07-2025", "30-08-2025"} {
+		paid, err := subSvc.MarkPaid(sub.ID)
+		if err != nil {
+			t.Fatalf("MarkPaid() error = %v", err)
+		}
+		if got := utils.FormatDate(paid.PaymentDate); got != want {
+			t.Errorf("MarkPaid() after update payment date = %s, want %s", got, want)
+		}
+	}
+}
+
 func TestSubscriptionService_ListSubscriptions(t *testing.T) {
 	subSvc, db, _ := setupSubscriptionService(t)
 
@@ -282,7 +349,7 @@ func TestSubscriptionService_SendNotifications(t *testing.T) {
 	}
 
 	notificationsSent := 0
-	mockTg.SendNotificationFunc = func(name string, price float64, currency string, days int, cycle string, paymentDate string) error {
+	mockTg.SendMessageFunc = func(text string) error {
 		notificationsSent++
 		return nil
 	}
@@ -292,6 +359,14 @@ func TestSubscriptionService_SendNotifications(t *testing.T) {
 		t.Errorf("SendNotifications() error = %v", err)
 	}
 
+	if notificationsSent != 0 {
+		t.Errorf("SendNotifications() sent %d notifications before draining, want 0", notificationsSent)
+	}
+
+	if _, err := subSvc.DrainOutbox(); err != nil {
+		t.Errorf("DrainOutbox() error = %v", err)
+	}
+
 	if notificationsSent != 2 {
 		t.Errorf("SendNotifications() sent %d notifications, want 2", notificationsSent)
 	}
@@ -341,3 +416,86 @@ func TestSubscriptionService_UpdatePastDuePayments(t *testing.T) {
 		}
 	}
 }
+
+func setupFaultyService(t *testing.T) (*SubscriptionService, *database.FaultyStore, *database.MemoryStore) {
+	mem := database.NewMemoryStore()
+	store := database.NewFaultyStore(mem)
+	return NewSubscriptionService(store, &MockTelegramService{}), store, mem
+}
+
+func TestSubscriptionService_UpdatePastDuePayments_UpdateFails(t *testing.T) {
+	subSvc, store, _ := setupFaultyService(t)
+
+	now := time.Now()
+	for _, name := range []string{"Broken", "Fine"} {
+		sub := &database.Subscription{Name: name, Price: 10, Currency: "USD", Cycle: "monthly", PaymentDate: now.AddDate(0, 0, -3)}
+		if err := store.CreateSubscription(sub); err != nil {
+			t.Fatalf("failed to create test subscription: %v", err)
+		}
+	}
+
+	store.FailWhen("UpdateSubscription", errors.New("database is locked"), func(_ int, args []any) bool {
+		return args[0].(*database.Subscription).Name == "Broken"
+	})
+
+	if err := subSvc.UpdatePastDuePayments(); err != nil {
+		t.Errorf("UpdatePastDuePayments() error = %v", err)
+	}
+	if n := store.Calls("UpdateSubscription"); n != 2 {
+		t.Errorf("UpdateSubscription called %d times, want 2", n)
+	}
+
+	pastDue, err := store.GetPastDuePayments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pastDue) != 1 || pastDue[0].Name != "Broken" {
+		t.Errorf("past due after update = %+v, want only Broken", pastDue)
+	}
+}
+
+func TestSubscriptionService_UpdatePastDuePayments_QueryFails(t *testing.T) {
+	subSvc, store, _ := setupFaultyService(t)
+
+	failure := errors.New("connection refused")
+	store.FailOn("GetPastDuePayments", failure)
+
+	if err := subSvc.UpdatePastDuePayments(); !errors.Is(err, failure) {
+		t.Errorf("UpdatePastDuePayments() error = %v, want %v", err, failure)
+	}
+}
+
+func TestSubscriptionService_UpdateSubscription_SaveFails(t *testing.T) {
+	subSvc, store, mem := setupFaultyService(t)
+
+	if err := subSvc.AddSubscription("Netflix", "15.99", "USD", "monthly", "15-02-2025", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	failure := errors.New("disk full")
+	store.FailOn("UpdateSubscription", failure)
+
+	if err := subSvc.UpdateSubscription(1, "", "19.99", "", "", ""); !errors.Is(err, failure) {
+		t.Errorf("UpdateSubscription() error = %v, want %v", err, failure)
+	}
+	if msgs := mem.OutboxMessages(); len(msgs) != 0 {
+		t.Errorf("price change queued although the update failed: %+v", msgs)
+	}
+}
+
+func TestSubscriptionService_Restore_RollsBack(t *testing.T) {
+	subSvc, store, _ := setupFaultyService(t)
+
+	backup := `{"format":"subtrack-backup","version":1,"subscriptions":[
+		{"name":"Netflix","price":15.99,"currency":"USD","cycle":"monthly","payment_date":"2025-02-15T00:00:00Z"},
+		{"name":"Spotify","price":9.99,"currency":"USD","cycle":"monthly","payment_date":"2025-02-20T00:00:00Z"}]}`
+
+	store.FailOnCall("CreateSubscription", 2, errors.New("disk full"))
+
+	if _, err := subSvc.Restore(strings.NewReader(backup), ConflictSkip); err == nil {
+		t.Fatal("Restore() succeeded although a write failed")
+	}
+	if subs, _ := store.GetAllSubscriptions(); len(subs) != 0 {
+		t.Errorf("Restore() left %d subscriptions behind", len(subs))
+	}
+}
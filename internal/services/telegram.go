@@ -10,9 +10,10 @@ import (
 type TelegramService struct {
 	bot    *tgbotapi.BotAPI
 	chatID int64
+	format MessageFormat
 }
 
-func NewTelegramService(botToken string, chatID int64) (*TelegramService, error) {
+func NewTelegramService(botToken string, chatID int64, format MessageFormat) (*TelegramService, error) {
 	bot, err := tgbotapi.NewBotAPI(botToken)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create bot: %w", err)
@@ -24,27 +25,23 @@ func NewTelegramService(botToken string, chatID int64) (*TelegramService, error)
 	return &TelegramService{
 		bot:    bot,
 		chatID: chatID,
+		format: format,
 	}, nil
 }
 
-func (t *TelegramService) SendNotification(name string, price float64, currency string, days int, cycle string, paymentDate string) error {
-	message := fmt.Sprintf("📢 Subscription Alert: %s\n💰 Price: %.2f %s\n📅 Payment in: %d days\n🔄 Cycle: %s\n📆 Next payment: %s",
-		name, price, currency, days, cycle, paymentDate)
-
-	msg := tgbotapi.NewMessage(t.chatID, message)
-	msg.ParseMode = "Markdown"
-
-	_, err := t.bot.Send(msg)
-	if err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
-	}
-
-	return nil
+// MessageFormat reports the markup messages are sent with.
+func (t *TelegramService) MessageFormat() MessageFormat {
+	return t.format
 }
 
 func (t *TelegramService) SendMessage(text string) error {
 	msg := tgbotapi.NewMessage(t.chatID, text)
-	msg.ParseMode = "Markdown"
+	switch t.format {
+	case FormatMarkdownV2:
+		msg.ParseMode = tgbotapi.ModeMarkdownV2
+	case FormatHTML:
+		msg.ParseMode = tgbotapi.ModeHTML
+	}
 
 	_, err := t.bot.Send(msg)
 	if err != nil {
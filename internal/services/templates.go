@@ -0,0 +1,310 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/utils"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// MessageFormat is the markup a channel expects. Values inserted by a
+// template are escaped for the format; literal template text is not.
+type MessageFormat string
+
+const (
+	FormatMarkdownV2 MessageFormat = "markdownv2"
+	FormatHTML       MessageFormat = "html"
+	FormatText       MessageFormat = "text"
+)
+
+func ParseMessageFormat(s string) (MessageFormat, error) {
+	switch f := MessageFormat(strings.ToLower(s)); f {
+	case FormatMarkdownV2, FormatHTML, FormatText:
+		return f, nil
+	default:
+		return "", fmt.Errorf("message format must be 'markdownv2', 'html' or 'text'")
+	}
+}
+
+// MessageData is the template data for subscription events such as
+// upcoming, trial_ending and price_change; for trial_ending, Days counts
+// down to the end of the trial. Digest templates receive a *Digest and
+// budget_exceeded templates a BudgetData instead.
+type MessageData struct {
+	database.Subscription
+	Days     int
+	OldPrice float64
+}
+
+var templateFuncs = map[string]any{
+	"money": func(amount float64, currency string) string {
+		return fmt.Sprintf("%.2f %s", amount, currency)
+	},
+	"date": utils.FormatDate,
+	"totals": func(totals []CurrencyTotal) string {
+		parts := make([]string, len(totals))
+		for i, t := range totals {
+			parts[i] = fmt.Sprintf("%.2f %s", t.Amount, t.Currency)
+		}
+		return strings.Join(parts, ", ")
+	},
+	"weekday": func(d utils.Date) string {
+		return d.Time().Weekday().String()
+	},
+	"heading": func(p DigestPeriod) string {
+		if p == DigestWeekly {
+			return "This week"
+		}
+		return "Today"
+	},
+}
+
+var markdownV2Replacer = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+	"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+	"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// EscapeMarkdownV2 escapes every character Telegram's MarkdownV2 reserves.
+func EscapeMarkdownV2(s string) string {
+	return markdownV2Replacer.Replace(s)
+}
+
+type executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+// compileTemplate parses src for format. HTML templates get html/template's
+// contextual escaping; MarkdownV2 templates have every action's output
+// piped through EscapeMarkdownV2.
+func compileTemplate(name, src string, format MessageFormat) (executor, error) {
+	switch format {
+	case FormatHTML:
+		return htmltemplate.New(name).Funcs(templateFuncs).Parse(src)
+
+	case FormatMarkdownV2:
+		tmpl, err := texttemplate.New(name).Funcs(templateFuncs).Funcs(texttemplate.FuncMap{
+			"escapeMarkdownV2": func(args ...any) string {
+				return EscapeMarkdownV2(fmt.Sprint(args...))
+			},
+		}).Parse(src)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tmpl.Templates() {
+			if t.Tree != nil {
+				escapeActions(t.Tree, t.Tree.Root)
+			}
+		}
+		return tmpl, nil
+
+	case FormatText:
+		return texttemplate.New(name).Funcs(templateFuncs).Parse(src)
+
+	default:
+		return nil, fmt.Errorf("unknown message format: %s", format)
+	}
+}
+
+// escapeActions appends the escaper to every action that prints a value.
+func escapeActions(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			escapeActions(tree, child)
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) > 0 {
+			return
+		}
+		escaper := parse.NewIdentifier("escapeMarkdownV2").SetTree(tree).SetPos(n.Pos)
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{escaper},
+		})
+	case *parse.IfNode:
+		escapeActions(tree, n.List)
+		escapeActions(tree, n.ElseList)
+	case *parse.RangeNode:
+		escapeActions(tree, n.List)
+		escapeActions(tree, n.ElseList)
+	case *parse.WithNode:
+		escapeActions(tree, n.List)
+		escapeActions(tree, n.ElseList)
+	}
+}
+
+func renderTemplate(name, src string, format MessageFormat, data any) (string, error) {
+	tmpl, err := compileTemplate(name, src, format)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", name, err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// DefaultTemplate returns the built-in template for event in format.
+func DefaultTemplate(event EventType, format MessageFormat) (string, error) {
+	src, err := templateFS.ReadFile(fmt.Sprintf("templates/%s.%s.tmpl", event, format))
+	if err != nil {
+		return "", fmt.Errorf("no built-in %s template for %s events", format, event)
+	}
+	return string(src), nil
+}
+
+// RenderDefault renders the built-in template for event in format.
+func RenderDefault(event EventType, format MessageFormat, data any) (string, error) {
+	src, err := DefaultTemplate(event, format)
+	if err != nil {
+		return "", err
+	}
+	return renderTemplate(string(event), src, format, data)
+}
+
+// channelFormat returns the markup the channel is configured for.
+func (s *SubscriptionService) channelFormat(channel string) MessageFormat {
+	if channel == ChannelTelegram {
+		if f, ok := s.tg.(interface{ MessageFormat() MessageFormat }); ok {
+			return f.MessageFormat()
+		}
+	}
+	return FormatMarkdownV2
+}
+
+// MessageTemplate returns the template source used for channel and event and
+// whether it is a stored override rather than the built-in default.
+func (s *SubscriptionService) MessageTemplate(channel string, event EventType) (string, bool, error) {
+	if err := validateChannel(channel); err != nil {
+		return "", false, err
+	}
+
+	custom, err := s.db.GetMessageTemplate(channel, string(event))
+	if err != nil {
+		return "", false, err
+	}
+	if custom != nil {
+		return custom.Body, true, nil
+	}
+
+	src, err := DefaultTemplate(event, s.channelFormat(channel))
+	return src, false, err
+}
+
+// SetMessageTemplate stores an override after checking that it renders
+// against sample data.
+func (s *SubscriptionService) SetMessageTemplate(channel, eventStr, body string) error {
+	if err := validateChannel(channel); err != nil {
+		return err
+	}
+	event, err := ParseEventType(eventStr)
+	if err != nil {
+		return err
+	}
+
+	if _, err := renderTemplate(string(event), body, s.channelFormat(channel), sampleData(event)); err != nil {
+		return err
+	}
+
+	return s.db.SaveMessageTemplate(&database.MessageTemplate{Channel: channel, EventType: string(event), Body: body})
+}
+
+// ResetMessageTemplate removes an override so the built-in template applies.
+func (s *SubscriptionService) ResetMessageTemplate(channel, eventStr string) error {
+	if err := validateChannel(channel); err != nil {
+		return err
+	}
+	event, err := ParseEventType(eventStr)
+	if err != nil {
+		return err
+	}
+	return s.db.DeleteMessageTemplate(channel, string(event))
+}
+
+// PreviewMessage renders the channel's template for event. Subscription
+// events use the subscription with subID, or sample data when subID is 0;
+// digest events use this week's digest.
+func (s *SubscriptionService) PreviewMessage(channel, eventStr string, subID uint) (string, error) {
+	event, err := ParseEventType(eventStr)
+	if err != nil {
+		return "", err
+	}
+
+	data := sampleData(event)
+	switch {
+	case event == EventDigest:
+		digest, err := s.BuildDigest(DigestWeekly)
+		if err != nil {
+			return "", err
+		}
+		if digest.Count > 0 {
+			data = digest
+		}
+	case subID != 0:
+		sub, err := s.db.GetSubscriptionByID(subID)
+		if err != nil {
+			return "", fmt.Errorf("subscription not found: %w", err)
+		}
+		switch {
+		case event == EventBudgetExceeded:
+			if data, err = s.budgetData(sub); err != nil {
+				return "", err
+			}
+		case event == EventTrialEnding && sub.TrialEndDate != nil:
+			data = MessageData{Subscription: *sub, Days: utils.DaysUntil(*sub.TrialEndDate), OldPrice: sub.Price}
+		default:
+			data = MessageData{Subscription: *sub, Days: utils.DaysUntil(sub.PaymentDate), OldPrice: sub.Price}
+		}
+	}
+
+	return s.renderMessage(channel, event, data)
+}
+
+func (s *SubscriptionService) renderMessage(channel string, event EventType, data any) (string, error) {
+	src, _, err := s.MessageTemplate(channel, event)
+	if err != nil {
+		return "", err
+	}
+	return renderTemplate(string(event), src, s.channelFormat(channel), data)
+}
+
+func sampleData(event EventType) any {
+	paymentDate := utils.Today().AddDays(3)
+	sub := database.Subscription{
+		ID:          1,
+		Name:        "My_Service (Pro)",
+		Price:       12.99,
+		Currency:    "USD",
+		Cycle:       "monthly",
+		PaymentDate: paymentDate.Time(),
+	}
+
+	switch event {
+	case EventDigest:
+		return NewDigest(DigestWeekly, utils.Today(), []database.Subscription{sub})
+	case EventBudgetExceeded:
+		return BudgetData{Subscription: sub, Spent: 112.99, Budget: 100}
+	case EventTrialEnding:
+		trialEnd := paymentDate.AddDays(-1).Time()
+		sub.TrialEndDate = &trialEnd
+		return MessageData{Subscription: sub, Days: 2, OldPrice: sub.Price}
+	}
+	return MessageData{Subscription: sub, Days: 3, OldPrice: 9.99}
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+)
+
+type formatMockTelegram struct {
+	MockTelegramService
+	format MessageFormat
+}
+
+func (m *formatMockTelegram) MessageFormat() MessageFormat {
+	return m.format
+}
+
+func TestEscapeMarkdownV2(t *testing.T) {
+	got := EscapeMarkdownV2(`My_Service *Pro* [beta] (v1.2) #1 a-b=c! {x} ~|> \`)
+	want := `My\_Service \*Pro\* \[beta\] \(v1\.2\) \#1 a\-b\=c\! \{x\} \~\|\> \\`
+	if got != want {
+		t.Errorf("EscapeMarkdownV2() = %q, want %q", got, want)
+	}
+}
+
+func TestRenderTemplate_Escaping(t *testing.T) {
+	data := MessageData{
+		Subscription: database.Subscription{
+			Name:        "A_B <C> & D.",
+			Price:       9.5,
+			Currency:    "USD",
+			Cycle:       "monthly",
+			PaymentDate: time.Date(2025, time.June, 10, 0, 0, 0, 0, time.UTC),
+		},
+		Days: 2,
+	}
+
+	tests := []struct {
+		name   string
+		format MessageFormat
+		src    string
+		want   string
+	}{
+		{
+			name:   "markdownv2 escapes values but not literals",
+			format: FormatMarkdownV2,
+			src:    `*{{.Name}}* costs {{money .Price .Currency}} in {{.Days}} days\.`,
+			want:   `*A\_B <C\> & D\.* costs 9\.50 USD in 2 days\.`,
+		},
+		{
+			name:   "markdownv2 escapes inside control structures",
+			format: FormatMarkdownV2,
+			src:    `{{if .Days}}{{with $d := date .PaymentDate}}_{{$d}}_{{end}}{{end}}`,
+			want:   `_10\-06\-2025_`,
+		},
+		{
+			name:   "html escapes values",
+			format: FormatHTML,
+			src:    `<b>{{.Name}}</b>`,
+			want:   `<b>A_B &lt;C&gt; &amp; D.</b>`,
+		},
+		{
+			name:   "text is unescaped",
+			format: FormatText,
+			src:    `{{.Name}}`,
+			want:   `A_B <C> & D.`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderTemplate("test", tt.src, tt.format, data)
+			if err != nil {
+				t.Fatalf("renderTemplate() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("renderTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDefaultTemplates_Render checks that every event preferences accept has
+// a built-in template in every format.
+func TestDefaultTemplates_Render(t *testing.T) {
+	for _, format := range []MessageFormat{FormatMarkdownV2, FormatHTML, FormatText} {
+		for _, event := range EventTypes {
+			src, err := DefaultTemplate(event, format)
+			if err != nil {
+				t.Errorf("DefaultTemplate(%s, %s) error = %v", event, format, err)
+				continue
+			}
+			out, err := renderTemplate(string(event), src, format, sampleData(event))
+			if err != nil {
+				t.Errorf("default %s %s template: %v", event, format, err)
+				continue
+			}
+			if !strings.Contains(out, "Service") {
+				t.Errorf("default %s %s template does not name the subscription: %q", event, format, out)
+			}
+		}
+	}
+
+	subSvc, _, _ := setupSubscriptionService(t)
+	for _, channel := range Channels {
+		for _, event := range EventTypes {
+			if _, err := subSvc.PreviewMessage(channel, string(event), 0); err != nil {
+				t.Errorf("PreviewMessage(%s, %s) error = %v", channel, event, err)
+			}
+		}
+	}
+}
+
+func TestSubscriptionService_MessageTemplates(t *testing.T) {
+	db, err := database.New(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+	tg := &formatMockTelegram{format: FormatHTML}
+	subSvc := NewSubscriptionService(db, tg)
+
+	if err := subSvc.SetMessageTemplate(ChannelTelegram, "upcoming", "{{.Nope}}"); err == nil {
+		t.Error("SetMessageTemplate() expected error for unknown field")
+	}
+	if err := subSvc.SetMessageTemplate(ChannelTelegram, "upcoming", "{{if}}"); err == nil {
+		t.Error("SetMessageTemplate() expected error for parse error")
+	}
+
+	if err := subSvc.SetMessageTemplate(ChannelTelegram, "upcoming", "<i>{{.Name}}</i> due in {{.Days}}d"); err != nil {
+		t.Fatalf("SetMessageTemplate() error = %v", err)
+	}
+
+	preview, err := subSvc.PreviewMessage(ChannelTelegram, "upcoming", 0)
+	if err != nil {
+		t.Fatalf("PreviewMessage() error = %v", err)
+	}
+	if preview != "<i>My_Service (Pro)</i> due in 3d" {
+		t.Errorf("PreviewMessage() = %q", preview)
+	}
+
+	var messages []string
+	tg.SendMessageFunc = func(text string) error {
+		messages = append(messages, text)
+		return nil
+	}
+	sub := database.Subscription{Name: "Tom & Jerry", Price: 5, Currency: "EUR", Cycle: "monthly", PaymentDate: time.Now().AddDate(0, 0, 1)}
+	if err := db.CreateSubscription(&sub); err != nil {
+		t.Fatalf("failed to create test subscription: %v", err)
+	}
+	if err := subSvc.SendNotifications([]database.Subscription{sub}); err != nil {
+		t.Fatalf("SendNotifications() error = %v", err)
+	}
+	if _, err := subSvc.DrainOutbox(); err != nil {
+		t.Fatalf("DrainOutbox() error = %v", err)
+	}
+	if len(messages) != 1 || messages[0] != "<i>Tom &amp; Jerry</i> due in 1d" {
+		t.Errorf("messages = %q", messages)
+	}
+
+	if err := subSvc.ResetMessageTemplate(ChannelTelegram, "upcoming"); err != nil {
+		t.Fatalf("ResetMessageTemplate() error = %v", err)
+	}
+	preview, err = subSvc.PreviewMessage(ChannelTelegram, "upcoming", sub.ID)
+	if err != nil {
+		t.Fatalf("PreviewMessage() error = %v", err)
+	}
+	if !strings.HasPrefix(preview, "📢 <b>Subscription Alert: Tom &amp; Jerry</b>") {
+		t.Errorf("PreviewMessage() after reset = %q", preview)
+	}
+}
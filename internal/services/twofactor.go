@@ -0,0 +1,278 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/totp"
+)
+
+const (
+	// totpIssuer names the account in authenticator apps.
+	totpIssuer = "SubTrack"
+	// totpSkew is how many 30 second steps either side of now a code may be
+	// from, to allow for clock drift.
+	totpSkew = 1
+	// recoveryCodeCount is how many recovery codes are issued at a time.
+	recoveryCodeCount = 10
+)
+
+var (
+	// ErrInvalidCode is returned for a wrong, reused or malformed
+	// authenticator or recovery code.
+	ErrInvalidCode = errors.New("invalid two-factor code")
+	// ErrTwoFactorEnabled is returned when enrolling a user who already has
+	// two-factor authentication.
+	ErrTwoFactorEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrTwoFactorDisabled is returned for operations that need two-factor
+	// authentication, or a pending enrolment, that the user does not have.
+	ErrTwoFactorDisabled = errors.New("two-factor authentication is not enabled")
+)
+
+// TwoFactorService enrols users in TOTP two-factor authentication and
+// checks their codes.
+type TwoFactorService struct {
+	db  database.Store
+	now func() time.Time
+	// mu serialises code checks so that a code, or a recovery code, cannot
+	// be accepted twice by concurrent requests.
+	mu sync.Mutex
+}
+
+func NewTwoFactorService(db database.Store) *TwoFactorService {
+	return &TwoFactorService{db: db, now: time.Now}
+}
+
+// TwoFactorStatus describes a user's two-factor authentication.
+type TwoFactorStatus struct {
+	Enabled bool
+	// Pending is set when enrolment was started but not confirmed.
+	Pending           bool
+	EnabledAt         time.Time
+	RecoveryCodesLeft int
+}
+
+// Enrolment is what the user needs to add the account to an authenticator
+// app.
+type Enrolment struct {
+	Secret string
+	// URI is the otpauth:// URI to show as a QR code.
+	URI string
+}
+
+func (s *TwoFactorService) Status(username string) (*TwoFactorStatus, error) {
+	tf, err := s.db.GetTwoFactor(username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load two-factor settings: %w", err)
+	}
+	status := &TwoFactorStatus{}
+	switch {
+	case tf == nil:
+	case tf.ConfirmedAt == nil:
+		status.Pending = true
+	default:
+		status.Enabled = true
+		status.EnabledAt = *tf.ConfirmedAt
+		status.RecoveryCodesLeft = len(strings.Fields(tf.RecoveryCodes))
+	}
+	return status, nil
+}
+
+// Enabled reports whether username must enter a code to log in.
+func (s *TwoFactorService) Enabled(username string) (bool, error) {
+	status, err := s.Status(username)
+	if err != nil {
+		return false, err
+	}
+	return status.Enabled, nil
+}
+
+// Begin starts enrolling username with a new secret, replacing any earlier
+// enrolment that was not confirmed. Two-factor authentication is not
+// required until Confirm succeeds.
+func (s *TwoFactorService) Begin(username string) (*Enrolment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tf, err := s.db.GetTwoFactor(username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load two-factor settings: %w", err)
+	}
+	if tf != nil && tf.ConfirmedAt != nil {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	secret := totp.GenerateSecret()
+	if err := s.db.SaveTwoFactor(&database.TwoFactor{Username: username, Secret: secret}); err != nil {
+		return nil, fmt.Errorf("failed to save two-factor settings: %w", err)
+	}
+	return &Enrolment{Secret: secret, URI: totp.URI(totpIssuer, username, secret)}, nil
+}
+
+// Pending returns the enrolment username started but has not confirmed.
+func (s *TwoFactorService) Pending(username string) (*Enrolment, error) {
+	tf, err := s.db.GetTwoFactor(username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load two-factor settings: %w", err)
+	}
+	if tf == nil || tf.ConfirmedAt != nil {
+		return nil, ErrTwoFactorDisabled
+	}
+	return &Enrolment{Secret: tf.Secret, URI: totp.URI(totpIssuer, username, tf.Secret)}, nil
+}
+
+// Confirm finishes enrolment once the user enters a code from their app,
+// proving it has the secret. It returns the recovery codes, which are only
+// stored hashed and cannot be shown again.
+func (s *TwoFactorService) Confirm(username, code string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tf, err := s.db.GetTwoFactor(username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load two-factor settings: %w", err)
+	}
+	if tf == nil {
+		return nil, ErrTwoFactorDisabled
+	}
+	if tf.ConfirmedAt != nil {
+		return nil, ErrTwoFactorEnabled
+	}
+	now := s.now()
+	step, ok := totp.Validate(tf.Secret, code, now, totpSkew)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	codes, hashes := newRecoveryCodes()
+	tf.RecoveryCodes = hashes
+	tf.LastStep = step
+	tf.ConfirmedAt = &now
+	if err := s.db.SaveTwoFactor(tf); err != nil {
+		return nil, fmt.Errorf("failed to save two-factor settings: %w", err)
+	}
+	return codes, nil
+}
+
+// Verify checks a code from username's authenticator app or one of their
+// recovery codes. A code is accepted once; a recovery code is used up.
+// It reports whether a recovery code was used.
+func (s *TwoFactorService) Verify(username, code string) (recovery bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tf, err := s.db.GetTwoFactor(username)
+	if err != nil {
+		return false, fmt.Errorf("failed to load two-factor settings: %w", err)
+	}
+	if tf == nil || tf.ConfirmedAt == nil {
+		return false, ErrTwoFactorDisabled
+	}
+
+	if isTOTPCode(code) {
+		step, ok := totp.Validate(tf.Secret, code, s.now(), totpSkew)
+		if !ok || step <= tf.LastStep {
+			return false, ErrInvalidCode
+		}
+		tf.LastStep = step
+	} else {
+		hashes := strings.Fields(tf.RecoveryCodes)
+		i := slices.Index(hashes, hashRecoveryCode(code))
+		if i < 0 {
+			return false, ErrInvalidCode
+		}
+		tf.RecoveryCodes = strings.Join(slices.Delete(hashes, i, i+1), " ")
+		recovery = true
+	}
+	if err := s.db.SaveTwoFactor(tf); err != nil {
+		return false, fmt.Errorf("failed to save two-factor settings: %w", err)
+	}
+	return recovery, nil
+}
+
+// RegenerateRecoveryCodes replaces username's recovery codes, for when they
+// are lost or running out.
+func (s *TwoFactorService) RegenerateRecoveryCodes(username string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tf, err := s.db.GetTwoFactor(username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load two-factor settings: %w", err)
+	}
+	if tf == nil || tf.ConfirmedAt == nil {
+		return nil, ErrTwoFactorDisabled
+	}
+	codes, hashes := newRecoveryCodes()
+	tf.RecoveryCodes = hashes
+	if err := s.db.SaveTwoFactor(tf); err != nil {
+		return nil, fmt.Errorf("failed to save two-factor settings: %w", err)
+	}
+	return codes, nil
+}
+
+// Disable removes username's two-factor authentication, or a pending
+// enrolment. It reports whether there was anything to remove.
+func (s *TwoFactorService) Disable(username string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tf, err := s.db.GetTwoFactor(username)
+	if err != nil {
+		return false, fmt.Errorf("failed to load two-factor settings: %w", err)
+	}
+	if tf == nil {
+		return false, nil
+	}
+	if err := s.db.DeleteTwoFactor(username); err != nil {
+		return false, fmt.Errorf("failed to remove two-factor settings: %w", err)
+	}
+	return true, nil
+}
+
+func isTOTPCode(code string) bool {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != totp.Digits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newRecoveryCodes returns new recovery codes, formatted as xxxx-xxxx, and
+// their hashes for storage.
+func newRecoveryCodes() (codes []string, hashes string) {
+	hashed := make([]string, recoveryCodeCount)
+	codes = make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		rand.Read(b)
+		code := strings.ToLower(recoveryEncoding.EncodeToString(b))
+		codes[i] = code[:4] + "-" + code[4:]
+		hashed[i] = hashRecoveryCode(code)
+	}
+	return codes, strings.Join(hashed, " ")
+}
+
+// hashRecoveryCode hashes code ignoring case, spaces and dashes, so that
+// users may type it however it was written down.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/totp"
+)
+
+func newTestTwoFactor(t *testing.T) (*TwoFactorService, *time.Time) {
+	t.Helper()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc := NewTwoFactorService(database.NewMemoryStore())
+	svc.now = func() time.Time { return now }
+	return svc, &now
+}
+
+func codeAt(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	code, err := totp.Code(secret, totp.Step(at))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestTwoFactor_Enrolment(t *testing.T) {
+	svc, now := newTestTwoFactor(t)
+
+	if enabled, err := svc.Enabled("admin"); err != nil || enabled {
+		t.Fatalf("Enabled() before enrolment = %v, %v", enabled, err)
+	}
+	if _, err := svc.Pending("admin"); !errors.Is(err, ErrTwoFactorDisabled) {
+		t.Errorf("Pending() before enrolment error = %v", err)
+	}
+
+	enrolment, err := svc.Begin("admin")
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	if pending, _ := svc.Pending("admin"); pending == nil || pending.Secret != enrolment.Secret {
+		t.Errorf("Pending() = %+v, want %+v", pending, enrolment)
+	}
+	// A pending enrolment does not yet protect logins.
+	if status, _ := svc.Status("admin"); status.Enabled || !status.Pending {
+		t.Errorf("Status() while pending = %+v", status)
+	}
+
+	// A code from a minute ago is outside the allowed clock drift.
+	if _, err := svc.Confirm("admin", codeAt(t, enrolment.Secret, now.Add(-2*totp.Period))); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Confirm(wrong code) error = %v", err)
+	}
+	codes, err := svc.Confirm("admin", codeAt(t, enrolment.Secret, *now))
+	if err != nil {
+		t.Fatalf("Confirm() error = %v", err)
+	}
+	if len(codes) != recoveryCodeCount || len(codes[0]) != 9 {
+		t.Errorf("Confirm() recovery codes = %v", codes)
+	}
+
+	status, _ := svc.Status("admin")
+	if !status.Enabled || status.Pending || !status.EnabledAt.Equal(*now) || status.RecoveryCodesLeft != recoveryCodeCount {
+		t.Errorf("Status() = %+v", status)
+	}
+	if _, err := svc.Begin("admin"); !errors.Is(err, ErrTwoFactorEnabled) {
+		t.Errorf("Begin() when enabled error = %v", err)
+	}
+}
+
+func TestTwoFactor_Verify(t *testing.T) {
+	svc, now := newTestTwoFactor(t)
+	enrolment, _ := svc.Begin("admin")
+	codes, err := svc.Confirm("admin", codeAt(t, enrolment.Secret, *now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The code used to confirm cannot be used again.
+	if _, err := svc.Verify("admin", codeAt(t, enrolment.Secret, *now)); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Verify(reused code) error = %v", err)
+	}
+
+	*now = now.Add(totp.Period)
+	recovery, err := svc.Verify("admin", codeAt(t, enrolment.Secret, *now))
+	if err != nil || recovery {
+		t.Errorf("Verify(next code) = %v, %v", recovery, err)
+	}
+
+	// Recovery codes work once, however they are typed.
+	recovery, err = svc.Verify("admin", " "+codes[3][:4]+codes[3][5:]+" ")
+	if err != nil || !recovery {
+		t.Errorf("Verify(recovery code) = %v, %v", recovery, err)
+	}
+	if _, err := svc.Verify("admin", codes[3]); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Verify(used recovery code) error = %v", err)
+	}
+	if status, _ := svc.Status("admin"); status.RecoveryCodesLeft != recoveryCodeCount-1 {
+		t.Errorf("RecoveryCodesLeft = %d", status.RecoveryCodesLeft)
+	}
+
+	renewed, err := svc.RegenerateRecoveryCodes("admin")
+	if err != nil || len(renewed) != recoveryCodeCount {
+		t.Fatalf("RegenerateRecoveryCodes() = %v, %v", renewed, err)
+	}
+	if _, err := svc.Verify("admin", codes[4]); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("old recovery code still works after renewal: %v", err)
+	}
+
+	if _, err := svc.Verify("bob", "123456"); !errors.Is(err, ErrTwoFactorDisabled) {
+		t.Errorf("Verify() for a user without 2FA error = %v", err)
+	}
+}
+
+func TestTwoFactor_Disable(t *testing.T) {
+	svc, now := newTestTwoFactor(t)
+	if removed, err := svc.Disable("admin"); err != nil || removed {
+		t.Errorf("Disable() without 2FA = %v, %v", removed, err)
+	}
+
+	enrolment, _ := svc.Begin("admin")
+	svc.Confirm("admin", codeAt(t, enrolment.Secret, *now))
+	if removed, err := svc.Disable("admin"); err != nil || !removed {
+		t.Errorf("Disable() = %v, %v", removed, err)
+	}
+	if enabled, _ := svc.Enabled("admin"); enabled {
+		t.Error("still enabled after Disable()")
+	}
+}
+
+func TestTwoFactor_StoreFailure(t *testing.T) {
+	store := database.NewFaultyStore(database.NewMemoryStore())
+	svc := NewTwoFactorService(store)
+
+	store.FailOn("SaveTwoFactor", errors.New("disk full"))
+	if _, err := svc.Begin("admin"); err == nil {
+		t.Error("Begin() with a failing store succeeded")
+	}
+	store.FailOn("GetTwoFactor", errors.New("disk full"))
+	if _, err := svc.Enabled("admin"); err == nil {
+		t.Error("Enabled() with a failing store succeeded")
+	}
+}
@@ -0,0 +1,223 @@
+package snapshot
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/utils"
+)
+
+const (
+	filePrefix = "subtrack-"
+	fileSuffix = ".db"
+	timeLayout = "20060102T150405Z"
+)
+
+type Snapshot struct {
+	Name      string
+	Path      string
+	CreatedAt time.Time
+	Size      int64
+}
+
+// Manager takes snapshots of the database into a directory and rotates them,
+// keeping the newest snapshot of each of the last KeepDaily days and of each
+// of the last KeepWeekly ISO weeks.
+type Manager struct {
+	db         *database.DB
+	dir        string
+	keepDaily  int
+	keepWeekly int
+	now        func() time.Time
+}
+
+func NewManager(db *database.DB, dir string, keepDaily, keepWeekly int) *Manager {
+	return &Manager{
+		db:         db,
+		dir:        dir,
+		keepDaily:  keepDaily,
+		keepWeekly: keepWeekly,
+		now:        time.Now,
+	}
+}
+
+// Create writes a snapshot, verifies its integrity and only then gives it
+// its final name, so List never returns a partial or corrupt file.
+func (m *Manager) Create() (*Snapshot, error) {
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	createdAt := m.now().UTC().Truncate(time.Second)
+	name := filePrefix + createdAt.Format(timeLayout) + fileSuffix
+	path := filepath.Join(m.dir, name)
+	tmp := path + ".tmp"
+
+	os.Remove(tmp)
+	if err := m.db.VacuumInto(tmp); err != nil {
+		return nil, err
+	}
+	if err := database.VerifyIntegrity(tmp); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{Name: name, Path: path, CreatedAt: createdAt, Size: info.Size()}, nil
+}
+
+// List returns the snapshots in the directory, newest first.
+func (m *Manager) List() ([]Snapshot, error) {
+	entries, err := os.ReadDir(m.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+
+	var snaps []Snapshot
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		createdAt, err := time.Parse(timeLayout, strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix))
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		snaps = append(snaps, Snapshot{Name: name, Path: filepath.Join(m.dir, name), CreatedAt: createdAt, Size: info.Size()})
+	}
+
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].CreatedAt.After(snaps[j].CreatedAt) })
+	return snaps, nil
+}
+
+// Find returns the snapshot with the given file name.
+func (m *Manager) Find(name string) (*Snapshot, error) {
+	snaps, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range snaps {
+		if snaps[i].Name == name {
+			return &snaps[i], nil
+		}
+	}
+	return nil, fmt.Errorf("snapshot not found: %s", name)
+}
+
+// Prune deletes snapshots that fall outside the daily and weekly retention
+// and returns them. The newest snapshot is always kept.
+func (m *Manager) Prune() ([]Snapshot, error) {
+	snaps, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+
+	keep := make(map[string]bool)
+	days := make(map[utils.Date]bool)
+	weeks := make(map[[2]int]bool)
+	for i, snap := range snaps {
+		if i == 0 {
+			keep[snap.Name] = true
+		}
+		day := utils.DateOf(snap.CreatedAt)
+		if !days[day] && len(days) < m.keepDaily {
+			days[day] = true
+			keep[snap.Name] = true
+		}
+		year, week := snap.CreatedAt.In(utils.Location()).ISOWeek()
+		if key := [2]int{year, week}; !weeks[key] && len(weeks) < m.keepWeekly {
+			weeks[key] = true
+			keep[snap.Name] = true
+		}
+	}
+
+	var removed []Snapshot
+	for _, snap := range snaps {
+		if keep[snap.Name] {
+			continue
+		}
+		if err := os.Remove(snap.Path); err != nil {
+			return removed, fmt.Errorf("failed to remove snapshot %s: %w", snap.Name, err)
+		}
+		removed = append(removed, snap)
+	}
+	return removed, nil
+}
+
+// Run creates a snapshot and prunes old ones. It is the scheduled job.
+func (m *Manager) Run() error {
+	snap, err := m.Create()
+	if err != nil {
+		return err
+	}
+	log.Printf("Created snapshot %s (%d bytes)", snap.Name, snap.Size)
+
+	removed, err := m.Prune()
+	for _, r := range removed {
+		log.Printf("Removed old snapshot %s", r.Name)
+	}
+	return err
+}
+
+// Restore verifies the snapshot and atomically replaces the database file at
+// dbPath with a copy of it. Nothing may have dbPath open while this runs.
+func Restore(snapshotPath, dbPath string) error {
+	if err := database.VerifyIntegrity(snapshotPath); err != nil {
+		return err
+	}
+
+	src, err := os.Open(snapshotPath)
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dbPath), filepath.Base(dbPath)+".restore-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to copy snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", dbPath+suffix, err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), dbPath); err != nil {
+		return fmt.Errorf("failed to replace database: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/utils"
+)
+
+func newTestManager(t *testing.T, keepDaily, keepWeekly int) (*Manager, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "subtrack.db")
+	db, err := database.New(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.CreateSubscription(&database.Subscription{
+		Name: "Netflix", Price: 15.99, Currency: "USD", Cycle: "monthly",
+		PaymentDate: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+	}); err != nil {
+		t.Fatalf("failed to create subscription: %v", err)
+	}
+
+	return NewManager(db, filepath.Join(dir, "snapshots"), keepDaily, keepWeekly), dbPath
+}
+
+func TestCreate(t *testing.T) {
+	m, _ := newTestManager(t, 7, 4)
+	m.now = func() time.Time { return time.Date(2025, 2, 15, 3, 0, 0, 0, time.UTC) }
+
+	snap, err := m.Create()
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if snap.Name != "subtrack-20250215T030000Z.db" {
+		t.Errorf("Name = %q", snap.Name)
+	}
+	if err := database.VerifyIntegrity(snap.Path); err != nil {
+		t.Errorf("snapshot failed integrity check: %v", err)
+	}
+	if _, err := os.Stat(snap.Path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind")
+	}
+
+	snaps, err := m.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(snaps) != 1 || snaps[0].Name != snap.Name {
+		t.Errorf("List() = %+v, want [%s]", snaps, snap.Name)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	utils.SetLocation(time.UTC)
+	m, _ := newTestManager(t, 3, 2)
+
+	// Two snapshots a day for ten days: Wed 05-02-2025 to Fri 14-02-2025.
+	start := time.Date(2025, 2, 5, 3, 0, 0, 0, time.UTC)
+	for d := 0; d < 10; d++ {
+		for _, h := range []int{0, 12} {
+			at := start.AddDate(0, 0, d).Add(time.Duration(h) * time.Hour)
+			m.now = func() time.Time { return at }
+			if _, err := m.Create(); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+		}
+	}
+
+	if _, err := m.Prune(); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+
+	snaps, err := m.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	var got []string
+	for _, s := range snaps {
+		got = append(got, s.Name)
+	}
+	// Newest of the last three days, plus the newest of ISO week 6
+	// (Sunday 09-02); week 7 is already covered by the daily snapshots.
+	want := []string{
+		"subtrack-20250214T150000Z.db",
+		"subtrack-20250213T150000Z.db",
+		"subtrack-20250212T150000Z.db",
+		"subtrack-20250209T150000Z.db",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("kept %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("kept[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestPruneKeepsNewest(t *testing.T) {
+	m, _ := newTestManager(t, 0, 0)
+
+	for _, at := range []time.Time{
+		time.Date(2025, 2, 14, 3, 0, 0, 0, time.UTC),
+		time.Date(2025, 2, 15, 3, 0, 0, 0, time.UTC),
+	} {
+		m.now = func() time.Time { return at }
+		if _, err := m.Create(); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	removed, err := m.Prune()
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if len(removed) != 1 || removed[0].Name != "subtrack-20250214T030000Z.db" {
+		t.Errorf("removed = %+v", removed)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	m, _ := newTestManager(t, 7, 4)
+
+	snap, err := m.Create()
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	target := filepath.Join(t.TempDir(), "restored.db")
+	if err := os.WriteFile(target, []byte("not a database"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(target+"-wal", []byte("stale"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Restore(snap.Path, target); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if _, err := os.Stat(target + "-wal"); !os.IsNotExist(err) {
+		t.Errorf("stale WAL file was not removed")
+	}
+
+	db, err := database.New(target)
+	if err != nil {
+		t.Fatalf("failed to open restored database: %v", err)
+	}
+	defer db.Close()
+
+	subs, err := db.GetAllSubscriptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 1 || subs[0].Name != "Netflix" {
+		t.Errorf("restored subscriptions = %+v", subs)
+	}
+}
+
+func TestRestoreRejectsCorruptSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.db")
+	if err := os.WriteFile(bad, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(dir, "subtrack.db")
+	if err := os.WriteFile(target, []byte("original"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Restore(bad, target); err == nil {
+		t.Fatal("Restore() succeeded with a corrupt snapshot")
+	}
+	if data, _ := os.ReadFile(target); string(data) != "original" {
+		t.Errorf("database was modified: %q", data)
+	}
+}
@@ -0,0 +1,296 @@
+// Package sso logs users in with an OpenID Connect provider, using the
+// authorization code flow with PKCE.
+package sso
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// loginTimeout is how long a user has to log in at the provider.
+const loginTimeout = 10 * time.Minute
+
+// ErrInvalidState is returned by Finish for a callback that does not match
+// a login started by Start, or that came too late.
+var ErrInvalidState = errors.New("single sign-on login expired or was not started here")
+
+// Config configures the provider and which ID token claims identify users.
+type Config struct {
+	// Issuer is the provider's issuer URL, where its discovery document is.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// Scopes are requested in addition to openid.
+	Scopes []string
+	// UsernameClaim names the claim that becomes the local username,
+	// preferred_username when empty.
+	UsernameClaim string
+	// RolesClaim names the claim listing the user's roles or groups,
+	// groups when empty. It may be a string or a list of strings.
+	RolesClaim string
+}
+
+// Identity is a user the provider vouched for.
+type Identity struct {
+	Subject  string
+	Username string
+	Roles    []string
+}
+
+// HasRole reports whether the identity has any of roles.
+func (id *Identity) HasRole(roles []string) bool {
+	for _, want := range roles {
+		for _, have := range id.Roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Client runs logins against the provider. The provider is discovered on
+// first use and again after a failure, so the service starts even when the
+// provider is down.
+//
+// A login in progress is kept by the browser rather than here: Start seals
+// it with a key only this Client knows, so starting logins costs the server
+// no memory. Logins started before a restart cannot be finished after it.
+type Client struct {
+	cfg Config
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+	aead     cipher.AEAD
+}
+
+// login is a login started by Start and waiting for the provider's callback.
+type login struct {
+	State       string `json:"s"`
+	Nonce       string `json:"n"`
+	Verifier    string `json:"v"`
+	RedirectURL string `json:"r"`
+	ExpiresAt   int64  `json:"e"`
+}
+
+func New(cfg Config) *Client {
+	if cfg.UsernameClaim == "" {
+		cfg.UsernameClaim = "preferred_username"
+	}
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = "groups"
+	}
+	return &Client{cfg: cfg}
+}
+
+// sealer returns the cipher that seals logins, creating its key on first
+// use.
+func (c *Client) sealer() (cipher.AEAD, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.aead != nil {
+		return c.aead, nil
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	c.aead = aead
+	return aead, nil
+}
+
+// seal encrypts l for the browser to keep.
+func (c *Client) seal(l login) (string, error) {
+	aead, err := c.sealer()
+	if err != nil {
+		return "", err
+	}
+	plain, err := json.Marshal(l)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, plain, nil)), nil
+}
+
+// open decrypts a login sealed by seal, failing for anything else.
+func (c *Client) open(sealed string) (*login, error) {
+	aead, err := c.sealer()
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil || len(data) < aead.NonceSize() {
+		return nil, ErrInvalidState
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+	var l login
+	if err := json.Unmarshal(plain, &l); err != nil {
+		return nil, ErrInvalidState
+	}
+	return &l, nil
+}
+
+func (c *Client) discover(ctx context.Context) (*oidc.Provider, error) {
+	c.mu.Lock()
+	provider := c.provider
+	c.mu.Unlock()
+	if provider != nil {
+		return provider, nil
+	}
+
+	// Discovery must not be cut short by the request that happens to
+	// trigger it.
+	ctx = context.WithoutCancel(ctx)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	provider, err := oidc.NewProvider(ctx, c.cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OpenID provider %s: %w", c.cfg.Issuer, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.provider = provider
+	return provider, nil
+}
+
+func (c *Client) oauth2Config(provider *oidc.Provider, redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     c.cfg.ClientID,
+		ClientSecret: c.cfg.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  redirectURL,
+		Scopes:       append([]string{oidc.ScopeOpenID}, c.cfg.Scopes...),
+	}
+}
+
+// Start begins a login that returns to redirectURL. It returns the
+// provider URL to send the user to and the sealed login, which the caller
+// must tie to the browser, typically in a cookie, and pass to Finish.
+func (c *Client) Start(ctx context.Context, redirectURL string) (authURL, sealed string, err error) {
+	provider, err := c.discover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	l := login{
+		State:       state,
+		Nonce:       nonce,
+		Verifier:    oauth2.GenerateVerifier(),
+		RedirectURL: redirectURL,
+		ExpiresAt:   time.Now().Add(loginTimeout).Unix(),
+	}
+	sealed, err = c.seal(l)
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL = c.oauth2Config(provider, redirectURL).AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(l.Verifier))
+	return authURL, sealed, nil
+}
+
+// Finish completes the login sealed by Start with the state and code the
+// provider returned, and returns who logged in.
+func (c *Client) Finish(ctx context.Context, sealed, returnedState, code string) (*Identity, error) {
+	if sealed == "" {
+		return nil, ErrInvalidState
+	}
+	l, err := c.open(sealed)
+	if err != nil {
+		return nil, err
+	}
+	if l.State != returnedState || time.Now().Unix() > l.ExpiresAt {
+		return nil, ErrInvalidState
+	}
+
+	provider, err := c.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	token, err := c.oauth2Config(provider, l.RedirectURL).Exchange(ctx, code, oauth2.VerifierOption(l.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("provider returned no ID token")
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: c.cfg.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+	if idToken.Nonce != l.Nonce {
+		return nil, fmt.Errorf("invalid ID token: nonce does not match")
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("invalid ID token claims: %w", err)
+	}
+	return c.identity(idToken.Subject, claims)
+}
+
+// identity maps ID token claims to a user.
+func (c *Client) identity(subject string, claims map[string]any) (*Identity, error) {
+	username, _ := claims[c.cfg.UsernameClaim].(string)
+	if strings.TrimSpace(username) == "" {
+		return nil, fmt.Errorf("ID token has no %s claim", c.cfg.UsernameClaim)
+	}
+
+	id := &Identity{Subject: subject, Username: username}
+	switch roles := claims[c.cfg.RolesClaim].(type) {
+	case string:
+		id.Roles = strings.Fields(strings.ReplaceAll(roles, ",", " "))
+	case []any:
+		for _, role := range roles {
+			if s, ok := role.(string); ok {
+				id.Roles = append(id.Roles, s)
+			}
+		}
+	}
+	return id, nil
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,163 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/sso/mockidp"
+)
+
+const redirectURL = "http://subtrack.test/login/sso/callback"
+
+func newTestProvider(t *testing.T, claims map[string]any) *Client {
+	t.Helper()
+	idp, err := mockidp.New("subtrack", "s3cret", claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(idp)
+	t.Cleanup(srv.Close)
+	idp.Issuer = srv.URL
+	return New(Config{Issuer: srv.URL, ClientID: "subtrack", ClientSecret: "s3cret", Scopes: []string{"profile", "groups"}})
+}
+
+// authorize follows authURL to the provider and returns the query it
+// redirects back with.
+func authorize(t *testing.T, authURL string) url.Values {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	back, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize = %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	return back.Query()
+}
+
+func TestLogin(t *testing.T) {
+	c := newTestProvider(t, map[string]any{"preferred_username": "jane", "groups": []string{"staff", "finance"}})
+	ctx := context.Background()
+
+	authURL, state, err := c.Start(ctx, redirectURL)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	back := authorize(t, authURL)
+
+	id, err := c.Finish(ctx, state, back.Get("state"), back.Get("code"))
+	if err != nil {
+		t.Fatalf("Finish() error = %v", err)
+	}
+	if id.Username != "jane" || id.Subject != "mock-user" || !slices.Equal(id.Roles, []string{"staff", "finance"}) {
+		t.Errorf("Finish() = %+v", id)
+	}
+	if !id.HasRole([]string{"admins", "finance"}) || id.HasRole([]string{"admins"}) {
+		t.Errorf("HasRole() is wrong for %v", id.Roles)
+	}
+
+	// The provider accepts each code once, so the login cannot be finished
+	// twice.
+	if _, err := c.Finish(ctx, state, back.Get("state"), back.Get("code")); err == nil {
+		t.Error("second Finish() succeeded")
+	}
+}
+
+func TestLogin_StateMismatch(t *testing.T) {
+	c := newTestProvider(t, map[string]any{"preferred_username": "jane"})
+	ctx := context.Background()
+
+	authURL, _, err := c.Start(ctx, redirectURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back := authorize(t, authURL)
+
+	// A callback carried into another browser has no matching state cookie.
+	_, otherState, _ := c.Start(ctx, redirectURL)
+	if _, err := c.Finish(ctx, otherState, back.Get("state"), back.Get("code")); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Finish() with another login's state error = %v", err)
+	}
+	if _, err := c.Finish(ctx, "", "", back.Get("code")); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Finish() without state error = %v", err)
+	}
+}
+
+func TestLogin_SealedState(t *testing.T) {
+	c := newTestProvider(t, map[string]any{"preferred_username": "jane"})
+	ctx := context.Background()
+
+	authURL, sealed, err := c.Start(ctx, redirectURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back := authorize(t, authURL)
+
+	// The browser keeps the login, but can neither read nor change it, and
+	// another server's logins are not accepted.
+	if strings.Contains(sealed, back.Get("state")) {
+		t.Error("Start() leaked the state in the sealed login")
+	}
+	tampered := []byte(sealed)
+	tampered[len(tampered)/2] ^= 1
+	tests := []struct {
+		name   string
+		client *Client
+		sealed string
+	}{
+		{"tampered", c, string(tampered)},
+		{"garbage", c, "not-sealed!"},
+		{"another server's", New(c.cfg), sealed},
+	}
+	for _, tt := range tests {
+		if _, err := tt.client.Finish(ctx, tt.sealed, back.Get("state"), back.Get("code")); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("Finish() with a %s login error = %v", tt.name, err)
+		}
+	}
+
+	expired, err := c.seal(login{State: back.Get("state"), ExpiresAt: time.Now().Add(-time.Second).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Finish(ctx, expired, back.Get("state"), back.Get("code")); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Finish() with an expired login error = %v", err)
+	}
+
+	if _, err := c.Finish(ctx, sealed, back.Get("state"), back.Get("code")); err != nil {
+		t.Errorf("Finish() error = %v", err)
+	}
+}
+
+func TestLogin_ProviderDown(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	c := New(Config{Issuer: srv.URL, ClientID: "subtrack"})
+	if _, _, err := c.Start(context.Background(), redirectURL); err == nil {
+		t.Error("Start() with the provider down succeeded")
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	c := New(Config{UsernameClaim: "email", RolesClaim: "roles"})
+
+	id, err := c.identity("sub", map[string]any{"email": "jane@example.com", "roles": "admin, finance"})
+	if err != nil || id.Username != "jane@example.com" || !slices.Equal(id.Roles, []string{"admin", "finance"}) {
+		t.Errorf("identity() = %+v, %v", id, err)
+	}
+	if id, err := c.identity("sub", map[string]any{"email": "jane@example.com"}); err != nil || len(id.Roles) != 0 {
+		t.Errorf("identity() without roles = %+v, %v", id, err)
+	}
+	if _, err := c.identity("sub", map[string]any{"preferred_username": "jane"}); err == nil {
+		t.Error("identity() without the username claim succeeded")
+	}
+}
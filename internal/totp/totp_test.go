@@ -0,0 +1,73 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key from the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode_RFC6238(t *testing.T) {
+	// The RFC lists eight-digit codes; six-digit codes are their last six.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Code() at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("Code() accepted an invalid secret")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, _ := Code(rfcSecret, Step(now))
+	previous, _ := Code(rfcSecret, Step(now)-1)
+	old, _ := Code(rfcSecret, Step(now)-2)
+
+	if step, ok := Validate(rfcSecret, code, now, 1); !ok || step != Step(now) {
+		t.Errorf("Validate(current) = %d, %v", step, ok)
+	}
+	if step, ok := Validate(rfcSecret, previous[:3]+" "+previous[3:], now, 1); !ok || step != Step(now)-1 {
+		t.Errorf("Validate(previous, spaced) = %d, %v", step, ok)
+	}
+	for _, bad := range []string{old, "12345", "1234567", ""} {
+		if _, ok := Validate(rfcSecret, bad, now, 1); ok {
+			t.Errorf("Validate(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestGenerateSecretAndURI(t *testing.T) {
+	secret := GenerateSecret()
+	if len(secret) != 32 || GenerateSecret() == secret {
+		t.Errorf("GenerateSecret() = %q", secret)
+	}
+	if _, err := Code(secret, 1); err != nil {
+		t.Errorf("generated secret does not decode: %v", err)
+	}
+
+	uri := URI("SubTrack", "admin user", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/SubTrack:admin%20user?") || !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("URI() = %s", uri)
+	}
+}
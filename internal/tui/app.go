@@ -0,0 +1,292 @@
+// Package tui is a full-screen terminal interface for triaging
+// subscriptions. App holds the state and turns key presses into service
+// calls and frames; Run drives it on a terminal, and tests drive it
+// headlessly through HandleKey and View.
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/services"
+	"github.com/berkaycubuk/subtrack/internal/utils"
+)
+
+// Service is what the TUI needs from SubscriptionService.
+type Service interface {
+	SearchSubscriptions(q database.SubscriptionQuery) (*database.SubscriptionPage, error)
+	AddSubscription(name, price, currency, cycle, paymentDate, category string) error
+	UpdateSubscription(id uint, name, price, currency, cycle, paymentDate string) error
+	SetSubscriptionCategory(id uint, category string) error
+	SetSubscriptionStatus(id uint, status string) error
+	DeleteSubscription(id uint) error
+	MarkPaid(id uint) (*database.Subscription, error)
+}
+
+type mode int
+
+const (
+	modeList mode = iota
+	modeForm
+	modeConfirmDelete
+	modeHelp
+)
+
+type App struct {
+	svc  Service
+	subs []database.Subscription
+
+	selected int
+	// offset is the first subscription shown; it follows the selection.
+	offset int
+	// rows is how many subscriptions the last frame showed, for paging.
+	rows int
+
+	mode    mode
+	form    *form
+	message string
+	isError bool
+	quit    bool
+}
+
+func New(svc Service) *App {
+	return &App{svc: svc, rows: 10}
+}
+
+// Load reads all subscriptions, sorted by next payment, keeping the
+// selection on the same subscription where it still exists.
+func (a *App) Load() error {
+	var selectedID uint
+	if sub := a.current(); sub != nil {
+		selectedID = sub.ID
+	}
+
+	var subs []database.Subscription
+	q := database.SubscriptionQuery{Sort: database.SortNextPayment, Limit: services.MaxPageSize}
+	for {
+		page, err := a.svc.SearchSubscriptions(q)
+		if err != nil {
+			return err
+		}
+		subs = append(subs, page.Subscriptions...)
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+	a.subs = subs
+
+	if i := slices.IndexFunc(subs, func(s database.Subscription) bool { return s.ID == selectedID }); i >= 0 {
+		a.selected = i
+	}
+	a.selected = min(a.selected, max(len(subs)-1, 0))
+	return nil
+}
+
+// Quit reports whether the user asked to leave.
+func (a *App) Quit() bool {
+	return a.quit
+}
+
+func (a *App) current() *database.Subscription {
+	if a.selected < 0 || a.selected >= len(a.subs) {
+		return nil
+	}
+	return &a.subs[a.selected]
+}
+
+func (a *App) setMessage(format string, args ...any) {
+	a.message, a.isError = fmt.Sprintf(format, args...), false
+}
+
+func (a *App) setError(err error) {
+	a.message, a.isError = err.Error(), true
+}
+
+// HandleKey applies one key press.
+func (a *App) HandleKey(k Key) {
+	if k.Code == KeyCtrlC {
+		a.quit = true
+		return
+	}
+
+	switch a.mode {
+	case modeForm:
+		a.handleFormKey(k)
+	case modeConfirmDelete:
+		a.handleDeleteKey(k)
+	case modeHelp:
+		a.mode = modeList
+	default:
+		a.handleListKey(k)
+	}
+}
+
+func (a *App) handleListKey(k Key) {
+	a.message = ""
+
+	switch k.Code {
+	case KeyUp:
+		a.move(-1)
+	case KeyDown:
+		a.move(1)
+	case KeyPgUp:
+		a.move(-a.rows)
+	case KeyPgDn:
+		a.move(a.rows)
+	case KeyHome:
+		a.move(-len(a.subs))
+	case KeyEnd:
+		a.move(len(a.subs))
+	case KeyEnter:
+		a.editSelected()
+	case KeyRune:
+		switch k.Rune {
+		case 'k':
+			a.move(-1)
+		case 'j':
+			a.move(1)
+		case 'g':
+			a.move(-len(a.subs))
+		case 'G':
+			a.move(len(a.subs))
+		case 'q':
+			a.quit = true
+		case 'a':
+			a.form, a.mode = newAddForm(), modeForm
+		case 'e':
+			a.editSelected()
+		case 'd':
+			if a.current() != nil {
+				a.mode = modeConfirmDelete
+			}
+		case 'p':
+			a.markPaid()
+		case 's':
+			a.cycleStatus()
+		case 'r':
+			if err := a.Load(); err != nil {
+				a.setError(err)
+			} else {
+				a.setMessage("Reloaded")
+			}
+		case '?':
+			a.mode = modeHelp
+		}
+	}
+}
+
+func (a *App) move(n int) {
+	a.selected = max(0, min(a.selected+n, len(a.subs)-1))
+}
+
+func (a *App) editSelected() {
+	if sub := a.current(); sub != nil {
+		a.form, a.mode = newEditForm(sub), modeForm
+	}
+}
+
+func (a *App) markPaid() {
+	sub := a.current()
+	if sub == nil {
+		return
+	}
+	paid, err := a.svc.MarkPaid(sub.ID)
+	if err != nil {
+		a.setError(err)
+		return
+	}
+	a.setMessage("✓ %s paid; next payment %s", paid.Name, utils.FormatDate(paid.PaymentDate))
+	a.reload()
+}
+
+// cycleStatus moves the selection to the next status: active, paused,
+// cancelled and back to active.
+func (a *App) cycleStatus() {
+	sub := a.current()
+	if sub == nil {
+		return
+	}
+	next := database.Statuses[(slices.Index(database.Statuses, sub.Status)+1)%len(database.Statuses)]
+	if err := a.svc.SetSubscriptionStatus(sub.ID, next); err != nil {
+		a.setError(err)
+		return
+	}
+	a.setMessage("✓ %s is now %s", sub.Name, next)
+	a.reload()
+}
+
+func (a *App) handleDeleteKey(k Key) {
+	a.mode = modeList
+	sub := a.current()
+	if sub == nil || k.Code != KeyRune || (k.Rune != 'y' && k.Rune != 'Y') {
+		a.setMessage("Delete cancelled")
+		return
+	}
+	if err := a.svc.DeleteSubscription(sub.ID); err != nil {
+		a.setError(err)
+		return
+	}
+	a.setMessage("✓ %s deleted", sub.Name)
+	a.reload()
+}
+
+func (a *App) handleFormKey(k Key) {
+	f := a.form
+	switch k.Code {
+	case KeyEsc:
+		a.form, a.mode = nil, modeList
+		a.setMessage("Cancelled")
+	case KeyEnter:
+		if err := a.save(f); err != nil {
+			f.err = err.Error()
+			return
+		}
+		a.form, a.mode = nil, modeList
+		if f.sub == nil {
+			a.setMessage("✓ %s added", f.value(fieldName))
+		} else {
+			a.setMessage("✓ %s updated", f.value(fieldName))
+		}
+		a.reload()
+	default:
+		f.handleKey(k)
+	}
+}
+
+// save adds or updates the subscription from the form's values.
+func (a *App) save(f *form) error {
+	if f.sub == nil {
+		return a.svc.AddSubscription(f.value(fieldName), f.value(fieldPrice), f.value(fieldCurrency),
+			f.value(fieldCycle), f.value(fieldPaymentDate), f.value(fieldCategory))
+	}
+
+	id := f.sub.ID
+	if f.value(fieldName) == "" {
+		return errors.New("name is required")
+	}
+	if err := a.svc.UpdateSubscription(id, f.value(fieldName), f.value(fieldPrice), f.value(fieldCurrency),
+		f.value(fieldCycle), f.value(fieldPaymentDate)); err != nil {
+		return err
+	}
+	if category := f.value(fieldCategory); category != f.sub.Category {
+		if err := a.svc.SetSubscriptionCategory(id, category); err != nil {
+			return err
+		}
+	}
+	if status := f.value(fieldStatus); status != f.sub.Status {
+		if err := a.svc.SetSubscriptionStatus(id, status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reload reloads after a change. A failure replaces the message.
+func (a *App) reload() {
+	if err := a.Load(); err != nil {
+		a.setError(err)
+	}
+}
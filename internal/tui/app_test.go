@@ -0,0 +1,205 @@
+package tui
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/services"
+	"github.com/berkaycubuk/subtrack/internal/utils"
+)
+
+func setupApp(t *testing.T) (*App, *database.MemoryStore) {
+	t.Helper()
+	store := database.NewMemoryStore()
+	today := utils.Today()
+	for _, sub := range []database.Subscription{
+		{Name: "Spotify", Price: 9.99, Currency: "USD", Cycle: "monthly", PaymentDate: today.AddDays(10).Time()},
+		{Name: "Netflix", Price: 15.99, Currency: "USD", Cycle: "monthly", PaymentDate: today.AddDays(2).Time(), Category: "Streaming"},
+		{Name: "Domain", Price: 12, Currency: "EUR", Cycle: "yearly", PaymentDate: today.AddDays(200).Time()},
+	} {
+		if err := store.CreateSubscription(&sub); err != nil {
+			t.Fatalf("failed to create test subscription: %v", err)
+		}
+	}
+
+	app := New(services.NewSubscriptionService(store, nil))
+	if err := app.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	return app, store
+}
+
+// press sends keys as typed, plus the named special keys.
+func press(app *App, keys ...any) {
+	for _, k := range keys {
+		switch k := k.(type) {
+		case string:
+			for _, key := range ParseKeys([]byte(k)) {
+				app.HandleKey(key)
+			}
+		case KeyCode:
+			app.HandleKey(Key{Code: k})
+		}
+	}
+}
+
+func selectedName(app *App, s Screen) string {
+	if s.Highlight < 0 {
+		return ""
+	}
+	for _, sub := range app.subs {
+		if strings.Contains(s.Lines[s.Highlight], " "+sub.Name+" ") {
+			return sub.Name
+		}
+	}
+	return "?"
+}
+
+func TestApp_ListAndNavigation(t *testing.T) {
+	app, _ := setupApp(t)
+
+	s := app.View(100, 20)
+	if len(s.Lines) != 20 {
+		t.Fatalf("View() has %d lines, want 20", len(s.Lines))
+	}
+	for i, line := range s.Lines {
+		if n := len([]rune(line)); n != 100 {
+			t.Errorf("line %d is %d columns wide, want 100: %q", i, n, line)
+		}
+	}
+
+	text := s.String()
+	netflix, spotify, domain := strings.Index(text, "Netflix"), strings.Index(text, "Spotify"), strings.Index(text, "Domain")
+	if netflix < 0 || !(netflix < spotify && spotify < domain) {
+		t.Errorf("subscriptions not sorted by next payment:\n%s", text)
+	}
+	for _, want := range []string{"in 2d", "Streaming", "Due in 7 days: 15.99 USD · 30 days: 25.98 USD"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("View() does not show %q:\n%s", want, text)
+		}
+	}
+	if got := selectedName(app, s); got != "Netflix" {
+		t.Errorf("selected %q, want Netflix", got)
+	}
+
+	press(app, "jj")
+	if got := selectedName(app, app.View(100, 20)); got != "Domain" {
+		t.Errorf("after jj selected %q, want Domain", got)
+	}
+	press(app, KeyDown, KeyUp)
+	if got := selectedName(app, app.View(100, 20)); got != "Spotify" {
+		t.Errorf("after Down Up selected %q, want Spotify", got)
+	}
+	press(app, KeyHome)
+	if got := selectedName(app, app.View(100, 20)); got != "Netflix" {
+		t.Errorf("after Home selected %q, want Netflix", got)
+	}
+
+	// A screen too short for every row scrolls to keep the selection shown.
+	press(app, "G")
+	s = app.View(100, 9)
+	if got := selectedName(app, s); got != "Domain" || strings.Contains(s.String(), "Netflix") {
+		t.Errorf("short screen selected %q:\n%s", got, s)
+	}
+
+	press(app, "q")
+	if !app.Quit() {
+		t.Error("q did not quit")
+	}
+}
+
+func TestApp_AddEditDelete(t *testing.T) {
+	app, store := setupApp(t)
+
+	press(app, "a", "Hulu", KeyTab, "7.99", KeyTab, "USD", KeyTab, KeyRight, KeyTab, KeyTab, "TV", KeyEnter)
+	if app.mode != modeList || !strings.Contains(app.View(100, 20).String(), "✓ Hulu added") {
+		t.Fatalf("add did not finish:\n%s", app.View(100, 20))
+	}
+	subs, _ := store.GetAllSubscriptions()
+	var hulu *database.Subscription
+	for i := range subs {
+		if subs[i].Name == "Hulu" {
+			hulu = &subs[i]
+		}
+	}
+	if hulu == nil || hulu.Price != 7.99 || hulu.Cycle != "yearly" || hulu.Category != "TV" || utils.DaysUntil(hulu.PaymentDate) != 0 {
+		t.Fatalf("added subscription = %+v", hulu)
+	}
+
+	// Invalid input keeps the form open with the service's error.
+	press(app, "a", "Bad", KeyTab, "cheap", KeyEnter)
+	if app.mode != modeForm || !strings.Contains(app.View(100, 20).String(), "Error: invalid price") {
+		t.Errorf("invalid add:\n%s", app.View(100, 20))
+	}
+	press(app, KeyEsc)
+
+	// Hulu is due today, so it is first. Rename it, clear the category and
+	// pause it.
+	press(app, KeyHome, "e")
+	for range "Hulu" {
+		press(app, KeyBackspace)
+	}
+	press(app, "Hulu Plus", KeyBacktab, KeyBacktab, KeyBackspace, KeyBackspace, KeyTab, " ", KeyEnter)
+	got, _ := store.GetSubscriptionByID(hulu.ID)
+	if got.Name != "Hulu Plus" || got.Category != "" || got.Status != database.StatusPaused {
+		t.Errorf("edited subscription = %+v", got)
+	}
+
+	press(app, "d")
+	if !strings.Contains(app.View(100, 20).String(), "Delete Hulu Plus? y/n") {
+		t.Fatalf("delete did not ask for confirmation:\n%s", app.View(100, 20))
+	}
+	press(app, "n")
+	if _, err := store.GetSubscriptionByID(hulu.ID); err != nil {
+		t.Fatal("n deleted the subscription")
+	}
+	press(app, "d", "y")
+	if _, err := store.GetSubscriptionByID(hulu.ID); err == nil {
+		t.Error("d y did not delete the subscription")
+	}
+	if got := selectedName(app, app.View(100, 20)); got != "Netflix" {
+		t.Errorf("after delete selected %q, want Netflix", got)
+	}
+}
+
+func TestApp_MarkPaidAndStatus(t *testing.T) {
+	app, store := setupApp(t)
+
+	netflix := app.subs[0]
+	press(app, "p")
+	got, _ := store.GetSubscriptionByID(netflix.ID)
+	want := utils.DateOf(netflix.PaymentDate).AddDate(0, 1, 0)
+	if utils.DateOf(got.PaymentDate) != want {
+		t.Errorf("after p payment date = %s, want %s", utils.DateOf(got.PaymentDate), want)
+	}
+	// Netflix moved down the list; the selection follows it.
+	if s := app.View(100, 20); selectedName(app, s) != "Netflix" || !strings.Contains(s.String(), "✓ Netflix paid") {
+		t.Errorf("after p:\n%s", s)
+	}
+
+	var statuses []string
+	for range database.Statuses {
+		press(app, "s")
+		got, _ := store.GetSubscriptionByID(netflix.ID)
+		statuses = append(statuses, got.Status)
+	}
+	if want := []string{"paused", "cancelled", "active"}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("s cycled through %v, want %v", statuses, want)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	got := ParseKeys([]byte("a\x1b[A\x1b[6~\x1bOB\r\x7f\x1b[Z\x03é"))
+	want := []Key{
+		{Code: KeyRune, Rune: 'a'}, {Code: KeyUp}, {Code: KeyPgDn}, {Code: KeyDown},
+		{Code: KeyEnter}, {Code: KeyBackspace}, {Code: KeyBacktab}, {Code: KeyCtrlC}, {Code: KeyRune, Rune: 'é'},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseKeys() = %v, want %v", got, want)
+	}
+	if got := ParseKeys([]byte("\x1b")); !reflect.DeepEqual(got, []Key{{Code: KeyEsc}}) {
+		t.Errorf("ParseKeys(ESC) = %v", got)
+	}
+}
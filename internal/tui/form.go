@@ -0,0 +1,106 @@
+package tui
+
+import (
+	"slices"
+	"strconv"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/utils"
+)
+
+// Form fields, in order.
+const (
+	fieldName = iota
+	fieldPrice
+	fieldCurrency
+	fieldCycle
+	fieldPaymentDate
+	fieldCategory
+	fieldStatus
+)
+
+type formField struct {
+	label string
+	value []rune
+	// choices, if set, are the only values; Left, Right and Space cycle
+	// through them.
+	choices []string
+}
+
+// form adds a subscription, or edits sub.
+type form struct {
+	sub    *database.Subscription
+	title  string
+	fields []formField
+	focus  int
+	err    string
+}
+
+func newAddForm() *form {
+	return &form{
+		title: "Add subscription",
+		fields: []formField{
+			{label: "Name"},
+			{label: "Price"},
+			{label: "Currency"},
+			{label: "Cycle", value: []rune("monthly"), choices: []string{"monthly", "yearly"}},
+			{label: "Payment date", value: []rune(utils.FormatDate(utils.Today().Time()))},
+			{label: "Category"},
+		},
+	}
+}
+
+func newEditForm(sub *database.Subscription) *form {
+	edited := *sub
+	return &form{
+		sub:   &edited,
+		title: "Edit " + sub.Name,
+		fields: []formField{
+			{label: "Name", value: []rune(sub.Name)},
+			{label: "Price", value: []rune(strconv.FormatFloat(sub.Price, 'f', 2, 64))},
+			{label: "Currency", value: []rune(sub.Currency)},
+			{label: "Cycle", value: []rune(sub.Cycle), choices: []string{"monthly", "yearly"}},
+			{label: "Payment date", value: []rune(utils.FormatDate(sub.PaymentDate))},
+			{label: "Category", value: []rune(sub.Category)},
+			{label: "Status", value: []rune(sub.Status), choices: database.Statuses},
+		},
+	}
+}
+
+func (f *form) value(field int) string {
+	return string(f.fields[field].value)
+}
+
+func (f *form) handleKey(k Key) {
+	field := &f.fields[f.focus]
+	switch k.Code {
+	case KeyTab, KeyDown:
+		f.focus = (f.focus + 1) % len(f.fields)
+	case KeyBacktab, KeyUp:
+		f.focus = (f.focus + len(f.fields) - 1) % len(f.fields)
+	case KeyLeft:
+		field.cycle(-1)
+	case KeyRight:
+		field.cycle(1)
+	case KeyBackspace:
+		if field.choices == nil && len(field.value) > 0 {
+			field.value = field.value[:len(field.value)-1]
+		}
+	case KeyRune:
+		switch {
+		case field.choices != nil && k.Rune == ' ':
+			field.cycle(1)
+		case field.choices == nil:
+			field.value = append(field.value, k.Rune)
+		}
+	}
+}
+
+func (field *formField) cycle(n int) {
+	if field.choices == nil {
+		return
+	}
+	i := slices.Index(field.choices, string(field.value))
+	i = (i + n + len(field.choices)) % len(field.choices)
+	field.value = []rune(field.choices[i])
+}
@@ -0,0 +1,90 @@
+package tui
+
+import "unicode/utf8"
+
+type KeyCode int
+
+const (
+	KeyRune KeyCode = iota
+	KeyEnter
+	KeyEsc
+	KeyBackspace
+	KeyDelete
+	KeyTab
+	KeyBacktab
+	KeyUp
+	KeyDown
+	KeyLeft
+	KeyRight
+	KeyHome
+	KeyEnd
+	KeyPgUp
+	KeyPgDn
+	KeyCtrlC
+)
+
+// Key is one key press. Rune is set for KeyRune.
+type Key struct {
+	Code KeyCode
+	Rune rune
+}
+
+// escapes are the terminal sequences for special keys, after the ESC byte.
+var escapes = map[string]KeyCode{
+	"[A": KeyUp, "[B": KeyDown, "[C": KeyRight, "[D": KeyLeft,
+	"OA": KeyUp, "OB": KeyDown, "OC": KeyRight, "OD": KeyLeft,
+	"[H": KeyHome, "[F": KeyEnd, "OH": KeyHome, "OF": KeyEnd,
+	"[1~": KeyHome, "[4~": KeyEnd, "[7~": KeyHome, "[8~": KeyEnd,
+	"[3~": KeyDelete, "[5~": KeyPgUp, "[6~": KeyPgDn, "[Z": KeyBacktab,
+}
+
+// ParseKeys decodes what one read from a terminal in raw mode returned. A
+// lone ESC is the Escape key; unknown escape sequences are dropped.
+func ParseKeys(b []byte) []Key {
+	var keys []Key
+	for len(b) > 0 {
+		switch c := b[0]; {
+		case c == 0x1b:
+			if len(b) == 1 {
+				return append(keys, Key{Code: KeyEsc})
+			}
+			n := escapeLen(b[1:])
+			if code, ok := escapes[string(b[1:1+n])]; ok {
+				keys = append(keys, Key{Code: code})
+			}
+			b = b[1+n:]
+			continue
+		case c == 0x03:
+			keys = append(keys, Key{Code: KeyCtrlC})
+		case c == '\r' || c == '\n':
+			keys = append(keys, Key{Code: KeyEnter})
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, Key{Code: KeyBackspace})
+		case c == '\t':
+			keys = append(keys, Key{Code: KeyTab})
+		case c < 0x20:
+			// Other control keys do nothing.
+		default:
+			r, size := utf8.DecodeRune(b)
+			keys = append(keys, Key{Code: KeyRune, Rune: r})
+			b = b[size:]
+			continue
+		}
+		b = b[1:]
+	}
+	return keys
+}
+
+// escapeLen is the length of the escape sequence at the start of b, which
+// follows an ESC: "[" or "O", parameters, and a final letter or "~".
+func escapeLen(b []byte) int {
+	if b[0] != '[' && b[0] != 'O' {
+		return 0
+	}
+	for i := 1; i < len(b); i++ {
+		if c := b[i]; c >= 0x40 && c <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(b)
+}
@@ -0,0 +1,102 @@
+package tui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// resizePoll is how often Run checks for a new terminal size while no keys
+// arrive.
+const resizePoll = 250 * time.Millisecond
+
+// Run shows app full screen on the terminal in and out until the user
+// quits, restoring the terminal afterwards.
+func Run(app *App, in, out *os.File) error {
+	if !term.IsTerminal(int(in.Fd())) || !term.IsTerminal(int(out.Fd())) {
+		return errors.New("the TUI needs an interactive terminal")
+	}
+	if err := app.Load(); err != nil {
+		return err
+	}
+
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(in.Fd()), state)
+
+	w := bufio.NewWriter(out)
+	// Switch to the alternate screen and hide the cursor, and back on exit.
+	w.WriteString("\x1b[?1049h\x1b[?25l")
+	defer func() {
+		w.WriteString("\x1b[?25h\x1b[?1049l")
+		w.Flush()
+	}()
+
+	keys := make(chan []Key)
+	readErr := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := in.Read(buf)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			keys <- ParseKeys(buf[:n])
+		}
+	}()
+
+	ticker := time.NewTicker(resizePoll)
+	defer ticker.Stop()
+
+	var lastWidth, lastHeight int
+	redraw := true
+	for !app.Quit() {
+		width, height, err := term.GetSize(int(out.Fd()))
+		if err != nil {
+			return err
+		}
+		if redraw || width != lastWidth || height != lastHeight {
+			draw(w, app.View(width, height))
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			lastWidth, lastHeight = width, height
+		}
+
+		redraw = false
+		select {
+		case ks := <-keys:
+			for _, k := range ks {
+				app.HandleKey(k)
+			}
+			redraw = true
+		case err := <-readErr:
+			return fmt.Errorf("failed to read from terminal: %w", err)
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// draw writes a frame from the top left corner, highlighting its highlighted
+// row in reverse video.
+func draw(w *bufio.Writer, s Screen) {
+	w.WriteString("\x1b[H")
+	for i, line := range s.Lines {
+		if i > 0 {
+			w.WriteString("\r\n")
+		}
+		if i == s.Highlight {
+			w.WriteString("\x1b[7m" + line + "\x1b[0m")
+		} else {
+			w.WriteString(line)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package tui
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/utils"
+)
+
+// Screen is one rendered frame: a line per terminal row, each exactly the
+// screen's width, and the row shown highlighted, or -1.
+type Screen struct {
+	Lines     []string
+	Highlight int
+}
+
+// String is the frame as text with trailing spaces removed, for tests.
+func (s Screen) String() string {
+	var b strings.Builder
+	for _, line := range s.Lines {
+		b.WriteString(strings.TrimRight(line, " "))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+type column struct {
+	title string
+	width int
+	right bool
+}
+
+// listColumns are the subscription table's columns; the name column takes
+// the width the others leave.
+var listColumns = []column{
+	{title: "ID", width: 4, right: true},
+	{title: "Name"},
+	{title: "Price", width: 10, right: true},
+	{title: "Cur", width: 4},
+	{title: "Cycle", width: 7},
+	{title: "Next", width: 10},
+	{title: "Due", width: 8, right: true},
+	{title: "Category", width: 12},
+	{title: "Status", width: 9},
+}
+
+const minNameWidth = 8
+
+// View renders the current state at the given size.
+func (a *App) View(width, height int) Screen {
+	width, height = max(width, 40), max(height, 8)
+	s := Screen{Highlight: -1}
+	add := func(line string) int {
+		s.Lines = append(s.Lines, fit(line, width))
+		return len(s.Lines) - 1
+	}
+
+	add(fmt.Sprintf(" SubTrack · %d subscriptions · %s", len(a.subs), utils.FormatDate(utils.Today().Time())))
+	add("")
+
+	// The body sits between the header and the four footer rows.
+	body := height - len(s.Lines) - 4
+	switch a.mode {
+	case modeForm:
+		for _, line := range a.form.lines() {
+			add(line)
+		}
+	case modeHelp:
+		for _, line := range helpLines {
+			add(line)
+		}
+	default:
+		s.Highlight = a.listLines(width, body, add)
+	}
+
+	for len(s.Lines) < height-4 {
+		add("")
+	}
+	s.Lines = s.Lines[:height-4]
+	if s.Highlight >= len(s.Lines) {
+		s.Highlight = -1
+	}
+
+	add("")
+	add(" " + a.totalsLine())
+	add(" " + a.statusLine())
+	add(" " + a.keysLine())
+	return s
+}
+
+// listLines adds the subscription table with at most rows lines and returns
+// the row of the selected subscription. add returns the row it added.
+func (a *App) listLines(width, rows int, add func(string) int) int {
+	if len(a.subs) == 0 {
+		add(" No subscriptions yet. Press a to add one.")
+		return -1
+	}
+
+	widths := make([]int, len(listColumns))
+	fixed := 0
+	for i, col := range listColumns {
+		widths[i] = col.width
+		fixed += col.width + 1
+	}
+	widths[1] = max(width-fixed, minNameWidth)
+
+	row := func(cells ...string) string {
+		parts := make([]string, len(cells))
+		for i, cell := range cells {
+			if listColumns[i].right {
+				parts[i] = fitLeft(cell, widths[i])
+			} else {
+				parts[i] = fit(cell, widths[i])
+			}
+		}
+		return " " + strings.Join(parts, " ")
+	}
+
+	titles := make([]string, len(listColumns))
+	for i, col := range listColumns {
+		titles[i] = col.title
+	}
+	add(row(titles...))
+
+	a.rows = max(rows-1, 1)
+	if a.selected < a.offset {
+		a.offset = a.selected
+	}
+	if a.selected >= a.offset+a.rows {
+		a.offset = a.selected - a.rows + 1
+	}
+	a.offset = max(0, min(a.offset, len(a.subs)-a.rows))
+
+	highlight := -1
+	end := min(a.offset+a.rows, len(a.subs))
+	for i := a.offset; i < end; i++ {
+		sub := a.subs[i]
+		line := add(row(strconv.FormatUint(uint64(sub.ID), 10), sub.Name, strconv.FormatFloat(sub.Price, 'f', 2, 64),
+			sub.Currency, sub.Cycle, utils.FormatDate(sub.PaymentDate), dueText(sub), sub.Category, sub.Status))
+		if i == a.selected {
+			highlight = line
+		}
+	}
+	return highlight
+}
+
+func dueText(sub database.Subscription) string {
+	switch days := utils.DaysUntil(sub.PaymentDate); {
+	case days == 0:
+		return "today"
+	case days < 0:
+		return fmt.Sprintf("%dd late", -days)
+	default:
+		return fmt.Sprintf("in %dd", days)
+	}
+}
+
+// totalsLine sums the active subscriptions due within the next 7 and 30
+// days, per currency.
+func (a *App) totalsLine() string {
+	week, month := map[string]float64{}, map[string]float64{}
+	for _, sub := range a.subs {
+		if sub.Status != database.StatusActive {
+			continue
+		}
+		days := utils.DaysUntil(sub.PaymentDate)
+		if days >= 0 && days < 7 {
+			week[sub.Currency] += sub.Price
+		}
+		if days >= 0 && days < 30 {
+			month[sub.Currency] += sub.Price
+		}
+	}
+	return "Due in 7 days: " + formatTotals(week) + " · 30 days: " + formatTotals(month)
+}
+
+func formatTotals(totals map[string]float64) string {
+	if len(totals) == 0 {
+		return "nothing"
+	}
+	var parts []string
+	for _, currency := range slices.Sorted(maps.Keys(totals)) {
+		parts = append(parts, strconv.FormatFloat(totals[currency], 'f', 2, 64)+" "+currency)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (a *App) statusLine() string {
+	switch {
+	case a.mode == modeConfirmDelete:
+		return fmt.Sprintf("Delete %s? y/n", a.current().Name)
+	case a.mode == modeForm && a.form.err != "":
+		return "Error: " + a.form.err
+	case a.isError && a.message != "":
+		return "Error: " + a.message
+	default:
+		return a.message
+	}
+}
+
+func (a *App) keysLine() string {
+	switch a.mode {
+	case modeForm:
+		return "Tab/↓ next · Shift-Tab/↑ previous · ←/→ change choice · Enter save · Esc cancel"
+	case modeHelp:
+		return "Press any key to go back"
+	default:
+		return "↑↓ move · a add · e edit · p paid · s status · d delete · r reload · ? help · q quit"
+	}
+}
+
+var helpLines = []string{
+	" Keys",
+	"",
+	"   ↑/k ↓/j      move the selection; PgUp/PgDn, Home/g and End/G jump",
+	"   a            add a subscription",
+	"   e, Enter     edit the selected subscription",
+	"   p            mark the current payment paid, moving the date on one cycle",
+	"   s            change status: active → paused → cancelled → active",
+	"   d            delete the selected subscription, after confirming with y",
+	"   r            reload from the database",
+	"   q, Ctrl-C    quit",
+	"",
+	" Subscriptions are sorted by next payment. Totals count active subscriptions only.",
+}
+
+func (f *form) lines() []string {
+	lines := []string{" " + f.title, ""}
+	for i, field := range f.fields {
+		marker := " "
+		value := string(field.value)
+		if i == f.focus {
+			marker = ">"
+			if field.choices == nil {
+				value += "_"
+			}
+		}
+		if field.choices != nil {
+			value = "‹ " + value + " ›"
+		}
+		lines = append(lines, fmt.Sprintf(" %s %-13s %s", marker, field.label, value))
+	}
+	return append(lines, "", "   Payment dates use DD-MM-YYYY.")
+}
+
+// fit pads or truncates s to exactly width columns, counting one column per
+// rune.
+func fit(s string, width int) string {
+	n := utf8.RuneCountInString(s)
+	if n <= width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	if width <= 1 {
+		return string([]rune(s)[:width])
+	}
+	return string([]rune(s)[:width-1]) + "…"
+}
+
+// fitLeft is fit, aligned right.
+func fitLeft(s string, width int) string {
+	n := utf8.RuneCountInString(s)
+	if n >= width {
+		return fit(s, width)
+	}
+	return strings.Repeat(" ", width-n) + s
+}
@@ -0,0 +1,73 @@
+package utils
+
+import "time"
+
+// Date is a calendar date with no time of day. Payment dates are compared
+// as Dates in the configured location so that "days until" does not depend
+// on the time of day a check runs or on the server's zone.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// nowFunc is replaced in tests to pin the current time.
+var nowFunc = time.Now
+
+// DateOf returns the calendar date of t in the configured location.
+func DateOf(t time.Time) Date {
+	y, m, d := t.In(location).Date()
+	return Date{Year: y, Month: m, Day: d}
+}
+
+// Today returns the current date in the configured location.
+func Today() Date {
+	return DateOf(nowFunc())
+}
+
+// In returns midnight at the start of d in loc.
+func (d Date) In(loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, loc)
+}
+
+// Time returns midnight at the start of d in the configured location.
+func (d Date) Time() time.Time {
+	return d.In(location)
+}
+
+// AddDays returns d shifted by n days.
+func (d Date) AddDays(n int) Date {
+	return d.AddDate(0, 0, n)
+}
+
+// AddDate returns d shifted like time.Time.AddDate, so 31 January plus one
+// month normalizes to early March.
+func (d Date) AddDate(years, months, days int) Date {
+	y, m, dd := d.In(time.UTC).AddDate(years, months, days).Date()
+	return Date{Year: y, Month: m, Day: dd}
+}
+
+// AddMonths returns d shifted by n months. A day the target month lacks
+// becomes its last day, so 31 January plus one month is 28 February.
+func (d Date) AddMonths(n int) Date {
+	first := Date{Year: d.Year, Month: d.Month, Day: 1}.AddDate(0, n, 0)
+	last := first.AddDate(0, 1, -1).Day
+	return Date{Year: first.Year, Month: first.Month, Day: min(d.Day, last)}
+}
+
+// Sub returns the number of days from u to d.
+func (d Date) Sub(u Date) int {
+	return int(d.In(time.UTC).Sub(u.In(time.UTC)).Hours() / 24)
+}
+
+func (d Date) Before(u Date) bool {
+	return d.Sub(u) < 0
+}
+
+func (d Date) After(u Date) bool {
+	return d.Sub(u) > 0
+}
+
+func (d Date) String() string {
+	return d.In(time.UTC).Format(dateFormat)
+}
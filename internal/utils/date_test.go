@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDateOf(t *testing.T) {
+	tokyo := mustLoadLocation(t, "Asia/Tokyo")
+	losAngeles := mustLoadLocation(t, "America/Los_Angeles")
+
+	instant := time.Date(2025, time.December, 31, 20, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		loc  *time.Location
+		want Date
+	}{
+		{name: "utc", loc: time.UTC, want: Date{2025, time.December, 31}},
+		{name: "ahead of utc", loc: tokyo, want: Date{2026, time.January, 1}},
+		{name: "behind utc", loc: losAngeles, want: Date{2025, time.December, 31}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pinNow(t, instant, tt.loc)
+			if got := DateOf(instant); got != tt.want {
+				t.Errorf("DateOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDate_Arithmetic(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Date
+		want Date
+	}{
+		{
+			name: "add days across month",
+			got:  Date{2025, time.January, 30}.AddDays(3),
+			want: Date{2025, time.February, 2},
+		},
+		{
+			name: "add days across berlin spring forward",
+			got:  Date{2025, time.March, 29}.AddDays(2),
+			want: Date{2025, time.March, 31},
+		},
+		{
+			name: "add month normalizes overflow",
+			got:  Date{2025, time.January, 31}.AddDate(0, 1, 0),
+			want: Date{2025, time.March, 3},
+		},
+		{
+			name: "add year from leap day",
+			got:  Date{2024, time.February, 29}.AddDate(1, 0, 0),
+			want: Date{2025, time.March, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDate_Sub(t *testing.T) {
+	tests := []struct {
+		name string
+		d, u Date
+		want int
+	}{
+		{name: "same day", d: Date{2025, time.March, 30}, u: Date{2025, time.March, 30}, want: 0},
+		{name: "over spring forward", d: Date{2025, time.March, 31}, u: Date{2025, time.March, 29}, want: 2},
+		{name: "over fall back", d: Date{2025, time.October, 27}, u: Date{2025, time.October, 25}, want: 2},
+		{name: "negative", d: Date{2024, time.December, 31}, u: Date{2025, time.January, 1}, want: -1},
+		{name: "leap year", d: Date{2025, time.January, 1}, u: Date{2024, time.January, 1}, want: 366},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.d.Sub(tt.u); got != tt.want {
+				t.Errorf("Sub() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDate_Time(t *testing.T) {
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	pinNow(t, time.Now(), berlin)
+
+	d := Date{2025, time.March, 30}
+	got := d.Time()
+	want := time.Date(2025, time.March, 30, 0, 0, 0, 0, berlin)
+	if !got.Equal(want) {
+		t.Errorf("Time() = %v, want %v", got, want)
+	}
+	if DateOf(got.UTC()) != d {
+		t.Errorf("DateOf(Time()) = %v, want %v", DateOf(got.UTC()), d)
+	}
+	if d.String() != "30-03-2025" {
+		t.Errorf("String() = %v, want 30-03-2025", d.String())
+	}
+}
@@ -2,48 +2,74 @@ package utils
 
 import (
 	"fmt"
-	"math"
 	"time"
 )
 
 const dateFormat = "02-01-2006"
 
+var location = time.UTC
+
+// SetLocation sets the time zone used for parsing, formatting and payment
+// date arithmetic. A nil location is ignored.
+func SetLocation(loc *time.Location) {
+	if loc != nil {
+		location = loc
+	}
+}
+
+// Location returns the time zone configured with SetLocation.
+func Location() *time.Location {
+	return location
+}
+
+// ParseDate parses a DD-MM-YYYY string as midnight in the configured location.
 func ParseDate(dateStr string) (time.Time, error) {
-	return time.Parse(dateFormat, dateStr)
+	return time.ParseInLocation(dateFormat, dateStr, location)
 }
 
 func FormatDate(t time.Time) string {
-	return t.Format(dateFormat)
+	return DateOf(t).String()
 }
 
+// DaysUntil returns the number of calendar days from today to the date of t,
+// negative when t falls on an earlier day.
 func DaysUntil(t time.Time) int {
-	now := time.Now()
-	duration := t.Sub(now)
-	hours := duration.Hours()
-	if hours < 0 {
-		return -1
-	}
-	days := int(math.Round(hours / 24))
-	return days
+	return DateOf(t).Sub(Today())
+}
+
+// UpdatePaymentDate advances paymentDate by one cycle and returns midnight of
+// the resulting day in the configured location.
+func UpdatePaymentDate(paymentDate time.Time, day int, cycle string) (time.Time, error) {
+	return AdvancePaymentDate(paymentDate, day, cycle, 1)
 }
 
-func UpdatePaymentDate(paymentDate time.Time, cycle string) (time.Time, error) {
+// AdvancePaymentDate advances paymentDate by n cycles and moves it to day of
+// the resulting month, or to the month's last day when it is shorter. Passing
+// the day the payment is anchored to, rather than the day of a date already
+// moved to the end of a short month, keeps a payment on the 31st on the last
+// day of every month, as the calendar feed's recurrence rule does.
+func AdvancePaymentDate(paymentDate time.Time, day int, cycle string, n int) (time.Time, error) {
+	date := DateOf(paymentDate)
+	date.Day = day
 	switch cycle {
 	case "monthly":
-		return paymentDate.AddDate(0, 1, 0), nil
+		return date.AddMonths(n).Time(), nil
 	case "yearly":
-		return paymentDate.AddDate(1, 0, 0), nil
+		return date.AddMonths(12 * n).Time(), nil
 	default:
 		return time.Time{}, fmt.Errorf("invalid cycle: %s", cycle)
 	}
 }
 
-func CalculateNextPaymentDate(lastPaymentDate time.Time, cycle string) (time.Time, error) {
+// CalculateNextPaymentDate advances lastPaymentDate by whole cycles until it
+// falls on today or later.
+func CalculateNextPaymentDate(lastPaymentDate time.Time, day int, cycle string) (time.Time, error) {
 	nextDate := lastPaymentDate
+	today := Today()
 
-	for nextDate.Before(time.Now()) {
+	for n := 1; DateOf(nextDate).Before(today); n++ {
 		var err error
-		nextDate, err = UpdatePaymentDate(nextDate, cycle)
+		nextDate, err = AdvancePaymentDate(lastPaymentDate, day, cycle, n)
 		if err != nil {
 			return time.Time{}, err
 		}
@@ -87,7 +87,8 @@ func TestFormatDate(t *testing.T) {
 }
 
 func TestDaysUntil(t *testing.T) {
-	now := time.Now()
+	now := time.Date(2025, time.June, 10, 23, 0, 0, 0, time.UTC)
+	pinNow(t, now, time.UTC)
 
 	tests := []struct {
 		name string
@@ -95,13 +96,18 @@ func TestDaysUntil(t *testing.T) {
 		want int
 	}{
 		{
-			name: "future date - same day",
-			t:    now.Add(2 * time.Hour),
+			name: "same day - later",
+			t:    now.Add(30 * time.Minute),
+			want: 0,
+		},
+		{
+			name: "same day - earlier",
+			t:    now.Add(-2 * time.Hour),
 			want: 0,
 		},
 		{
-			name: "future date - 1 day",
-			t:    now.Add(24 * time.Hour),
+			name: "next day - one hour away",
+			t:    now.Add(1 * time.Hour),
 			want: 1,
 		},
 		{
@@ -115,9 +121,9 @@ func TestDaysUntil(t *testing.T) {
 			want: -1,
 		},
 		{
-			name: "past date - few hours",
-			t:    now.Add(-2 * time.Hour),
-			want: -1,
+			name: "past date - 3 days",
+			t:    now.Add(-3 * 24 * time.Hour),
+			want: -3,
 		},
 	}
 
@@ -131,12 +137,92 @@ func TestDaysUntil(t *testing.T) {
 	}
 }
 
+func TestDaysUntil_DST(t *testing.T) {
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	newYork := mustLoadLocation(t, "America/New_York")
+
+	tests := []struct {
+		name string
+		loc  *time.Location
+		now  time.Time
+		date string
+		want int
+	}{
+		{
+			name: "berlin spring forward - 23 hour day",
+			loc:  berlin,
+			now:  time.Date(2025, time.March, 29, 23, 30, 0, 0, berlin),
+			date: "31-03-2025",
+			want: 2,
+		},
+		{
+			name: "berlin spring forward - late evening before",
+			loc:  berlin,
+			now:  time.Date(2025, time.March, 29, 23, 59, 0, 0, berlin),
+			date: "30-03-2025",
+			want: 1,
+		},
+		{
+			name: "berlin fall back - 25 hour day",
+			loc:  berlin,
+			now:  time.Date(2025, time.October, 26, 0, 30, 0, 0, berlin),
+			date: "27-10-2025",
+			want: 1,
+		},
+		{
+			name: "berlin fall back - same day",
+			loc:  berlin,
+			now:  time.Date(2025, time.October, 26, 23, 30, 0, 0, berlin),
+			date: "26-10-2025",
+			want: 0,
+		},
+		{
+			name: "new york spring forward - week ahead",
+			loc:  newYork,
+			now:  time.Date(2025, time.March, 8, 21, 0, 0, 0, newYork),
+			date: "15-03-2025",
+			want: 7,
+		},
+		{
+			name: "new york fall back - past date",
+			loc:  newYork,
+			now:  time.Date(2025, time.November, 2, 1, 30, 0, 0, newYork),
+			date: "01-11-2025",
+			want: -1,
+		},
+		{
+			name: "utc evening is next day in new york zone",
+			loc:  newYork,
+			now:  time.Date(2025, time.November, 3, 2, 0, 0, 0, time.UTC),
+			date: "03-11-2025",
+			want: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pinNow(t, tt.now, tt.loc)
+
+			paymentDate, err := ParseDate(tt.date)
+			if err != nil {
+				t.Fatalf("ParseDate() error = %v", err)
+			}
+
+			// Payment dates come back from the database in UTC.
+			if got := DaysUntil(paymentDate.UTC()); got != tt.want {
+				t.Errorf("DaysUntil() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestUpdatePaymentDate(t *testing.T) {
 	baseDate := time.Date(2025, time.February, 15, 0, 0, 0, 0, time.UTC)
 
 	tests := []struct {
 		name    string
 		date    time.Time
+		day     int
 		cycle   string
 		want    time.Time
 		wantErr bool
@@ -153,6 +239,37 @@ func TestUpdatePaymentDate(t *testing.T) {
 			cycle: "yearly",
 			want:  time.Date(2026, time.February, 15, 0, 0, 0, 0, time.UTC),
 		},
+		{
+			name:  "monthly from the 31st",
+			date:  time.Date(2025, time.January, 31, 0, 0, 0, 0, time.UTC),
+			cycle: "monthly",
+			want:  time.Date(2025, time.February, 28, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "monthly from the 31st in a leap year",
+			date:  time.Date(2024, time.January, 31, 0, 0, 0, 0, time.UTC),
+			cycle: "monthly",
+			want:  time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "monthly from the 30th",
+			date:  time.Date(2025, time.November, 30, 0, 0, 0, 0, time.UTC),
+			cycle: "monthly",
+			want:  time.Date(2025, time.December, 30, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "yearly from 29 February",
+			date:  time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC),
+			cycle: "yearly",
+			want:  time.Date(2025, time.February, 28, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "monthly from the 28th of February paid on the 31st",
+			date:  time.Date(2025, time.February, 28, 0, 0, 0, 0, time.UTC),
+			day:   31,
+			cycle: "monthly",
+			want:  time.Date(2025, time.March, 31, 0, 0, 0, 0, time.UTC),
+		},
 		{
 			name:    "invalid cycle",
 			date:    baseDate,
@@ -169,7 +286,11 @@ func TestUpdatePaymentDate(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := UpdatePaymentDate(tt.date, tt.cycle)
+			day := tt.day
+			if day == 0 {
+				day = tt.date.Day()
+			}
+			got, err := UpdatePaymentDate(tt.date, day, tt.cycle)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("UpdatePaymentDate() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -181,8 +302,36 @@ func TestUpdatePaymentDate(t *testing.T) {
 	}
 }
 
+func TestAdvancePaymentDate(t *testing.T) {
+	tests := []struct {
+		date  time.Time
+		cycle string
+		want  []string
+	}{
+		// The 31st returns after short months rather than drifting.
+		{time.Date(2025, time.January, 31, 0, 0, 0, 0, time.UTC), "monthly", []string{"28-02-2025", "31-03-2025", "30-04-2025", "31-05-2025"}},
+		{time.Date(2025, time.December, 30, 0, 0, 0, 0, time.UTC), "monthly", []string{"30-01-2026", "28-02-2026", "30-03-2026"}},
+		{time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC), "yearly", []string{"28-02-2025", "28-02-2026", "28-02-2027", "29-02-2028"}},
+	}
+	for _, tt := range tests {
+		for i, want := range tt.want {
+			got, err := AdvancePaymentDate(tt.date, tt.date.Day(), tt.cycle, i+1)
+			if err != nil {
+				t.Fatalf("AdvancePaymentDate() error = %v", err)
+			}
+			if FormatDate(got) != want {
+				t.Errorf("AdvancePaymentDate(%s, %s, %d) = %s, want %s", FormatDate(tt.date), tt.cycle, i+1, FormatDate(got), want)
+			}
+		}
+	}
+	if _, err := AdvancePaymentDate(time.Now(), 1, "weekly", 1); err == nil {
+		t.Error("AdvancePaymentDate() with an invalid cycle succeeded")
+	}
+}
+
 func TestCalculateNextPaymentDate(t *testing.T) {
 	now := time.Now()
+	today := Today()
 
 	tests := []struct {
 		name    string
@@ -215,16 +364,78 @@ func TestCalculateNextPaymentDate(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := CalculateNextPaymentDate(tt.date, tt.cycle)
+			got, err := CalculateNextPaymentDate(tt.date, tt.date.Day(), tt.cycle)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CalculateNextPaymentDate() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if !tt.wantErr {
-				if got.Before(now) {
-					t.Errorf("CalculateNextPaymentDate() = %v, should be after now", got)
+				if DateOf(got).Before(today) {
+					t.Errorf("CalculateNextPaymentDate() = %v, should be today or later", got)
 				}
 			}
 		})
 	}
 }
+
+func TestCalculateNextPaymentDate_EndOfMonth(t *testing.T) {
+	pinNow(t, time.Date(2025, time.April, 15, 12, 0, 0, 0, time.UTC), time.UTC)
+
+	got, err := CalculateNextPaymentDate(time.Date(2025, time.January, 31, 0, 0, 0, 0, time.UTC), 31, "monthly")
+	if err != nil {
+		t.Fatalf("CalculateNextPaymentDate() error = %v", err)
+	}
+	if want := "30-04-2025"; FormatDate(got) != want {
+		t.Errorf("CalculateNextPaymentDate() = %s, want %s", FormatDate(got), want)
+	}
+
+	// A date already moved to the end of February returns to the 31st.
+	got, err = CalculateNextPaymentDate(time.Date(2025, time.February, 28, 0, 0, 0, 0, time.UTC), 31, "monthly")
+	if err != nil {
+		t.Fatalf("CalculateNextPaymentDate() error = %v", err)
+	}
+	if want := "30-04-2025"; FormatDate(got) != want {
+		t.Errorf("CalculateNextPaymentDate() = %s, want %s", FormatDate(got), want)
+	}
+}
+
+func TestParseDate_Location(t *testing.T) {
+	istanbul := mustLoadLocation(t, "Europe/Istanbul")
+
+	pinNow(t, time.Now(), istanbul)
+
+	got, err := ParseDate("15-02-2025")
+	if err != nil {
+		t.Fatalf("ParseDate() error = %v", err)
+	}
+
+	want := time.Date(2025, time.February, 15, 0, 0, 0, 0, istanbul)
+	if !got.Equal(want) {
+		t.Errorf("ParseDate() = %v, want %v", got, want)
+	}
+
+	if s := FormatDate(got.UTC()); s != "15-02-2025" {
+		t.Errorf("FormatDate() = %v, want 15-02-2025", s)
+	}
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("failed to load location %s: %v", name, err)
+	}
+	return loc
+}
+
+// pinNow fixes the current time and location for the duration of the test.
+func pinNow(t *testing.T, now time.Time, loc *time.Location) {
+	t.Helper()
+	prevNow, prevLoc := nowFunc, location
+	nowFunc = func() time.Time { return now }
+	SetLocation(loc)
+	t.Cleanup(func() {
+		nowFunc = prevNow
+		location = prevLoc
+	})
+}
@@ -0,0 +1,248 @@
+package web
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/berkaycubuk/subtrack/internal/api"
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/services"
+)
+
+// maxAPIBody caps JSON request bodies; imports and restores take files and
+// use maxImportSize.
+const maxAPIBody = 1 << 20
+
+func (s *Server) registerAPI(mux *http.ServeMux) {
+	mux.HandleFunc("GET "+api.Prefix+"/health", s.requireToken(s.handleAPIHealth))
+	mux.HandleFunc("GET "+api.Prefix+"/subscriptions", s.requireToken(s.handleAPIList))
+	mux.HandleFunc("POST "+api.Prefix+"/subscriptions", s.requireToken(s.handleAPICreate))
+	mux.HandleFunc("GET "+api.Prefix+"/subscriptions/{id}", s.requireToken(s.handleAPIGet))
+	mux.HandleFunc("PATCH "+api.Prefix+"/subscriptions/{id}", s.requireToken(s.handleAPIUpdate))
+	mux.HandleFunc("DELETE "+api.Prefix+"/subscriptions/{id}", s.requireToken(s.handleAPIDelete))
+	mux.HandleFunc("POST "+api.Prefix+"/import", s.requireToken(s.handleAPIImport))
+	mux.HandleFunc("GET "+api.Prefix+"/export.csv", s.requireToken(s.handleExportCSV))
+	mux.HandleFunc("GET "+api.Prefix+"/export.ics", s.requireToken(s.handleAPIExportICS))
+	mux.HandleFunc("GET "+api.Prefix+"/backup", s.requireToken(s.handleBackup))
+	mux.HandleFunc("POST "+api.Prefix+"/restore", s.requireToken(s.handleAPIRestore))
+}
+
+// requireToken admits requests carrying the API token as a bearer token.
+// Without a configured token the API is off.
+func (s *Server) requireToken(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.apiToken == "" {
+			writeAPIError(w, http.StatusForbidden, errors.New("the API is disabled; set API_TOKEN on the server to enable it"))
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.apiToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="subtrack"`)
+			writeAPIError(w, http.StatusUnauthorized, errors.New("invalid API token"))
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing API response: %v", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, api.Error{Error: err.Error()})
+}
+
+// writeServiceError reports an error from the subscription service. Most are
+// validation errors worded for the user, like the web forms show them.
+func writeServiceError(w http.ResponseWriter, err error) {
+	if errors.Is(err, database.ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, errors.New("subscription not found"))
+		return
+	}
+	writeAPIError(w, http.StatusUnprocessableEntity, err)
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeAPIError(w, http.StatusBadRequest, errors.New("invalid JSON body: "+err.Error()))
+		return false
+	}
+	return true
+}
+
+func apiID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, errors.New("invalid ID"))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (s *Server) handleAPIHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleAPIList takes the same query parameters as the dashboard.
+func (s *Server) handleAPIList(w http.ResponseWriter, r *http.Request) {
+	q, err := services.ParseListQuery(r.URL.Query())
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	page, err := s.subSvc.SearchSubscriptions(q)
+	if errors.Is(err, database.ErrInvalidCursor) {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		log.Printf("Error listing subscriptions: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, errors.New("internal server error"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, api.SubscriptionList{Subscriptions: page.Subscriptions, NextCursor: page.NextCursor})
+}
+
+func (s *Server) handleAPICreate(w http.ResponseWriter, r *http.Request) {
+	var sub api.NewSubscription
+	if !decodeJSON(w, r, &sub) {
+		return
+	}
+
+	if err := s.subSvc.AddSubscription(sub.Name, sub.Price, sub.Currency, sub.Cycle, sub.PaymentDate, sub.Category); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) handleAPIGet(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiID(w, r)
+	if !ok {
+		return
+	}
+
+	sub, err := s.subSvc.GetSubscription(id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sub)
+}
+
+func (s *Server) handleAPIUpdate(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiID(w, r)
+	if !ok {
+		return
+	}
+
+	var changes api.SubscriptionChanges
+	if !decodeJSON(w, r, &changes) {
+		return
+	}
+
+	if changes.Name != "" || changes.Price != "" || changes.Currency != "" || changes.Cycle != "" || changes.PaymentDate != "" {
+		if err := s.subSvc.UpdateSubscription(id, changes.Name, changes.Price, changes.Currency, changes.Cycle, changes.PaymentDate); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+	}
+	if changes.Category != nil {
+		if err := s.subSvc.SetSubscriptionCategory(id, *changes.Category); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+	}
+	if changes.Status != "" {
+		if err := s.subSvc.SetSubscriptionStatus(id, changes.Status); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+	}
+	if changes.TrialEndDate != nil {
+		if err := s.subSvc.SetTrialEndDate(id, *changes.TrialEndDate); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+	}
+
+	sub, err := s.subSvc.GetSubscription(id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sub)
+}
+
+func (s *Server) handleAPIDelete(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.subSvc.DeleteSubscription(id); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAPIImport imports the CSV request body. dry_run=true only validates
+// it, and map takes the CLI's field=Column pairs.
+func (s *Server) handleAPIImport(w http.ResponseWriter, r *http.Request) {
+	mapping, err := services.ParseColumnMapping(r.URL.Query().Get("map"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	result, err := s.subSvc.ImportCSV(http.MaxBytesReader(w, r.Body, maxImportSize), services.ImportOptions{Mapping: mapping, DryRun: dryRun})
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, api.NewImportResult(result))
+}
+
+func (s *Server) handleAPIExportICS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	if err := s.subSvc.ExportICS(w); err != nil {
+		log.Printf("Error exporting calendar: %v", err)
+	}
+}
+
+// handleAPIRestore restores the backup in the request body, resolving
+// conflicts by the on_conflict parameter (default skip).
+func (s *Server) handleAPIRestore(w http.ResponseWriter, r *http.Request) {
+	policyStr := r.URL.Query().Get("on_conflict")
+	if policyStr == "" {
+		policyStr = string(services.ConflictSkip)
+	}
+	policy, err := services.ParseConflictPolicy(policyStr)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	result, err := s.subSvc.Restore(http.MaxBytesReader(w, r.Body, maxImportSize), policy)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, api.NewRestoreResult(result))
+}
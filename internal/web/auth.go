@@ -1,53 +1,94 @@
 package web
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/hex"
+	"log"
 	"net/http"
 	"sync"
 	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/password"
+)
+
+// role is what a logged-in user may do.
+type role string
+
+const (
+	roleAdmin role = "admin"
+	// roleViewer may look but not change anything.
+	roleViewer role = "viewer"
 )
 
 type session struct {
+	username string
+	role     role
+	// sso is set for users who logged in with single sign-on. They have no
+	// password or two-factor settings here.
+	sso       bool
+	expiresAt time.Time
+}
+
+// pendingLogin is a login whose password was right but that still needs a
+// two-factor code.
+type pendingLogin struct {
+	username  string
 	expiresAt time.Time
 }
 
+// pendingLoginTTL is how long the user has to enter their two-factor code.
+const pendingLoginTTL = 5 * time.Minute
+
 type sessionStore struct {
 	mu       sync.Mutex
 	sessions map[string]session
+	pending  map[string]pendingLogin
 }
 
 func newSessionStore() *sessionStore {
 	return &sessionStore{
 		sessions: make(map[string]session),
+		pending:  make(map[string]pendingLogin),
 	}
 }
 
-func (s *sessionStore) createSession() (string, error) {
+func newToken() (string, error) {
 	b := make([]byte, 32)
 	if _, err := rand.Read(b); err != nil {
 		return "", err
 	}
-	token := hex.EncodeToString(b)
+	return hex.EncodeToString(b), nil
+}
+
+func (s *sessionStore) createSession(sess session) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.sessions[token] = session{expiresAt: time.Now().Add(24 * time.Hour)}
+	sess.expiresAt = time.Now().Add(24 * time.Hour)
+	s.sessions[token] = sess
 	return token, nil
 }
 
-func (s *sessionStore) validateSession(token string) bool {
+// validateSession returns the session for token, or nil when there is none
+// or it has expired.
+func (s *sessionStore) validateSession(token string) *session {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	sess, ok := s.sessions[token]
 	if !ok {
-		return false
+		return nil
 	}
 	if time.Now().After(sess.expiresAt) {
 		delete(s.sessions, token)
-		return false
+		return nil
 	}
-	return true
+	return &sess
 }
 
 func (s *sessionStore) destroySession(token string) {
@@ -56,13 +97,94 @@ func (s *sessionStore) destroySession(token string) {
 	delete(s.sessions, token)
 }
 
+func (s *sessionStore) createPendingLogin(username string) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for t, p := range s.pending {
+		if now.After(p.expiresAt) {
+			delete(s.pending, t)
+		}
+	}
+	s.pending[token] = pendingLogin{username: username, expiresAt: now.Add(pendingLoginTTL)}
+	return token, nil
+}
+
+// pendingLogin returns the username of the pending login token, or false
+// when there is none or it has expired.
+func (s *sessionStore) pendingLogin(token string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pending[token]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(p.expiresAt) {
+		delete(s.pending, token)
+		return "", false
+	}
+	return p.username, true
+}
+
+func (s *sessionStore) destroyPendingLogin(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pending, token)
+}
+
+type sessionKey struct{}
+
+// currentSession returns the session requireAuth found for r, or nil.
+func currentSession(r *http.Request) *session {
+	sess, _ := r.Context().Value(sessionKey{}).(*session)
+	return sess
+}
+
+// requireAuth sends clients that are not logged in to the login page, and
+// refuses viewers anything but GET requests.
 func (srv *Server) requireAuth(handler http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("session")
-		if err != nil || !srv.sessions.validateSession(cookie.Value) {
-			http.Redirect(w, r, "/login", http.StatusSeeOther)
+		var sess *session
+		if cookie, err := r.Cookie("session"); err == nil {
+			sess = srv.sessions.validateSession(cookie.Value)
+		}
+		if sess == nil {
+			http.Redirect(w, r, srv.path("/login"), http.StatusSeeOther)
+			return
+		}
+		if sess.role != roleAdmin && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Forbidden: your account has read-only access", http.StatusForbidden)
+			return
+		}
+		handler(w, r.WithContext(context.WithValue(r.Context(), sessionKey{}, sess)))
+	}
+}
+
+// localOnly limits handler to users who logged in with the local password,
+// for pages about the local account.
+func localOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if sess := currentSession(r); sess == nil || sess.sso {
+			http.Error(w, "Your account is managed by your identity provider", http.StatusForbidden)
 			return
 		}
 		handler(w, r)
 	}
 }
+
+// checkPassword reports whether pw is the local user's password.
+func (s *Server) checkPassword(pw string) bool {
+	if s.passwordHash == "" {
+		return subtle.ConstantTimeCompare([]byte(pw), []byte(s.password)) == 1
+	}
+	ok, err := password.Check(s.passwordHash, pw)
+	if err != nil {
+		log.Printf("Error checking password: %v", err)
+	}
+	return ok
+}
@@ -0,0 +1,52 @@
+package web
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/services"
+	"github.com/berkaycubuk/subtrack/internal/utils"
+)
+
+// monthFormat is the calendar's month query parameter, as in 2025-02.
+const monthFormat = "2006-01"
+
+type calendarPage struct {
+	Calendar *services.Calendar
+	// Title names the month; Prev and Next link to the months either side.
+	Title string
+	Prev  string
+	Next  string
+}
+
+func (s *Server) handleCalendar(w http.ResponseWriter, r *http.Request) {
+	data := pageData{Title: "Calendar"}
+	month := utils.Today()
+	if m := r.URL.Query().Get("month"); m != "" {
+		t, err := time.Parse(monthFormat, m)
+		if err != nil {
+			data.Error = "Invalid month " + m + ": use YYYY-MM"
+		} else {
+			month = utils.Date{Year: t.Year(), Month: t.Month(), Day: 1}
+		}
+	}
+
+	cal, err := s.reportSvc.Calendar(month)
+	if err != nil {
+		log.Printf("Error building calendar: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	link := func(d utils.Date) string {
+		return "/calendar?month=" + d.In(time.UTC).Format(monthFormat)
+	}
+	data.Data = calendarPage{
+		Calendar: cal,
+		Title:    cal.Month.In(time.UTC).Format("January 2006"),
+		Prev:     link(cal.Month.AddDate(0, -1, 0)),
+		Next:     link(cal.Month.AddDate(0, 1, 0)),
+	}
+	s.render(w, r, "calendar.html", data)
+}
@@ -0,0 +1,107 @@
+package web
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+
+	"github.com/berkaycubuk/subtrack/internal/services"
+)
+
+// Charts are inline SVG drawn on a fixed coordinate grid and scaled to the
+// page width by the browser, so the dashboard needs no scripts or CDN.
+
+const (
+	chartWidth  = 600
+	chartHeight = 220
+	chartColor  = "#3498db"
+)
+
+// spendChart draws the projected payments per month as a bar chart.
+func spendChart(cr services.CurrencyReport) template.HTML {
+	const top, bottom, left, right = 20, 30, 8, 8
+	plotHeight := float64(chartHeight - top - bottom)
+	slot := float64(chartWidth-left-right) / float64(len(cr.Months))
+	barWidth := slot * 0.7
+
+	peak := 0.0
+	for _, m := range cr.Months {
+		peak = max(peak, m.Amount)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" viewBox="0 0 %d %d" role="img" aria-label="Payments per month in %s">`,
+		chartWidth, chartHeight, html.EscapeString(cr.Currency))
+	fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#ddd"/>`,
+		left, chartHeight-bottom, chartWidth-right, chartHeight-bottom)
+	for i, m := range cr.Months {
+		height := 0.0
+		if peak > 0 {
+			height = m.Amount / peak * plotHeight
+		}
+		x := left + float64(i)*slot + (slot-barWidth)/2
+		y := float64(chartHeight-bottom) - height
+		label := m.Month.Month.String()[:3]
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s %d: %.2f %s</title></rect>`,
+			x, y, barWidth, height, chartColor, label, m.Month.Year, m.Amount, html.EscapeString(cr.Currency))
+		if m.Amount > 0 {
+			fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" font-size="10" text-anchor="middle" fill="#666">%s</text>`,
+				x+barWidth/2, y-4, shortAmount(m.Amount))
+		}
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" font-size="11" text-anchor="middle" fill="#666">%s</text>`,
+			x+barWidth/2, chartHeight-bottom+16, label)
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// categoryChart draws the monthly cost per category as horizontal bars.
+func categoryChart(cr services.CurrencyReport) template.HTML {
+	const rowHeight, labelWidth, valueWidth = 28, 150, 90
+	barSpace := float64(chartWidth - labelWidth - valueWidth)
+	height := max(len(cr.Categories), 1) * rowHeight
+
+	peak := 0.0
+	for _, c := range cr.Categories {
+		peak = max(peak, c.Monthly)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" viewBox="0 0 %d %d" role="img" aria-label="Monthly cost by category in %s">`,
+		chartWidth, height, html.EscapeString(cr.Currency))
+	for i, c := range cr.Categories {
+		y := i * rowHeight
+		name := c.Category
+		if name == "" {
+			name = "Uncategorized"
+		}
+		width := 0.0
+		if peak > 0 {
+			width = c.Monthly / peak * barSpace
+		}
+		fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="12" text-anchor="end" fill="#333">%s</text>`,
+			labelWidth-8, y+18, html.EscapeString(truncate(name, 22)))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="%d" rx="3" fill="%s"><title>%s: %.2f %s per month</title></rect>`,
+			labelWidth, y+6, width, rowHeight-10, chartColor, html.EscapeString(name), c.Monthly, html.EscapeString(cr.Currency))
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" font-size="12" fill="#666">%.2f</text>`,
+			float64(labelWidth)+width+6, y+18, c.Monthly)
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// shortAmount abbreviates thousands so bar labels stay narrow.
+func shortAmount(amount float64) string {
+	if amount >= 1000 {
+		return fmt.Sprintf("%.1fk", amount/1000)
+	}
+	return fmt.Sprintf("%.0f", amount)
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// clientIP returns the address of the client that made r. Requests from a
+// trusted proxy are attributed to the address it forwarded for: the
+// rightmost X-Forwarded-For entry that is not itself a trusted proxy. Each
+// proxy appends the address it received the request from, so entries to the
+// left of that one came from the client and cannot be believed.
+func (s *Server) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	addr = addr.Unmap()
+	if !s.trustedProxy(addr) {
+		return addr.String()
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+		if !s.trustedProxy(addr) {
+			break
+		}
+	}
+	return addr.String()
+}
+
+// fromTrustedProxy reports whether r came straight from a trusted proxy,
+// whose X-Forwarded-* headers can be believed.
+func (s *Server) fromTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	return err == nil && s.trustedProxy(addr.Unmap())
+}
+
+// forwardedHeader returns the first entry of a trusted proxy's X-Forwarded
+// header name: the one the proxy nearest the client saw.
+func (s *Server) forwardedHeader(r *http.Request, name string) string {
+	if !s.fromTrustedProxy(r) {
+		return ""
+	}
+	value, _, _ := strings.Cut(r.Header.Get(name), ",")
+	return strings.TrimSpace(value)
+}
+
+// isHTTPS reports whether the client used HTTPS, to the server or to a
+// trusted proxy that says so in X-Forwarded-Proto.
+func (s *Server) isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(s.forwardedHeader(r, "X-Forwarded-Proto"), "https")
+}
+
+// externalURL is the absolute URL of path on the server as the client sees
+// it, taking the scheme and host from a trusted proxy's X-Forwarded-Proto
+// and X-Forwarded-Host.
+func (s *Server) externalURL(r *http.Request, path string) string {
+	scheme := "http"
+	if s.isHTTPS(r) {
+		scheme = "https"
+	}
+	host := s.forwardedHeader(r, "X-Forwarded-Host")
+	if host == "" {
+		host = r.Host
+	}
+	return scheme + "://" + host + s.path(path)
+}
+
+func (s *Server) trustedProxy(addr netip.Addr) bool {
+	for _, prefix := range s.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
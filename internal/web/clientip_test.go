@@ -0,0 +1,85 @@
+package web
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("127.0.0.1/32")}
+
+	tests := []struct {
+		name      string
+		trusted   []netip.Prefix
+		remote    string
+		forwarded []string
+		want      string
+	}{
+		{name: "direct", remote: "203.0.113.7:5000", want: "203.0.113.7"},
+		{name: "header ignored without trusted proxies", remote: "203.0.113.7:5000", forwarded: []string{"198.51.100.1"}, want: "203.0.113.7"},
+		{name: "header ignored from an untrusted peer", trusted: proxies, remote: "203.0.113.7:5000", forwarded: []string{"198.51.100.1"}, want: "203.0.113.7"},
+		{name: "trusted proxy", trusted: proxies, remote: "127.0.0.1:5000", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "chain of trusted proxies", trusted: proxies, remote: "127.0.0.1:5000", forwarded: []string{"198.51.100.1, 10.1.2.3", "10.0.0.2"}, want: "198.51.100.1"},
+		// The client sent its own X-Forwarded-For, which the proxy appended to.
+		{name: "forged leftmost entry", trusted: proxies, remote: "127.0.0.1:5000", forwarded: []string{"192.0.2.66, 198.51.100.1"}, want: "198.51.100.1"},
+		{name: "forged garbage", trusted: proxies, remote: "127.0.0.1:5000", forwarded: []string{"not-an-ip, 198.51.100.1"}, want: "198.51.100.1"},
+		{name: "proxy without header", trusted: proxies, remote: "127.0.0.1:5000", want: "127.0.0.1"},
+		{name: "IPv4-mapped IPv6 peer", remote: "[::ffff:203.0.113.7]:5000", want: "203.0.113.7"},
+		{name: "IPv6", trusted: proxies, remote: "127.0.0.1:5000", forwarded: []string{"2001:db8::1"}, want: "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{trustedProxies: tt.trusted}
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := s.clientIP(r); got != tt.want {
+				t.Errorf("clientIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExternalURL(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("127.0.0.1/32")}
+	forwarded := map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "subs.example.com"}
+
+	tests := []struct {
+		name    string
+		trusted []netip.Prefix
+		remote  string
+		header  map[string]string
+		want    string
+	}{
+		{name: "direct", remote: "203.0.113.7:5000", want: "http://localhost:8080/subtrack/feed"},
+		{name: "trusted proxy", trusted: proxies, remote: "127.0.0.1:5000", header: forwarded, want: "https://subs.example.com/subtrack/feed"},
+		{name: "trusted proxy, first of several", trusted: proxies, remote: "127.0.0.1:5000",
+			header: map[string]string{"X-Forwarded-Proto": "HTTPS, http", "X-Forwarded-Host": "subs.example.com, internal"},
+			want:   "https://subs.example.com/subtrack/feed"},
+		{name: "untrusted peer", trusted: proxies, remote: "203.0.113.7:5000", header: forwarded, want: "http://localhost:8080/subtrack/feed"},
+		{name: "no trusted proxies", remote: "127.0.0.1:5000", header: forwarded, want: "http://localhost:8080/subtrack/feed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{trustedProxies: tt.trusted, basePath: "/subtrack"}
+			r := httptest.NewRequest("GET", "http://localhost:8080/subtrack/", nil)
+			r.RemoteAddr = tt.remote
+			for name, value := range tt.header {
+				r.Header.Set(name, value)
+			}
+			if got := s.externalURL(r, "/feed"); got != tt.want {
+				t.Errorf("externalURL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// Served over TLS directly.
+	s := &Server{}
+	r := httptest.NewRequest("GET", "https://localhost:8443/", nil)
+	if got, want := s.externalURL(r, "/feed"), "https://localhost:8443/feed"; got != want {
+		t.Errorf("externalURL() over TLS = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,130 @@
+package web
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/services"
+)
+
+const maxImportSize = 10 << 20
+
+type importPage struct {
+	CSV     string
+	Mapping map[string]string
+	Fields  []string
+	Result  *services.ImportResult
+}
+
+func (s *Server) handleExportCSV(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="subtrack-`+time.Now().Format("2006-01-02")+`.csv"`)
+
+	if err := s.subSvc.ExportCSV(w); err != nil {
+		log.Printf("Error exporting subscriptions: %v", err)
+	}
+}
+
+func (s *Server) handleBackup(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="subtrack-backup-`+time.Now().Format("2006-01-02")+`.json"`)
+
+	if err := s.subSvc.WriteBackup(w); err != nil {
+		log.Printf("Error writing backup: %v", err)
+	}
+}
+
+// handleFeed serves the calendar feed. Calendar apps cannot log in, so the
+// secret token in the URL takes the place of a session.
+func (s *Server) handleFeed(w http.ResponseWriter, r *http.Request) {
+	ok, err := s.subSvc.ValidFeedToken(s.username, r.PathValue("token"))
+	if err != nil {
+		log.Printf("Error checking feed token: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="subtrack.ics"`)
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	if err := s.subSvc.ExportICS(w); err != nil {
+		log.Printf("Error writing calendar feed: %v", err)
+	}
+}
+
+func (s *Server) handleRotateFeed(w http.ResponseWriter, r *http.Request) {
+	if _, err := s.subSvc.RotateFeedToken(s.username); err != nil {
+		log.Printf("Error rotating feed token: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, s.path("/"), http.StatusSeeOther)
+}
+
+// feedURL is the absolute calendar feed URL for the request's host.
+func (s *Server) feedURL(r *http.Request, token string) string {
+	return s.externalURL(r, "/feed/"+token+"/payments.ics")
+}
+
+func (s *Server) handleImportForm(w http.ResponseWriter, r *http.Request) {
+	s.render(w, r, "import.html", pageData{Title: "Import Subscriptions", Data: importPage{
+		Mapping: map[string]string{},
+		Fields:  services.CSVFields,
+	}})
+}
+
+// handleImport previews an uploaded CSV file as a dry run, or imports it when
+// the preview is confirmed. The confirmed form carries the CSV text so the
+// file does not have to be uploaded twice.
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	if err := r.ParseMultipartForm(maxImportSize); err != nil && err != http.ErrNotMultipart {
+		http.Error(w, "Invalid upload", http.StatusBadRequest)
+		return
+	}
+
+	page := importPage{Mapping: map[string]string{}, Fields: services.CSVFields}
+	for _, field := range services.CSVFields {
+		if column := strings.TrimSpace(r.FormValue("map_" + field)); column != "" {
+			page.Mapping[field] = column
+		}
+	}
+
+	page.CSV = r.FormValue("csv")
+	if file, _, err := r.FormFile("file"); err == nil {
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			http.Error(w, "Invalid upload", http.StatusBadRequest)
+			return
+		}
+		page.CSV = string(data)
+	}
+
+	render := func(errMsg string) {
+		s.render(w, r, "import.html", pageData{Title: "Import Subscriptions", Error: errMsg, Data: page})
+	}
+
+	if page.CSV == "" {
+		render("Choose a CSV file to import")
+		return
+	}
+
+	dryRun := r.FormValue("action") != "import"
+	result, err := s.subSvc.ImportCSV(strings.NewReader(page.CSV), services.ImportOptions{Mapping: page.Mapping, DryRun: dryRun})
+	if err != nil {
+		render(err.Error())
+		return
+	}
+
+	page.Result = result
+	render("")
+}
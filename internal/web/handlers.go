@@ -1,13 +1,17 @@
 package web
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
 	"crypto/subtle"
 
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/services"
 	"github.com/berkaycubuk/subtrack/internal/utils"
 )
 
@@ -15,32 +19,92 @@ type pageData struct {
 	Title string
 	Error string
 	Data  any
+	// CSRFToken goes in a hidden csrf_token field of every form that posts.
+	CSRFToken string
+	// ReadOnly hides controls that change data from viewers.
+	ReadOnly bool
+	// LocalUser is set for users who logged in with the local password;
+	// only they have a Security page.
+	LocalUser bool
+	// SSO offers single sign-on on the login page.
+	SSO bool
+	// Base is the base path that links in the pages start with.
+	Base string
 }
 
+type dashboardPage struct {
+	// Report summarizes every subscription, whatever the filter.
+	Report        *services.Report
+	Subscriptions []database.Subscription
+	FeedURL       string
+	// Filter holds the query string the list was filtered with, to refill
+	// the filter form. Filtered is true when any filter is applied.
+	Filter   url.Values
+	Filtered bool
+	// NextPage and FirstPage are links to other pages of the same listing,
+	// empty when there is no such page.
+	NextPage  string
+	FirstPage string
+}
+
+// dashboardPageSize is the page size when the query string does not set one.
+const dashboardPageSize = 25
+
 func (s *Server) handleLoginForm(w http.ResponseWriter, r *http.Request) {
 	// If already logged in, redirect to dashboard
-	if cookie, err := r.Cookie("session"); err == nil && s.sessions.validateSession(cookie.Value) {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+	if cookie, err := r.Cookie("session"); err == nil && s.sessions.validateSession(cookie.Value) != nil {
+		http.Redirect(w, r, s.path("/"), http.StatusSeeOther)
 		return
 	}
-	tmpl := parseTemplate("login.html")
-	tmpl.Execute(w, pageData{Title: "Login"})
+	s.render(w, r, "login.html", pageData{Title: "Login"})
 }
 
 func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	username := r.FormValue("username")
 	password := r.FormValue("password")
 
+	ip := s.clientIP(r)
+	keys := loginKeys(ip, username)
+	if wait := s.logins.wait(keys); wait > 0 {
+		s.renderLockedOut(w, r, wait)
+		return
+	}
+
 	usernameMatch := subtle.ConstantTimeCompare([]byte(username), []byte(s.username)) == 1
-	passwordMatch := subtle.ConstantTimeCompare([]byte(password), []byte(s.password)) == 1
+	passwordMatch := s.checkPassword(password)
 
 	if !usernameMatch || !passwordMatch {
-		tmpl := parseTemplate("login.html")
-		tmpl.Execute(w, pageData{Title: "Login", Error: "Invalid username or password"})
+		s.audit.Record(services.AuditLoginFailed, auditUsername(username), ip, "invalid username or password")
+		if lockout := s.logins.fail(keys); lockout > 0 {
+			s.audit.Record(services.AuditLoginLocked, auditUsername(username), ip, "locked out for "+lockout.String())
+			s.renderLockedOut(w, r, lockout)
+			return
+		}
+		s.render(w, r, "login.html", pageData{Title: "Login", Error: "Invalid username or password"})
+		return
+	}
+
+	// With two-factor authentication the login is not over, so earlier
+	// failures still count until the code is right too.
+	enabled, err := s.twoFactor.Enabled(username)
+	if err != nil {
+		log.Printf("Error checking two-factor authentication: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if enabled {
+		s.startPendingLogin(w, r, username)
 		return
 	}
 
-	token, err := s.sessions.createSession()
+	s.logins.succeed(keys)
+	s.audit.Record(services.AuditLoginSucceeded, username, ip, "")
+	s.startSession(w, r, session{username: username, role: roleAdmin})
+}
+
+// startSession logs the client in and sends it to the dashboard.
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request, sess session) {
+	token, err := s.sessions.createSession(sess)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -49,45 +113,117 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     "session",
 		Value:    token,
-		Path:     "/",
+		Path:     s.path("/"),
 		HttpOnly: true,
+		Secure:   s.secureCookies,
 		SameSite: http.SameSiteLaxMode,
 		MaxAge:   int(24 * time.Hour / time.Second),
 	})
 
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	http.Redirect(w, r, s.path("/"), http.StatusSeeOther)
+}
+
+// renderLockedOut answers a login attempt from a client or for a username
+// that is locked out, without checking the password.
+func (s *Server) renderLockedOut(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	setRetryAfter(w, wait)
+	s.renderStatus(w, r, http.StatusTooManyRequests, "login.html", pageData{
+		Title: "Login",
+		Error: "Too many failed attempts. Try again in " + wait.Round(time.Second).String() + ".",
+	})
+}
+
+// auditUsername shortens a username typed at the login form, which could be
+// anything, before it is stored in the audit trail.
+func auditUsername(username string) string {
+	if r := []rune(username); len(r) > 64 {
+		return string(r[:64]) + "…"
+	}
+	return username
 }
 
 func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
 	if cookie, err := r.Cookie("session"); err == nil {
-		s.sessions.destroySession(cookie.Value)
+		if sess := s.sessions.validateSession(cookie.Value); sess != nil {
+			s.sessions.destroySession(cookie.Value)
+			s.audit.Record(services.AuditLogout, sess.username, s.clientIP(r), "")
+		}
 	}
 
 	http.SetCookie(w, &http.Cookie{
-		Name:   "session",
-		Value:  "",
-		Path:   "/",
-		MaxAge: -1,
+		Name:     "session",
+		Value:    "",
+		Path:     s.path("/"),
+		HttpOnly: true,
+		Secure:   s.secureCookies,
+		MaxAge:   -1,
 	})
 
-	http.Redirect(w, r, "/login", http.StatusSeeOther)
+	http.Redirect(w, r, s.path("/login"), http.StatusSeeOther)
 }
 
 func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
-	subs, err := s.subSvc.ListSubscriptions()
+	token, err := s.subSvc.FeedToken(s.username)
+	if err != nil {
+		log.Printf("Error loading feed token: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	report, err := s.reportSvc.Report()
+	if err != nil {
+		log.Printf("Error building report: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	filter := r.URL.Query()
+	page := dashboardPage{Report: report, FeedURL: s.feedURL(r, token), Filter: filter}
+	data := pageData{Title: "Dashboard", Data: &page}
+
+	q, err := services.ParseListQuery(filter)
+	if err != nil {
+		data.Error = err.Error()
+		page.Filtered = true
+		s.render(w, r, "dashboard.html", data)
+		return
+	}
+	if q.Limit == 0 {
+		q.Limit = dashboardPageSize
+	}
+
+	result, err := s.subSvc.SearchSubscriptions(q)
+	if errors.Is(err, database.ErrInvalidCursor) {
+		// Cursors from a changed query or an old link restart the listing.
+		q.Cursor = ""
+		result, err = s.subSvc.SearchSubscriptions(q)
+	}
 	if err != nil {
 		log.Printf("Error listing subscriptions: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	tmpl := parseTemplate("dashboard.html")
-	tmpl.Execute(w, pageData{Title: "Dashboard", Data: subs})
+	page.Subscriptions = result.Subscriptions
+	query := services.EncodeListQuery(q)
+	if result.NextCursor != "" {
+		next := services.EncodeListQuery(q)
+		next.Set("cursor", result.NextCursor)
+		page.NextPage = "/?" + next.Encode()
+	}
+	if q.Cursor != "" {
+		page.FirstPage = "/?" + query.Encode()
+	}
+	for _, key := range []string{"sort", "order", "limit"} {
+		query.Del(key)
+	}
+	page.Filtered = len(query) > 0
+
+	s.render(w, r, "dashboard.html", data)
 }
 
 func (s *Server) handleAddForm(w http.ResponseWriter, r *http.Request) {
-	tmpl := parseTemplate("form.html")
-	tmpl.Execute(w, pageData{Title: "Add Subscription"})
+	s.render(w, r, "form.html", pageData{Title: "Add Subscription"})
 }
 
 func (s *Server) handleAdd(w http.ResponseWriter, r *http.Request) {
@@ -96,16 +232,16 @@ func (s *Server) handleAdd(w http.ResponseWriter, r *http.Request) {
 	currency := r.FormValue("currency")
 	cycle := r.FormValue("cycle")
 	paymentDate := r.FormValue("payment_date")
+	category := r.FormValue("category")
 
-	if err := s.subSvc.AddSubscription(name, price, currency, cycle, paymentDate); err != nil {
-		tmpl := parseTemplate("form.html")
-		tmpl.Execute(w, pageData{Title: "Add Subscription", Error: err.Error(), Data: map[string]string{
-			"Name": name, "Price": price, "Currency": currency, "Cycle": cycle, "PaymentDate": paymentDate,
+	if err := s.subSvc.AddSubscription(name, price, currency, cycle, paymentDate, category); err != nil {
+		s.render(w, r, "form.html", pageData{Title: "Add Subscription", Error: err.Error(), Data: map[string]string{
+			"Name": name, "Price": price, "Currency": currency, "Cycle": cycle, "PaymentDate": paymentDate, "Category": category,
 		}})
 		return
 	}
 
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	http.Redirect(w, r, s.path("/"), http.StatusSeeOther)
 }
 
 func (s *Server) handleEditForm(w http.ResponseWriter, r *http.Request) {
@@ -121,14 +257,15 @@ func (s *Server) handleEditForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tmpl := parseTemplate("form.html")
-	tmpl.Execute(w, pageData{Title: "Edit Subscription", Data: map[string]string{
+	s.render(w, r, "form.html", pageData{Title: "Edit Subscription", Data: map[string]string{
 		"ID":          strconv.FormatUint(uint64(sub.ID), 10),
 		"Name":        sub.Name,
 		"Price":       strconv.FormatFloat(sub.Price, 'f', 2, 64),
 		"Currency":    sub.Currency,
 		"Cycle":       sub.Cycle,
 		"PaymentDate": utils.FormatDate(sub.PaymentDate),
+		"Category":    sub.Category,
+		"Status":      sub.Status,
 	}})
 }
 
@@ -144,16 +281,25 @@ func (s *Server) handleEdit(w http.ResponseWriter, r *http.Request) {
 	currency := r.FormValue("currency")
 	cycle := r.FormValue("cycle")
 	paymentDate := r.FormValue("payment_date")
+	category := r.FormValue("category")
+	status := r.FormValue("status")
 
-	if err := s.subSvc.UpdateSubscription(uint(id), name, price, currency, cycle, paymentDate); err != nil {
-		tmpl := parseTemplate("form.html")
-		tmpl.Execute(w, pageData{Title: "Edit Subscription", Error: err.Error(), Data: map[string]string{
+	err = s.subSvc.UpdateSubscription(uint(id), name, price, currency, cycle, paymentDate)
+	if err == nil {
+		err = s.subSvc.SetSubscriptionCategory(uint(id), category)
+	}
+	if err == nil && status != "" {
+		err = s.subSvc.SetSubscriptionStatus(uint(id), status)
+	}
+	if err != nil {
+		s.render(w, r, "form.html", pageData{Title: "Edit Subscription", Error: err.Error(), Data: map[string]string{
 			"ID": strconv.FormatUint(id, 10), "Name": name, "Price": price, "Currency": currency, "Cycle": cycle, "PaymentDate": paymentDate,
+			"Category": category, "Status": status,
 		}})
 		return
 	}
 
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	http.Redirect(w, r, s.path("/"), http.StatusSeeOther)
 }
 
 func (s *Server) handleDeleteForm(w http.ResponseWriter, r *http.Request) {
@@ -169,8 +315,7 @@ func (s *Server) handleDeleteForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tmpl := parseTemplate("delete.html")
-	tmpl.Execute(w, pageData{Title: "Delete Subscription", Data: sub})
+	s.render(w, r, "delete.html", pageData{Title: "Delete Subscription", Data: sub})
 }
 
 func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
@@ -185,5 +330,5 @@ func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	http.Redirect(w, r, s.path("/"), http.StatusSeeOther)
 }
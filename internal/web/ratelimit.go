@@ -0,0 +1,211 @@
+package web
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/api"
+)
+
+const (
+	// Failed logins allowed before a lockout, per client IP and per
+	// username. Usernames get more, since anyone can spend them and lock the
+	// real user out.
+	loginAttemptsPerIP   = 5
+	loginAttemptsPerUser = 10
+	// The first lockout lasts loginLockout and each further failure doubles
+	// it, up to maxLoginLockout.
+	loginLockout    = time.Minute
+	maxLoginLockout = time.Hour
+	// forgetFailures is how long after its last failure a client or
+	// username starts afresh.
+	forgetFailures = 24 * time.Hour
+	// pruneInterval is how often idle entries are dropped from the limiters.
+	pruneInterval = time.Minute
+)
+
+// loginThrottle counts failed logins and locks out the client IPs and
+// usernames that keep failing.
+type loginThrottle struct {
+	mu        sync.Mutex
+	now       func() time.Time
+	failures  map[string]*loginFailures
+	lastPrune time.Time
+}
+
+type loginFailures struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// throttleKey is a client IP or a username and the failures it is allowed.
+type throttleKey struct {
+	key     string
+	allowed int
+}
+
+func newLoginThrottle() *loginThrottle {
+	return &loginThrottle{now: time.Now, failures: make(map[string]*loginFailures)}
+}
+
+// loginKeys are the throttle keys for a login attempt.
+func loginKeys(ip, username string) []throttleKey {
+	return []throttleKey{
+		{key: "ip:" + ip, allowed: loginAttemptsPerIP},
+		{key: "user:" + username, allowed: loginAttemptsPerUser},
+	}
+}
+
+// wait returns how long until all keys may try again, or zero.
+func (t *loginThrottle) wait(keys []throttleKey) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	var wait time.Duration
+	for _, k := range keys {
+		if f := t.failures[k.key]; f != nil {
+			wait = max(wait, f.lockedUntil.Sub(now))
+		}
+	}
+	return wait
+}
+
+// fail records a failed attempt against keys and returns the longest
+// lockout it started, or zero.
+func (t *loginThrottle) fail(keys []throttleKey) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	t.prune(now)
+	var lockout time.Duration
+	for _, k := range keys {
+		f := t.failures[k.key]
+		if f == nil {
+			f = &loginFailures{}
+			t.failures[k.key] = f
+		}
+		f.count++
+		f.last = now
+		if over := f.count - k.allowed; over > 0 {
+			d := maxLoginLockout
+			if over <= 16 {
+				d = min(loginLockout<<(over-1), maxLoginLockout)
+			}
+			f.lockedUntil = now.Add(d)
+			lockout = max(lockout, d)
+		}
+	}
+	return lockout
+}
+
+// succeed forgets the failures of keys.
+func (t *loginThrottle) succeed(keys []throttleKey) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, k := range keys {
+		delete(t.failures, k.key)
+	}
+}
+
+func (t *loginThrottle) prune(now time.Time) {
+	if now.Sub(t.lastPrune) < pruneInterval {
+		return
+	}
+	t.lastPrune = now
+	for key, f := range t.failures {
+		if now.Sub(f.last) > forgetFailures && now.After(f.lockedUntil) {
+			delete(t.failures, key)
+		}
+	}
+}
+
+// rateLimiter is a token bucket per client: each holds up to a minute's
+// worth of requests and refills at the per-minute rate.
+type rateLimiter struct {
+	mu        sync.Mutex
+	now       func() time.Time
+	perSecond float64
+	burst     float64
+	buckets   map[string]*bucket
+	lastPrune time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{
+		now:       time.Now,
+		perSecond: float64(perMinute) / 60,
+		burst:     float64(perMinute),
+		buckets:   make(map[string]*bucket),
+	}
+}
+
+// allow takes a token from key's bucket. When it is empty, allow returns
+// false and how long until the next token.
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+	b := l.buckets[key]
+	if b == nil {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.perSecond)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.perSecond * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// prune drops buckets that have refilled, which behave like new ones.
+func (l *rateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < pruneInterval {
+		return
+	}
+	l.lastPrune = now
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.perSecond >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// limitAPI applies the API rate limit per client IP. Other routes are not
+// limited here; logins have their own throttle.
+func (s *Server) limitAPI(next http.Handler) http.Handler {
+	if s.apiLimiter == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, api.Prefix+"/") {
+			if ok, wait := s.apiLimiter.allow(s.clientIP(r)); !ok {
+				setRetryAfter(w, wait)
+				writeAPIError(w, http.StatusTooManyRequests, errors.New("rate limit exceeded; slow down and retry later"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setRetryAfter tells the client how many whole seconds to wait.
+func setRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
@@ -0,0 +1,173 @@
+package web
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock for the limiters that only moves when told to.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestThrottle() (*loginThrottle, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)}
+	t := newLoginThrottle()
+	t.now = clock.now
+	return t, clock
+}
+
+func TestLoginThrottle_Allowance(t *testing.T) {
+	tests := []struct {
+		name string
+		// keys returns the keys of the i-th attempt.
+		keys    func(i int) []throttleKey
+		allowed int
+	}{
+		// Guessing passwords for many users from one address.
+		{name: "per IP", keys: func(i int) []throttleKey { return loginKeys("203.0.113.7", string(rune('a'+i))) }, allowed: loginAttemptsPerIP},
+		// Guessing one user's password from many addresses.
+		{name: "per username", keys: func(i int) []throttleKey { return loginKeys("203.0.113."+string(rune('0'+i)), "admin") }, allowed: loginAttemptsPerUser},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttle, _ := newTestThrottle()
+			for i := 0; i < tt.allowed; i++ {
+				if wait := throttle.wait(tt.keys(i)); wait != 0 {
+					t.Fatalf("attempt %d waits %v", i+1, wait)
+				}
+				if lockout := throttle.fail(tt.keys(i)); lockout != 0 {
+					t.Fatalf("failure %d locked out for %v", i+1, lockout)
+				}
+			}
+			if lockout := throttle.fail(tt.keys(tt.allowed)); lockout != loginLockout {
+				t.Errorf("failure %d locked out for %v, want %v", tt.allowed+1, lockout, loginLockout)
+			}
+			if wait := throttle.wait(tt.keys(tt.allowed + 1)); wait != loginLockout {
+				t.Errorf("next attempt waits %v, want %v", wait, loginLockout)
+			}
+		})
+	}
+}
+
+func TestLoginThrottle_Lockout(t *testing.T) {
+	throttle, clock := newTestThrottle()
+	keys := loginKeys("203.0.113.7", "admin")
+	for i := 0; i < loginAttemptsPerIP; i++ {
+		throttle.fail(keys)
+	}
+
+	// Each failure after the lockout ends doubles it, up to an hour.
+	want := []time.Duration{
+		time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 16 * time.Minute, 32 * time.Minute,
+		time.Hour, time.Hour,
+	}
+	for i, w := range want {
+		lockout := throttle.fail(keys)
+		if lockout != w {
+			t.Errorf("lockout %d = %v, want %v", i+1, lockout, w)
+		}
+		clock.advance(lockout - time.Second)
+		if wait := throttle.wait(keys); wait != time.Second {
+			t.Errorf("lockout %d: wait just before it ends = %v, want 1s", i+1, wait)
+		}
+		clock.advance(time.Second)
+		if wait := throttle.wait(keys); wait != 0 {
+			t.Errorf("lockout %d: wait after it ends = %v", i+1, wait)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		throttle.fail(keys)
+	}
+	if wait := throttle.wait(keys); wait != maxLoginLockout {
+		t.Errorf("wait after many failures = %v, want %v", wait, maxLoginLockout)
+	}
+}
+
+func TestLoginThrottle_Reset(t *testing.T) {
+	tests := []struct {
+		name  string
+		reset func(throttle *loginThrottle, clock *fakeClock, keys []throttleKey)
+	}{
+		{name: "success", reset: func(throttle *loginThrottle, _ *fakeClock, keys []throttleKey) { throttle.succeed(keys) }},
+		{name: "a day without failures", reset: func(_ *loginThrottle, clock *fakeClock, _ []throttleKey) { clock.advance(forgetFailures + time.Second) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttle, clock := newTestThrottle()
+			keys := loginKeys("203.0.113.7", "admin")
+			for i := 0; i < loginAttemptsPerIP; i++ {
+				throttle.fail(keys)
+			}
+
+			tt.reset(throttle, clock, keys)
+
+			// A fresh start allows the full count again.
+			for i := 0; i < loginAttemptsPerIP; i++ {
+				if lockout := throttle.fail(keys); lockout != 0 {
+					t.Fatalf("failure %d after the reset locked out for %v", i+1, lockout)
+				}
+			}
+			if lockout := throttle.fail(keys); lockout != loginLockout {
+				t.Errorf("failure %d after the reset locked out for %v, want %v", loginAttemptsPerIP+1, lockout, loginLockout)
+			}
+		})
+	}
+
+	// Failures less than a day apart keep counting.
+	throttle, clock := newTestThrottle()
+	keys := loginKeys("203.0.113.7", "admin")
+	for i := 0; i < loginAttemptsPerIP; i++ {
+		clock.advance(forgetFailures - time.Minute)
+		throttle.fail(keys)
+	}
+	if lockout := throttle.fail(keys); lockout == 0 {
+		t.Error("failures spread over days never locked out")
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)}
+	limiter := newRateLimiter(60)
+	limiter.now = clock.now
+
+	// A full bucket allows a burst of a minute's worth.
+	for i := 0; i < 60; i++ {
+		if ok, _ := limiter.allow("a"); !ok {
+			t.Fatalf("request %d refused", i+1)
+		}
+	}
+	ok, wait := limiter.allow("a")
+	if ok || wait != time.Second {
+		t.Errorf("request over the burst = %v, %v, want refused for 1s", ok, wait)
+	}
+	if ok, _ := limiter.allow("b"); !ok {
+		t.Error("another client was refused")
+	}
+
+	tests := []struct {
+		advance time.Duration
+		allowed int
+	}{
+		{advance: 500 * time.Millisecond, allowed: 0},
+		{advance: 500 * time.Millisecond, allowed: 1},
+		{advance: 10 * time.Second, allowed: 10},
+		// Refilling stops at the burst.
+		{advance: time.Hour, allowed: 60},
+	}
+	for _, tt := range tests {
+		clock.advance(tt.advance)
+		allowed := 0
+		for {
+			ok, _ := limiter.allow("a")
+			if !ok {
+				break
+			}
+			allowed++
+		}
+		if allowed != tt.allowed {
+			t.Errorf("after %v, %d requests allowed, want %d", tt.advance, allowed, tt.allowed)
+		}
+	}
+}
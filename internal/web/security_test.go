@@ -0,0 +1,150 @@
+package web
+
+import (
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/berkaycubuk/subtrack/internal/api"
+)
+
+func TestCSRFProtect(t *testing.T) {
+	tests := []struct {
+		name       string
+		cookie     string
+		field      string
+		wantStatus int
+	}{
+		{name: "no token", wantStatus: http.StatusForbidden},
+		{name: "field without cookie", field: strings.Repeat("a", 64), wantStatus: http.StatusForbidden},
+		{name: "cookie without field", cookie: strings.Repeat("a", 64), wantStatus: http.StatusForbidden},
+		{name: "mismatched", cookie: strings.Repeat("a", 64), field: strings.Repeat("b", 64), wantStatus: http.StatusForbidden},
+		{name: "matching", cookie: strings.Repeat("a", 64), field: strings.Repeat("a", 64), wantStatus: http.StatusSeeOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, Options{})
+			form := url.Values{"username": {"admin"}, "password": {"secret"}}
+			if tt.field != "" {
+				form.Set(csrfField, tt.field)
+			}
+			header := http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+			if tt.cookie != "" {
+				header.Set("Cookie", csrfCookie+"="+tt.cookie)
+			}
+
+			resp, _ := ts.do(t, http.MethodPost, "/login", strings.NewReader(form.Encode()), header)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("POST /login = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCSRFProtect_API(t *testing.T) {
+	ts := newTestServer(t, Options{APIToken: "token"})
+
+	body := `{"name": "Netflix", "price": "9.99", "currency": "USD", "cycle": "monthly", "payment_date": "01-01-2030"}`
+	resp, _ := ts.do(t, http.MethodPost, api.Prefix+"/subscriptions", strings.NewReader(body), http.Header{
+		"Authorization": {"Bearer token"},
+		"Content-Type":  {"application/json"},
+	})
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("POST %s/subscriptions without a CSRF token = %d, want 201", api.Prefix, resp.StatusCode)
+	}
+}
+
+func TestCSRFToken_Issued(t *testing.T) {
+	ts := newTestServer(t, Options{})
+
+	_, body := ts.get(t, "/login")
+	token := ts.cookie(t, "/", csrfCookie)
+	if len(token) != 64 {
+		t.Fatalf("csrf cookie = %q", token)
+	}
+	if !strings.Contains(body, `name="csrf_token" value="`+token+`"`) {
+		t.Error("login form does not carry the cookie's token")
+	}
+
+	// The token is kept for later pages.
+	ts.get(t, "/login")
+	if got := ts.cookie(t, "/", csrfCookie); got != token {
+		t.Errorf("csrf cookie changed to %q", got)
+	}
+}
+
+func TestCSRFToken_LockedOut(t *testing.T) {
+	ts := newTestServer(t, Options{})
+	for i := 0; i <= loginAttemptsPerUser; i++ {
+		ts.postForm(t, "/login", url.Values{"username": {"admin"}, "password": {"wrong"}})
+	}
+
+	// A cookie the server did not issue is replaced on the locked-out page.
+	u, _ := url.Parse(ts.url + "/")
+	ts.client.Jar.SetCookies(u, []*http.Cookie{{Name: csrfCookie, Value: "stale", Path: "/"}})
+	form := url.Values{"username": {"admin"}, "password": {"wrong"}, csrfField: {"stale"}}
+	resp, body := ts.do(t, http.MethodPost, "/login", strings.NewReader(form.Encode()),
+		http.Header{"Content-Type": {"application/x-www-form-urlencoded"}})
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("POST /login while locked out = %d, want 429", resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("locked-out response has no Retry-After")
+	}
+	token := ts.cookie(t, "/", csrfCookie)
+	if len(token) != 64 {
+		t.Fatalf("csrf cookie = %q", token)
+	}
+	if !strings.Contains(body, `name="csrf_token" value="`+token+`"`) {
+		t.Error("locked-out form does not carry the cookie's token")
+	}
+
+	// The form can be posted again with it.
+	resp, _ = ts.postForm(t, "/login", url.Values{"username": {"admin"}, "password": {"wrong"}})
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("POST /login with the new token = %d, want 429", resp.StatusCode)
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	loopback := []netip.Prefix{netip.MustParsePrefix("127.0.0.1/32")}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		header   http.Header
+		wantHSTS bool
+	}{
+		{name: "plain HTTP"},
+		{name: "secure cookies", opts: Options{SecureCookies: true}, wantHSTS: true},
+		{name: "HTTPS at a trusted proxy", opts: Options{TrustedProxies: loopback},
+			header: http.Header{"X-Forwarded-Proto": {"https"}}, wantHSTS: true},
+		{name: "HTTPS claimed by an untrusted client",
+			header: http.Header{"X-Forwarded-Proto": {"https"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, tt.opts)
+
+			// Headers are set on every response, errors included.
+			for _, path := range []string{"/login", "/", "/missing"} {
+				resp, _ := ts.do(t, http.MethodGet, path, nil, tt.header)
+				h := resp.Header
+				if got := h.Get("Content-Security-Policy"); got != contentSecurityPolicy {
+					t.Errorf("GET %s: Content-Security-Policy = %q", path, got)
+				}
+				if got := h.Get("X-Frame-Options"); got != "DENY" {
+					t.Errorf("GET %s: X-Frame-Options = %q, want DENY", path, got)
+				}
+				if got := h.Get("X-Content-Type-Options"); got != "nosniff" {
+					t.Errorf("GET %s: X-Content-Type-Options = %q, want nosniff", path, got)
+				}
+				if got := h.Get("Strict-Transport-Security"); (got != "") != tt.wantHSTS {
+					t.Errorf("GET %s: Strict-Transport-Security = %q, want it sent: %v", path, got, tt.wantHSTS)
+				}
+			}
+		})
+	}
+}
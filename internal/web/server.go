@@ -4,31 +4,113 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"net/netip"
+	"strings"
 	"time"
 
 	"github.com/berkaycubuk/subtrack/internal/services"
+	"github.com/berkaycubuk/subtrack/internal/sso"
 )
 
 type Server struct {
 	httpServer *http.Server
 	subSvc     *services.SubscriptionService
+	reportSvc  *services.ReportService
+	audit      *services.AuditLog
+	twoFactor  *services.TwoFactorService
 	sessions   *sessionStore
+	logins     *loginThrottle
+	// apiLimiter limits API requests per client; nil means no limit.
+	apiLimiter *rateLimiter
 	username   string
-	password   string
+	// password is the web UI password in plain text, or empty when
+	// passwordHash is set instead.
+	password     string
+	passwordHash string
+	// apiToken grants access to the JSON API; the API is disabled when it
+	// is empty.
+	apiToken string
+	// secureCookies marks cookies Secure, for sites served over HTTPS.
+	secureCookies bool
+	// trustedProxies may report the client's address, scheme and host in
+	// X-Forwarded-For, X-Forwarded-Proto and X-Forwarded-Host.
+	trustedProxies []netip.Prefix
+	// basePath is the path the UI is served under, e.g. /subtrack, or empty
+	// at the root.
+	basePath string
+	// tls serves HTTPS when set.
+	tls *TLSOptions
+	// sso logs users in with an OpenID Connect provider; nil when single
+	// sign-on is off.
+	sso     *sso.Client
+	ssoOpts *SSOOptions
 }
 
-func NewServer(subSvc *services.SubscriptionService, username, password string) *Server {
+// Options configure a Server.
+type Options struct {
+	// Username and Password log in to the web UI. PasswordHash, an argon2id
+	// or bcrypt hash, takes the place of Password when set.
+	Username     string
+	Password     string
+	PasswordHash string
+	// APIToken enables the JSON API; see Server.
+	APIToken string
+	// SecureCookies should be set when the site is served over HTTPS,
+	// directly or behind a TLS-terminating proxy.
+	SecureCookies bool
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For,
+	// X-Forwarded-Proto and X-Forwarded-Host headers are believed.
+	TrustedProxies []netip.Prefix
+	// BasePath serves the UI under a path such as /subtrack instead of the
+	// root, for a reverse proxy that shares a host between applications.
+	BasePath string
+	// TLS serves HTTPS instead of HTTP, and implies SecureCookies.
+	TLS *TLSOptions
+	// APIRateLimit is how many API requests a client may make per minute;
+	// zero means no limit.
+	APIRateLimit int
+	// SSO offers single sign-on next to the login form when set.
+	SSO *SSOOptions
+}
+
+func NewServer(subSvc *services.SubscriptionService, reportSvc *services.ReportService, audit *services.AuditLog, twoFactor *services.TwoFactorService, opts Options) *Server {
 	srv := &Server{
-		subSvc:   subSvc,
-		sessions: newSessionStore(),
-		username: username,
-		password: password,
+		subSvc:         subSvc,
+		reportSvc:      reportSvc,
+		audit:          audit,
+		twoFactor:      twoFactor,
+		sessions:       newSessionStore(),
+		logins:         newLoginThrottle(),
+		username:       opts.Username,
+		password:       opts.Password,
+		passwordHash:   opts.PasswordHash,
+		apiToken:       opts.APIToken,
+		secureCookies:  opts.SecureCookies,
+		trustedProxies: opts.TrustedProxies,
+		basePath:       opts.BasePath,
+		tls:            opts.TLS,
+	}
+	if opts.TLS != nil {
+		srv.secureCookies = true
+	}
+	if opts.APIRateLimit > 0 {
+		srv.apiLimiter = newRateLimiter(opts.APIRateLimit)
+	}
+	if opts.SSO != nil {
+		srv.sso = sso.New(opts.SSO.Config)
+		srv.ssoOpts = opts.SSO
 	}
 
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("GET /login", srv.handleLoginForm)
 	mux.HandleFunc("POST /login", srv.handleLogin)
+	mux.HandleFunc("GET /login/verify", srv.handleVerifyForm)
+	mux.HandleFunc("POST /login/verify", srv.handleVerify)
+	if srv.sso != nil {
+		mux.HandleFunc("GET /login/sso", srv.handleSSOLogin)
+		mux.HandleFunc("GET /login/sso/callback", srv.handleSSOCallback)
+	}
 	mux.HandleFunc("GET /logout", srv.handleLogout)
 	mux.HandleFunc("GET /{$}", srv.requireAuth(srv.handleDashboard))
 	mux.HandleFunc("GET /add", srv.requireAuth(srv.handleAddForm))
@@ -37,18 +119,71 @@ func NewServer(subSvc *services.SubscriptionService, username, password string)
 	mux.HandleFunc("POST /edit/{id}", srv.requireAuth(srv.handleEdit))
 	mux.HandleFunc("GET /delete/{id}", srv.requireAuth(srv.handleDeleteForm))
 	mux.HandleFunc("POST /delete/{id}", srv.requireAuth(srv.handleDelete))
+	mux.HandleFunc("GET /calendar", srv.requireAuth(srv.handleCalendar))
+	mux.HandleFunc("GET /import", srv.requireAuth(srv.handleImportForm))
+	mux.HandleFunc("POST /import", srv.requireAuth(srv.handleImport))
+	mux.HandleFunc("GET /export.csv", srv.requireAuth(srv.handleExportCSV))
+	mux.HandleFunc("GET /backup.json", srv.requireAuth(srv.handleBackup))
+	mux.HandleFunc("GET /feed/{token}/payments.ics", srv.handleFeed)
+	mux.HandleFunc("POST /feed/rotate", srv.requireAuth(srv.handleRotateFeed))
+	mux.HandleFunc("GET /security", srv.requireAuth(localOnly(srv.handleSecurity)))
+	mux.HandleFunc("POST /security/2fa/begin", srv.requireAuth(localOnly(srv.handleTwoFactorBegin)))
+	mux.HandleFunc("POST /security/2fa/confirm", srv.requireAuth(localOnly(srv.handleTwoFactorConfirm)))
+	mux.HandleFunc("POST /security/2fa/disable", srv.requireAuth(localOnly(srv.handleTwoFactorDisable)))
+	mux.HandleFunc("POST /security/2fa/recovery-codes", srv.requireAuth(localOnly(srv.handleRecoveryCodes)))
+	srv.registerAPI(mux)
 
 	srv.httpServer = &http.Server{
-		Handler: mux,
+		Handler: srv.stripBasePath(srv.securityHeaders(srv.limitAPI(srv.csrfProtect(mux)))),
 	}
 
 	return srv
 }
 
+// Handler is the server's HTTP handler, for serving it some other way.
+func (s *Server) Handler() http.Handler {
+	return s.httpServer.Handler
+}
+
 func (s *Server) Start(addr string) error {
 	s.httpServer.Addr = addr
-	log.Printf("Web server starting on %s", addr)
-	return s.httpServer.ListenAndServe()
+	if s.tls == nil {
+		log.Printf("Web server starting on %s", addr)
+		return s.httpServer.ListenAndServe()
+	}
+
+	cfg, err := tlsConfig(s.tls)
+	if err != nil {
+		return err
+	}
+	s.httpServer.TLSConfig = cfg
+	log.Printf("Web server starting on %s with TLS", addr)
+	return s.httpServer.ListenAndServeTLS("", "")
+}
+
+// path is p, a path in the UI such as /login, under the base path.
+func (s *Server) path(p string) string {
+	return s.basePath + p
+}
+
+// stripBasePath removes the base path from requests, so routes are matched
+// without it. Requests without it are served as they are, which suits
+// proxies that strip the prefix themselves.
+func (s *Server) stripBasePath(next http.Handler) http.Handler {
+	if s.basePath == "" {
+		return next
+	}
+	strip := http.StripPrefix(s.basePath, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == s.basePath:
+			http.Redirect(w, r, s.path("/"), http.StatusMovedPermanently)
+		case strings.HasPrefix(r.URL.Path, s.basePath+"/"):
+			strip.ServeHTTP(w, r)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
 }
 
 func (s *Server) Shutdown() error {
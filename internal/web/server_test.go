@@ -0,0 +1,165 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/berkaycubuk/subtrack/internal/database"
+	"github.com/berkaycubuk/subtrack/internal/services"
+)
+
+// testServer is a Server on the in-memory store with a browser-like client
+// that keeps cookies and does not follow redirects.
+type testServer struct {
+	*Server
+	db     *database.MemoryStore
+	url    string
+	client *http.Client
+}
+
+func newTestServer(t *testing.T, opts Options) *testServer {
+	t.Helper()
+	if opts.Username == "" {
+		opts.Username, opts.Password = "admin", "secret"
+	}
+	db := database.NewMemoryStore()
+	srv := NewServer(services.NewSubscriptionService(db, nil), services.NewReportService(db),
+		services.NewAuditLog(db), services.NewTwoFactorService(db), opts)
+	hs := httptest.NewServer(srv.Handler())
+	t.Cleanup(hs.Close)
+
+	jar, _ := cookiejar.New(nil)
+	return &testServer{
+		Server: srv,
+		db:     db,
+		url:    hs.URL,
+		client: &http.Client{
+			Jar:           jar,
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+// do sends a request to path, or to an absolute URL, and reads the body.
+func (ts *testServer) do(t *testing.T, method, path string, body io.Reader, header http.Header) (*http.Response, string) {
+	t.Helper()
+	target := path
+	if strings.HasPrefix(path, "/") {
+		target = ts.url + path
+	}
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := ts.client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return resp, string(b)
+}
+
+func (ts *testServer) get(t *testing.T, path string) (*http.Response, string) {
+	t.Helper()
+	return ts.do(t, http.MethodGet, path, nil, nil)
+}
+
+// postForm posts form to path with the CSRF token from the client's
+// cookie, fetching the login page first for one if needed.
+func (ts *testServer) postForm(t *testing.T, path string, form url.Values) (*http.Response, string) {
+	t.Helper()
+	token := ts.cookie(t, path, csrfCookie)
+	if token == "" {
+		ts.get(t, ts.path("/login"))
+		token = ts.cookie(t, path, csrfCookie)
+	}
+	form.Set(csrfField, token)
+	return ts.do(t, http.MethodPost, path, strings.NewReader(form.Encode()),
+		http.Header{"Content-Type": {"application/x-www-form-urlencoded"}})
+}
+
+// cookie returns the value of the client's cookie name for path.
+func (ts *testServer) cookie(t *testing.T, path, name string) string {
+	t.Helper()
+	u, _ := url.Parse(ts.url + path)
+	for _, c := range ts.client.Jar.Cookies(u) {
+		if c.Name == name {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+// login logs in with the local username and password.
+func (ts *testServer) login(t *testing.T) {
+	t.Helper()
+	resp, _ := ts.postForm(t, ts.path("/login"), url.Values{"username": {ts.username}, "password": {ts.password}})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("login = %d", resp.StatusCode)
+	}
+}
+
+func TestStripBasePath(t *testing.T) {
+	tests := []struct {
+		path         string
+		wantStatus   int
+		wantLocation string
+	}{
+		{path: "/subtrack", wantStatus: http.StatusMovedPermanently, wantLocation: "/subtrack/"},
+		{path: "/subtrack/", wantStatus: http.StatusSeeOther, wantLocation: "/subtrack/login"},
+		{path: "/subtrack/login", wantStatus: http.StatusOK},
+		// A proxy that strips the prefix itself.
+		{path: "/", wantStatus: http.StatusSeeOther, wantLocation: "/subtrack/login"},
+		{path: "/login", wantStatus: http.StatusOK},
+		{path: "/subtrackers", wantStatus: http.StatusNotFound},
+	}
+	ts := newTestServer(t, Options{BasePath: "/subtrack"})
+	for _, tt := range tests {
+		resp, _ := ts.get(t, tt.path)
+		if resp.StatusCode != tt.wantStatus || resp.Header.Get("Location") != tt.wantLocation {
+			t.Errorf("GET %s = %d %q, want %d %q", tt.path, resp.StatusCode, resp.Header.Get("Location"), tt.wantStatus, tt.wantLocation)
+		}
+	}
+}
+
+func TestBasePath_Pages(t *testing.T) {
+	ts := newTestServer(t, Options{BasePath: "/subtrack"})
+
+	resp, body := ts.get(t, "/subtrack/login")
+	if !strings.Contains(body, `action="/subtrack/login"`) {
+		t.Error("login form does not post under the base path")
+	}
+	for _, c := range resp.Cookies() {
+		if c.Path != "/subtrack/" {
+			t.Errorf("cookie %s has path %q, want /subtrack/", c.Name, c.Path)
+		}
+	}
+
+	resp, _ = ts.postForm(t, "/subtrack/login", url.Values{"username": {"admin"}, "password": {"secret"}})
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/subtrack/" {
+		t.Fatalf("login = %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	var session *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == "session" {
+			session = c
+		}
+	}
+	if session == nil || session.Path != "/subtrack/" {
+		t.Fatalf("session cookie = %v, want path /subtrack/", session)
+	}
+
+	resp, body = ts.get(t, "/subtrack/")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, `href="/subtrack/add"`) {
+		t.Errorf("dashboard = %d, want links under the base path", resp.StatusCode)
+	}
+}
@@ -0,0 +1,144 @@
+package web
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/berkaycubuk/subtrack/internal/services"
+	"github.com/berkaycubuk/subtrack/internal/sso"
+)
+
+// ssoStateCookie holds a single sign-on login in progress, sealed by
+// sso.Client, in the browser that started it.
+const ssoStateCookie = "sso_state"
+
+// SSOOptions enable single sign-on with an OpenID Connect provider.
+type SSOOptions struct {
+	sso.Config
+	// RedirectURL is the callback URL registered with the provider, ending
+	// in /login/sso/callback. When empty it is worked out from each
+	// request, which is wrong behind a proxy that terminates TLS unless
+	// the proxy is trusted and sends X-Forwarded-Proto.
+	RedirectURL string
+	// Users with any of AdminRoles get full access and users with any of
+	// ViewerRoles read-only access. Everyone else is refused.
+	AdminRoles  []string
+	ViewerRoles []string
+}
+
+// role maps a user from the provider to a local role.
+func (o *SSOOptions) role(id *sso.Identity) (role, bool) {
+	switch {
+	case id.HasRole(o.AdminRoles):
+		return roleAdmin, true
+	case id.HasRole(o.ViewerRoles):
+		return roleViewer, true
+	}
+	return "", false
+}
+
+func (s *Server) ssoRedirectURL(r *http.Request) string {
+	if s.ssoOpts.RedirectURL != "" {
+		return s.ssoOpts.RedirectURL
+	}
+	return s.externalURL(r, "/login/sso/callback")
+}
+
+// ssoLoginKeys throttles single sign-on by client IP alone, since who is
+// logging in is only known once the provider says so.
+func ssoLoginKeys(ip string) []throttleKey {
+	return []throttleKey{{key: "ip:" + ip, allowed: loginAttemptsPerIP}}
+}
+
+// handleSSOLogin sends the user to the provider to log in.
+func (s *Server) handleSSOLogin(w http.ResponseWriter, r *http.Request) {
+	if wait := s.logins.wait(ssoLoginKeys(s.clientIP(r))); wait > 0 {
+		s.renderLockedOut(w, r, wait)
+		return
+	}
+
+	authURL, sealed, err := s.sso.Start(r.Context(), s.ssoRedirectURL(r))
+	if err != nil {
+		log.Printf("Error starting single sign-on: %v", err)
+		s.render(w, r, "login.html", pageData{Title: "Login", Error: "Single sign-on is unavailable. Try again later."})
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoStateCookie,
+		Value:    sealed,
+		Path:     s.path("/login/sso"),
+		HttpOnly: true,
+		Secure:   s.secureCookies,
+		// Lax still sends the cookie on the provider's redirect back.
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   10 * 60,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// handleSSOCallback finishes a login when the provider sends the user back.
+// Callbacks that fail on the user's side count towards the login throttle.
+func (s *Server) handleSSOCallback(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoStateCookie,
+		Value:    "",
+		Path:     s.path("/login/sso"),
+		HttpOnly: true,
+		Secure:   s.secureCookies,
+		MaxAge:   -1,
+	})
+
+	ip := s.clientIP(r)
+	keys := ssoLoginKeys(ip)
+	if wait := s.logins.wait(keys); wait > 0 {
+		s.renderLockedOut(w, r, wait)
+		return
+	}
+	fail := func(username, reason, message string) {
+		if reason != "" {
+			s.audit.Record(services.AuditLoginFailed, username, ip, "single sign-on: "+reason)
+		}
+		if lockout := s.logins.fail(keys); lockout > 0 {
+			s.audit.Record(services.AuditLoginLocked, username, ip, "locked out for "+lockout.String())
+			s.renderLockedOut(w, r, lockout)
+			return
+		}
+		s.render(w, r, "login.html", pageData{Title: "Login", Error: message})
+	}
+
+	q := r.URL.Query()
+	if code := q.Get("error"); code != "" {
+		// The user cancelled, or the provider would not log them in.
+		fail("", auditUsername(code), "Single sign-on failed: "+auditUsername(code))
+		return
+	}
+
+	var sealed string
+	if cookie, err := r.Cookie(ssoStateCookie); err == nil {
+		sealed = cookie.Value
+	}
+	id, err := s.sso.Finish(r.Context(), sealed, q.Get("state"), q.Get("code"))
+	if errors.Is(err, sso.ErrInvalidState) {
+		fail("", "", "Your single sign-on login expired. Try again.")
+		return
+	}
+	if err != nil {
+		log.Printf("Error finishing single sign-on: %v", err)
+		s.audit.Record(services.AuditLoginFailed, "", ip, "single sign-on: "+err.Error())
+		s.render(w, r, "login.html", pageData{Title: "Login", Error: "Single sign-on failed. Try again later."})
+		return
+	}
+
+	username := auditUsername(id.Username)
+	role, ok := s.ssoOpts.role(id)
+	if !ok {
+		fail(username, "no allowed role", "Your account is not allowed to use SubTrack.")
+		return
+	}
+
+	s.logins.succeed(keys)
+	s.audit.Record(services.AuditLoginSucceeded, username, ip, "single sign-on as "+string(role))
+	s.startSession(w, r, session{username: id.Username, role: role, sso: true})
+}
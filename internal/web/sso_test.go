@@ -0,0 +1,176 @@
+package web
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/berkaycubuk/subtrack/internal/services"
+	"github.com/berkaycubuk/subtrack/internal/sso"
+	"github.com/berkaycubuk/subtrack/internal/sso/mockidp"
+)
+
+// newSSOTestServer returns a server whose single sign-on goes to a mock
+// provider, which logs everyone in with its current Claims.
+func newSSOTestServer(t *testing.T) (*testServer, *mockidp.Provider) {
+	t.Helper()
+	idp, err := mockidp.New("subtrack", "s3cret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := httptest.NewServer(idp)
+	t.Cleanup(hs.Close)
+	idp.Issuer = hs.URL
+
+	ts := newTestServer(t, Options{SSO: &SSOOptions{
+		Config:      sso.Config{Issuer: hs.URL, ClientID: "subtrack", ClientSecret: "s3cret"},
+		AdminRoles:  []string{"admins"},
+		ViewerRoles: []string{"staff"},
+	}})
+	return ts, idp
+}
+
+// ssoLogin goes through single sign-on and returns the callback's response.
+func ssoLogin(t *testing.T, ts *testServer) (*http.Response, string) {
+	t.Helper()
+	resp, _ := ts.get(t, "/login/sso")
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("GET /login/sso = %d", resp.StatusCode)
+	}
+	resp, _ = ts.get(t, resp.Header.Get("Location"))
+	callback := resp.Header.Get("Location")
+	if resp.StatusCode != http.StatusFound || !strings.HasPrefix(callback, ts.url+"/login/sso/callback?") {
+		t.Fatalf("provider = %d %q", resp.StatusCode, callback)
+	}
+	return ts.get(t, callback)
+}
+
+func lastAudit(t *testing.T, ts *testServer) string {
+	t.Helper()
+	events, err := ts.db.GetAuditEvents(1)
+	if err != nil || len(events) == 0 {
+		t.Fatalf("GetAuditEvents() = %v, %v", events, err)
+	}
+	e := events[0]
+	return e.Action + " " + e.Username + ": " + e.Detail
+}
+
+func TestSSOCallback_Roles(t *testing.T) {
+	tests := []struct {
+		groups     []string
+		wantRole   string
+		wantStatus int // of adding a subscription
+	}{
+		{groups: []string{"staff", "admins"}, wantRole: "admin", wantStatus: http.StatusSeeOther},
+		{groups: []string{"staff"}, wantRole: "viewer", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantRole, func(t *testing.T) {
+			ts, idp := newSSOTestServer(t)
+			idp.Claims = map[string]any{"preferred_username": "jane", "groups": tt.groups}
+
+			resp, _ := ssoLogin(t, ts)
+			if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/" {
+				t.Fatalf("callback = %d %q", resp.StatusCode, resp.Header.Get("Location"))
+			}
+			if got, want := lastAudit(t, ts), services.AuditLoginSucceeded+" jane: single sign-on as "+tt.wantRole; got != want {
+				t.Errorf("audit = %q, want %q", got, want)
+			}
+
+			resp, body := ts.get(t, "/")
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("GET / = %d", resp.StatusCode)
+			}
+			if admin := tt.wantRole == "admin"; strings.Contains(body, `href="/add"`) != admin {
+				t.Errorf("Add link shown to %s = %v, want %v", tt.wantRole, !admin, admin)
+			}
+			if strings.Contains(body, `href="/security"`) {
+				t.Error("Security link shown to a single sign-on user")
+			}
+
+			resp, _ = ts.postForm(t, "/add", url.Values{
+				"name": {"Netflix"}, "price": {"9.99"}, "currency": {"USD"}, "cycle": {"monthly"}, "payment_date": {"01-01-2030"},
+			})
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("POST /add = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if resp, _ := ts.get(t, "/security"); resp.StatusCode != http.StatusForbidden {
+				t.Errorf("GET /security = %d, want 403", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestSSOCallback_Refused(t *testing.T) {
+	ts, idp := newSSOTestServer(t)
+	idp.Claims = map[string]any{"preferred_username": "mallory", "groups": []string{"contractors"}}
+
+	resp, body := ssoLogin(t, ts)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "not allowed to use SubTrack") {
+		t.Errorf("callback = %d, want the refusal", resp.StatusCode)
+	}
+	if got, want := lastAudit(t, ts), services.AuditLoginFailed+" mallory: single sign-on: no allowed role"; got != want {
+		t.Errorf("audit = %q, want %q", got, want)
+	}
+	if ts.cookie(t, "/", "session") != "" {
+		t.Error("refused user got a session")
+	}
+}
+
+func TestSSOCallback_ProviderError(t *testing.T) {
+	ts, _ := newSSOTestServer(t)
+
+	resp, body := ts.get(t, "/login/sso/callback?error=access_denied&state=x")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "Single sign-on failed: access_denied") {
+		t.Errorf("callback = %d, want the provider's error", resp.StatusCode)
+	}
+	if got, want := lastAudit(t, ts), services.AuditLoginFailed+" : single sign-on: access_denied"; got != want {
+		t.Errorf("audit = %q, want %q", got, want)
+	}
+}
+
+func TestSSOCallback_StateMismatch(t *testing.T) {
+	ts, idp := newSSOTestServer(t)
+	idp.Claims = map[string]any{"preferred_username": "jane", "groups": []string{"admins"}}
+
+	resp, _ := ts.get(t, "/login/sso")
+	resp, _ = ts.get(t, resp.Header.Get("Location"))
+	callbackURL := resp.Header.Get("Location")
+	callback, _ := url.Parse(callbackURL)
+	q := callback.Query()
+	q.Set("state", "forged")
+	callback.RawQuery = q.Encode()
+
+	resp, body := ts.get(t, callback.String())
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "login expired") {
+		t.Errorf("callback with a forged state = %d, want the expiry message", resp.StatusCode)
+	}
+	if ts.cookie(t, "/", "session") != "" {
+		t.Error("forged callback got a session")
+	}
+
+	// The real callback in another browser, without the cookie from
+	// /login/sso.
+	ts.client.Jar, _ = cookiejar.New(nil)
+	if resp, body := ts.get(t, callbackURL); resp.StatusCode != http.StatusOK || !strings.Contains(body, "login expired") {
+		t.Errorf("callback without the state cookie = %d, want the expiry message", resp.StatusCode)
+	}
+}
+
+func TestSSOLogin_Throttled(t *testing.T) {
+	ts, _ := newSSOTestServer(t)
+
+	for i := 0; i < loginAttemptsPerIP; i++ {
+		ts.get(t, "/login/sso/callback?state=forged&code=x")
+	}
+	resp, _ := ts.get(t, "/login/sso/callback?state=forged&code=x")
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("callback after %d failures = %d, want 429", loginAttemptsPerIP, resp.StatusCode)
+	}
+	if resp, _ := ts.get(t, "/login/sso"); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("GET /login/sso while locked out = %d, want 429", resp.StatusCode)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"embed"
 	"fmt"
 	"html/template"
+	"log"
+	"net/http"
 	"time"
 
 	"github.com/berkaycubuk/subtrack/internal/utils"
@@ -20,6 +22,37 @@ var funcMap = template.FuncMap{
 	"formatInputDate": func(t time.Time) string {
 		return utils.FormatDate(t)
 	},
+	"spendChart":    spendChart,
+	"categoryChart": categoryChart,
+}
+
+// render writes the page template name, filling in the CSRF token, the base
+// path and what the user may do.
+func (s *Server) render(w http.ResponseWriter, r *http.Request, name string, data pageData) {
+	s.renderStatus(w, r, http.StatusOK, name, data)
+}
+
+// renderStatus is render with a status other than 200. The status is written
+// last, after the CSRF cookie, so that it does not drop the cookie and a
+// failed render still answers with a 500.
+func (s *Server) renderStatus(w http.ResponseWriter, r *http.Request, code int, name string, data pageData) {
+	token, err := s.csrfToken(w, r)
+	if err != nil {
+		log.Printf("Error rendering %s: %v", name, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	data.CSRFToken = token
+	if sess := currentSession(r); sess != nil {
+		data.ReadOnly = sess.role != roleAdmin
+		data.LocalUser = !sess.sso
+	}
+	data.SSO = s.sso != nil
+	data.Base = s.basePath
+	if code != http.StatusOK {
+		w.WriteHeader(code)
+	}
+	parseTemplate(name).Execute(w, data)
 }
 
 func parseTemplate(name string) *template.Template {
@@ -0,0 +1,155 @@
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// certCheckInterval is how often the certificate files are checked for a
+// renewed certificate.
+const certCheckInterval = 30 * time.Second
+
+// TLSOptions make the server serve HTTPS itself.
+type TLSOptions struct {
+	// CertFile and KeyFile are PEM files, as written by certbot and most
+	// other ACME clients. A renewed certificate is picked up without a
+	// restart.
+	CertFile string
+	KeyFile  string
+	// SelfSigned serves a certificate generated at startup instead, for
+	// trying HTTPS locally. Browsers warn about it.
+	SelfSigned bool
+}
+
+// tlsConfig returns the TLS configuration for opts.
+func tlsConfig(opts *TLSOptions) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if opts.SelfSigned {
+		cert, err := selfSignedCert()
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{*cert}
+		return cfg, nil
+	}
+
+	certs := &certReloader{certFile: opts.CertFile, keyFile: opts.KeyFile}
+	if err := certs.load(); err != nil {
+		return nil, err
+	}
+	cfg.GetCertificate = certs.getCertificate
+	return cfg, nil
+}
+
+// certReloader serves a certificate from files, loading it again when the
+// files change.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+// load reads the certificate files if they changed since they were last
+// read.
+func (c *certReloader) load() error {
+	modTime, err := c.latestModTime()
+	if err != nil {
+		return err
+	}
+	if c.cert != nil && modTime.Equal(c.modTime) {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	if c.cert != nil {
+		log.Printf("Reloaded TLS certificate from %s", c.certFile)
+	}
+	c.cert, c.modTime = &cert, modTime
+	return nil
+}
+
+func (c *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to read TLS certificate: %w", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// getCertificate serves the current certificate, checking the files now
+// and then. While the files are being replaced they may not match, so a
+// certificate that fails to load keeps the old one in use until the next
+// check.
+func (c *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now := time.Now(); now.Sub(c.checked) >= certCheckInterval {
+		c.checked = now
+		if err := c.load(); err != nil {
+			log.Printf("Error reloading TLS certificate: %v", err)
+		}
+	}
+	return c.cert, nil
+}
+
+// selfSignedCert generates a certificate for localhost and this machine's
+// hostname, valid for a year.
+func selfSignedCert() (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate TLS key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate TLS certificate: %w", err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"SubTrack"}, CommonName: "localhost"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if hostname, err := os.Hostname(); err == nil && hostname != "localhost" {
+		template.DNSNames = append(template.DNSNames, hostname)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate TLS certificate: %w", err)
+	}
+	fingerprint := sha256.Sum256(der)
+	log.Printf("Generated a self-signed TLS certificate for %v, SHA-256 fingerprint %s", template.DNSNames, hex.EncodeToString(fingerprint[:]))
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
@@ -0,0 +1,128 @@
+package web
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a new self-signed certificate and its key to PEM files.
+func writeCert(t *testing.T, certFile, keyFile string, modTime time.Time) *tls.Certificate {
+	t.Helper()
+	cert, err := selfSignedCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: cert.Certificate[0]},
+		keyFile:  {Type: "PRIVATE KEY", Bytes: key},
+	} {
+		if err := os.WriteFile(name, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(name, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return cert
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	modTime := time.Now().Add(-time.Hour)
+	first := writeCert(t, certFile, keyFile, modTime)
+
+	certs := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := certs.load(); err != nil {
+		t.Fatal(err)
+	}
+	serves := func(want *tls.Certificate) bool {
+		t.Helper()
+		cert, err := certs.getCertificate(&tls.ClientHelloInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bytes.Equal(cert.Certificate[0], want.Certificate[0])
+	}
+	if !serves(first) {
+		t.Fatal("not serving the certificate from the files")
+	}
+
+	// A renewal is picked up at the next check, not before.
+	second := writeCert(t, certFile, keyFile, modTime.Add(time.Minute))
+	if !serves(first) {
+		t.Error("reloaded before the next check")
+	}
+	certs.checked = time.Now().Add(-certCheckInterval)
+	if !serves(second) {
+		t.Fatal("renewed certificate not served after the next check")
+	}
+
+	// A half-written renewal keeps the old certificate in use.
+	if err := os.WriteFile(keyFile, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(keyFile, modTime.Add(2*time.Minute), modTime.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	certs.checked = time.Now().Add(-certCheckInterval)
+	if !serves(second) {
+		t.Error("broken renewal replaced the certificate")
+	}
+}
+
+func TestTLSConfig_MissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	_, err := tlsConfig(&TLSOptions{CertFile: filepath.Join(dir, "cert.pem"), KeyFile: filepath.Join(dir, "key.pem")})
+	if err == nil {
+		t.Error("tlsConfig() with missing files succeeded")
+	}
+}
+
+func TestSelfSignedCert(t *testing.T) {
+	cfg, err := tlsConfig(&TLSOptions{SelfSigned: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Certificates) != 1 || cfg.MinVersion != tls.VersionTLS12 {
+		t.Fatalf("tlsConfig() = %d certificates, minimum version %x", len(cfg.Certificates), cfg.MinVersion)
+	}
+	cert, err := x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, host := range []string{"localhost", "127.0.0.1", "::1"} {
+		if err := cert.VerifyHostname(host); err != nil {
+			t.Errorf("certificate not valid for %s: %v", host, err)
+		}
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		if err := cert.VerifyHostname(hostname); err != nil {
+			t.Errorf("certificate not valid for the hostname: %v", err)
+		}
+	}
+	if now := time.Now(); now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		t.Errorf("certificate valid from %v to %v", cert.NotBefore, cert.NotAfter)
+	}
+	if cert.NotAfter.Before(time.Now().AddDate(0, 11, 0)) {
+		t.Errorf("certificate expires %v, want in a year", cert.NotAfter)
+	}
+
+	// It is usable by a client that trusts it.
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	if _, err := cert.Verify(x509.VerifyOptions{DNSName: "localhost", Roots: roots}); err != nil {
+		t.Errorf("Verify() = %v", err)
+	}
+}
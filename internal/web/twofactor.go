@@ -0,0 +1,268 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/berkaycubuk/subtrack/internal/services"
+)
+
+// pendingLoginCookie holds the token of a login that is waiting for its
+// two-factor code.
+const pendingLoginCookie = "pending_login"
+
+type securityPage struct {
+	Status *services.TwoFactorStatus
+	// Enrolment and its QR code are set while enrolment is pending.
+	Enrolment *services.Enrolment
+	QRCode    template.HTML
+	// RecoveryCodes are shown once, just after they are issued.
+	RecoveryCodes []string
+}
+
+// startPendingLogin asks a client that got the password right for its
+// two-factor code.
+func (s *Server) startPendingLogin(w http.ResponseWriter, r *http.Request, username string) {
+	token, err := s.sessions.createPendingLogin(username)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     pendingLoginCookie,
+		Value:    token,
+		Path:     s.path("/login"),
+		HttpOnly: true,
+		Secure:   s.secureCookies,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(pendingLoginTTL / time.Second),
+	})
+
+	http.Redirect(w, r, s.path("/login/verify"), http.StatusSeeOther)
+}
+
+// pendingLogin returns the pending login token and username of r.
+func (s *Server) pendingLogin(r *http.Request) (token, username string, ok bool) {
+	cookie, err := r.Cookie(pendingLoginCookie)
+	if err != nil {
+		return "", "", false
+	}
+	username, ok = s.sessions.pendingLogin(cookie.Value)
+	return cookie.Value, username, ok
+}
+
+func (s *Server) endPendingLogin(w http.ResponseWriter, token string) {
+	s.sessions.destroyPendingLogin(token)
+	http.SetCookie(w, &http.Cookie{
+		Name:     pendingLoginCookie,
+		Value:    "",
+		Path:     s.path("/login"),
+		HttpOnly: true,
+		Secure:   s.secureCookies,
+		MaxAge:   -1,
+	})
+}
+
+func (s *Server) handleVerifyForm(w http.ResponseWriter, r *http.Request) {
+	if _, _, ok := s.pendingLogin(r); !ok {
+		http.Redirect(w, r, s.path("/login"), http.StatusSeeOther)
+		return
+	}
+	s.render(w, r, "verify.html", pageData{Title: "Two-Factor Authentication"})
+}
+
+// handleVerify finishes a login with a code from the user's authenticator
+// app or a recovery code. Wrong codes count as failed logins, so guessing
+// codes locks the client out like guessing passwords does; a lockout also
+// ends the pending login, and the password must be entered again.
+func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	token, username, ok := s.pendingLogin(r)
+	if !ok {
+		http.Redirect(w, r, s.path("/login"), http.StatusSeeOther)
+		return
+	}
+
+	ip := s.clientIP(r)
+	keys := loginKeys(ip, username)
+	if wait := s.logins.wait(keys); wait > 0 {
+		s.endPendingLogin(w, token)
+		s.renderLockedOut(w, r, wait)
+		return
+	}
+
+	recovery, err := s.twoFactor.Verify(username, r.FormValue("code"))
+	switch {
+	case errors.Is(err, services.ErrInvalidCode):
+		s.audit.Record(services.AuditLoginFailed, username, ip, "invalid two-factor code")
+		if lockout := s.logins.fail(keys); lockout > 0 {
+			s.audit.Record(services.AuditLoginLocked, username, ip, "locked out for "+lockout.String())
+			s.endPendingLogin(w, token)
+			s.renderLockedOut(w, r, lockout)
+			return
+		}
+		s.render(w, r, "verify.html", pageData{Title: "Two-Factor Authentication", Error: "Invalid code"})
+		return
+	case errors.Is(err, services.ErrTwoFactorDisabled):
+		// Two-factor authentication was reset since the password was
+		// checked; start again.
+		s.endPendingLogin(w, token)
+		http.Redirect(w, r, s.path("/login"), http.StatusSeeOther)
+		return
+	case err != nil:
+		log.Printf("Error verifying two-factor code: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	s.endPendingLogin(w, token)
+	s.logins.succeed(keys)
+	detail := "two-factor code"
+	if recovery {
+		detail = "recovery code"
+		if status, err := s.twoFactor.Status(username); err == nil {
+			detail = fmt.Sprintf("recovery code, %d left", status.RecoveryCodesLeft)
+		}
+	}
+	s.audit.Record(services.AuditLoginSucceeded, username, ip, detail)
+	s.startSession(w, r, session{username: username, role: roleAdmin})
+}
+
+func (s *Server) handleSecurity(w http.ResponseWriter, r *http.Request) {
+	s.renderSecurity(w, r, http.StatusOK, "", nil)
+}
+
+// renderSecurity renders the security page with an optional error and the
+// recovery codes that were just issued.
+func (s *Server) renderSecurity(w http.ResponseWriter, r *http.Request, code int, errMsg string, recoveryCodes []string) {
+	status, err := s.twoFactor.Status(s.username)
+	if err != nil {
+		log.Printf("Error loading two-factor status: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	page := securityPage{Status: status, RecoveryCodes: recoveryCodes}
+	if status.Pending {
+		page.Enrolment, err = s.twoFactor.Pending(s.username)
+		if err == nil {
+			page.QRCode, err = qrCode(page.Enrolment.URI)
+		}
+		if err != nil {
+			log.Printf("Error loading two-factor enrolment: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	s.renderStatus(w, r, code, "security.html", pageData{Title: "Security", Error: errMsg, Data: &page})
+}
+
+// handleTwoFactorBegin starts enrolment, replacing one that was never
+// confirmed.
+func (s *Server) handleTwoFactorBegin(w http.ResponseWriter, r *http.Request) {
+	_, err := s.twoFactor.Begin(s.username)
+	if errors.Is(err, services.ErrTwoFactorEnabled) {
+		s.renderSecurity(w, r, http.StatusOK, err.Error(), nil)
+		return
+	}
+	if err != nil {
+		log.Printf("Error starting two-factor enrolment: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, s.path("/security"), http.StatusSeeOther)
+}
+
+func (s *Server) handleTwoFactorConfirm(w http.ResponseWriter, r *http.Request) {
+	codes, err := s.twoFactor.Confirm(s.username, r.FormValue("code"))
+	switch {
+	case errors.Is(err, services.ErrInvalidCode):
+		s.renderSecurity(w, r, http.StatusOK, "Invalid code. Check that your device's clock is correct and try the next code.", nil)
+		return
+	case errors.Is(err, services.ErrTwoFactorDisabled), errors.Is(err, services.ErrTwoFactorEnabled):
+		s.renderSecurity(w, r, http.StatusOK, err.Error(), nil)
+		return
+	case err != nil:
+		log.Printf("Error confirming two-factor enrolment: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	s.audit.Record(services.AuditTwoFactorEnabled, s.username, s.clientIP(r), "")
+	s.renderSecurity(w, r, http.StatusOK, "", codes)
+}
+
+// handleTwoFactorDisable turns two-factor authentication off, which takes
+// a current code, or cancels an enrolment that was not confirmed.
+func (s *Server) handleTwoFactorDisable(w http.ResponseWriter, r *http.Request) {
+	status, err := s.twoFactor.Status(s.username)
+	if err != nil {
+		log.Printf("Error loading two-factor status: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if status.Enabled && !s.checkCode(w, r) {
+		return
+	}
+
+	if _, err := s.twoFactor.Disable(s.username); err != nil {
+		log.Printf("Error disabling two-factor authentication: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if status.Enabled {
+		s.audit.Record(services.AuditTwoFactorDisabled, s.username, s.clientIP(r), "")
+	}
+	http.Redirect(w, r, s.path("/security"), http.StatusSeeOther)
+}
+
+func (s *Server) handleRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	if !s.checkCode(w, r) {
+		return
+	}
+	codes, err := s.twoFactor.RegenerateRecoveryCodes(s.username)
+	if err != nil {
+		log.Printf("Error renewing recovery codes: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	s.audit.Record(services.AuditRecoveryCodesRenewed, s.username, s.clientIP(r), "")
+	s.renderSecurity(w, r, http.StatusOK, "", codes)
+}
+
+// checkCode checks the two-factor code posted to change two-factor
+// settings, and answers the request itself when it is wrong. Wrong codes
+// count against the login throttle, so that someone with a stolen session
+// cannot guess their way to turning two-factor authentication off.
+func (s *Server) checkCode(w http.ResponseWriter, r *http.Request) bool {
+	ip := s.clientIP(r)
+	keys := loginKeys(ip, s.username)
+	if wait := s.logins.wait(keys); wait > 0 {
+		setRetryAfter(w, wait)
+		s.renderSecurity(w, r, http.StatusTooManyRequests, "Too many failed attempts. Try again in "+wait.Round(time.Second).String()+".", nil)
+		return false
+	}
+
+	_, err := s.twoFactor.Verify(s.username, r.FormValue("code"))
+	switch {
+	case errors.Is(err, services.ErrInvalidCode):
+		if lockout := s.logins.fail(keys); lockout > 0 {
+			s.audit.Record(services.AuditLoginLocked, s.username, ip, "locked out for "+lockout.String())
+		}
+		s.renderSecurity(w, r, http.StatusOK, "Invalid code", nil)
+		return false
+	case errors.Is(err, services.ErrTwoFactorDisabled):
+		s.renderSecurity(w, r, http.StatusOK, err.Error(), nil)
+		return false
+	case err != nil:
+		log.Printf("Error verifying two-factor code: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}